@@ -0,0 +1,163 @@
+package main
+
+import (
+	"example/hello/internal"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// envPrefix 플래그 이름 앞에 붙여 환경 변수 이름을 만듭니다. (-chunk-size → RTMP_CHUNK_SIZE)
+const envPrefix = "RTMP_"
+
+// override 설정 파일 값을 덮어쓰는 명령줄 플래그입니다. 같은 값을 환경 변수로도 줄 수 있습니다.
+type override struct {
+	name  string
+	usage string
+	apply func(cfg *internal.Config, value string) error
+}
+
+var overrides = []override{
+	{"listen", "RTMP listen addresses, comma separated (e.g. :1935,:1936)", func(cfg *internal.Config, v string) error {
+		cfg.Listen = splitList(v)
+		return nil
+	}},
+	{"tls-listen", "RTMPS listen addresses, comma separated (requires tls.certificates in the config file)", func(cfg *internal.Config, v string) error {
+		cfg.TLS.Listen = splitList(v)
+		return nil
+	}},
+	{"http", "HTTP listen address for HTTP-FLV, WebSocket-FLV and RTMPT (empty disables it)", func(cfg *internal.Config, v string) error {
+		cfg.HTTPListen = v
+		return nil
+	}},
+	{"admin-listen", "listen address for the admin API and /metrics (empty disables it)", func(cfg *internal.Config, v string) error {
+		cfg.Admin.Listen = v
+		return nil
+	}},
+	{"hls-dir", "output directory for ffmpeg HLS previews", func(cfg *internal.Config, v string) error {
+		cfg.Paths.HLS = v
+		return nil
+	}},
+	{"cmaf-dir", "output directory for CMAF segments and manifests", func(cfg *internal.Config, v string) error {
+		cfg.Paths.CMAF = v
+		return nil
+	}},
+	{"record-dir", "output directory for recordings", func(cfg *internal.Config, v string) error {
+		cfg.Paths.Record = v
+		return nil
+	}},
+	{"vod-dir", "directory of VOD files", func(cfg *internal.Config, v string) error {
+		cfg.Paths.VOD = v
+		return nil
+	}},
+	{"chunk-size", "outgoing RTMP chunk size", func(cfg *internal.Config, v string) error {
+		return parseUint32(v, &cfg.RTMP.ChunkSize)
+	}},
+	{"window-ack-size", "window acknowledgement size sent to clients", func(cfg *internal.Config, v string) error {
+		return parseUint32(v, &cfg.RTMP.WindowAckSize)
+	}},
+	{"peer-bandwidth", "peer bandwidth sent to clients", func(cfg *internal.Config, v string) error {
+		return parseUint32(v, &cfg.RTMP.PeerBandwidth)
+	}},
+	{"max-connections", "maximum concurrent RTMP connections (0 = unlimited)", func(cfg *internal.Config, v string) (err error) {
+		cfg.Limits.MaxConnections, err = strconv.Atoi(v)
+		return
+	}},
+	{"proxy-protocol", "read PROXY protocol headers from trusted load balancers (true/false)", func(cfg *internal.Config, v string) (err error) {
+		cfg.ProxyProtocol.Enabled, err = strconv.ParseBool(v)
+		return
+	}},
+	{"shutdown-timeout", "how long to wait for recordings to finish on shutdown", func(cfg *internal.Config, v string) (err error) {
+		cfg.ShutdownTimeout, err = time.ParseDuration(v)
+		return
+	}},
+}
+
+// loadConfig 설정 파일을 읽고 환경 변수, 명령줄 플래그 순서로 덮어쓴 뒤 검사합니다.
+// checkOnly는 -check-config가 주어졌는지 여부입니다.
+func loadConfig(args []string) (cfg *internal.Config, checkOnly bool, err error) {
+	fs := flag.NewFlagSet("server", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to the YAML config file (env "+envPrefix+"CONFIG)")
+	fs.BoolVar(&checkOnly, "check-config", false, "validate the configuration and exit")
+	values := make(map[string]*string, len(overrides))
+	for _, o := range overrides {
+		values[o.name] = fs.String(o.name, "", o.usage+" (env "+envName(o.name)+")")
+	}
+	fs.Parse(args)
+
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	path := *configPath
+	if !set["config"] {
+		path = os.Getenv(envPrefix + "CONFIG")
+	}
+	if path == "" {
+		cfg = internal.DefaultConfig()
+	} else if cfg, err = internal.LoadConfig(path); err != nil {
+		return nil, checkOnly, err
+	}
+
+	for _, o := range overrides {
+		source, value := "-"+o.name, *values[o.name]
+		if !set[o.name] {
+			source = envName(o.name)
+			v, ok := os.LookupEnv(source)
+			if !ok {
+				continue
+			}
+			value = v
+		}
+		if err := o.apply(cfg, value); err != nil {
+			return nil, checkOnly, fmt.Errorf("%s: invalid value %q", source, value)
+		}
+	}
+	return cfg, checkOnly, cfg.Validate()
+}
+
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+func splitList(s string) []string {
+	var res []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			res = append(res, item)
+		}
+	}
+	return res
+}
+
+func parseUint32(s string, dst *uint32) error {
+	v, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return err
+	}
+	*dst = uint32(v)
+	return nil
+}
+
+// printConfig -check-config 결과로 적용될 주요 설정을 출력합니다.
+func printConfig(cfg *internal.Config) {
+	fmt.Printf("listen: %s\n", strings.Join(cfg.Listen, ", "))
+	if len(cfg.TLS.Listen) > 0 {
+		fmt.Printf("tls.listen: %s (%d certificates)\n", strings.Join(cfg.TLS.Listen, ", "), len(cfg.TLS.Certificates))
+	}
+	fmt.Printf("http_listen: %s\n", cfg.HTTPListen)
+	fmt.Printf("admin: listen=%s token=%t access=%d rules\n", cfg.Admin.Listen, cfg.Admin.Token != "", len(cfg.Admin.Access))
+	fmt.Printf("rtmp: chunk_size=%d window_ack_size=%d peer_bandwidth=%d\n", cfg.RTMP.ChunkSize, cfg.RTMP.WindowAckSize, cfg.RTMP.PeerBandwidth)
+	fmt.Printf("limits: max_connections=%d max_connections_per_ip=%d max_publishers_per_app=%d max_viewers_per_stream=%d max_outgoing_bandwidth=%d\n",
+		cfg.Limits.MaxConnections, cfg.Limits.MaxConnectionsPerIP, cfg.Limits.MaxPublishersPerApp, cfg.Limits.MaxViewersPerStream, cfg.Limits.MaxOutgoingBandwidth)
+	fmt.Printf("timeouts: handshake=%s idle=%s publish_idle=%s write=%s\n", cfg.Timeouts.Handshake, cfg.Timeouts.Idle, cfg.Timeouts.PublishIdle, cfg.Timeouts.Write)
+	names := make([]string, 0, len(cfg.Apps))
+	for name := range cfg.Apps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Printf("apps: %s\n", strings.Join(names, ", "))
+}
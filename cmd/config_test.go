@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestConfig(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	path := writeTestConfig(t, "rtmp:\n  chunk_size: 1000\n  window_ack_size: 2000\n  peer_bandwidth: 3000\nhttp_listen: \":8081\"\n")
+	// 명령줄 플래그 > 환경 변수 > 설정 파일 > 기본값 순서입니다.
+	t.Setenv("RTMP_CONFIG", path)
+	t.Setenv("RTMP_WINDOW_ACK_SIZE", "2100")
+	t.Setenv("RTMP_PEER_BANDWIDTH", "3100")
+
+	cfg, checkOnly, err := loadConfig([]string{"-peer-bandwidth", "3200", "-check-config"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !checkOnly {
+		t.Error("-check-config was not reported")
+	}
+	if cfg.RTMP.ChunkSize != 1000 || cfg.RTMP.WindowAckSize != 2100 || cfg.RTMP.PeerBandwidth != 3200 {
+		t.Errorf("chunk_size %d (want 1000 from the file), window_ack_size %d (want 2100 from env), peer_bandwidth %d (want 3200 from the flag)",
+			cfg.RTMP.ChunkSize, cfg.RTMP.WindowAckSize, cfg.RTMP.PeerBandwidth)
+	}
+	if cfg.HTTPListen != ":8081" || len(cfg.Listen) != 1 || cfg.Listen[0] != ":1935" {
+		t.Errorf("http_listen %q, listen %q", cfg.HTTPListen, cfg.Listen)
+	}
+
+	// 빈 플래그 값도 환경 변수보다 우선합니다.
+	t.Setenv("RTMP_HTTP", ":8082")
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-http", ""}, ""},
+		{nil, ":8082"},
+	} {
+		if cfg, _, err = loadConfig(tt.args); err != nil {
+			t.Errorf("%q: %s", tt.args, err)
+		} else if cfg.HTTPListen != tt.want {
+			t.Errorf("%q: http_listen %q, want %q", tt.args, cfg.HTTPListen, tt.want)
+		}
+	}
+
+	// -config가 RTMP_CONFIG보다 우선합니다.
+	other := writeTestConfig(t, "rtmp:\n  chunk_size: 1500\n")
+	if cfg, _, err = loadConfig([]string{"-config", other}); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.RTMP.ChunkSize != 1500 {
+		t.Errorf("-config: chunk_size %d, want 1500", cfg.RTMP.ChunkSize)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	path := writeTestConfig(t, "rtmp:\n  chunk_sise: 1000\n")
+	if _, _, err := loadConfig([]string{"-config", path}); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("unknown field in the config file: %v", err)
+	}
+
+	t.Setenv("RTMP_CHUNK_SIZE", "big")
+	if _, _, err := loadConfig(nil); err == nil || !strings.Contains(err.Error(), `RTMP_CHUNK_SIZE: invalid value "big"`) {
+		t.Errorf("invalid env value: %v", err)
+	}
+	if _, _, err := loadConfig([]string{"-chunk-size", "-1"}); err == nil || !strings.Contains(err.Error(), `-chunk-size: invalid value "-1"`) {
+		t.Errorf("invalid flag value: %v", err)
+	}
+}
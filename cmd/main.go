@@ -1,46 +1,168 @@
 package main
 
 import (
+	"context"
 	"example/hello/internal"
+	"fmt"
 	"log"
 	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 func main() {
-	InitServer()
+	if len(os.Args) > 1 && os.Args[1] == "token" {
+		if err := runTokenCommand(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	cfg, checkOnly, err := loadConfig(os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration:\n%s\n", err.Error())
+		os.Exit(1)
+	}
+	if checkOnly {
+		printConfig(cfg)
+		fmt.Println("Configuration OK")
+		return
+	}
+	InitServer(cfg)
 }
 
 // InitServer RTMP 서버를 초기화하고 시작하는 함수입니다.
-// RTMP 서버는 TCP 포트 1935에서 리스닝을 시작합니다.
-func InitServer() {
-	listener, err := net.Listen("tcp", ":1935")
-	if err != nil {
-		log.Printf("Error starting RTMP server %s", err.Error())
-		panic(err)
+// RTMP 서버는 설정의 listen 주소(기본 TCP 포트 1935)에서, RTMPS 서버는 tls.listen 주소에서 리스닝을 시작합니다.
+func InitServer(cfg *internal.Config) {
+	listeners := make([]net.Listener, 0, len(cfg.Listen))
+	for _, addr := range cfg.Listen {
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			log.Printf("Error starting RTMP server %s", err.Error())
+			panic(err)
+		}
+		log.Printf("RTMP Server started at %s", addr)
+		listeners = append(listeners, listener)
+	}
+	tlsListeners := make([]net.Listener, 0, len(cfg.TLS.Listen))
+	var certs *internal.CertStore
+	if len(cfg.TLS.Listen) > 0 {
+		var err error
+		if certs, err = internal.NewCertStore(cfg.TLS.Certificates); err != nil {
+			log.Printf("Error loading RTMPS certificates %s", err.Error())
+			panic(err)
+		}
+		go certs.Watch(context.Background(), cfg.TLS.ReloadInterval)
+	}
+	for _, addr := range cfg.TLS.Listen {
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			log.Printf("Error starting RTMPS server %s", err.Error())
+			panic(err)
+		}
+		log.Printf("RTMPS Server started at %s", addr)
+		tlsListeners = append(tlsListeners, listener)
 	}
-	log.Println("RTMP Server started at port 1935")
 
-	ctx := initStreamContext()
+	ctx := initStreamContext(cfg)
+	server := internal.NewServer(ctx)
+	var httpServer *http.Server
+	if cfg.HTTPListen != "" {
+		httpServer = internal.NewHTTPServer(ctx)
+		go internal.ListenAndServeHTTP(httpServer)
+	}
+	var adminServer *http.Server
+	if cfg.Admin.Listen != "" {
+		adminServer = internal.NewAdminServer(ctx)
+		go internal.ListenAndServeHTTP(adminServer)
+	}
 
 	go internal.InitPreviewServer(ctx)
 
-	for {
-		conn, err := listener.Accept()
-		if err != nil {
-			log.Printf("Unable to accept connection %s", err.Error())
+	go watchReloadSignal(ctx)
+
+	shutdownDone := make(chan struct{})
+	go waitForSignal(server, []*http.Server{httpServer, adminServer}, cfg.ShutdownTimeout, shutdownDone)
+
+	if cfg.RTMPT.Enabled && httpServer != nil {
+		log.Printf("RTMPT enabled on %s", cfg.HTTPListen)
+		listeners = append(listeners, ctx.ListenRTMPT(cfg.RTMPT))
+	}
+
+	errc := make(chan error, len(listeners)+len(tlsListeners))
+	for _, listener := range listeners {
+		go func(l net.Listener) {
+			errc <- server.Serve(l)
+		}(listener)
+	}
+	for _, listener := range tlsListeners {
+		go func(l net.Listener) {
+			errc <- server.ServeTLS(l, certs.TLSConfig())
+		}(listener)
+	}
+	if err := <-errc; err != internal.ErrServerClosed {
+		log.Printf("RTMP server stopped %s", err.Error())
+		return
+	}
+	<-shutdownDone
+}
+
+// waitForSignal SIGINT, SIGTERM을 받으면 RTMP 서버, HTTP 서버들(nil은 건너뜀)을 차례로 정상 종료합니다.
+// timeout이 지나도록 녹화, 플레이리스트가 마무리되지 않으면 남은 연결을 강제로 끊습니다.
+// 종료 중에 신호를 한 번 더 받으면 기다리지 않고 바로 끝냅니다.
+func waitForSignal(server *internal.Server, httpServers []*http.Server, timeout time.Duration, done chan struct{}) {
+	sig := make(chan os.Signal, 2)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	s := <-sig
+	log.Printf("Received %s, shutting down gracefully (send again to force)", s)
+	go func() {
+		<-sig
+		log.Println("Forced shutdown")
+		os.Exit(1)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	// RTMP 서버를 먼저 닫아야 허브가 닫히면서 HTTP-FLV 응답도 끝납니다.
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("RTMP server shutdown: %s", err.Error())
+	}
+	for _, httpServer := range httpServers {
+		if httpServer == nil {
 			continue
 		}
-		connection := internal.NewConnection(conn, ctx)
+		if err := httpServer.Shutdown(ctx); err != nil {
+			log.Printf("HTTP server shutdown: %s", err.Error())
+			httpServer.Close()
+		}
+	}
+	close(done)
+}
 
-		go connection.Serve()
+// watchReloadSignal SIGHUP을 받을 때마다 설정 파일을 다시 읽어 적용합니다. 설정이 잘못되었으면 기존 설정을 유지합니다.
+func watchReloadSignal(ctx *internal.StreamContext) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP)
+	for range sig {
+		log.Println("Received SIGHUP, reloading configuration")
+		if _, err := ctx.ReloadConfig(); err != nil {
+			log.Printf("Config reload failed, keeping the current configuration: %s", err.Error())
+		}
 	}
 }
 
-// initStreamContext 스트리밍에 필요한 전역 상태를 관리하는 컨텍스트를 초기화합니다.
-// 세션 관리를 위한 map, 미리보기 채널을 초기화합니다.
-func initStreamContext() (ctx *internal.StreamContext) {
-	ctx = &internal.StreamContext{}
-	ctx.Sessions = make(map[string]*internal.Connection)
+// initStreamContext 스트리밍에 필요한 전역 상태를 관리하는 컨텍스트를 설정으로 초기화합니다.
+// 세션 관리를 위한 map, app 설정, 미리보기 채널을 초기화하고, 시작할 때와 같은 파일, 환경 변수, 플래그로 설정을 다시 읽도록 합니다.
+func initStreamContext(cfg *internal.Config) (ctx *internal.StreamContext) {
+	ctx = internal.NewStreamContext(cfg)
 	ctx.Preview = make(chan string)
+	ctx.ConfigSource = func() (*internal.Config, error) {
+		cfg, _, err := loadConfig(os.Args[1:])
+		return cfg, err
+	}
 	return
 }
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"example/hello/internal"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+// runTokenCommand "token" 하위 명령입니다. 퍼블리시, 재생 토큰을 만들어 스트림 이름 뒤에 붙일 형태로 출력합니다.
+//
+//	server token -config config.yaml -app live -stream camera1 -action publish -ttl 24h
+//	camera1?exp=1767225600&sig=...
+func runTokenCommand(args []string) error {
+	fs := flag.NewFlagSet("token", flag.ExitOnError)
+	configPath := fs.String("config", "", "config file to read the app's first token secret from (env "+envPrefix+"CONFIG)")
+	secret := fs.String("secret", "", "signing secret (env "+envPrefix+"TOKEN_SECRET), overrides -config")
+	app := fs.String("app", "live", "app name")
+	stream := fs.String("stream", "", "stream name (required)")
+	action := fs.String("action", internal.TokenActionPublish, "publish or play")
+	ttl := fs.Duration("ttl", time.Hour, "how long the token is valid")
+	ip := fs.String("ip", "", "bind the token to this client IP")
+	fs.Parse(args)
+
+	if *stream == "" {
+		return errors.New("-stream is required")
+	}
+	if *action != internal.TokenActionPublish && *action != internal.TokenActionPlay {
+		return fmt.Errorf("-action must be %s or %s", internal.TokenActionPublish, internal.TokenActionPlay)
+	}
+	if *secret == "" {
+		*secret = os.Getenv(envPrefix + "TOKEN_SECRET")
+	}
+	if *secret == "" {
+		path := *configPath
+		if path == "" {
+			path = os.Getenv(envPrefix + "CONFIG")
+		}
+		if path == "" {
+			return errors.New("either -secret or -config is required")
+		}
+		cfg, err := internal.LoadConfig(path)
+		if err != nil {
+			return err
+		}
+		conf, ok := cfg.Apps[*app]
+		if !ok || len(conf.Auth.Token.Secrets) == 0 {
+			return fmt.Errorf("app %q has no token secrets in %s", *app, path)
+		}
+		*secret = conf.Auth.Token.Secrets[0]
+	}
+
+	fmt.Printf("%s?%s\n", *stream, internal.TokenQuery(*secret, *action, *app, *stream, time.Now().Add(*ttl), *ip))
+	return nil
+}
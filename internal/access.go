@@ -0,0 +1,200 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// 접근 규칙을 검사하는 시점입니다. 메트릭의 action 라벨로도 사용합니다.
+const (
+	AccessActionAccept  = "accept"  // TCP 연결을 받은 직후 (핸드셰이크 전)
+	AccessActionConnect = "connect" // connect 명령 (app이 정해진 뒤)
+	AccessActionPublish = "publish"
+	AccessActionPlay    = "play"
+)
+
+// errAccessDenied 접근 규칙에 의해 거절되었습니다.
+var errAccessDenied = errors.New("access denied")
+
+// AccessConfig 클라이언트 주소에 따른 접근 규칙입니다. 규칙은 "allow <주소>" 또는 "deny <주소>" 형식이며,
+// 주소는 CIDR(10.0.0.0/8, 2001:db8::/32), 단일 IP 또는 all 입니다.
+// 위에서부터 처음 일치하는 규칙을 따르고, 일치하는 규칙이 없으면 허용합니다. (nginx allow/deny와 같음)
+//
+//	access:
+//	  publish: ["allow 192.168.10.0/24", "deny all"]
+//	  play: ["allow 203.0.113.0/24", "allow 2001:db8::/32", "deny all"]
+//
+// 전역 access.accept는 핸드셰이크 전에, connect, publish, play는 각 명령에서 검사합니다.
+// app에 규칙이 없는 동작은 전역 규칙을 사용합니다.
+type AccessConfig struct {
+	Accept  []string `yaml:"accept"` // 전역 설정에서만 사용합니다.
+	Connect []string `yaml:"connect"`
+	Publish []string `yaml:"publish"`
+	Play    []string `yaml:"play"`
+
+	// parsed validate에서 해석한 동작별 규칙입니다. 요청마다 규칙을 다시 해석하지 않습니다.
+	parsed map[string][]accessRule
+}
+
+// accessRule 해석한 규칙 하나입니다. network가 nil이면 모든 주소에 일치합니다.
+type accessRule struct {
+	allow   bool
+	network *net.IPNet
+}
+
+func parseAccessRule(s string) (accessRule, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 2 || (fields[0] != "allow" && fields[0] != "deny") {
+		return accessRule{}, fmt.Errorf("%q: expected \"allow <cidr|ip|all>\" or \"deny <cidr|ip|all>\"", s)
+	}
+	rule := accessRule{allow: fields[0] == "allow"}
+	if fields[1] == "all" {
+		return rule, nil
+	}
+	network, err := parseNetwork(fields[1])
+	if err != nil {
+		return accessRule{}, fmt.Errorf("%q: %w", s, err)
+	}
+	rule.network = network
+	return rule, nil
+}
+
+// parseAccessRules 규칙 목록을 해석합니다. 잘못된 규칙이 있으면 에러를 반환합니다.
+func parseAccessRules(rules []string) ([]accessRule, error) {
+	var parsed []accessRule
+	for _, s := range rules {
+		rule, err := parseAccessRule(s)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, rule)
+	}
+	return parsed, nil
+}
+
+// lenientAccessRules validate를 거치지 않은 규칙 목록을 해석합니다. 잘못된 규칙은 건너뜁니다.
+func lenientAccessRules(rules []string) []accessRule {
+	var parsed []accessRule
+	for _, s := range rules {
+		if rule, err := parseAccessRule(s); err == nil {
+			parsed = append(parsed, rule)
+		}
+	}
+	return parsed
+}
+
+// parseNetwork CIDR 또는 단일 IP(/32, /128로 취급)를 해석합니다.
+func parseNetwork(addr string) (*net.IPNet, error) {
+	if !strings.Contains(addr, "/") {
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address %q", addr)
+		}
+		bits := 128
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 32
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, network, err := net.ParseCIDR(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CIDR %q", addr)
+	}
+	return network, nil
+}
+
+// accessAllowed rules를 순서대로 검사합니다. 주소를 해석할 수 없으면 all 규칙에만 일치합니다.
+func accessAllowed(rules []accessRule, addr string) bool {
+	ip := net.ParseIP(addr)
+	for _, rule := range rules {
+		if rule.network == nil || (ip != nil && rule.network.Contains(ip)) {
+			return rule.allow
+		}
+	}
+	return true
+}
+
+func (conf *AccessConfig) rules(action string) []string {
+	switch action {
+	case AccessActionAccept:
+		return conf.Accept
+	case AccessActionConnect:
+		return conf.Connect
+	case AccessActionPublish:
+		return conf.Publish
+	case AccessActionPlay:
+		return conf.Play
+	}
+	return nil
+}
+
+// accessRules action의 해석한 규칙입니다. validate를 거치지 않은 설정이면 여기서 해석합니다.
+func (conf *AccessConfig) accessRules(action string) []accessRule {
+	if rules, ok := conf.parsed[action]; ok {
+		return rules
+	}
+	return lenientAccessRules(conf.rules(action))
+}
+
+// validate 모든 규칙의 형식을 확인하고 해석한 규칙을 저장합니다. global이 아니면 accept 규칙을 쓸 수 없습니다.
+func (conf *AccessConfig) validate(global bool) error {
+	if !global && len(conf.Accept) > 0 {
+		return errors.New("accept: only allowed in the global access section")
+	}
+	parsed := make(map[string][]accessRule)
+	for _, action := range []string{AccessActionAccept, AccessActionConnect, AccessActionPublish, AccessActionPlay} {
+		rules, err := parseAccessRules(conf.rules(action))
+		if err != nil {
+			return fmt.Errorf("%s: %w", action, err)
+		}
+		parsed[action] = rules
+	}
+	conf.parsed = parsed
+	return nil
+}
+
+// checkAccess app의 action 규칙으로 addr을 검사합니다. app에 규칙이 없으면 전역 규칙을 사용합니다.
+// 거절하면 로그를 남기고 rtmp_access_denied_total을 늘립니다.
+func (ctx *StreamContext) checkAccess(app, action, addr string) error {
+	rules := ctx.app(app).Access.accessRules(action)
+	if len(rules) == 0 {
+		ctx.mu.RLock()
+		rules = ctx.Access.accessRules(action)
+		ctx.mu.RUnlock()
+	}
+	if accessAllowed(rules, addr) {
+		return nil
+	}
+	if app == "" {
+		log.Printf("Access denied for %s (%s)", addr, action)
+	} else {
+		log.Printf("Access denied for %s (%s %s)", addr, action, app)
+	}
+	ctx.Metrics.Inc("rtmp_access_denied_total", "action", action)
+	return errAccessDenied
+}
+
+// hostIP "host:port" 주소에서 IP만 꺼냅니다.
+func hostIP(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+// httpClientIP HTTP-FLV, WebSocket-FLV 요청의 클라이언트 IP입니다.
+func httpClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
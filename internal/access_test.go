@@ -0,0 +1,131 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAccessAllowed(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []string
+		addr  string
+		want  bool
+	}{
+		{"no rules", nil, "192.0.2.1", true},
+		{"no match", []string{"deny 10.0.0.0/8"}, "192.0.2.1", true},
+		{"first match allows", []string{"allow 192.0.2.0/24", "deny all"}, "192.0.2.1", true},
+		{"first match denies", []string{"deny 192.0.2.1", "allow 192.0.2.0/24"}, "192.0.2.1", false},
+		{"falls through to all", []string{"allow 192.0.2.0/24", "deny all"}, "198.51.100.1", false},
+		{"allow all", []string{"allow all", "deny 192.0.2.1"}, "192.0.2.1", true},
+		{"bare IP is a single host", []string{"deny 192.0.2.1"}, "192.0.2.2", true},
+		{"bare IP matches", []string{"deny 192.0.2.1"}, "192.0.2.1", false},
+		{"CIDR boundary", []string{"deny 192.0.2.0/25"}, "192.0.2.128", true},
+		{"IPv6 CIDR", []string{"allow 2001:db8::/32", "deny all"}, "2001:db8:1::5", true},
+		{"IPv6 outside CIDR", []string{"allow 2001:db8::/32", "deny all"}, "2001:db9::1", false},
+		{"bare IPv6", []string{"deny 2001:db8::1"}, "2001:db8::1", false},
+		{"IPv4 rule and IPv6 address", []string{"deny 192.0.2.0/24"}, "2001:db8::1", true},
+		{"IPv4-mapped IPv6 address", []string{"deny 192.0.2.0/24"}, "::ffff:192.0.2.1", false},
+		{"unparsable address matches only all", []string{"deny 0.0.0.0/0", "allow all"}, "pipe", true},
+		{"unparsable address denied by all", []string{"deny all"}, "pipe", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := parseAccessRules(tt.rules)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := accessAllowed(rules, tt.addr); got != tt.want {
+				t.Errorf("%q for %s: %v, want %v", tt.rules, tt.addr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAccessConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		conf   AccessConfig
+		global bool
+		err    string
+	}{
+		{"valid", AccessConfig{Accept: []string{"deny 10.0.0.0/8"}, Play: []string{"allow ::1", "deny all"}}, true, ""},
+		{"unknown verb", AccessConfig{Play: []string{"block all"}}, true, `play: "block all"`},
+		{"missing address", AccessConfig{Publish: []string{"allow"}}, true, `publish: "allow"`},
+		{"bad CIDR", AccessConfig{Connect: []string{"allow 10.0.0.0/33"}}, true, "invalid CIDR"},
+		{"bad IP", AccessConfig{Play: []string{"deny 10.0.0"}}, true, "invalid IP address"},
+		{"accept in an app", AccessConfig{Accept: []string{"deny all"}}, false, "only allowed in the global access section"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.conf.validate(tt.global)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(tt.conf.parsed[AccessActionPlay]) != len(tt.conf.Play) {
+					t.Errorf("parsed play rules %v", tt.conf.parsed[AccessActionPlay])
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestCheckAccess(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Access = AccessConfig{
+		Accept:  []string{"deny 203.0.113.0/24"},
+		Connect: []string{"allow 10.0.0.0/8", "deny all"},
+		Play:    []string{"deny all"},
+	}
+	cfg.Apps = map[string]*AppConfig{
+		"open":  {Name: "open", Access: AccessConfig{Play: []string{"allow all"}}},
+		"plain": {Name: "plain"},
+		"*":     {Name: "*", Access: AccessConfig{Publish: []string{"deny 10.0.0.1"}}},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	ctx := NewStreamContext(cfg)
+
+	tests := []struct {
+		app, action, addr string
+		allowed           bool
+	}{
+		{"", AccessActionAccept, "203.0.113.5", false},
+		{"", AccessActionAccept, "10.0.0.1", true},
+		// app에 규칙이 있는 동작은 app 규칙을 따릅니다.
+		{"open", AccessActionPlay, "192.0.2.1", true},
+		// app에 규칙이 없는 동작은 전역 규칙을 따릅니다.
+		{"plain", AccessActionPlay, "192.0.2.1", false},
+		{"open", AccessActionConnect, "10.1.1.1", true},
+		{"open", AccessActionConnect, "192.0.2.1", false},
+		{"plain", AccessActionPublish, "192.0.2.1", true},
+		// 와일드카드 app의 규칙은 설정에 없는 app에 적용됩니다.
+		{"other", AccessActionPublish, "10.0.0.1", false},
+		{"other", AccessActionPublish, "10.0.0.2", true},
+		{"other", AccessActionPlay, "10.0.0.2", false},
+	}
+	for _, tt := range tests {
+		err := ctx.checkAccess(tt.app, tt.action, tt.addr)
+		if (err == nil) != tt.allowed {
+			t.Errorf("%s %s from %s: %v, want allowed %v", tt.action, tt.app, tt.addr, err, tt.allowed)
+		}
+	}
+	if got := ctx.Metrics.Value("rtmp_access_denied_total", "action", AccessActionPlay); got != 2 {
+		t.Errorf("rtmp_access_denied_total{action=play} = %d, want 2", got)
+	}
+
+	// validate를 거치지 않은 설정도 같은 규칙으로 검사합니다.
+	ctx.Access = AccessConfig{Play: []string{"allow 192.0.2.1", "deny all"}}
+	if err := ctx.checkAccess("plain", AccessActionPlay, "192.0.2.1"); err != nil {
+		t.Errorf("unvalidated rules: %v", err)
+	}
+	if err := ctx.checkAccess("plain", AccessActionPlay, "192.0.2.2"); err == nil {
+		t.Error("unvalidated rules allowed 192.0.2.2")
+	}
+}
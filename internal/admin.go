@@ -0,0 +1,106 @@
+package internal
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// AccessActionAdmin 관리 API 요청입니다. rtmp_access_denied_total의 action 라벨로 사용합니다.
+const AccessActionAdmin = "admin"
+
+// AdminConfig 녹화, 릴레이, 설정 다시 읽기 API와 /metrics를 제공하는 관리 리스너 설정입니다.
+// 재생용 HTTP 리스너(http_listen)와 분리해, 시청자가 서버를 조작하거나 연결 정보를 볼 수 없도록 합니다.
+//
+//	admin:
+//	  listen: "127.0.0.1:8081"
+//	  token: change-me
+//	  access: ["allow 10.0.0.0/8", "deny all"]
+type AdminConfig struct {
+	Listen string `yaml:"listen"` // 관리 API 리스닝 주소 (비어 있으면 띄우지 않음)
+	// Token 설정하면 Authorization: Bearer {token} 헤더가 있는 요청만 받습니다.
+	Token string `yaml:"token"`
+	// Access 관리 API 클라이언트 주소 규칙입니다. ("allow <주소>", "deny <주소>", access 규칙과 같은 형식)
+	Access []string `yaml:"access"`
+
+	// rules validate에서 해석한 Access 규칙입니다.
+	rules []accessRule
+}
+
+// defaultAdminConfig 관리 API는 기본으로 이 서버에서만 접근할 수 있습니다.
+var defaultAdminConfig = AdminConfig{Listen: "127.0.0.1:8081"}
+
+// validate 주소와 접근 규칙을 검사합니다. 루프백이 아닌 주소에서 받으려면 토큰이나 접근 규칙이 필요합니다.
+func (conf *AdminConfig) validate(httpListen string) error {
+	rules, err := parseAccessRules(conf.Access)
+	if err != nil {
+		return fmt.Errorf("access: %w", err)
+	}
+	conf.rules = rules
+	if conf.Listen == "" {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(conf.Listen)
+	if err != nil {
+		return fmt.Errorf("listen: invalid address %q: %s", conf.Listen, err.Error())
+	}
+	if conf.Listen == httpListen {
+		return errors.New("listen: must differ from http_listen")
+	}
+	if ip := net.ParseIP(host); (ip == nil || !ip.IsLoopback()) && host != "localhost" && conf.Token == "" && len(conf.Access) == 0 {
+		return fmt.Errorf("listen: %q is reachable from other hosts, set token or access", conf.Listen)
+	}
+	return nil
+}
+
+// admin 현재 적용 중인 관리 API 설정을 반환합니다.
+func (ctx *StreamContext) admin() AdminConfig {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+	return ctx.Admin
+}
+
+// NewAdminServer 녹화, 릴레이, 설정 다시 읽기 API와 /metrics를 등록한 관리 HTTP 서버를 만듭니다.
+// 모든 요청은 admin.access 규칙과 admin.token으로 검사합니다.
+func NewAdminServer(ctx *StreamContext) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/record", ctx.serveRecordAPI)
+	mux.HandleFunc("/api/record/", ctx.serveRecordAPI)
+	mux.HandleFunc("/api/relay", ctx.serveRelayAPI)
+	mux.HandleFunc("/api/reload", ctx.serveReloadAPI)
+	mux.HandleFunc("/metrics", ctx.serveMetrics)
+	return &http.Server{Addr: ctx.admin().Listen, Handler: ctx.checkAdmin(mux)}
+}
+
+// checkAdmin 접근 규칙, 토큰을 확인한 뒤 next로 요청을 넘깁니다.
+func (ctx *StreamContext) checkAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conf := ctx.admin()
+		addr := httpClientIP(r)
+		rules := conf.rules
+		if rules == nil {
+			rules = lenientAccessRules(conf.Access)
+		}
+		if !accessAllowed(rules, addr) {
+			log.Printf("Access denied for %s (%s %s)", addr, AccessActionAdmin, r.URL.Path)
+			ctx.Metrics.Inc("rtmp_access_denied_total", "action", AccessActionAdmin)
+			writeJSON(w, http.StatusForbidden, apiResponse{Error: errAccessDenied.Error()})
+			return
+		}
+		if conf.Token != "" {
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(conf.Token)) != 1 {
+				log.Printf("Admin request from %s rejected: invalid token", addr)
+				ctx.Metrics.Inc("rtmp_access_denied_total", "action", AccessActionAdmin)
+				w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+				writeJSON(w, http.StatusUnauthorized, apiResponse{Error: "unauthorized"})
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -2,12 +2,17 @@ package amf
 
 import (
 	"encoding/binary"
+	"errors"
+	"fmt"
 	"math"
+	"time"
 )
 
 var rtmpCommandParams = map[string][]string{
 	"connect":       []string{"transId", "cmdObj", "args"},
 	"_result":       []string{"transId", "cmdObj", "info"},
+	"_error":        []string{"transId", "cmdObj", "info"},
+	"onStatus":      []string{"transId", "cmdObj", "info"},
 	"releaseStream": []string{"transId", "cmdObj", "streamName"},
 	"createStream":  []string{"transId", "cmdObj"},
 	"publish":       []string{"transId", "cmdObj", "streamName", "type"},
@@ -15,97 +20,173 @@ var rtmpCommandParams = map[string][]string{
 	"FCUnpublish":   []string{"transId", "cmdObj", "streamName"},
 	"onFCPublish":   []string{"transId", "cmdObj", "info"},
 	"@setDataFrame": []string{"method", "dataObj"},
+	"onMetaData":    []string{"dataObj"},
 	"play":          []string{"transId", "cmdObj", "streamName", "start", "duration", "reset"},
+	"seek":          []string{"transId", "cmdObj", "ms"},
+	"pause":         []string{"transId", "cmdObj", "pause", "ms"},
+	"pauseRaw":      []string{"transId", "cmdObj", "pause", "ms"},
 }
 
+// maxDepth 객체, 배열이 중첩될 수 있는 최대 깊이입니다. 깊게 중첩된 악의적인 입력이 스택을 소진하지 않도록 제한합니다.
+const maxDepth = 32
+
+var (
+	// ErrShortData 값의 길이가 남은 데이터보다 깁니다.
+	ErrShortData = errors.New("amf: unexpected end of data")
+	// ErrTooDeep 객체, 배열이 maxDepth보다 깊게 중첩되었습니다.
+	ErrTooDeep = errors.New("amf: nesting too deep")
+	// ErrNotCommand 첫 번째 값이 명령 이름(문자열)이 아닙니다.
+	ErrNotCommand = errors.New("amf: command name is not a string")
+)
+
 type DecodedData struct {
 	remainingData []byte
 	value         interface{}
 }
 
-func getDecoder(data []byte) func() DecodedData {
-	typeMarker := uint8(data[0])
-	var decoder func([]byte) DecodedData
+// decodeValue 타입 마커(1바이트)를 읽고 그에 맞는 값을 읽습니다.
+// 데이터가 잘렸거나 지원하지 않는 마커이면 에러를 반환합니다. 입력이 무엇이든 panic 하지 않습니다.
+func decodeValue(data []byte, depth int) (DecodedData, error) {
+	if len(data) == 0 {
+		return DecodedData{}, ErrShortData
+	}
+	if depth > maxDepth {
+		return DecodedData{}, ErrTooDeep
+	}
+	typeMarker, data := data[0], data[1:]
 	switch typeMarker {
 	case 0x00:
-		decoder = decodeNumber
-
+		return decodeNumber(data)
 	case 0x01:
-		decoder = decodeBool
-
+		return decodeBool(data)
 	case 0x02:
-		decoder = decodeString
-
+		return decodeString(data)
 	case 0x03:
-		decoder = decodeObject
-
-	case 0x05:
-		decoder = decodeNull
-
+		return decodeObject(data, depth)
+	case 0x05, 0x06, 0x0D: // null, undefined, unsupported
+		return decodeNull(data)
+	case 0x07: // reference: 참조 테이블을 유지하지 않으므로 인덱스만 건너뜁니다.
+		if len(data) < 2 {
+			return DecodedData{}, ErrShortData
+		}
+		return DecodedData{remainingData: data[2:]}, nil
 	case 0x08:
-		decoder = decodeECMAArray
-	}
-
-	return func() DecodedData {
-		return decoder(data[1:])
+		return decodeECMAArray(data, depth)
+	case 0x0A:
+		return decodeStrictArray(data, depth)
+	case 0x0B:
+		return decodeDate(data)
+	case 0x0C, 0x0F: // long string, XML document
+		return decodeLongString(data)
+	case 0x10: // typed object: 클래스 이름 뒤에 일반 객체가 옵니다.
+		class, err := decodeString(data)
+		if err != nil {
+			return DecodedData{}, err
+		}
+		return decodeObject(class.remainingData, depth)
 	}
+	return DecodedData{}, fmt.Errorf("amf: unsupported type marker 0x%02x", typeMarker)
 }
 
 // Decode AMF0 command object
-func Decode(data []byte) map[string]interface{} {
-	decoded := getDecoder(data)()
+// 명령 이름 뒤의 값은 rtmpCommandParams의 이름으로 담고, 데이터가 잘렸거나 잘못되었으면 에러를 반환합니다.
+func Decode(data []byte) (map[string]interface{}, error) {
+	decoded, err := decodeValue(data, 0)
+	if err != nil {
+		return nil, err
+	}
+	name, ok := decoded.value.(string)
+	if !ok {
+		return nil, ErrNotCommand
+	}
 	cmd := map[string]interface{}{
-		"cmd": decoded.value,
+		"cmd": name,
 	}
-	params := rtmpCommandParams[decoded.value.(string)]
+	params := rtmpCommandParams[name]
 
 	for _, param := range params {
 		if len(decoded.remainingData) > 0 {
-			decoded = getDecoder(decoded.remainingData)()
+			if decoded, err = decodeValue(decoded.remainingData, 0); err != nil {
+				return nil, fmt.Errorf("%s %s: %w", name, param, err)
+			}
 			cmd[param] = decoded.value
 		}
 	}
 
-	return cmd
+	return cmd, nil
 }
 
-func decodeNumber(data []byte) DecodedData {
+func decodeNumber(data []byte) (DecodedData, error) {
+	if len(data) < 8 {
+		return DecodedData{}, ErrShortData
+	}
 	return DecodedData{
 		remainingData: data[8:],
 		value:         math.Float64frombits(binary.BigEndian.Uint64(data[:8])),
-	}
+	}, nil
 }
 
-func decodeBool(data []byte) DecodedData {
+func decodeBool(data []byte) (DecodedData, error) {
+	if len(data) < 1 {
+		return DecodedData{}, ErrShortData
+	}
 	return DecodedData{
 		remainingData: data[1:],
 		value:         data[0] != 0,
-	}
+	}, nil
 }
 
 // decodeString 첫 2바이트는 문자열의 길이를 나타내며, 이어지는 문자열은 UTF-8로 인코딩됩니다.
-func decodeString(data []byte) DecodedData {
-	len := binary.BigEndian.Uint16(data[:2]) // 문자열 길이
+func decodeString(data []byte) (DecodedData, error) {
+	if len(data) < 2 {
+		return DecodedData{}, ErrShortData
+	}
+	n := 2 + int(binary.BigEndian.Uint16(data[:2])) // 길이 필드 + 문자열 길이
+	if len(data) < n {
+		return DecodedData{}, ErrShortData
+	}
 	return DecodedData{
-		remainingData: data[2+len:],
-		value:         string(data[2 : 2+len]), // 아스키 코드로 변환
+		remainingData: data[n:],
+		value:         string(data[2:n]),
+	}, nil
+}
+
+// decodeLongString 길이가 4바이트인 문자열입니다. (long string, XML document)
+func decodeLongString(data []byte) (DecodedData, error) {
+	if len(data) < 4 {
+		return DecodedData{}, ErrShortData
 	}
+	n := binary.BigEndian.Uint32(data[:4])
+	if uint64(len(data)-4) < uint64(n) {
+		return DecodedData{}, ErrShortData
+	}
+	return DecodedData{
+		remainingData: data[4+n:],
+		value:         string(data[4 : 4+n]),
+	}, nil
 }
 
-func decodeObject(data []byte) DecodedData {
+// decodeObject 키(길이 2바이트 문자열)와 값의 쌍이 빈 키와 객체 끝 마커(0x09)까지 이어집니다.
+// 끝 마커 없이 데이터가 끝나도 그때까지 읽은 속성을 반환합니다.
+func decodeObject(data []byte, depth int) (DecodedData, error) {
 	object := make(map[string]interface{})
 	tData := data
 	for len(tData) != 0 {
-		decoded := decodeString(tData)
+		decoded, err := decodeString(tData)
+		if err != nil {
+			return DecodedData{}, err
+		}
 		key := decoded.value.(string)
 		tData = decoded.remainingData
 
-		if uint8(tData[0]) == 0x09 {
+		if len(tData) > 0 && uint8(tData[0]) == 0x09 {
 			tData = tData[1:]
 			break
 		}
 
-		decoded = getDecoder(tData)()
+		if decoded, err = decodeValue(tData, depth+1); err != nil {
+			return DecodedData{}, err
+		}
 		tData = decoded.remainingData
 		object[key] = decoded.value
 	}
@@ -113,20 +194,61 @@ func decodeObject(data []byte) DecodedData {
 	return DecodedData{
 		remainingData: tData,
 		value:         object,
-	}
+	}, nil
 }
 
-func decodeNull(data []byte) DecodedData {
+func decodeNull(data []byte) (DecodedData, error) {
 	return DecodedData{
 		remainingData: data,
 		value:         nil,
+	}, nil
+}
+
+// decodeECMAArray 4바이트 개수(참고용) 뒤에 객체와 같은 키, 값 쌍이 옵니다.
+func decodeECMAArray(data []byte, depth int) (DecodedData, error) {
+	if len(data) < 4 {
+		return DecodedData{}, ErrShortData
 	}
+	return decodeObject(data[4:], depth)
 }
 
-func decodeECMAArray(data []byte) DecodedData {
-	decoded := decodeObject(data[4:])
+// decodeStrictArray 4바이트 개수 뒤에 값이 개수만큼 옵니다.
+func decodeStrictArray(data []byte, depth int) (DecodedData, error) {
+	if len(data) < 4 {
+		return DecodedData{}, ErrShortData
+	}
+	count := binary.BigEndian.Uint32(data[:4])
+	tData := data[4:]
+	// 값은 최소 1바이트이므로, 남은 데이터보다 큰 개수는 잘못된 값입니다. (큰 슬라이스를 미리 만들지 않기 위함)
+	if uint64(count) > uint64(len(tData)) {
+		return DecodedData{}, ErrShortData
+	}
+	array := make([]interface{}, 0, count)
+	for i := uint32(0); i < count; i++ {
+		decoded, err := decodeValue(tData, depth+1)
+		if err != nil {
+			return DecodedData{}, err
+		}
+		tData = decoded.remainingData
+		array = append(array, decoded.value)
+	}
 	return DecodedData{
-		remainingData: decoded.remainingData,
-		value:         decoded.value,
+		remainingData: tData,
+		value:         array,
+	}, nil
+}
+
+// decodeDate 1970년부터의 밀리초(8바이트 double) 뒤에 사용하지 않는 시간대(2바이트)가 옵니다.
+func decodeDate(data []byte) (DecodedData, error) {
+	if len(data) < 10 {
+		return DecodedData{}, ErrShortData
 	}
+	ms := math.Float64frombits(binary.BigEndian.Uint64(data[:8]))
+	if math.IsNaN(ms) || math.IsInf(ms, 0) {
+		ms = 0
+	}
+	return DecodedData{
+		remainingData: data[10:],
+		value:         time.UnixMilli(int64(ms)),
+	}, nil
 }
@@ -0,0 +1,88 @@
+package amf
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// nested depth 단계로 중첩된 객체를 인자로 가진 connect 명령입니다.
+func nested(depth int) []byte {
+	b, _ := Encode("connect", 1.0)
+	for i := 0; i < depth; i++ {
+		b = append(b, 0x03, 0x00, 0x01, 'a') // 객체 시작, 키 "a"
+	}
+	b = append(b, 0x05) // 가장 안쪽 값 null
+	for i := 0; i < depth; i++ {
+		b = append(b, 0x00, 0x00, 0x09) // 객체 끝
+	}
+	return b
+}
+
+func FuzzDecode(f *testing.F) {
+	connect, _ := Encode("connect", 1.0, map[string]interface{}{"app": "live", "tcUrl": "rtmp://localhost/live", "fpad": false})
+	publish, _ := Encode("publish", 5.0, nil, "stream?token=abc", "live")
+	f.Add(connect)
+	f.Add(publish)
+	f.Add(connect[:len(connect)-5])
+	f.Add(nested(maxDepth))
+	f.Add(nested(maxDepth + 10))
+	f.Add([]byte{0x02, 0x00, 0x04, 'p', 'l', 'a', 'y', 0x0A, 0xFF, 0xFF, 0xFF, 0xFF})      // 개수만 큰 strict array
+	f.Add([]byte{0x02, 0x00, 0x04, 'p', 'l', 'a', 'y', 0x0C, 0xFF, 0xFF, 0xFF, 0xFF, 'a'}) // 길이만 큰 long string
+	f.Add([]byte{0x02, 0xFF, 0xFF})
+	f.Add([]byte{0x00, 0x3F, 0xF0})
+	f.Add([]byte{0x42})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		cmd, err := Decode(data)
+		if err != nil {
+			if cmd != nil {
+				t.Errorf("Decode returned %v with error %s", cmd, err)
+			}
+			return
+		}
+		if _, ok := cmd["cmd"].(string); !ok {
+			t.Errorf("decoded command without a name: %v", cmd)
+		}
+	})
+}
+
+func TestDecodeLimits(t *testing.T) {
+	for name, tc := range map[string]struct {
+		data []byte
+		err  error
+	}{
+		"max depth":       {nested(maxDepth), nil},
+		"too deep":        {nested(maxDepth + 1), ErrTooDeep},
+		"huge array":      {[]byte{0x02, 0x00, 0x04, 'p', 'l', 'a', 'y', 0x0A, 0xFF, 0xFF, 0xFF, 0xFF, 0x05}, ErrShortData},
+		"huge string":     {[]byte{0x02, 0x00, 0x04, 'p', 'l', 'a', 'y', 0x0C, 0xFF, 0xFF, 0xFF, 0xFF, 'a'}, ErrShortData},
+		"short name":      {[]byte{0x02, 0x00, 0x04, 'p'}, ErrShortData},
+		"short number":    {[]byte{0x02, 0x00, 0x04, 'p', 'l', 'a', 'y', 0x00, 0x3F}, ErrShortData},
+		"not a command":   {[]byte{0x00, 0x3F, 0xF0, 0, 0, 0, 0, 0, 0}, ErrNotCommand},
+		"empty":           {nil, ErrShortData},
+		"truncated value": {[]byte{0x02, 0x00, 0x04, 'p', 'l', 'a', 'y', 0x02, 0x00}, ErrShortData},
+	} {
+		if _, err := Decode(tc.data); !errors.Is(err, tc.err) {
+			t.Errorf("%s: error %v, want %v", name, err, tc.err)
+		}
+	}
+	if _, err := Decode([]byte{0x02, 0x00, 0x04, 'p', 'l', 'a', 'y', 0x42}); err == nil {
+		t.Error("unknown marker: no error")
+	}
+}
+
+func TestDecodeRoundTrip(t *testing.T) {
+	data, _ := Encode("play", 4.0, nil, "stream", -2.0, -1.0, true)
+	cmd, err := Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]interface{}{"cmd": "play", "transId": 4.0, "cmdObj": nil, "streamName": "stream", "start": -2.0, "duration": -1.0, "reset": true} {
+		if got := cmd[key]; got != want {
+			t.Errorf("%s = %v, want %v", key, got, want)
+		}
+	}
+	if again, _ := Encode("play", 4.0, nil, "stream", -2.0, -1.0, true); !bytes.Equal(again, data) {
+		t.Error("Encode is not deterministic")
+	}
+}
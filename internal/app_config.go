@@ -0,0 +1,66 @@
+package internal
+
+import "time"
+
+// 녹화 형식입니다.
+const (
+	RecordFormatFLV  = "flv"
+	RecordFormatMP4  = "mp4"  // 종료 시 moov를 앞에 둔 faststart MP4
+	RecordFormatFMP4 = "fmp4" // 녹화 중에도 재생 가능한 fragmented MP4
+)
+
+// AppConfig RTMP 애플리케이션(rtmp://host/{app}/{stream}의 app)별 설정입니다.
+type AppConfig struct {
+	Name string `yaml:"-"`    // 설정 파일에서는 apps 아래의 키가 이름입니다.
+	CMAF bool   `yaml:"cmaf"` // fMP4(CMAF) 세그먼트와 HLS/DASH 매니페스트를 생성합니다.
+
+	Record            bool          `yaml:"record"`              // 퍼블리시가 시작되면 자동으로 녹화합니다.
+	RecordFormat      string        `yaml:"record_format"`       // 녹화 형식 (flv, mp4, fmp4). 비어 있으면 flv 입니다.
+	RecordMaxDuration time.Duration `yaml:"record_max_duration"` // 녹화 파일 하나의 최대 길이 (0이면 제한 없음)
+	RecordMaxSize     int64         `yaml:"record_max_size"`     // 녹화 파일 하나의 최대 크기 (0이면 제한 없음)
+
+	// DVRWindow 타임시프트로 되돌려 볼 수 있는 길이 (0이면 사용하지 않음).
+	// 설정하면 최근 DVRWindow 만큼의 패킷을 메모리에 보관하고, CMAF 플레이리스트도 같은 길이로 유지합니다.
+	DVRWindow time.Duration `yaml:"dvr_window"`
+
+	// Push 퍼블리시가 시작되면 스트림을 그대로 보낼 RTMP 주소 목록입니다. 주소의 {stream}은 스트림 이름으로 바뀝니다.
+	// (예: rtmp://a.rtmp.youtube.com/live2/KEY, rtmp://backup.example.com/live/{stream})
+	Push []string `yaml:"push"`
+	// StreamPush 특정 스트림만 보낼 RTMP 주소 목록입니다. (스트림 이름 → 주소 목록)
+	StreamPush map[string][]string `yaml:"stream_push"`
+
+	// Origins 엣지 모드에서 로컬에 없는 스트림을 play로 받아올 오리진 주소 목록입니다. (예: rtmp://origin1:1935/live)
+	// 주소에 app이 없으면 엣지와 같은 app 이름을 사용합니다.
+	Origins []string `yaml:"origins"`
+	// OriginHash true이면 스트림 이름의 해시 링으로 오리진을 고르고, false이면 Origins 순서대로 시도합니다.
+	OriginHash bool `yaml:"origin_hash"`
+	// EdgeIdleTimeout 마지막 시청자가 나간 뒤 오리진 연결을 유지하는 시간입니다. (0이면 30초)
+	EdgeIdleTimeout time.Duration `yaml:"edge_idle_timeout"`
+
+	VOD    bool   `yaml:"vod"`     // play 요청 시 라이브 스트림 대신 {VODDir}/{streamName}.flv 파일을 재생합니다.
+	VODDir string `yaml:"vod_dir"` // VOD 파일 디렉터리 (비어 있으면 paths.vod/{app})
+
+	MaxPublishers int `yaml:"max_publishers"` // 동시에 퍼블리시할 수 있는 스트림 수 (0이면 전역 limits.max_publishers_per_app)
+	MaxViewers    int `yaml:"max_viewers"`    // 스트림 하나의 동시 시청자 수 (0이면 전역 limits.max_viewers_per_stream)
+
+	Auth AuthConfig `yaml:"auth"`
+	// Access 이 app의 connect, publish, play 접근 규칙입니다. 비어 있는 동작은 전역 access 규칙을 사용합니다.
+	Access AccessConfig `yaml:"access"`
+	// Webhooks 이 app에만 적용할 웹훅입니다. 적힌 값만 전역 webhooks 설정을 덮어씁니다.
+	Webhooks WebhookConfig `yaml:"webhooks"`
+}
+
+// WildcardAppName 이 이름으로 설정한 app은 apps에 없는 모든 app 이름에 적용됩니다.
+// 설정하지 않으면 apps에 없는 app의 connect는 거절합니다.
+const WildcardAppName = "*"
+
+// defaultAppConfig 다시 읽으면서 설정에서 빠진 app의 기존 세션에 적용되는 기본 설정입니다.
+var defaultAppConfig = AppConfig{}
+
+// recordLimitReached 녹화 파일이 최대 크기 또는 최대 길이에 도달했는지 확인합니다.
+func (conf *AppConfig) recordLimitReached(size int64, duration time.Duration) bool {
+	if conf.RecordMaxSize > 0 && size >= conf.RecordMaxSize {
+		return true
+	}
+	return conf.RecordMaxDuration > 0 && duration >= conf.RecordMaxDuration
+}
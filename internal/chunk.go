@@ -2,7 +2,6 @@ package internal
 
 import (
 	"encoding/binary"
-	"encoding/hex"
 	"example/hello/internal/util/endian"
 	"fmt"
 	"math"
@@ -159,23 +158,47 @@ func (chunk *rtmpChunk) createPayloadArray(c *Connection) [][]byte {
 	return payloads
 }
 
-func (c *Connection) setMaxWriteChunkSize(size uint16) {
-	buf, _ := hex.DecodeString("02000000000004010000000000001000")
-	if _, err := c.Writer.Write(buf); err != nil {
+// controlMessage 청크 스트림 2번으로 보내는 프로토콜 제어 메시지를 만듭니다. (fmt 0, 타임스탬프 0, 메시지 스트림 ID 0)
+func controlMessage(messageType uint8, payload []byte) []byte {
+	buf := make([]byte, 12+len(payload))
+	buf[0] = 0x02
+	endian.PutU24BE(buf[4:], uint32(len(payload)))
+	buf[7] = messageType
+	copy(buf[12:], payload)
+	return buf
+}
+
+// setMaxWriteChunkSize 서버가 보낼 청크 크기를 알리고, 이후 메시지를 그 크기로 나누어 보냅니다.
+// 다른 고루틴이 이전 크기로 나눈 메시지를 그 사이에 쓰지 않도록 청크 크기도 잠금을 잡은 채로 바꿉니다.
+func (c *Connection) setMaxWriteChunkSize(size uint32) {
+	payload := make([]byte, 4)
+	binary.BigEndian.PutUint32(payload, size&0x7fffffff)
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if _, err := c.Writer.Write(controlMessage(1, payload)); err != nil {
 		fmt.Println("Failed to set max chunk size")
+		return
 	}
+	c.WriteMaxChunkSize = int(size)
 }
 
 func (c *Connection) sendWindowACK(size uint32) {
-	buf, _ := hex.DecodeString("02000000000004030000000010001000")
-	if _, err := c.Writer.Write(buf); err != nil {
+	payload := make([]byte, 4)
+	binary.BigEndian.PutUint32(payload, size)
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if _, err := c.Writer.Write(controlMessage(5, payload)); err != nil {
 		fmt.Println("Failed to sent window ack size")
 	}
 }
 
 func (c *Connection) setPeerBandwidth(size uint32, limit uint8) {
-	buf, _ := hex.DecodeString("0200000000000506000000000100100002")
-	if _, err := c.Writer.Write(buf); err != nil {
+	payload := make([]byte, 5)
+	binary.BigEndian.PutUint32(payload, size)
+	payload[4] = limit
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if _, err := c.Writer.Write(controlMessage(6, payload)); err != nil {
 		fmt.Println("Failed to set peer bandwidth")
 	}
 }
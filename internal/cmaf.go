@@ -0,0 +1,487 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"example/hello/internal/codec/aac"
+	"example/hello/internal/codec/h264"
+	"example/hello/internal/format/fmp4"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// errInvalidName app, 스트림 이름을 출력 파일 경로에 사용할 수 없습니다.
+var errInvalidName = errors.New("invalid app or stream name")
+
+// validPathName 출력 경로의 한 요소로 사용할 수 있는 이름인지 확인합니다.
+// 비어 있거나 상위 디렉터리로 벗어날 수 있는 이름(.., /, \)은 허용하지 않습니다. (vodFilePath와 같은 규칙)
+func validPathName(name string) bool {
+	return name != "" && name != "." && !strings.Contains(name, "..") && !strings.ContainsAny(name, `/\`)
+}
+
+// outputPath base 아래에 names를 이은 경로를 만듭니다. 이름이 잘못되었거나 정리한 경로가 base를 벗어나면 errInvalidName을 반환합니다.
+func outputPath(base string, names ...string) (string, error) {
+	for _, name := range names {
+		if !validPathName(name) {
+			return "", errInvalidName
+		}
+	}
+	path := filepath.Join(append([]string{base}, names...)...)
+	rel, err := filepath.Rel(filepath.Clean(base), path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", errInvalidName
+	}
+	return path, nil
+}
+
+const (
+	cmafPlaylistSize = 6  // 플레이리스트에 노출할 세그먼트 수
+	cmafKeepSegments = 12 // 디스크에 남겨둘 세그먼트 수 (플레이어가 아직 받고 있을 수 있는 세그먼트를 보존합니다.)
+
+	// cmafAudioOnlySegmentDuration 비디오가 없는 스트림은 키프레임이 없으므로 이 길이(ms)마다 세그먼트를 나눕니다.
+	cmafAudioOnlySegmentDuration = 2000
+
+	cmafVideoTrackID = 1
+	cmafAudioTrackID = 2
+)
+
+// cmafSegment 디스크에 쓰여진 하나의 세그먼트 정보입니다.
+type cmafSegment struct {
+	index         int
+	start         uint32 // ms
+	duration      uint32 // ms
+	audioStart    uint64 // 오디오 timescale 기준
+	audioDuration uint64
+	size          int
+}
+
+// cmafPackager 허브로부터 받은 패킷을 GOP 단위의 fMP4 세그먼트로 만들고,
+// 같은 세그먼트를 참조하는 HLS(EXT-X-MAP) 플레이리스트와 DASH(SegmentTemplate) MPD를 씁니다.
+type cmafPackager struct {
+	app    string
+	stream string
+	dir    string
+	// dvrWindow 0보다 크면 최근 세그먼트 몇 개 대신 이 길이만큼의 세그먼트를 플레이리스트에 노출합니다.
+	dvrWindow time.Duration
+
+	video     *fmp4.Track
+	audio     *fmp4.Track
+	videoConf *h264.DecoderConfig
+	audioConf *aac.Config
+
+	initialized bool
+	startTime   time.Time
+	firstTime   uint32
+
+	sequence     uint32
+	nextIndex    int
+	segmentStart uint32
+	videoBuf     []*Packet
+	audioBuf     []*Packet
+	audioTime    uint64 // 다음 오디오 세그먼트의 시작 디코딩 시간
+	segments     []cmafSegment
+	maxBandwidth int
+}
+
+// newCMAFPackager {base}/{app}/{stream}에 세그먼트를 쓰는 패키저를 만듭니다.
+// app, 스트림 이름이 출력 디렉터리를 벗어나면 errInvalidName을 반환합니다.
+func newCMAFPackager(base, app, stream string, dvrWindow time.Duration) (*cmafPackager, error) {
+	dir, err := outputPath(base, app, stream)
+	if err != nil {
+		return nil, err
+	}
+	return &cmafPackager{
+		app:       app,
+		stream:    stream,
+		dir:       dir,
+		dvrWindow: dvrWindow,
+		nextIndex: 1,
+	}, nil
+}
+
+func (p *cmafPackager) WritePacket(pkt *Packet) error {
+	switch {
+	case pkt.IsSequenceHeader() && pkt.IsVideo():
+		conf, err := h264.ParseDecoderConfig(pkt.Payload())
+		if err != nil {
+			log.Printf("CMAF: invalid AVC sequence header for %s: %s", p.stream, err.Error())
+			return nil
+		}
+		p.videoConf = conf
+	case pkt.IsSequenceHeader() && pkt.IsAudio():
+		conf, err := aac.ParseConfig(pkt.Payload())
+		if err != nil {
+			log.Printf("CMAF: invalid AAC sequence header for %s: %s", p.stream, err.Error())
+			return nil
+		}
+		p.audioConf = conf
+	case pkt.IsAVC():
+		return p.writeVideo(pkt)
+	case pkt.IsAAC():
+		return p.writeAudio(pkt)
+	}
+	return nil
+}
+
+func (p *cmafPackager) writeVideo(pkt *Packet) error {
+	if p.videoConf == nil {
+		return nil
+	}
+	if !p.initialized {
+		if !pkt.IsKeyFrame() {
+			return nil
+		}
+		if err := p.init(pkt.Timestamp); err != nil {
+			return err
+		}
+	}
+	// 키프레임이 오면 이전 GOP를 하나의 세그먼트로 씁니다.
+	if pkt.IsKeyFrame() && len(p.videoBuf) > 0 {
+		if err := p.flush(pkt.Timestamp); err != nil {
+			return err
+		}
+	}
+	p.videoBuf = append(p.videoBuf, pkt)
+	return nil
+}
+
+func (p *cmafPackager) writeAudio(pkt *Packet) error {
+	if p.audioConf == nil {
+		return nil
+	}
+	if !p.initialized {
+		// 비디오 시퀀스 헤더가 있으면 첫 키프레임부터 시작합니다.
+		if p.videoConf != nil {
+			return nil
+		}
+		if err := p.init(pkt.Timestamp); err != nil {
+			return err
+		}
+	}
+	if p.video == nil && len(p.audioBuf) > 0 && pkt.Timestamp-p.segmentStart >= cmafAudioOnlySegmentDuration {
+		if err := p.flush(pkt.Timestamp); err != nil {
+			return err
+		}
+	}
+	p.audioBuf = append(p.audioBuf, pkt)
+	return nil
+}
+
+// init 받은 시퀀스 헤더로 트랙을 구성하고 초기화 세그먼트를 씁니다.
+func (p *cmafPackager) init(timestamp uint32) error {
+	if err := os.MkdirAll(p.dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	if p.videoConf != nil {
+		p.video = &fmp4.Track{
+			ID:        cmafVideoTrackID,
+			Kind:      fmp4.VideoTrack,
+			Timescale: 1000,
+			Width:     p.videoConf.Width,
+			Height:    p.videoConf.Height,
+			AVCC:      p.videoConf.Record,
+		}
+		if err := p.writeFile("init-video.mp4", func(b *bytes.Buffer) error { return fmp4.WriteInit(b, p.video) }); err != nil {
+			return err
+		}
+	}
+	if p.audioConf != nil {
+		p.audio = &fmp4.Track{
+			ID:           cmafAudioTrackID,
+			Kind:         fmp4.AudioTrack,
+			Timescale:    uint32(p.audioConf.SampleRate),
+			SampleRate:   p.audioConf.SampleRate,
+			ChannelCount: p.audioConf.ChannelCount,
+			ASC:          p.audioConf.Record,
+		}
+		if err := p.writeFile("init-audio.mp4", func(b *bytes.Buffer) error { return fmp4.WriteInit(b, p.audio) }); err != nil {
+			return err
+		}
+		p.audioTime = uint64(timestamp) * uint64(p.audio.Timescale) / 1000
+	}
+
+	p.initialized = true
+	p.startTime = time.Now()
+	p.firstTime = timestamp
+	p.segmentStart = timestamp
+	log.Printf("CMAF packager started for %s/%s", p.app, p.stream)
+	return nil
+}
+
+// flush end(ms) 이전까지의 패킷을 하나의 세그먼트로 쓰고 플레이리스트를 갱신합니다.
+func (p *cmafPackager) flush(end uint32) error {
+	seg := cmafSegment{
+		index:      p.nextIndex,
+		start:      p.segmentStart,
+		duration:   end - p.segmentStart,
+		audioStart: p.audioTime,
+	}
+
+	if p.video != nil && len(p.videoBuf) > 0 {
+		frag := &fmp4.TrackFragment{Track: p.video, BaseTime: uint64(p.videoBuf[0].Timestamp)}
+		for i, pkt := range p.videoBuf {
+			next := end
+			if i+1 < len(p.videoBuf) {
+				next = p.videoBuf[i+1].Timestamp
+			}
+			frag.Samples = append(frag.Samples, fmp4.Sample{
+				Duration:          next - pkt.Timestamp,
+				CompositionOffset: pkt.CompositionTime(),
+				KeyFrame:          pkt.IsKeyFrame(),
+				Data:              pkt.Payload(),
+			})
+		}
+		n, err := p.writeFragment(fmt.Sprintf("video-%d.m4s", seg.index), frag)
+		if err != nil {
+			return err
+		}
+		seg.size += n
+		p.videoBuf = p.videoBuf[:0]
+	}
+
+	if p.audio != nil {
+		// 세그먼트 경계 이후의 오디오는 다음 세그먼트로 넘깁니다.
+		var samples []*Packet
+		var rest []*Packet
+		for _, pkt := range p.audioBuf {
+			if pkt.Timestamp < end {
+				samples = append(samples, pkt)
+			} else {
+				rest = append(rest, pkt)
+			}
+		}
+		if len(samples) > 0 {
+			frag := &fmp4.TrackFragment{Track: p.audio, BaseTime: p.audioTime}
+			for _, pkt := range samples {
+				frag.Samples = append(frag.Samples, fmp4.Sample{
+					Duration: aac.SamplesPerFrame,
+					KeyFrame: true,
+					Data:     pkt.Payload(),
+				})
+			}
+			n, err := p.writeFragment(fmt.Sprintf("audio-%d.m4s", seg.index), frag)
+			if err != nil {
+				return err
+			}
+			seg.size += n
+			seg.audioDuration = uint64(len(samples)) * aac.SamplesPerFrame
+			p.audioTime += seg.audioDuration
+		}
+		p.audioBuf = rest
+	}
+
+	if seg.duration > 0 {
+		if bandwidth := seg.size * 8 * 1000 / int(seg.duration); bandwidth > p.maxBandwidth {
+			p.maxBandwidth = bandwidth
+		}
+	}
+
+	p.segments = append(p.segments, seg)
+	p.nextIndex++
+	p.segmentStart = end
+
+	// 플레이리스트에서 빠진 뒤 일정 개수가 지난 오래된 세그먼트를 삭제합니다.
+	for len(p.segments)-len(p.playlistSegments()) > cmafKeepSegments-cmafPlaylistSize {
+		old := p.segments[0]
+		p.segments = p.segments[1:]
+		os.Remove(filepath.Join(p.dir, fmt.Sprintf("video-%d.m4s", old.index)))
+		os.Remove(filepath.Join(p.dir, fmt.Sprintf("audio-%d.m4s", old.index)))
+	}
+
+	return p.writeManifests(false)
+}
+
+func (p *cmafPackager) writeFragment(name string, frag *fmp4.TrackFragment) (int, error) {
+	p.sequence++
+	var size int
+	err := p.writeFile(name, func(b *bytes.Buffer) error {
+		err := fmp4.WriteFragment(b, p.sequence, frag)
+		size = b.Len()
+		return err
+	})
+	return size, err
+}
+
+// writeFile 임시 파일에 쓴 뒤 이름을 바꿔, 플레이어가 쓰는 중인 파일을 읽지 않도록 합니다.
+func (p *cmafPackager) writeFile(name string, fill func(b *bytes.Buffer) error) error {
+	var b bytes.Buffer
+	if err := fill(&b); err != nil {
+		return err
+	}
+	path := filepath.Join(p.dir, name)
+	if err := os.WriteFile(path+".tmp", b.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// playlistSegments 플레이리스트에 노출할 최근 세그먼트를 반환합니다.
+// 타임시프트가 설정되어 있으면 마지막 세그먼트 끝에서 dvrWindow 안에 시작하는 세그먼트를 모두 노출합니다.
+func (p *cmafPackager) playlistSegments() []cmafSegment {
+	if p.dvrWindow > 0 && len(p.segments) > 0 {
+		last := p.segments[len(p.segments)-1]
+		end := int64(last.start) + int64(last.duration)
+		window := int64(p.dvrWindow / time.Millisecond)
+		i := 0
+		for i < len(p.segments)-1 && end-int64(p.segments[i].start) > window {
+			i++
+		}
+		return p.segments[i:]
+	}
+	if len(p.segments) > cmafPlaylistSize {
+		return p.segments[len(p.segments)-cmafPlaylistSize:]
+	}
+	return p.segments
+}
+
+func (p *cmafPackager) writeManifests(ended bool) error {
+	segments := p.playlistSegments()
+	if len(segments) == 0 {
+		return nil
+	}
+	if err := p.writeFile("index.m3u8", func(b *bytes.Buffer) error { return p.masterPlaylist(b) }); err != nil {
+		return err
+	}
+	if p.video != nil {
+		if err := p.writeFile("video.m3u8", func(b *bytes.Buffer) error { return p.mediaPlaylist(b, "video", segments, ended) }); err != nil {
+			return err
+		}
+	}
+	if p.audio != nil {
+		if err := p.writeFile("audio.m3u8", func(b *bytes.Buffer) error { return p.mediaPlaylist(b, "audio", segments, ended) }); err != nil {
+			return err
+		}
+	}
+	return p.writeFile("index.mpd", func(b *bytes.Buffer) error { return p.mpd(b, segments, ended) })
+}
+
+func (p *cmafPackager) codecs() string {
+	var codecs []string
+	if p.video != nil {
+		codecs = append(codecs, p.videoConf.Codec())
+	}
+	if p.audio != nil {
+		codecs = append(codecs, p.audioConf.Codec())
+	}
+	return strings.Join(codecs, ",")
+}
+
+func (p *cmafPackager) masterPlaylist(b *bytes.Buffer) error {
+	b.WriteString("#EXTM3U\n#EXT-X-VERSION:7\n#EXT-X-INDEPENDENT-SEGMENTS\n")
+	if p.video == nil {
+		fmt.Fprintf(b, "#EXT-X-STREAM-INF:BANDWIDTH=%d,CODECS=\"%s\"\naudio.m3u8\n", p.maxBandwidth, p.codecs())
+		return nil
+	}
+	stream := fmt.Sprintf("#EXT-X-STREAM-INF:BANDWIDTH=%d,CODECS=\"%s\"", p.maxBandwidth, p.codecs())
+	if p.video.Width > 0 && p.video.Height > 0 {
+		stream += fmt.Sprintf(",RESOLUTION=%dx%d", p.video.Width, p.video.Height)
+	}
+	if p.audio != nil {
+		b.WriteString("#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID=\"audio\",NAME=\"default\",DEFAULT=YES,AUTOSELECT=YES,URI=\"audio.m3u8\"\n")
+		stream += ",AUDIO=\"audio\""
+	}
+	fmt.Fprintf(b, "%s\nvideo.m3u8\n", stream)
+	return nil
+}
+
+func (p *cmafPackager) mediaPlaylist(b *bytes.Buffer, kind string, segments []cmafSegment, ended bool) error {
+	var target uint32
+	for _, seg := range segments {
+		if d := (seg.duration + 999) / 1000; d > target {
+			target = d
+		}
+	}
+	fmt.Fprintf(b, "#EXTM3U\n#EXT-X-VERSION:7\n#EXT-X-TARGETDURATION:%d\n#EXT-X-MEDIA-SEQUENCE:%d\n", target, segments[0].index)
+	// 타임시프트 플레이리스트는 아직 삭제된 세그먼트가 없는 동안 EVENT 타입으로 알려, 플레이어가 처음부터 되감을 수 있게 합니다.
+	// window가 지나 세그먼트가 빠지기 시작하면 EVENT 규격(세그먼트 삭제 불가)에 맞지 않으므로 일반 라이브 플레이리스트가 됩니다.
+	if p.dvrWindow > 0 && segments[0].index == 1 {
+		b.WriteString("#EXT-X-PLAYLIST-TYPE:EVENT\n")
+	}
+	fmt.Fprintf(b, "#EXT-X-MAP:URI=\"init-%s.mp4\"\n", kind)
+	for _, seg := range segments {
+		fmt.Fprintf(b, "#EXTINF:%.3f,\n%s-%d.m4s\n", float64(seg.duration)/1000, kind, seg.index)
+	}
+	if ended {
+		b.WriteString("#EXT-X-ENDLIST\n")
+	}
+	return nil
+}
+
+// timeShiftBufferDepth DASH 플레이어가 되감을 수 있는 길이(초) 입니다.
+func (p *cmafPackager) timeShiftBufferDepth() int {
+	if p.dvrWindow > 0 {
+		return int(p.dvrWindow / time.Second)
+	}
+	return cmafPlaylistSize * 10
+}
+
+func (p *cmafPackager) mpd(b *bytes.Buffer, segments []cmafSegment, ended bool) error {
+	availabilityStart := p.startTime.Add(-time.Duration(p.firstTime) * time.Millisecond).UTC()
+	b.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	if ended {
+		last := p.segments[len(p.segments)-1]
+		duration := float64(last.start+last.duration-p.firstTime) / 1000
+		fmt.Fprintf(b, "<MPD xmlns=\"urn:mpeg:dash:schema:mpd:2011\" profiles=\"urn:mpeg:dash:profile:isoff-live:2011\" type=\"static\" mediaPresentationDuration=\"PT%.3fS\" minBufferTime=\"PT2S\">\n", duration)
+		fmt.Fprintf(b, "  <Period id=\"0\" start=\"PT%.3fS\">\n", float64(p.firstTime)/1000)
+	} else {
+		fmt.Fprintf(b, "<MPD xmlns=\"urn:mpeg:dash:schema:mpd:2011\" profiles=\"urn:mpeg:dash:profile:isoff-live:2011\" type=\"dynamic\" availabilityStartTime=\"%s\" publishTime=\"%s\" minimumUpdatePeriod=\"PT2S\" minBufferTime=\"PT2S\" timeShiftBufferDepth=\"PT%dS\">\n",
+			availabilityStart.Format(time.RFC3339), time.Now().UTC().Format(time.RFC3339), p.timeShiftBufferDepth())
+		b.WriteString("  <Period id=\"0\" start=\"PT0S\">\n")
+	}
+
+	if p.video != nil {
+		b.WriteString("    <AdaptationSet contentType=\"video\" mimeType=\"video/mp4\" segmentAlignment=\"true\" startWithSAP=\"1\">\n")
+		fmt.Fprintf(b, "      <Representation id=\"video\" codecs=\"%s\" bandwidth=\"%d\" width=\"%d\" height=\"%d\">\n", p.videoConf.Codec(), p.maxBandwidth, p.video.Width, p.video.Height)
+		fmt.Fprintf(b, "        <SegmentTemplate timescale=\"%d\" initialization=\"init-video.mp4\" media=\"video-$Number$.m4s\" startNumber=\"%d\"", p.video.Timescale, segments[0].index)
+		if ended {
+			fmt.Fprintf(b, " presentationTimeOffset=\"%d\"", p.firstTime)
+		}
+		b.WriteString(">\n          <SegmentTimeline>\n")
+		for _, seg := range segments {
+			fmt.Fprintf(b, "            <S t=\"%d\" d=\"%d\"/>\n", seg.start, seg.duration)
+		}
+		b.WriteString("          </SegmentTimeline>\n        </SegmentTemplate>\n      </Representation>\n    </AdaptationSet>\n")
+	}
+	if p.audio != nil {
+		b.WriteString("    <AdaptationSet contentType=\"audio\" mimeType=\"audio/mp4\" segmentAlignment=\"true\" startWithSAP=\"1\">\n")
+		fmt.Fprintf(b, "      <Representation id=\"audio\" codecs=\"%s\" bandwidth=\"128000\" audioSamplingRate=\"%d\">\n", p.audioConf.Codec(), p.audio.SampleRate)
+		fmt.Fprintf(b, "        <SegmentTemplate timescale=\"%d\" initialization=\"init-audio.mp4\" media=\"audio-$Number$.m4s\" startNumber=\"%d\"", p.audio.Timescale, segments[0].index)
+		if ended {
+			fmt.Fprintf(b, " presentationTimeOffset=\"%d\"", uint64(p.firstTime)*uint64(p.audio.Timescale)/1000)
+		}
+		b.WriteString(">\n          <SegmentTimeline>\n")
+		for _, seg := range segments {
+			fmt.Fprintf(b, "            <S t=\"%d\" d=\"%d\"/>\n", seg.audioStart, seg.audioDuration)
+		}
+		b.WriteString("          </SegmentTimeline>\n        </SegmentTemplate>\n      </Representation>\n    </AdaptationSet>\n")
+	}
+
+	b.WriteString("  </Period>\n</MPD>\n")
+	return nil
+}
+
+// Close 퍼블리셔가 종료되면 남은 패킷을 마지막 세그먼트로 쓰고 플레이리스트를 종료합니다.
+func (p *cmafPackager) Close() error {
+	if !p.initialized {
+		return nil
+	}
+	end := p.segmentStart
+	if n := len(p.videoBuf); n > 0 {
+		end = p.videoBuf[n-1].Timestamp + 1
+	}
+	if n := len(p.audioBuf); n > 0 && p.audioBuf[n-1].Timestamp+1 > end {
+		end = p.audioBuf[n-1].Timestamp + 1
+	}
+	if end > p.segmentStart {
+		if err := p.flush(end); err != nil {
+			return err
+		}
+	}
+	log.Printf("CMAF packager finished for %s/%s", p.app, p.stream)
+	return p.writeManifests(true)
+}
@@ -0,0 +1,405 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 320x240 Baseline 프로필 SPS, PPS로 만든 AVCDecoderConfigurationRecord와 AAC-LC 44.1kHz 스테레오 AudioSpecificConfig입니다.
+var (
+	testAVCC = []byte{
+		0x01, 0x42, 0xc0, 0x1e, 0xff,
+		0xe1, 0x00, 0x08, 0x67, 0x42, 0xc0, 0x1e, 0xda, 0x05, 0x07, 0xe4,
+		0x01, 0x00, 0x04, 0x68, 0xce, 0x38, 0x80,
+	}
+	testASC = []byte{0x12, 0x10}
+
+	testAVCSequenceHeader = &Packet{Type: MessageTypeVideo, Data: append([]byte{0x17, 0, 0, 0, 0}, testAVCC...)}
+	testAACSequenceHeader = &Packet{Type: MessageTypeAudio, Data: append([]byte{0xaf, 0}, testASC...)}
+)
+
+// testAVCFrame timestamp(ms)의 H.264 프레임 태그를 만듭니다. 페이로드는 길이 4바이트 + NALU 형식입니다.
+func testAVCFrame(timestamp uint32, keyFrame bool, cts int32) *Packet {
+	header := byte(0x27)
+	nalu := []byte{0x41, 0x9a, byte(timestamp), byte(timestamp >> 8)}
+	if keyFrame {
+		header = 0x17
+		nalu[0] = 0x65
+	}
+	data := []byte{header, 1, byte(cts >> 16), byte(cts >> 8), byte(cts)}
+	data = binary.BigEndian.AppendUint32(data, uint32(len(nalu)))
+	return &Packet{Type: MessageTypeVideo, Timestamp: timestamp, Data: append(data, nalu...)}
+}
+
+// testAACFrame timestamp(ms)의 raw AAC 프레임 태그를 만듭니다.
+func testAACFrame(timestamp uint32) *Packet {
+	return &Packet{Type: MessageTypeAudio, Timestamp: timestamp, Data: []byte{0xaf, 1, 0x21, byte(timestamp), byte(timestamp >> 8)}}
+}
+
+// testAACTimestamp n번째 AAC 프레임(1024 샘플, 44.1kHz)의 시작 시간(ms)입니다.
+func testAACTimestamp(n int) uint32 {
+	return uint32(n * 1024 * 1000 / 44100)
+}
+
+// writeTestGOPs 시퀀스 헤더 뒤에 1초 길이(25fps)의 GOP를 gops개 만큼 보내고, 보낸 오디오 프레임 수를 반환합니다.
+// 마지막 GOP는 다음 키프레임이 오기 전이므로 아직 세그먼트로 쓰이지 않습니다.
+func writeTestGOPs(t *testing.T, w PacketWriter, gops int) int {
+	t.Helper()
+	write := func(p *Packet) {
+		if err := w.WritePacket(p); err != nil {
+			t.Fatalf("WritePacket at %dms: %s", p.Timestamp, err)
+		}
+	}
+	write(testAVCSequenceHeader)
+	write(testAACSequenceHeader)
+	audio := 0
+	for frame := 0; frame < gops*25; frame++ {
+		timestamp := uint32(frame * 40)
+		for testAACTimestamp(audio) < timestamp {
+			write(testAACFrame(testAACTimestamp(audio)))
+			audio++
+		}
+		write(testAVCFrame(timestamp, frame%25 == 0, 40))
+	}
+	return audio
+}
+
+// testBox MP4 박스 하나의 타입과 바디입니다.
+type testBox struct {
+	typ  string
+	body []byte
+}
+
+// parseTestBoxes b를 같은 단계의 박스 목록으로 나눕니다.
+func parseTestBoxes(t *testing.T, b []byte) []testBox {
+	t.Helper()
+	var boxes []testBox
+	for len(b) > 0 {
+		if len(b) < 8 {
+			t.Fatalf("truncated box header % x", b)
+		}
+		size, header := uint64(binary.BigEndian.Uint32(b)), uint64(8)
+		if size == 1 {
+			size, header = binary.BigEndian.Uint64(b[8:]), 16
+		}
+		if size < header || size > uint64(len(b)) {
+			t.Fatalf("box %q size %d, %d bytes left", b[4:8], size, len(b))
+		}
+		boxes = append(boxes, testBox{string(b[4:8]), b[header:size]})
+		b = b[size:]
+	}
+	return boxes
+}
+
+// findTestBoxes path를 따라 내려가 마지막 타입의 박스 바디를 모두 반환합니다. 중간 단계는 처음 만난 박스를 따라갑니다.
+func findTestBoxes(t *testing.T, b []byte, path ...string) [][]byte {
+	t.Helper()
+	for i, typ := range path {
+		var found [][]byte
+		for _, box := range parseTestBoxes(t, b) {
+			if box.typ == typ {
+				found = append(found, box.body)
+			}
+		}
+		if len(found) == 0 {
+			t.Fatalf("no %s box in %s", typ, strings.Join(path[:i], "/"))
+		}
+		if i == len(path)-1 {
+			return found
+		}
+		b = found[0]
+		// 샘플 테이블의 stsd는 풀 박스 헤더와 엔트리 수 뒤에 샘플 엔트리가 옵니다.
+		if typ == "stsd" {
+			b = b[8:]
+		}
+	}
+	return [][]byte{b}
+}
+
+func findTestBox(t *testing.T, b []byte, path ...string) []byte {
+	t.Helper()
+	return findTestBoxes(t, b, path...)[0]
+}
+
+// testSyncSampleFlags 키프레임 샘플의 trun 플래그입니다. (sample_depends_on = 2)
+const testSyncSampleFlags = 0x02000000
+
+// testTrunSample trun 박스의 샘플 하나입니다.
+type testTrunSample struct {
+	duration, size, flags uint32
+	cts                   int32
+}
+
+// parseTestFragment moof + mdat 세그먼트에서 시퀀스 번호, 기준 시간, 샘플과 mdat 페이로드를 읽습니다.
+func parseTestFragment(t *testing.T, b []byte) (sequence uint32, baseTime uint64, samples []testTrunSample, mdat []byte) {
+	t.Helper()
+	boxes := parseTestBoxes(t, b)
+	if len(boxes) != 2 || boxes[0].typ != "moof" || boxes[1].typ != "mdat" {
+		t.Fatalf("fragment boxes %v, want moof, mdat", boxes)
+	}
+	sequence = binary.BigEndian.Uint32(findTestBox(t, b, "moof", "mfhd")[4:])
+	baseTime = binary.BigEndian.Uint64(findTestBox(t, b, "moof", "traf", "tfdt")[4:])
+	trun := findTestBox(t, b, "moof", "traf", "trun")
+	count := int(binary.BigEndian.Uint32(trun[4:]))
+	// default-base-is-moof이므로 data_offset은 moof 시작부터 mdat 페이로드까지의 거리입니다.
+	if offset := binary.BigEndian.Uint32(trun[8:]); int(offset) != len(boxes[0].body)+8+8 {
+		t.Errorf("trun data_offset %d, want %d", offset, len(boxes[0].body)+16)
+	}
+	if len(trun) != 12+count*16 {
+		t.Fatalf("trun with %d samples is %d bytes", count, len(trun))
+	}
+	var total uint32
+	for i := 0; i < count; i++ {
+		e := trun[12+i*16:]
+		s := testTrunSample{binary.BigEndian.Uint32(e), binary.BigEndian.Uint32(e[4:]), binary.BigEndian.Uint32(e[8:]), int32(binary.BigEndian.Uint32(e[12:]))}
+		samples = append(samples, s)
+		total += s.size
+	}
+	mdat = boxes[1].body
+	if int(total) != len(mdat) {
+		t.Errorf("sample sizes add up to %d, mdat has %d bytes", total, len(mdat))
+	}
+	return
+}
+
+func readTestFile(t *testing.T, path string) []byte {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// testMPD 테스트에서 확인하는 MPD 필드입니다.
+type testMPD struct {
+	Type           string `xml:"type,attr"`
+	AdaptationSets []struct {
+		ContentType     string `xml:"contentType,attr"`
+		Representations []struct {
+			Codecs   string `xml:"codecs,attr"`
+			Template struct {
+				Timescale   uint32 `xml:"timescale,attr"`
+				StartNumber int    `xml:"startNumber,attr"`
+				Segments    []struct {
+					T uint64 `xml:"t,attr"`
+					D uint64 `xml:"d,attr"`
+				} `xml:"SegmentTimeline>S"`
+			} `xml:"SegmentTemplate"`
+		} `xml:"Representation"`
+	} `xml:"Period>AdaptationSet"`
+}
+
+func readTestMPD(t *testing.T, path string) testMPD {
+	t.Helper()
+	var mpd testMPD
+	if err := xml.Unmarshal(readTestFile(t, path), &mpd); err != nil {
+		t.Fatalf("invalid MPD: %s", err)
+	}
+	if len(mpd.AdaptationSets) != 2 || mpd.AdaptationSets[0].ContentType != "video" || mpd.AdaptationSets[1].ContentType != "audio" {
+		t.Fatalf("MPD adaptation sets %+v", mpd.AdaptationSets)
+	}
+	return mpd
+}
+
+func TestCMAFPackager(t *testing.T) {
+	base := t.TempDir()
+	p, err := newCMAFPackager(base, "live", "cmaf", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestGOPs(t, p, 3)
+	dir := filepath.Join(base, "live", "cmaf")
+
+	t.Run("init segments", func(t *testing.T) {
+		init := readTestFile(t, filepath.Join(dir, "init-video.mp4"))
+		if ftyp := findTestBox(t, init, "ftyp"); string(ftyp[:4]) != "iso6" || !bytes.Contains(ftyp, []byte("cmfc")) {
+			t.Errorf("ftyp %q", ftyp)
+		}
+		tkhd := findTestBox(t, init, "moov", "trak", "tkhd")
+		if id, w, h := binary.BigEndian.Uint32(tkhd[12:]), binary.BigEndian.Uint32(tkhd[76:])>>16, binary.BigEndian.Uint32(tkhd[80:])>>16; id != cmafVideoTrackID || w != 320 || h != 240 {
+			t.Errorf("tkhd track %d %dx%d", id, w, h)
+		}
+		if timescale := binary.BigEndian.Uint32(findTestBox(t, init, "moov", "trak", "mdia", "mdhd")[12:]); timescale != 1000 {
+			t.Errorf("video timescale %d", timescale)
+		}
+		if hdlr := findTestBox(t, init, "moov", "trak", "mdia", "hdlr"); string(hdlr[8:12]) != "vide" {
+			t.Errorf("video handler %q", hdlr[8:12])
+		}
+		avc1 := findTestBox(t, init, "moov", "trak", "mdia", "minf", "stbl", "stsd", "avc1")
+		if avcC := findTestBox(t, avc1[78:], "avcC"); !bytes.Equal(avcC, testAVCC) {
+			t.Errorf("avcC % x", avcC)
+		}
+		if trex := findTestBox(t, init, "moov", "mvex", "trex"); binary.BigEndian.Uint32(trex[4:]) != cmafVideoTrackID {
+			t.Errorf("trex track %d", binary.BigEndian.Uint32(trex[4:]))
+		}
+
+		init = readTestFile(t, filepath.Join(dir, "init-audio.mp4"))
+		if timescale := binary.BigEndian.Uint32(findTestBox(t, init, "moov", "trak", "mdia", "mdhd")[12:]); timescale != 44100 {
+			t.Errorf("audio timescale %d", timescale)
+		}
+		mp4a := findTestBox(t, init, "moov", "trak", "mdia", "minf", "stbl", "stsd", "mp4a")
+		if channels, rate := binary.BigEndian.Uint16(mp4a[16:]), binary.BigEndian.Uint32(mp4a[24:])>>16; channels != 2 || rate != 44100 {
+			t.Errorf("mp4a %d channels, %dHz", channels, rate)
+		}
+		if esds := findTestBox(t, mp4a[28:], "esds"); !bytes.HasSuffix(esds, append(append([]byte{0x05, 0x80, 0x80, 0x80, 0x02}, testASC...), 0x06, 0x80, 0x80, 0x80, 0x01, 0x02)) {
+			t.Errorf("esds does not carry the AudioSpecificConfig: % x", esds)
+		}
+	})
+
+	t.Run("media segments", func(t *testing.T) {
+		if _, err := os.Stat(filepath.Join(dir, "video-3.m4s")); !os.IsNotExist(err) {
+			t.Errorf("the last GOP was written before the next keyframe: %v", err)
+		}
+		audioTime := uint64(0)
+		for i, start := range []uint64{0, 1000} {
+			name := filepath.Join(dir, fmt.Sprintf("video-%d.m4s", i+1))
+			_, baseTime, samples, mdat := parseTestFragment(t, readTestFile(t, name))
+			if baseTime != start || len(samples) != 25 {
+				t.Fatalf("%s: base time %d, %d samples", name, baseTime, len(samples))
+			}
+			var duration uint32
+			for j, s := range samples {
+				duration += s.duration
+				if wantKey := j == 0; (s.flags == testSyncSampleFlags) != wantKey || s.cts != 40 {
+					t.Errorf("%s sample %d: flags %08x, cts %d", name, j, s.flags, s.cts)
+				}
+			}
+			if duration != 1000 {
+				t.Errorf("%s: duration %d, want 1000", name, duration)
+			}
+			// mdat은 길이가 붙은 NALU를 그대로 담습니다.
+			if want := testAVCFrame(uint32(start), true, 40).Payload(); !bytes.HasPrefix(mdat, want) {
+				t.Errorf("%s: mdat starts with % x, want % x", name, mdat[:len(want)], want)
+			}
+
+			name = filepath.Join(dir, fmt.Sprintf("audio-%d.m4s", i+1))
+			_, baseTime, samples, _ = parseTestFragment(t, readTestFile(t, name))
+			if baseTime != audioTime {
+				t.Errorf("%s: base time %d, want %d", name, baseTime, audioTime)
+			}
+			for _, s := range samples {
+				if s.duration != 1024 || s.size != 3 {
+					t.Errorf("%s: sample duration %d, size %d", name, s.duration, s.size)
+				}
+				audioTime += uint64(s.duration)
+			}
+			// 세그먼트 경계 전에 시작한 오디오 프레임만 담습니다.
+			if end := uint64(start+1000) * 44100 / 1000; audioTime < end || audioTime-end >= 1024 {
+				t.Errorf("%s: audio ends at %d, segment at %d", name, audioTime, end)
+			}
+		}
+	})
+
+	t.Run("playlists", func(t *testing.T) {
+		master := string(readTestFile(t, filepath.Join(dir, "index.m3u8")))
+		for _, want := range []string{`CODECS="avc1.42c01e,mp4a.40.2"`, "RESOLUTION=320x240", `AUDIO="audio"`, `URI="audio.m3u8"`, "\nvideo.m3u8\n"} {
+			if !strings.Contains(master, want) {
+				t.Errorf("master playlist does not contain %q:\n%s", want, master)
+			}
+		}
+		video := string(readTestFile(t, filepath.Join(dir, "video.m3u8")))
+		want := "#EXTM3U\n#EXT-X-VERSION:7\n#EXT-X-TARGETDURATION:1\n#EXT-X-MEDIA-SEQUENCE:1\n#EXT-X-MAP:URI=\"init-video.mp4\"\n" +
+			"#EXTINF:1.000,\nvideo-1.m4s\n#EXTINF:1.000,\nvideo-2.m4s\n"
+		if video != want {
+			t.Errorf("video playlist:\n%s\nwant:\n%s", video, want)
+		}
+		if audio := string(readTestFile(t, filepath.Join(dir, "audio.m3u8"))); !strings.Contains(audio, "#EXT-X-MAP:URI=\"init-audio.mp4\"\n#EXTINF:1.000,\naudio-1.m4s\n") {
+			t.Errorf("audio playlist:\n%s", audio)
+		}
+
+		mpd := readTestMPD(t, filepath.Join(dir, "index.mpd"))
+		if mpd.Type != "dynamic" {
+			t.Errorf("MPD type %q", mpd.Type)
+		}
+		video2 := mpd.AdaptationSets[0].Representations[0]
+		if video2.Codecs != "avc1.42c01e" || video2.Template.Timescale != 1000 || video2.Template.StartNumber != 1 ||
+			len(video2.Template.Segments) != 2 || video2.Template.Segments[1].T != 1000 || video2.Template.Segments[1].D != 1000 {
+			t.Errorf("video representation %+v", video2)
+		}
+		audio := mpd.AdaptationSets[1].Representations[0]
+		if s := audio.Template.Segments; audio.Template.Timescale != 44100 || len(s) != 2 || s[0].T != 0 || s[1].T != s[0].D {
+			t.Errorf("audio representation %+v", audio)
+		}
+	})
+
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	t.Run("close", func(t *testing.T) {
+		// 마지막 GOP는 Close에서 세그먼트로 쓰이고 플레이리스트가 끝납니다.
+		video := string(readTestFile(t, filepath.Join(dir, "video.m3u8")))
+		if !strings.Contains(video, "video-3.m4s\n") || !strings.HasSuffix(video, "#EXT-X-ENDLIST\n") {
+			t.Errorf("video playlist after close:\n%s", video)
+		}
+		if mpd := readTestMPD(t, filepath.Join(dir, "index.mpd")); mpd.Type != "static" || len(mpd.AdaptationSets[0].Representations[0].Template.Segments) != 3 {
+			t.Errorf("MPD after close %+v", mpd)
+		}
+	})
+}
+
+func TestCMAFPackagerRollover(t *testing.T) {
+	base := t.TempDir()
+	p, err := newCMAFPackager(base, "live", "rollover", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 19개 세그먼트를 쓰면 최근 cmafPlaylistSize개만 플레이리스트에 남고, cmafKeepSegments개 보다 오래된 파일은 지워집니다.
+	writeTestGOPs(t, p, 20)
+	dir := filepath.Join(base, "live", "rollover")
+
+	video := string(readTestFile(t, filepath.Join(dir, "video.m3u8")))
+	if !strings.Contains(video, "#EXT-X-MEDIA-SEQUENCE:14\n") || strings.Count(video, "#EXTINF") != cmafPlaylistSize || !strings.Contains(video, "video-19.m4s") {
+		t.Errorf("video playlist:\n%s", video)
+	}
+	for index := 1; index <= 19; index++ {
+		for _, kind := range []string{"video", "audio"} {
+			name := filepath.Join(dir, fmt.Sprintf("%s-%d.m4s", kind, index))
+			_, err := os.Stat(name)
+			if kept := index > 19-cmafKeepSegments; kept != (err == nil) {
+				t.Errorf("%s: kept %v, stat %v", filepath.Base(name), kept, err)
+			}
+		}
+	}
+	// 시퀀스 번호는 트랙에 상관없이 프래그먼트마다 늘어납니다.
+	seq, baseTime, _, _ := parseTestFragment(t, readTestFile(t, filepath.Join(dir, "video-19.m4s")))
+	if seq != 37 || baseTime != 18000 {
+		t.Errorf("video-19.m4s: sequence %d, base time %d", seq, baseTime)
+	}
+	mpd := readTestMPD(t, filepath.Join(dir, "index.mpd"))
+	if tmpl := mpd.AdaptationSets[0].Representations[0].Template; tmpl.StartNumber != 14 || len(tmpl.Segments) != cmafPlaylistSize || tmpl.Segments[0].T != 13000 {
+		t.Errorf("MPD video template %+v", tmpl)
+	}
+}
+
+func TestCMAFPackagerAudioOnly(t *testing.T) {
+	base := t.TempDir()
+	p, err := newCMAFPackager(base, "live", "radio", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.WritePacket(testAACSequenceHeader)
+	for n := 0; testAACTimestamp(n) < 5000; n++ {
+		if err := p.WritePacket(testAACFrame(testAACTimestamp(n))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dir := filepath.Join(base, "live", "radio")
+	if _, err := os.Stat(filepath.Join(dir, "init-video.mp4")); !os.IsNotExist(err) {
+		t.Errorf("init-video.mp4 for an audio-only stream: %v", err)
+	}
+	// 비디오가 없으면 cmafAudioOnlySegmentDuration마다 세그먼트를 나눕니다.
+	audio := string(readTestFile(t, filepath.Join(dir, "audio.m3u8")))
+	if strings.Count(audio, "#EXTINF") != 2 || !strings.Contains(audio, "audio-2.m4s") {
+		t.Errorf("audio playlist:\n%s", audio)
+	}
+	if master := string(readTestFile(t, filepath.Join(dir, "index.m3u8"))); !strings.Contains(master, `CODECS="mp4a.40.2"`) || !strings.Contains(master, "\naudio.m3u8\n") {
+		t.Errorf("master playlist:\n%s", master)
+	}
+}
@@ -0,0 +1,50 @@
+package aac
+
+import (
+	"errors"
+	"fmt"
+)
+
+/*
+	AAC
+	RTMP/FLV에서 AAC 오디오는 시퀀스 헤더로 AudioSpecificConfig(ISO 14496-3)를 먼저 보내고,
+	이후에는 ADTS 헤더가 없는 raw AAC 프레임을 전송합니다.
+*/
+
+var ErrInvalidConfig = errors.New("aac: invalid AudioSpecificConfig")
+
+var sampleRates = []int{96000, 88200, 64000, 48000, 44100, 32000, 24000, 22050, 16000, 12000, 11025, 8000, 7350}
+
+// SamplesPerFrame AAC-LC 한 프레임에 들어있는 샘플 수입니다.
+const SamplesPerFrame = 1024
+
+// Config AudioSpecificConfig에서 필요한 정보만 추출한 구조체입니다.
+type Config struct {
+	Record       []byte // 원본 AudioSpecificConfig 바이트 (fMP4의 esds 박스에 그대로 사용됩니다.)
+	ObjectType   uint8
+	SampleRate   int
+	ChannelCount int
+}
+
+// ParseConfig AudioSpecificConfig를 파싱합니다.
+func ParseConfig(b []byte) (*Config, error) {
+	if len(b) < 2 {
+		return nil, ErrInvalidConfig
+	}
+	conf := &Config{
+		Record:     b,
+		ObjectType: b[0] >> 3,
+	}
+	index := int((b[0]&0x07)<<1 | b[1]>>7)
+	if index >= len(sampleRates) {
+		return nil, ErrInvalidConfig
+	}
+	conf.SampleRate = sampleRates[index]
+	conf.ChannelCount = int((b[1] >> 3) & 0x0f)
+	return conf, nil
+}
+
+// Codec HLS/DASH 매니페스트에 사용하는 코덱 문자열을 반환합니다. (예: mp4a.40.2)
+func (conf *Config) Codec() string {
+	return fmt.Sprintf("mp4a.40.%d", conf.ObjectType)
+}
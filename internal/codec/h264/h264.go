@@ -0,0 +1,89 @@
+package h264
+
+import (
+	"errors"
+	"fmt"
+)
+
+/*
+	H.264 (AVC)
+	RTMP/FLV에서 H.264 영상은 시퀀스 헤더로 AVCDecoderConfigurationRecord를 먼저 보내고,
+	이후의 프레임은 길이(4바이트) + NALU 형식(AVCC)으로 전송됩니다.
+*/
+
+var ErrInvalidConfig = errors.New("h264: invalid AVCDecoderConfigurationRecord")
+
+// DecoderConfig AVCDecoderConfigurationRecord에서 필요한 정보만 추출한 구조체입니다.
+type DecoderConfig struct {
+	Record  []byte // 원본 avcC 바이트 (fMP4의 avcC 박스에 그대로 사용됩니다.)
+	Profile uint8
+	Compat  uint8
+	Level   uint8
+	SPS     [][]byte
+	PPS     [][]byte
+	Width   int
+	Height  int
+}
+
+// ParseDecoderConfig AVCDecoderConfigurationRecord를 파싱합니다.
+func ParseDecoderConfig(b []byte) (*DecoderConfig, error) {
+	if len(b) < 7 || b[0] != 1 {
+		return nil, ErrInvalidConfig
+	}
+	conf := &DecoderConfig{
+		Record:  b,
+		Profile: b[1],
+		Compat:  b[2],
+		Level:   b[3],
+	}
+
+	offset := 5
+	numSPS := int(b[offset] & 0x1f)
+	offset++
+	for i := 0; i < numSPS; i++ {
+		nalu, n, err := readParameterSet(b[offset:])
+		if err != nil {
+			return nil, err
+		}
+		conf.SPS = append(conf.SPS, nalu)
+		offset += n
+	}
+
+	if offset >= len(b) {
+		return nil, ErrInvalidConfig
+	}
+	numPPS := int(b[offset])
+	offset++
+	for i := 0; i < numPPS; i++ {
+		nalu, n, err := readParameterSet(b[offset:])
+		if err != nil {
+			return nil, err
+		}
+		conf.PPS = append(conf.PPS, nalu)
+		offset += n
+	}
+
+	if len(conf.SPS) > 0 {
+		if sps, err := ParseSPS(conf.SPS[0]); err == nil {
+			conf.Width = sps.Width
+			conf.Height = sps.Height
+		}
+	}
+	return conf, nil
+}
+
+func readParameterSet(b []byte) ([]byte, int, error) {
+	if len(b) < 2 {
+		return nil, 0, ErrInvalidConfig
+	}
+	size := int(b[0])<<8 | int(b[1])
+	if len(b) < 2+size {
+		return nil, 0, ErrInvalidConfig
+	}
+	return b[2 : 2+size], 2 + size, nil
+}
+
+// Codec HLS/DASH 매니페스트에 사용하는 코덱 문자열을 반환합니다. (예: avc1.64001f)
+func (conf *DecoderConfig) Codec() string {
+	return fmt.Sprintf("avc1.%02x%02x%02x", conf.Profile, conf.Compat, conf.Level)
+}
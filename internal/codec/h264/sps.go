@@ -0,0 +1,167 @@
+package h264
+
+import "errors"
+
+var ErrInvalidSPS = errors.New("h264: invalid SPS")
+
+// SPS Sequence Parameter Set에서 해상도 계산에 필요한 값만 담습니다.
+type SPS struct {
+	ProfileIDC uint
+	LevelIDC   uint
+	Width      int
+	Height     int
+}
+
+// ParseSPS SPS NALU(헤더 1바이트 포함)를 파싱하여 해상도를 계산합니다.
+func ParseSPS(nalu []byte) (sps *SPS, err error) {
+	if len(nalu) < 4 {
+		return nil, ErrInvalidSPS
+	}
+	defer func() {
+		// 비트 리더가 범위를 벗어나면 잘못된 SPS로 취급합니다.
+		if recover() != nil {
+			sps, err = nil, ErrInvalidSPS
+		}
+	}()
+
+	r := &bitReader{b: removeEmulationPrevention(nalu[1:])}
+	sps = &SPS{}
+	sps.ProfileIDC = r.bits(8)
+	r.bits(8) // constraint flags
+	sps.LevelIDC = r.bits(8)
+	r.ue() // seq_parameter_set_id
+
+	chromaFormatIDC := uint(1)
+	switch sps.ProfileIDC {
+	case 100, 110, 122, 244, 44, 83, 86, 118, 128, 138, 139, 134, 135:
+		chromaFormatIDC = r.ue()
+		if chromaFormatIDC == 3 {
+			r.bits(1) // separate_colour_plane_flag
+		}
+		r.ue()              // bit_depth_luma_minus8
+		r.ue()              // bit_depth_chroma_minus8
+		r.bits(1)           // qpprime_y_zero_transform_bypass_flag
+		if r.bits(1) == 1 { // seq_scaling_matrix_present_flag
+			count := 8
+			if chromaFormatIDC == 3 {
+				count = 12
+			}
+			for i := 0; i < count; i++ {
+				if r.bits(1) == 1 {
+					size := 16
+					if i >= 6 {
+						size = 64
+					}
+					skipScalingList(r, size)
+				}
+			}
+		}
+	}
+
+	r.ue() // log2_max_frame_num_minus4
+	pocType := r.ue()
+	if pocType == 0 {
+		r.ue() // log2_max_pic_order_cnt_lsb_minus4
+	} else if pocType == 1 {
+		r.bits(1)
+		r.se()
+		r.se()
+		n := r.ue()
+		for i := uint(0); i < n; i++ {
+			r.se()
+		}
+	}
+	r.ue()    // max_num_ref_frames
+	r.bits(1) // gaps_in_frame_num_value_allowed_flag
+
+	widthInMbs := r.ue() + 1
+	heightInMapUnits := r.ue() + 1
+	frameMbsOnly := r.bits(1)
+	if frameMbsOnly == 0 {
+		r.bits(1) // mb_adaptive_frame_field_flag
+	}
+	r.bits(1) // direct_8x8_inference_flag
+
+	var cropLeft, cropRight, cropTop, cropBottom uint
+	if r.bits(1) == 1 {
+		cropLeft, cropRight, cropTop, cropBottom = r.ue(), r.ue(), r.ue(), r.ue()
+	}
+
+	cropUnitX, cropUnitY := uint(1), 2-frameMbsOnly
+	if chromaFormatIDC == 1 {
+		cropUnitX, cropUnitY = 2, 2*(2-frameMbsOnly)
+	} else if chromaFormatIDC == 2 {
+		cropUnitX, cropUnitY = 2, 2-frameMbsOnly
+	}
+
+	sps.Width = int(widthInMbs*16 - cropUnitX*(cropLeft+cropRight))
+	sps.Height = int((2-frameMbsOnly)*heightInMapUnits*16 - cropUnitY*(cropTop+cropBottom))
+	return sps, nil
+}
+
+func skipScalingList(r *bitReader, size int) {
+	last, next := 8, 8
+	for j := 0; j < size; j++ {
+		if next != 0 {
+			next = (last + r.se() + 256) % 256
+		}
+		if next != 0 {
+			last = next
+		}
+	}
+}
+
+// removeEmulationPrevention NALU 안의 0x000003 시퀀스에서 0x03을 제거합니다.
+func removeEmulationPrevention(b []byte) []byte {
+	res := make([]byte, 0, len(b))
+	zeros := 0
+	for _, v := range b {
+		if zeros >= 2 && v == 0x03 {
+			zeros = 0
+			continue
+		}
+		if v == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+		res = append(res, v)
+	}
+	return res
+}
+
+type bitReader struct {
+	b   []byte
+	pos int
+}
+
+func (r *bitReader) bits(n int) uint {
+	var v uint
+	for i := 0; i < n; i++ {
+		bit := (r.b[r.pos/8] >> (7 - uint(r.pos%8))) & 1
+		v = v<<1 | uint(bit)
+		r.pos++
+	}
+	return v
+}
+
+// ue Exp-Golomb 부호 없는 정수를 읽습니다.
+func (r *bitReader) ue() uint {
+	zeros := 0
+	for r.bits(1) == 0 {
+		zeros++
+		if zeros > 31 {
+			panic(ErrInvalidSPS)
+		}
+	}
+	return (1 << uint(zeros)) - 1 + r.bits(zeros)
+}
+
+// se Exp-Golomb 부호 있는 정수를 읽습니다.
+func (r *bitReader) se() int {
+	v := r.ue()
+	if v&1 == 1 {
+		return int((v + 1) / 2)
+	}
+	return -int(v / 2)
+}
@@ -0,0 +1,352 @@
+package internal
+
+import (
+	"errors"
+	"example/hello/internal/yaml"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Config 서버 실행 파일의 설정입니다. 설정 파일(YAML), 환경 변수, 명령줄 플래그 순서로 덮어씁니다.
+//
+//	listen: [":1935"]
+//	http_listen: ":8080"
+//	admin:
+//	  listen: "127.0.0.1:8081"
+//	paths:
+//	  record: /data/record/
+//	rtmp:
+//	  chunk_size: 4096
+//	limits:
+//	  max_connections: 1000
+//	webhooks:
+//	  on_publish: http://auth.internal/rtmp/publish
+//	apps:
+//	  live:
+//	    cmaf: true
+//	    auth:
+//	      publish_keys: [secret-key]
+type Config struct {
+	Listen          []string      `yaml:"listen"`           // RTMP 리스닝 주소 목록
+	HTTPListen      string        `yaml:"http_listen"`      // HTTP-FLV, WebSocket-FLV, RTMPT 리스닝 주소 (비어 있으면 HTTP 서버를 띄우지 않음)
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"` // 종료 신호를 받은 뒤 녹화 마무리를 기다리는 최대 시간
+
+	Paths    PathConfig            `yaml:"paths"`
+	RTMP     RTMPConfig            `yaml:"rtmp"`
+	Limits   LimitConfig           `yaml:"limits"`
+	Timeouts TimeoutConfig         `yaml:"timeouts"`
+	Webhooks WebhookConfig         `yaml:"webhooks"` // 모든 app에 적용되는 웹훅 (app의 webhooks에 적힌 값이 우선)
+	Access   AccessConfig          `yaml:"access"`   // 클라이언트 주소 접근 규칙 (app의 access에 규칙이 있는 동작은 app 규칙이 우선)
+	Admin    AdminConfig           `yaml:"admin"`    // 관리 API, /metrics 리스너
+	Apps     map[string]*AppConfig `yaml:"apps"`
+
+	ProxyProtocol ProxyProtocolConfig `yaml:"proxy_protocol"` // 로드 밸런서 뒤에서 실행할 때 원래 클라이언트 주소를 받습니다.
+	TLS           TLSConfig           `yaml:"tls"`            // RTMPS 리스너
+	RTMPT         RTMPTConfig         `yaml:"rtmpt"`          // HTTP 리스너의 RTMPT(HTTP 터널)
+}
+
+// PathConfig 출력, 입력 파일 경로입니다.
+type PathConfig struct {
+	HLS    string `yaml:"hls"`    // ffmpeg 미리보기 HLS 출력 경로
+	CMAF   string `yaml:"cmaf"`   // CMAF 세그먼트, 매니페스트 출력 경로
+	Record string `yaml:"record"` // 녹화 파일 경로
+	VOD    string `yaml:"vod"`    // VOD 파일 경로
+}
+
+// RTMPConfig connect 직후 클라이언트에게 보내는 프로토콜 제어 값입니다.
+type RTMPConfig struct {
+	ChunkSize     uint32 `yaml:"chunk_size"`      // 서버가 보내는 청크 크기
+	WindowAckSize uint32 `yaml:"window_ack_size"` // 클라이언트가 이만큼 받을 때마다 Acknowledgement를 보냅니다.
+	PeerBandwidth uint32 `yaml:"peer_bandwidth"`  // 클라이언트의 출력 대역폭 제한 (바이트)
+}
+
+// LimitConfig 서버 자원 제한입니다. 0은 제한 없음입니다.
+type LimitConfig struct {
+	MaxConnections      int `yaml:"max_connections"`        // 동시에 받을 수 있는 RTMP 연결 수
+	MaxConnectionsPerIP int `yaml:"max_connections_per_ip"` // 클라이언트 IP 하나의 동시 RTMP 연결 수
+	MaxPublishersPerApp int `yaml:"max_publishers_per_app"` // app 하나에서 동시에 퍼블리시할 수 있는 스트림 수 (app의 max_publishers가 우선)
+	MaxViewersPerStream int `yaml:"max_viewers_per_stream"` // 스트림 하나의 동시 시청자 수 (RTMP, HTTP-FLV, WebSocket-FLV. app의 max_viewers가 우선)
+	// MaxOutgoingBandwidth 클라이언트에게 보내는 전체 전송량(바이트/초)입니다. 넘으면 새 시청자를 받지 않습니다.
+	MaxOutgoingBandwidth int64 `yaml:"max_outgoing_bandwidth"`
+}
+
+// AuthConfig app의 인증 설정입니다.
+type AuthConfig struct {
+	// PublishKeys 비어 있지 않으면 목록에 있는 스트림 키로만 퍼블리시할 수 있습니다.
+	PublishKeys []string `yaml:"publish_keys"`
+	// Token 서명된 만료 토큰으로 퍼블리시, 재생을 허용합니다.
+	Token TokenConfig `yaml:"token"`
+	// Connect connect 단계에서 요구할 인증 방식입니다. (adobe, llnw, 비어 있으면 사용하지 않음)
+	// 인코더만 접속하는 app에 설정합니다. 설정하면 재생 클라이언트도 인증해야 합니다.
+	Connect string `yaml:"connect"`
+	// Users connect 인증의 사용자 이름과 비밀번호입니다. 챌린지 응답을 계산하려면 원문 비밀번호가 필요합니다.
+	Users map[string]string `yaml:"users"`
+}
+
+// 청크 크기 범위입니다. 스펙상 최대값은 2^31-1 이지만, 너무 큰 값은 클라이언트 메모리를 낭비하므로 제한합니다.
+const (
+	minChunkSize = 128
+	maxChunkSize = 65536
+)
+
+// defaultRTMPConfig 설정하지 않은 값에 사용하는 기본값입니다.
+var defaultRTMPConfig = RTMPConfig{
+	ChunkSize:     4096,
+	WindowAckSize: 5000000, // 5MB
+	// 대역폭 값인 5000000은 5000000 바이트/초를 나타내고, 일반적으로 고화질 또는 고속 스트리밍에 적합한 대역폭 수준입니다.
+	PeerBandwidth: 5000000,
+}
+
+// DefaultConfig 설정 파일이 없을 때 사용하는 기본 설정입니다.
+func DefaultConfig() *Config {
+	return &Config{
+		Listen:          []string{":1935"},
+		HTTPListen:      ":8080",
+		ShutdownTimeout: 10 * time.Second,
+		Paths: PathConfig{
+			HLS:    "/hls-preview/",
+			CMAF:   "/cmaf-preview/",
+			Record: "/record/",
+			VOD:    "/vod/",
+		},
+		RTMP:     defaultRTMPConfig,
+		Timeouts: defaultTimeoutConfig,
+		Admin:    defaultAdminConfig,
+		Apps: map[string]*AppConfig{
+			"live": {Name: "live", CMAF: true},
+			"dvr":  {Name: "dvr", CMAF: true, DVRWindow: 30 * time.Minute},
+			// 설정 파일 없이 실행해도 임의의 app으로 퍼블리시, 재생할 수 있습니다.
+			WildcardAppName: {Name: WildcardAppName},
+		},
+	}
+}
+
+// LoadConfig path의 설정 파일을 기본 설정 위에 읽습니다. 파일에 없는 값은 기본값을 유지합니다.
+// apps를 적으면 기본 app 목록을 대신합니다.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := DefaultConfig()
+	cfg.Apps = nil
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if cfg.Apps == nil {
+		cfg.Apps = DefaultConfig().Apps
+	}
+	for name, app := range cfg.Apps {
+		if app == nil {
+			app = &AppConfig{}
+			cfg.Apps[name] = app
+		}
+		app.Name = name
+	}
+	return cfg, nil
+}
+
+// Validate 잘못된 값을 모두 찾아 하나의 에러로 반환합니다.
+func (cfg *Config) Validate() error {
+	var errs []error
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if len(cfg.Listen) == 0 {
+		fail("listen: at least one RTMP listen address is required")
+	}
+	for _, addr := range cfg.Listen {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			fail("listen: invalid address %q: %s", addr, err.Error())
+		}
+	}
+	if cfg.HTTPListen != "" {
+		if _, _, err := net.SplitHostPort(cfg.HTTPListen); err != nil {
+			fail("http_listen: invalid address %q: %s", cfg.HTTPListen, err.Error())
+		}
+	}
+	if cfg.ShutdownTimeout < 0 {
+		fail("shutdown_timeout: must not be negative")
+	}
+
+	for name, path := range map[string]string{"hls": cfg.Paths.HLS, "cmaf": cfg.Paths.CMAF, "record": cfg.Paths.Record, "vod": cfg.Paths.VOD} {
+		if path == "" {
+			fail("paths.%s: must not be empty", name)
+		}
+	}
+
+	if size := cfg.RTMP.ChunkSize; size < minChunkSize || size > maxChunkSize {
+		fail("rtmp.chunk_size: %d is out of range [%d, %d]", size, minChunkSize, maxChunkSize)
+	}
+	if cfg.RTMP.WindowAckSize == 0 {
+		fail("rtmp.window_ack_size: must be positive")
+	}
+	if cfg.RTMP.PeerBandwidth == 0 {
+		fail("rtmp.peer_bandwidth: must be positive")
+	}
+	for name, v := range map[string]int64{
+		"max_connections":        int64(cfg.Limits.MaxConnections),
+		"max_connections_per_ip": int64(cfg.Limits.MaxConnectionsPerIP),
+		"max_publishers_per_app": int64(cfg.Limits.MaxPublishersPerApp),
+		"max_viewers_per_stream": int64(cfg.Limits.MaxViewersPerStream),
+		"max_outgoing_bandwidth": cfg.Limits.MaxOutgoingBandwidth,
+	} {
+		if v < 0 {
+			fail("limits.%s: must not be negative", name)
+		}
+	}
+	if err := cfg.Timeouts.validate(); err != nil {
+		fail("timeouts.%w", err)
+	}
+	if err := cfg.Webhooks.validate(); err != nil {
+		fail("webhooks.%w", err)
+	}
+	if err := cfg.Access.validate(true); err != nil {
+		fail("access.%w", err)
+	}
+	if err := cfg.Admin.validate(cfg.HTTPListen); err != nil {
+		fail("admin.%w", err)
+	}
+	if err := cfg.ProxyProtocol.validate(); err != nil {
+		fail("proxy_protocol.%w", err)
+	}
+	for _, err := range cfg.TLS.validate() {
+		fail("tls.%w", err)
+	}
+	if err := cfg.RTMPT.validate(cfg.HTTPListen); err != nil {
+		fail("rtmpt.%w", err)
+	}
+
+	for name, app := range cfg.Apps {
+		for _, err := range app.validate() {
+			fail("apps.%s.%w", name, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validate app 설정 하나를 검사해 잘못된 값마다 에러를 반환합니다.
+func (conf *AppConfig) validate() []error {
+	var errs []error
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if conf.Name == "" || strings.Contains(conf.Name, "/") {
+		fail("name: invalid app name %q", conf.Name)
+	}
+	switch conf.RecordFormat {
+	case "", RecordFormatFLV, RecordFormatMP4, RecordFormatFMP4:
+	default:
+		fail("record_format: unknown format %q (flv, mp4, fmp4)", conf.RecordFormat)
+	}
+	for name, d := range map[string]time.Duration{"record_max_duration": conf.RecordMaxDuration, "dvr_window": conf.DVRWindow, "edge_idle_timeout": conf.EdgeIdleTimeout} {
+		if d < 0 {
+			fail("%s: must not be negative", name)
+		}
+	}
+	if conf.RecordMaxSize < 0 {
+		fail("record_max_size: must not be negative")
+	}
+	if conf.MaxPublishers < 0 {
+		fail("max_publishers: must not be negative")
+	}
+	if conf.MaxViewers < 0 {
+		fail("max_viewers: must not be negative")
+	}
+	for _, target := range conf.Push {
+		if err := validateRTMPURL(target); err != nil {
+			fail("push: %w", err)
+		}
+	}
+	for stream, targets := range conf.StreamPush {
+		for _, target := range targets {
+			if err := validateRTMPURL(target); err != nil {
+				fail("stream_push.%s: %w", stream, err)
+			}
+		}
+	}
+	for _, origin := range conf.Origins {
+		if err := validateRTMPURL(origin); err != nil {
+			fail("origins: %w", err)
+		}
+	}
+	if err := conf.Webhooks.validate(); err != nil {
+		fail("webhooks.%w", err)
+	}
+	if err := conf.Access.validate(false); err != nil {
+		fail("access.%w", err)
+	}
+	if err := conf.Auth.Token.validate(); err != nil {
+		fail("auth.token.%w", err)
+	}
+	switch conf.Auth.Connect {
+	case "":
+	case ConnectAuthAdobe, ConnectAuthLLNW:
+		if len(conf.Auth.Users) == 0 {
+			fail("auth.users: at least one user is required when connect auth is enabled")
+		}
+	default:
+		fail("auth.connect: unknown method %q (adobe, llnw)", conf.Auth.Connect)
+	}
+	if conf.VOD && (len(conf.Origins) > 0 || conf.DVRWindow > 0) {
+		fail("vod: cannot be combined with origins or dvr_window")
+	}
+	return errs
+}
+
+func validateRTMPURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "rtmp" && u.Scheme != "rtmps") || u.Host == "" {
+		return fmt.Errorf("%q is not an rtmp:// or rtmps:// URL", s)
+	}
+	return nil
+}
+
+// NewStreamContext cfg로 스트림 컨텍스트를 만듭니다.
+// 같은 프로세스에서 여러 서버를 실행할 수 있도록 출력 경로 등은 컨텍스트마다 따로 가집니다.
+func NewStreamContext(cfg *Config) *StreamContext {
+	previewHost := "localhost"
+	if len(cfg.Listen) > 0 {
+		if _, port, err := net.SplitHostPort(cfg.Listen[0]); err == nil {
+			previewHost = net.JoinHostPort("localhost", port)
+		}
+	}
+
+	return &StreamContext{
+		Sessions:    make(map[string]*Connection),
+		Paths:       cfg.Paths,
+		HTTPListen:  cfg.HTTPListen,
+		previewHost: previewHost,
+		Apps:        cfg.Apps,
+		RTMP:        cfg.RTMP,
+		Limits:      cfg.Limits,
+		Timeouts:    cfg.Timeouts,
+		Webhooks:    cfg.Webhooks,
+		Access:      cfg.Access,
+		Admin:       cfg.Admin,
+		Proxy:       cfg.ProxyProtocol,
+		config:      cfg,
+	}
+}
+
+// allowsPublish 퍼블리시 키 목록이 있으면 stream이 목록에 있는지 확인합니다.
+func (auth *AuthConfig) allowsPublish(stream string) bool {
+	if len(auth.PublishKeys) == 0 {
+		return true
+	}
+	for _, key := range auth.PublishKeys {
+		if key == stream {
+			return true
+		}
+	}
+	return false
+}
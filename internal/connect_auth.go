@@ -0,0 +1,165 @@
+package internal
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// connect 인증 방식입니다. (FMLE, ffmpeg, OBS 등 librtmp 계열 인코더가 지원하는 방식)
+const (
+	ConnectAuthAdobe = "adobe"
+	ConnectAuthLLNW  = "llnw" // Limelight
+)
+
+// connectChallengeTimeout 발급한 챌린지로 다시 connect 해야 하는 시간입니다.
+const connectChallengeTimeout = time.Minute
+
+// llnw 방식에서 인코더가 고정값으로 사용하는 값입니다.
+const (
+	llnwRealm  = "live"
+	llnwMethod = "publish"
+	llnwQop    = "auth"
+)
+
+// connectChallenge needauth 응답으로 발급한 챌린지입니다. 한 번만 사용할 수 있습니다.
+type connectChallenge struct {
+	authmod string
+	app     string
+	user    string
+	salt    string // adobe
+	expires time.Time
+}
+
+// connectAuth app이 connect 인증을 요구하면 tcUrl/app 쿼리의 인증 파라미터를 확인합니다.
+// 인증이 끝나지 않았으면 인코더가 다음 단계로 진행할 수 있도록 NetConnection.Connect.Rejected의 description을 반환합니다.
+//
+//  1. 인증 파라미터가 없거나 authmod가 app 설정과 다름: "code=403 need auth; authmod=adobe"
+//     → 인코더가 ?authmod=adobe&user=.. 를 붙여 다시 connect
+//  2. authmod, user만 있음: "?reason=needauth&user=..&salt=..&challenge=..&opaque=.." → 인코더가 응답을 계산해 다시 connect
+//  3. response 있음: 검증에 성공하면 통과, 실패하면 "?reason=authfailed"
+func (c *Connection) connectAuth(query url.Values) (description string, ok bool) {
+	conf := c.Context.app(c.AppName).Auth
+	if conf.Connect == "" {
+		return "", true
+	}
+
+	// app에 설정한 방식이 아닌 authmod는 받지 않고, 설정한 방식으로 다시 시작하도록 알립니다.
+	authmod, user := query.Get("authmod"), query.Get("user")
+	if authmod != conf.Connect || user == "" {
+		return fmt.Sprintf("[ AccessManager.Reject ] : [ code=403 need auth; authmod=%s ] : ", conf.Connect), false
+	}
+	password, known := conf.Users[user]
+	if !known {
+		log.Printf("Connect auth failed for app %s: no such user %q", c.AppName, user)
+		return fmt.Sprintf("[ AccessManager.Reject ] : [ authmod=%s ] : ?reason=nosuchuser", authmod), false
+	}
+
+	if query.Get("response") == "" {
+		return c.Context.issueChallenge(authmod, c.AppName, user), false
+	}
+
+	var key string
+	if authmod == ConnectAuthAdobe {
+		key = query.Get("opaque")
+	} else {
+		key = query.Get("nonce")
+	}
+	ch, found := c.Context.takeChallenge(key)
+	if found && ch.authmod == authmod && ch.app == c.AppName && ch.user == user {
+		// 일부 인코더는 base64 응답의 +를 그대로 보내므로, 쿼리 해석에서 공백으로 바뀐 것을 되돌립니다.
+		response := strings.ReplaceAll(query.Get("response"), " ", "+")
+		var expected string
+		if authmod == ConnectAuthAdobe {
+			expected = adobeResponse(user, ch.salt, password, key, query.Get("challenge"))
+		} else {
+			expected = llnwResponse(user, password, c.AppName, key, query.Get("nc"), query.Get("cnonce"))
+		}
+		if subtle.ConstantTimeCompare([]byte(response), []byte(expected)) == 1 {
+			log.Printf("Connect auth succeeded for app %s, user %s (%s)", c.AppName, user, authmod)
+			return "", true
+		}
+	}
+	log.Printf("Connect auth failed for app %s, user %s (%s)", c.AppName, user, authmod)
+	if authmod == ConnectAuthAdobe {
+		return fmt.Sprintf("[ AccessManager.Reject ] : [ authmod=adobe ] : ?reason=authfailed&opaque=%s", key), false
+	}
+	return "[ AccessManager.Reject ] : [ authmod=llnw ] : ?reason=authfailed", false
+}
+
+// issueChallenge 새 챌린지를 저장하고 needauth description을 만듭니다.
+func (ctx *StreamContext) issueChallenge(authmod, app, user string) string {
+	ch := &connectChallenge{authmod: authmod, app: app, user: user, expires: time.Now().Add(connectChallengeTimeout)}
+	key := randomHex(8)
+	var description string
+	if authmod == ConnectAuthAdobe {
+		// 인코더는 opaque가 있으면 challenge 대신 opaque를 응답 계산에 사용합니다.
+		ch.salt = randomHex(8)
+		description = fmt.Sprintf("[ AccessManager.Reject ] : [ authmod=adobe ] : ?reason=needauth&user=%s&salt=%s&challenge=%s&opaque=%s", user, ch.salt, key, key)
+	} else {
+		description = fmt.Sprintf("[ AccessManager.Reject ] : [ authmod=llnw ] : ?reason=needauth&user=%s&nonce=%s", user, key)
+	}
+
+	ctx.challengeMu.Lock()
+	defer ctx.challengeMu.Unlock()
+	if ctx.challenges == nil {
+		ctx.challenges = make(map[string]*connectChallenge)
+	}
+	now := time.Now()
+	for k, old := range ctx.challenges {
+		if now.After(old.expires) {
+			delete(ctx.challenges, k)
+		}
+	}
+	ctx.challenges[key] = ch
+	return description
+}
+
+// takeChallenge 만료되지 않은 챌린지를 꺼내고 지웁니다.
+func (ctx *StreamContext) takeChallenge(key string) (*connectChallenge, bool) {
+	ctx.challengeMu.Lock()
+	defer ctx.challengeMu.Unlock()
+	ch, ok := ctx.challenges[key]
+	if !ok {
+		return nil, false
+	}
+	delete(ctx.challenges, key)
+	return ch, time.Now().Before(ch.expires)
+}
+
+// adobeResponse base64(md5(base64(md5(user + salt + password)) + opaque + challenge2))
+func adobeResponse(user, salt, password, opaque, challenge2 string) string {
+	hash := md5.Sum([]byte(user + salt + password))
+	hash = md5.Sum([]byte(base64.StdEncoding.EncodeToString(hash[:]) + opaque + challenge2))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// llnwResponse HTTP Digest와 같은 방식입니다. md5(HA1:nonce:nc:cnonce:qop:HA2)
+// HA2의 경로는 인코더(ffmpeg, librtmp)와 같이 app 전체를 사용하고, app에 인스턴스가 없으면 /_definst_를 붙입니다.
+func llnwResponse(user, password, app, nonce, nc, cnonce string) string {
+	ha1 := md5Hex(user + ":" + llnwRealm + ":" + password)
+	path := app
+	if !strings.Contains(app, "/") {
+		path += "/_definst_"
+	}
+	ha2 := md5Hex(llnwMethod + ":/" + path)
+	return md5Hex(strings.Join([]string{ha1, nonce, nc, cnonce, llnwQop, ha2}, ":"))
+}
+
+func md5Hex(s string) string {
+	hash := md5.Sum([]byte(s))
+	return hex.EncodeToString(hash[:])
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,178 @@
+package internal
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// connectWithAuth ffmpeg처럼 인증 파라미터(authParams)를 app과 tcUrl에 붙여 connect 합니다.
+func connectWithAuth(t *testing.T, addr, app, authParams string) error {
+	t.Helper()
+	ctx := contextWithTestTimeout(t)
+	client, err := DialRTMP(ctx, "rtmp://"+addr+"/"+app+"/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	client.App += authParams
+	client.TcURL += authParams
+	return client.Connect(ctx)
+}
+
+// connectRejection connect가 NetConnection.Connect.Rejected로 거절되었는지 확인하고 description을 반환합니다.
+func connectRejection(t *testing.T, err error) string {
+	t.Helper()
+	var status *RTMPStatusError
+	if !errors.As(err, &status) || status.Code != "NetConnection.Connect.Rejected" {
+		t.Fatalf("error %v, want NetConnection.Connect.Rejected", err)
+	}
+	return status.Description
+}
+
+// needAuthParams ffmpeg처럼 description의 "?reason=needauth" 뒤의 파라미터를 읽습니다.
+func needAuthParams(t *testing.T, description string) url.Values {
+	t.Helper()
+	_, rawQuery, ok := strings.Cut(description, "?reason=needauth")
+	if !ok {
+		t.Fatalf("description %q has no needauth parameters", description)
+	}
+	params := url.Values{}
+	for _, kv := range strings.Split(strings.TrimPrefix(rawQuery, "&"), "&") {
+		k, v, _ := strings.Cut(kv, "=")
+		params.Set(k, v)
+	}
+	return params
+}
+
+// ffmpegAdobeAuth ffmpeg(rtmpproto.c do_adobe_auth)와 같은 방식으로 응답 파라미터를 만듭니다.
+func ffmpegAdobeAuth(user, password string, params url.Values) string {
+	challenge2 := fmt.Sprintf("%08x", rand.Uint32())
+	hash := md5.Sum([]byte(user + params.Get("salt") + password))
+	salted := base64.StdEncoding.EncodeToString(hash[:])
+	key := params.Get("opaque")
+	if key == "" {
+		key = params.Get("challenge")
+	}
+	hash = md5.Sum([]byte(salted + key + challenge2))
+	response := base64.StdEncoding.EncodeToString(hash[:])
+	authParams := fmt.Sprintf("?authmod=adobe&user=%s&challenge=%s&response=%s", user, challenge2, response)
+	if opaque := params.Get("opaque"); opaque != "" {
+		authParams += "&opaque=" + opaque
+	}
+	return authParams
+}
+
+// ffmpegLLNWAuth ffmpeg(rtmpproto.c do_llnw_auth)와 같은 방식으로 응답 파라미터를 만듭니다.
+func ffmpegLLNWAuth(user, password, app string, params url.Values) string {
+	md5Hex := func(s string) string {
+		hash := md5.Sum([]byte(s))
+		return hex.EncodeToString(hash[:])
+	}
+	nc := fmt.Sprintf("%08x", 1)
+	cnonce := fmt.Sprintf("%08x", rand.Uint32())
+	nonce := params.Get("nonce")
+	ha1 := md5Hex(user + ":live:" + password)
+	path := app
+	if !strings.Contains(app, "/") {
+		path += "/_definst_"
+	}
+	ha2 := md5Hex("publish:/" + path)
+	response := md5Hex(ha1 + ":" + nonce + ":" + nc + ":" + cnonce + ":auth:" + ha2)
+	return fmt.Sprintf("?authmod=llnw&user=%s&nonce=%s&cnonce=%s&nc=%s&response=%s", user, nonce, cnonce, nc, response)
+}
+
+func connectAuthTestServer(t *testing.T) string {
+	t.Helper()
+	users := map[string]string{"alice": "s3cret"}
+	_, addr := startTestServer(t, map[string]*AppConfig{
+		"adobe": {Auth: AuthConfig{Connect: ConnectAuthAdobe, Users: users}},
+		"llnw":  {Auth: AuthConfig{Connect: ConnectAuthLLNW, Users: users}},
+	})
+	return addr
+}
+
+func TestConnectAuthAdobe(t *testing.T) {
+	addr := connectAuthTestServer(t)
+
+	description := connectRejection(t, connectWithAuth(t, addr, "adobe", ""))
+	if !strings.Contains(description, "code=403 need auth; authmod=adobe") {
+		t.Fatalf("first connect: %q", description)
+	}
+	description = connectRejection(t, connectWithAuth(t, addr, "adobe", "?authmod=adobe&user=alice"))
+	params := needAuthParams(t, description)
+	if !strings.HasPrefix(description, "[ AccessManager.Reject ] : [ authmod=adobe ] : ?reason=needauth&") ||
+		params.Get("user") != "alice" || params.Get("salt") == "" || params.Get("challenge") == "" || params.Get("opaque") == "" {
+		t.Fatalf("needauth: %q", description)
+	}
+	authParams := ffmpegAdobeAuth("alice", "s3cret", params)
+	if err := connectWithAuth(t, addr, "adobe", authParams); err != nil {
+		t.Fatalf("connect with response: %s", err)
+	}
+
+	// 챌린지는 한 번만 사용할 수 있습니다.
+	description = connectRejection(t, connectWithAuth(t, addr, "adobe", authParams))
+	if !strings.Contains(description, "[ authmod=adobe ] : ?reason=authfailed&opaque="+params.Get("opaque")) {
+		t.Errorf("replayed response: %q", description)
+	}
+	params = needAuthParams(t, connectRejection(t, connectWithAuth(t, addr, "adobe", "?authmod=adobe&user=alice")))
+	description = connectRejection(t, connectWithAuth(t, addr, "adobe", ffmpegAdobeAuth("alice", "wrong", params)))
+	if !strings.Contains(description, "?reason=authfailed") {
+		t.Errorf("wrong password: %q", description)
+	}
+}
+
+func TestConnectAuthLLNW(t *testing.T) {
+	addr := connectAuthTestServer(t)
+
+	description := connectRejection(t, connectWithAuth(t, addr, "llnw", ""))
+	if !strings.Contains(description, "code=403 need auth; authmod=llnw") {
+		t.Fatalf("first connect: %q", description)
+	}
+	description = connectRejection(t, connectWithAuth(t, addr, "llnw", "?authmod=llnw&user=alice"))
+	params := needAuthParams(t, description)
+	if !strings.HasPrefix(description, "[ AccessManager.Reject ] : [ authmod=llnw ] : ?reason=needauth&") ||
+		params.Get("user") != "alice" || params.Get("nonce") == "" {
+		t.Fatalf("needauth: %q", description)
+	}
+	if err := connectWithAuth(t, addr, "llnw", ffmpegLLNWAuth("alice", "s3cret", "llnw", params)); err != nil {
+		t.Fatalf("connect with response: %s", err)
+	}
+
+	params = needAuthParams(t, connectRejection(t, connectWithAuth(t, addr, "llnw", "?authmod=llnw&user=alice")))
+	description = connectRejection(t, connectWithAuth(t, addr, "llnw", ffmpegLLNWAuth("alice", "wrong", "llnw", params)))
+	if description != "[ AccessManager.Reject ] : [ authmod=llnw ] : ?reason=authfailed" {
+		t.Errorf("wrong password: %q", description)
+	}
+}
+
+func TestConnectAuthRejects(t *testing.T) {
+	addr := connectAuthTestServer(t)
+
+	description := connectRejection(t, connectWithAuth(t, addr, "adobe", "?authmod=adobe&user=mallory"))
+	if description != "[ AccessManager.Reject ] : [ authmod=adobe ] : ?reason=nosuchuser" {
+		t.Errorf("unknown user: %q", description)
+	}
+	// app에 설정한 방식과 다른 authmod는 설정한 방식으로 다시 시작하게 합니다.
+	description = connectRejection(t, connectWithAuth(t, addr, "adobe", "?authmod=llnw&user=alice"))
+	if !strings.Contains(description, "code=403 need auth; authmod=adobe") {
+		t.Errorf("llnw on an adobe app: %q", description)
+	}
+	description = connectRejection(t, connectWithAuth(t, addr, "llnw", "?authmod=adobe&user=alice"))
+	if !strings.Contains(description, "code=403 need auth; authmod=llnw") {
+		t.Errorf("adobe on an llnw app: %q", description)
+	}
+	// 다른 app에서 받은 챌린지로는 인증할 수 없습니다.
+	params := needAuthParams(t, connectRejection(t, connectWithAuth(t, addr, "llnw", "?authmod=llnw&user=alice")))
+	authParams := ffmpegLLNWAuth("alice", "s3cret", "llnw", params)
+	description = connectRejection(t, connectWithAuth(t, addr, "adobe", strings.Replace(authParams, "authmod=llnw", "authmod=adobe", 1)))
+	if !strings.Contains(description, "?reason=authfailed") {
+		t.Errorf("challenge from another app: %q", description)
+	}
+}
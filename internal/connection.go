@@ -3,6 +3,7 @@ package internal
 import (
 	"bufio"
 	"encoding/binary"
+	"errors"
 	"example/hello/internal/amf"
 	"example/hello/internal/format/flvio"
 	"example/hello/internal/util/endian"
@@ -10,6 +11,11 @@ import (
 	"io"
 	"log"
 	"net"
+	"net/url"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type Connection struct {
@@ -31,14 +37,60 @@ type Connection struct {
 	GotFirstAudio    bool
 	GotFirstVideo    bool
 
+	// tcURL connect 명령의 tcUrl 입니다.
+	tcURL string
+	// streamQuery 퍼블리시, 재생한 스트림 이름의 쿼리 파라미터입니다. (key?token=..)
+	streamQuery url.Values
+	// playName 재생 중인 스트림 이름입니다. 재생이 시작된 뒤에만 설정하며, 연결이 끝나면 on_play_done 웹훅으로 알립니다.
+	playName string
+
 	FirstAudio []byte
 	FirstVideo []byte
-	GOP        [][]byte
 
-	Clients        []Channel
-	WaitingClients []Channel
+	// Hub 퍼블리셔일 때 시청자, 패키저 등에게 패킷을 나누어 주는 허브입니다.
+	Hub *StreamHub
+	// playSubscription 시청자일 때 퍼블리셔 허브에 대한 구독입니다.
+	playSubscription *Subscription
+	// recording 녹화 중일 때 녹화기의 구독입니다.
+	recording *Subscription
+	recordMu  sync.Mutex
+	// dvr 퍼블리셔일 때 타임시프트 재생을 위한 버퍼입니다. (DVRWindow가 설정된 app만)
+	dvr *dvrBuffer
+	// edge 오리진에서 받아온 스트림의 가상 퍼블리셔일 때의 엣지 풀입니다.
+	edge *edgePull
+	// relays 퍼블리셔일 때 다른 서버로 스트림을 보내는 푸시 릴레이 목록입니다.
+	relays []*pushRelay
+	// vod 파일 재생(VOD) 또는 타임시프트 재생 중일 때의 플레이어입니다.
+	vod *vodPlayer
 
 	ConnectionStatus *ConnectionStatus
+	// client 다른 서버에 접속한 클라이언트 연결일 때 받은 메시지를 처리합니다.
+	client *RTMPClient
+
+	// publishStreamID, playStreamID 퍼블리시, 재생 중인 메시지 스트림 ID 입니다. (0이면 없음)
+	// 서버 종료 시 다른 고루틴에서 읽으므로 atomic을 사용합니다.
+	publishStreamID atomic.Uint32
+	playStreamID    atomic.Uint32
+
+	// timeouts 연결을 받을 때의 시간 제한입니다. (다른 서버에 접속한 클라이언트 연결은 제한 없음)
+	timeouts TimeoutConfig
+	// lastMedia 퍼블리셔가 마지막으로 오디오, 비디오를 보낸 시각입니다.
+	lastMedia time.Time
+	// viewers 퍼블리셔일 때 시청자 수입니다. (RTMP, HTTP-FLV, WebSocket-FLV)
+	viewers atomic.Int32
+	// viewing 시청자일 때 시청 중인 스트림의 퍼블리셔입니다. 연결이 끝나면 시청자 수에서 뺍니다.
+	viewing *Connection
+
+	// pendingMessageSize 여러 청크 스트림으로 조립 중인 메시지 길이의 합입니다. (maxPendingMessageSize까지)
+	pendingMessageSize int
+
+	// pingStop 시청자에게 Ping Request를 보내는 고루틴을 멈춥니다. (재생 중일 때만)
+	pingStop chan struct{}
+
+	// shuttingDown 서버 종료 알림을 보내는 중입니다.
+	shuttingDown atomic.Bool
+
+	writeMu sync.Mutex
 }
 
 type ConnectionStatus struct {
@@ -48,14 +100,8 @@ type ConnectionStatus struct {
 	GotMessage            bool
 }
 
-type Channel struct {
-	ChannelID int64
-	Send      chan []byte
-	Exit      chan bool
-}
-
 func NewConnection(conn net.Conn, ctx *StreamContext) *Connection {
-	return &Connection{
+	c := &Connection{
 		Conn:              conn,
 		Reader:            bufio.NewReader(conn),
 		Writer:            bufio.NewWriter(conn),
@@ -68,13 +114,33 @@ func NewConnection(conn net.Conn, ctx *StreamContext) *Connection {
 		Context:           ctx,
 		ConnectionStatus:  &ConnectionStatus{},
 	}
+	if ctx != nil {
+		c.timeouts = ctx.timeouts()
+	}
+	return c
 }
 
 // Serve RTMP 연결을 처리합니다. Handshake, Connection Prepare, Connection Complete, Message 처리를 수행합니다.
 func (c *Connection) Serve() (err error) {
+	defer c.close()
+	defer func() {
+		switch {
+		case err == nil:
+		case isTimeout(err):
+			c.onTimeout(c.readTimeoutKind())
+		case errors.Is(err, errProtocol):
+			c.onProtocolError(err)
+		}
+	}()
+
+	if c.timeouts.Handshake > 0 {
+		c.Conn.SetDeadline(time.Now().Add(c.timeouts.Handshake))
+	}
 	if err = c.handshake(); err != nil {
 		return
 	}
+	// 이후의 읽기, 쓰기 제한 시간은 메시지마다 설정합니다.
+	c.Conn.SetDeadline(time.Time{})
 
 	if err = c.prepareConnection(); err != nil {
 		return
@@ -152,6 +218,136 @@ func (c *Connection) handshake() (err error) {
 	return
 }
 
+// close 연결이 끝났을 때 퍼블리셔 세션과 시청자 구독을 정리합니다.
+func (c *Connection) close() {
+	c.Conn.Close()
+	if c.playSubscription != nil {
+		c.playSubscription.Close()
+	}
+	if c.viewing != nil {
+		c.Context.removeViewer(c.viewing)
+	}
+	if c.vod != nil {
+		c.vod.Close()
+	}
+	if c.pingStop != nil {
+		close(c.pingStop)
+	}
+	if c.Context != nil && c.Context.Hooks != nil {
+		c.Context.Hooks.OnClose(c)
+	}
+	if c.playName != "" {
+		c.callWebhookAsync(webhookPlayDone, c.playName, c.streamQuery)
+	}
+	if c.Hub != nil {
+		c.callWebhookAsync(webhookPublishDone, c.StreamKey, c.streamQuery)
+		c.Context.remove(sessionKey(c.AppName, c.StreamKey), c)
+		c.Context.Metrics.AddGauge("rtmp_publishers", -1, "app", c.AppName)
+		// 허브를 닫으면 녹화기, 패키저 구독도 남은 패킷을 처리한 뒤 마무리됩니다.
+		c.Hub.Close()
+		log.Printf("Publisher closed for stream key %s", c.StreamKey)
+	}
+}
+
+// writeMessage 청크로 나눈 메시지를 쓰고 Flush 합니다.
+// 시청자에게 미디어를 보내는 고루틴과 명령에 응답하는 고루틴이 동시에 쓸 수 있으므로 잠금을 사용합니다.
+func (c *Connection) writeMessage(chunk *rtmpChunk) (err error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if timeout := c.writeTimeout(); timeout > 0 {
+		c.Conn.SetWriteDeadline(time.Now().Add(timeout))
+		defer func() {
+			// 받지 않는 클라이언트에게 더 쓰지 않도록 연결을 닫습니다. 종료 알림 중에는 Shutdown이 닫습니다.
+			if err != nil && isTimeout(err) && !c.shuttingDown.Load() {
+				c.onTimeout(timeoutWrite)
+			}
+		}()
+	}
+	for _, ch := range c.create(chunk) {
+		if _, err = c.Writer.Write(ch); err != nil {
+			return
+		}
+		if c.Context != nil {
+			c.Context.outgoing.Add(len(ch))
+		}
+	}
+	return c.Writer.Flush()
+}
+
+// User Control Message 이벤트 타입입니다.
+const (
+	userControlStreamBegin      = 0
+	userControlStreamEOF        = 1
+	userControlStreamDry        = 2
+	userControlStreamIsRecorded = 4
+	userControlPingRequest      = 6
+	userControlPingResponse     = 7
+)
+
+// sendUserControl 스트림 ID(Ping은 타임스탬프)를 인자로 가지는 User Control Message(타입 4)를 보냅니다.
+func (c *Connection) sendUserControl(event uint16, streamID uint32) error {
+	b := make([]byte, 6)
+	binary.BigEndian.PutUint16(b[:2], event)
+	binary.BigEndian.PutUint32(b[2:], streamID)
+	return c.writeMessage(&rtmpChunk{
+		header: &chunkHeader{
+			fmt:         0,
+			csID:        2,
+			messageType: 4,
+			length:      uint32(len(b)),
+		},
+		payload: b,
+	})
+}
+
+// handleUserControl 상대가 보낸 Ping Request에 같은 타임스탬프로 Ping Response를 보냅니다.
+func (c *Connection) handleUserControl(payload []byte) {
+	if len(payload) >= 6 && binary.BigEndian.Uint16(payload) == userControlPingRequest {
+		c.sendUserControl(userControlPingResponse, binary.BigEndian.Uint32(payload[2:]))
+	}
+}
+
+// sendStatus onStatus 명령으로 NetStream 상태(NetStream.Play.Start 등)를 알립니다.
+func (c *Connection) sendStatus(streamID uint32, level, code, description string) error {
+	info := flvio.AMFMap{
+		"level":       level,
+		"code":        code,
+		"description": description,
+	}
+	amfPayload, length := amf.Encode("onStatus", 0, nil, info)
+	return c.writeMessage(&rtmpChunk{
+		header: &chunkHeader{
+			fmt:             0,
+			csID:            3,
+			messageType:     20,
+			messageStreamID: streamID,
+			length:          uint32(length),
+		},
+		payload: amfPayload,
+	})
+}
+
+// sendPlayStatus onPlayStatus 데이터 메시지로 재생 상태(NetStream.Play.Complete 등)를 알립니다.
+func (c *Connection) sendPlayStatus(streamID uint32, code string, duration float64, bytes int64) error {
+	info := flvio.AMFMap{
+		"level":    "status",
+		"code":     code,
+		"duration": duration,
+		"bytes":    bytes,
+	}
+	amfPayload, length := amf.Encode("onPlayStatus", info)
+	return c.writeMessage(&rtmpChunk{
+		header: &chunkHeader{
+			fmt:             0,
+			csID:            6,
+			messageType:     18,
+			messageStreamID: streamID,
+			length:          uint32(length),
+		},
+		payload: amfPayload,
+	})
+}
+
 //func (c *Connection) handshake() (err error) {
 //	err = handshake.NewHandShake(c.Conn).Handshake()
 //	if err != nil {
@@ -196,6 +392,7 @@ func (c *Connection) a() (err error) {
 // readMessage RTMP 메시지를 읽어들입니다.
 func (c *Connection) readMessage() (err error) {
 	c.ConnectionStatus.GotMessage = false
+	c.setReadDeadline()
 	for {
 		if err = c.readChunk(); err != nil {
 			log.Printf("Error while reading message: %s", err.Error())
@@ -233,16 +430,23 @@ func (c *Connection) readChunk() (err error) {
 		if _, err = io.ReadFull(c.Reader, c.ReadBuffer[bytesRead:bytesRead+2]); err != nil {
 			return
 		}
-		csID = (uint32(c.ReadBuffer[bytesRead+1]) * 256) + uint32(c.ReadBuffer[bytesRead]+64)
+		csID = (uint32(c.ReadBuffer[bytesRead+1]) * 256) + uint32(c.ReadBuffer[bytesRead]) + 64
 		bytesRead += 2
 	}
 
 	// csMap 은 각 청크 스트림 ID에 대한 마지막 청크의 상태를 저장하는데 사용
 	chunk, ok := c.csMap[csID]
 	if !ok {
+		if len(c.csMap) >= maxChunkStreams {
+			return protocolError("too many chunk streams (csID %d)", csID)
+		}
 		log.Printf("New Chunk %d", csID)
 		chunk = c.createRtmpChunk(_fmt, csID)
 	}
+	// 메시지를 이어 받는 중에는 fmt 3 청크만 올 수 있습니다. 길이가 바뀌면 이미 받은 데이터와 맞지 않습니다.
+	if chunk.bytes > 0 && _fmt != 3 {
+		return protocolError("fmt %d chunk on csID %d before the previous message was complete", _fmt, csID)
+	}
 
 	// timestamp - 3 bytes
 	// fmt 0 - absolute timestamp, fmt 1, 2 - timestamp delta
@@ -286,12 +490,29 @@ func (c *Connection) readChunk() (err error) {
 		chunk.header.hasExtendedTimestamp = false
 	}
 
-	chunk.delta = chunk.header.timestamp  // chunk 간 시간 간격
-	chunk.clock += chunk.header.timestamp // stream 내에서 현재까지의 총 진행 시간\
+	// 메시지의 첫 청크에서만 시간을 갱신합니다. (이어지는 fmt 3 청크는 같은 메시지에 속합니다.)
+	// fmt 0 은 절대 시간, fmt 1, 2 는 직전 메시지와의 시간 간격, fmt 3 은 직전 간격을 그대로 사용합니다.
+	if chunk.bytes == 0 {
+		switch _fmt {
+		case 0:
+			chunk.delta = chunk.header.timestamp
+			chunk.clock = chunk.header.timestamp
+		case 1, 2:
+			chunk.delta = chunk.header.timestamp  // chunk 간 시간 간격
+			chunk.clock += chunk.header.timestamp // stream 내에서 현재까지의 총 진행 시간
+		default:
+			chunk.clock += chunk.delta
+		}
+	}
 
-	// 첫 번째 데이터를 읽을 때 payload를 초기화합니다.
+	// 첫 번째 데이터를 읽을 때 길이를 검사하고 payload를 초기화합니다.
+	// 헤더에 적힌 길이만큼 미리 할당하지 않고, 실제로 받은 만큼만 늘립니다.
 	if chunk.bytes == 0 {
-		chunk.payload = make([]byte, chunk.header.length)
+		if err = c.checkMessageLength(chunk.header.messageType, chunk.header.length); err != nil {
+			return
+		}
+		c.pendingMessageSize += int(chunk.header.length)
+		chunk.payload = make([]byte, 0, min(chunk.header.length, payloadReadStep))
 		chunk.capacity = chunk.header.length
 	}
 
@@ -302,47 +523,54 @@ func (c *Connection) readChunk() (err error) {
 		size = c.ReadMaxChunkSize
 	}
 
-	var n int
-	n, err = io.ReadFull(c.Reader, c.ReadBuffer[bytesRead:bytesRead+size])
-
-	chunk.payload = append(chunk.payload[:chunk.bytes], c.ReadBuffer[bytesRead:bytesRead+size]...) // ...는 슬라이스의 요소를 개별적으로 풀어서 append 함수의 인자로 넘깁니다.
-	chunk.bytes += n
-	bytesRead += n
-
-	// client 채널에 데이터를 전송합니다. (여기서는 ffmpeg에게 전송하여 HLS로 변환합니다.)
-	if c.ConnectionStatus.ConnectionComplete {
-		temp := make([]byte, bytesRead)
-		copy(temp, c.ReadBuffer[:bytesRead])
-		for _, client := range c.Clients {
-			client.Send <- temp
-		}
+	// 버퍼를 거치지 않고 payload에 바로 읽습니다.
+	// 청크 크기가 커도 실제로 받은 만큼만 늘어나도록 payloadReadStep씩 늘리며 읽습니다.
+	for read, n := 0, 0; read < size && err == nil; read += n {
+		step := min(size-read, payloadReadStep)
+		chunk.payload = slices.Grow(chunk.payload, step)
+		n, err = io.ReadFull(c.Reader, chunk.payload[chunk.bytes:chunk.bytes+step])
+		chunk.payload = chunk.payload[:chunk.bytes+n]
+		chunk.bytes += n
+		bytesRead += n
 	}
 
 	// 모든 데이터를 읽었을 때
-	if chunk.bytes == int(chunk.header.length) {
+	if err == nil && chunk.bytes == int(chunk.header.length) {
 		c.ConnectionStatus.GotMessage = true
 		chunk.bytes = 0
-		c.handleChunk(chunk)
+		c.pendingMessageSize -= int(chunk.header.length)
+		c.csMap[chunk.header.csID] = chunk
+		return c.handleChunk(chunk)
 	}
 	c.csMap[chunk.header.csID] = chunk
 	bytesRead = 0
 	return
 }
 
-func (c *Connection) handleChunk(chunk *rtmpChunk) {
+// handleChunk 조립이 끝난 메시지를 처리합니다. 에러를 반환하면 연결을 닫습니다.
+func (c *Connection) handleChunk(chunk *rtmpChunk) error {
+	if c.client != nil {
+		return c.client.handleChunk(chunk)
+	}
 	switch chunk.header.messageType {
 	case 1: // Set Max Read Chunk Size
-		c.ReadMaxChunkSize = int(binary.BigEndian.Uint32(chunk.payload)) // 4096
+		return c.setReadChunkSize(chunk.payload)
+	case 2: // Abort Message
+		c.abortMessage(chunk.payload)
+	case 3:
+		// Acknowledgement (Window ACK Size 만큼 받을 때마다 클라이언트가 보냄)
 	case 4:
-		c.handleDataMessages(chunk)
+		// User Control은 Ping Request에만 응답합니다. (SetBufferLength 등은 사용하지 않음)
+		// Ping Response는 읽기 제한 시간을 늘리는 것 말고는 처리할 것이 없습니다.
+		c.handleUserControl(chunk.payload)
 	case 5:
 		// Window ACK Size
 
 	case 20: // AMF0 Command
-		c.handleAmf0Commands(chunk)
+		return c.handleAmf0Commands(chunk)
 
 	case 18:
-		c.handleDataMessages(chunk)
+		return c.handleDataMessages(chunk)
 
 	case 8:
 		c.handleAudioData(chunk)
@@ -354,10 +582,14 @@ func (c *Connection) handleChunk(chunk *rtmpChunk) {
 		log.Printf("UNKNOWN CHUNK RECEIVED %d", chunk.header.messageType)
 		log.Println(chunk.header.fmt, chunk.header.csID, chunk.header.hasExtendedTimestamp, chunk.header.length, chunk.header.messageStreamID, chunk.header.messageType, chunk.header.timestamp)
 	}
+	return nil
 }
 
-func (c *Connection) handleAmf0Commands(chunk *rtmpChunk) {
-	command := amf.Decode(chunk.payload)
+func (c *Connection) handleAmf0Commands(chunk *rtmpChunk) error {
+	command, err := amf.Decode(chunk.payload)
+	if err != nil {
+		return protocolError("invalid command: %s", err.Error())
+	}
 
 	switch command["cmd"] {
 	case "connect":
@@ -372,25 +604,62 @@ func (c *Connection) handleAmf0Commands(chunk *rtmpChunk) {
 		c.onPublish(command, chunk.header.messageStreamID)
 	case "play":
 		c.onPlay(command, chunk)
+	case "seek":
+		c.onSeek(command, chunk.header.messageStreamID)
+	case "pause", "pauseRaw":
+		c.onPause(command, chunk.header.messageStreamID)
 
 	default:
 		log.Println("Unknown AMF Command Received")
 	}
+	return nil
 }
 
 func (c *Connection) onConnect(connectCommand map[string]interface{}) {
 	log.Printf("Connect Command: %v", connectCommand)
 
-	c.AppName = connectCommand["cmdObj"].(map[string]interface{})["app"].(string)
-	c.setMaxWriteChunkSize(128)
-	c.sendWindowACK(5000000) // 윈도우 크기는 서버가 클라이언트로부터 얼마나 많은 데이터를 받아들일 수 있는지를 정하는 한계 값입니다. 서버가 클라이언트로부터 데이터를 받아들이는 속도를 조절하는데 사용됩니다. (5MB)
+	params, _ := connectCommand["cmdObj"].(map[string]interface{})
+	app, _ := params["app"].(string)
+	// 일부 인코더는 app에 쿼리(app?token=..)를 붙이고, 나머지는 tcUrl에 붙입니다.
+	app, query := splitStreamName(app)
+	c.AppName = app
+	c.tcURL, _ = params["tcUrl"].(string)
+	if u, err := url.Parse(c.tcURL); err == nil && len(query) == 0 {
+		query = u.Query()
+	}
+	if !c.Context.appExists(c.AppName) {
+		log.Printf("Connect rejected for app %s: app not configured", c.AppName)
+		c.rejectConnect(connectCommand["transId"], "Application not found: "+c.AppName)
+		return
+	}
+	if err := c.Context.checkAccess(c.AppName, AccessActionConnect, c.clientIP()); err != nil {
+		c.rejectConnect(connectCommand["transId"], "Access denied")
+		return
+	}
+	if description, ok := c.connectAuth(query); !ok {
+		c.rejectConnect(connectCommand["transId"], description)
+		return
+	}
+	if hooks := c.Context.Hooks; hooks != nil {
+		if err := hooks.OnConnect(c); err != nil {
+			log.Printf("Connect rejected for app %s: %s", c.AppName, err.Error())
+			c.rejectConnect(connectCommand["transId"], err.Error())
+			return
+		}
+	}
+	if err := c.callWebhook(webhookConnect, "", query); err != nil {
+		log.Printf("Connect rejected for app %s: %s", c.AppName, err.Error())
+		c.rejectConnect(connectCommand["transId"], err.Error())
+		return
+	}
+	conf := c.Context.rtmpConfig()
+	c.setMaxWriteChunkSize(conf.ChunkSize)
+	c.sendWindowACK(conf.WindowAckSize) // 윈도우 크기는 서버가 클라이언트로부터 얼마나 많은 데이터를 받아들일 수 있는지를 정하는 한계 값입니다. 서버가 클라이언트로부터 데이터를 받아들이는 속도를 조절하는데 사용됩니다. (기본 5MB)
 
 	// 대역폭은 네트워크에서 사용 가능한 최대 전송 속도를 나타냅니다.
 	// RTMP 경우, 대역폭 설정은 클라이언트와 서버 간의 통신을 최적화하고 스트리밍의 품질과 안정성을 유지하기 위해 중요합니다.
-	// 대역폭 값인 5000000은 5000000 바이트/초 또는 약 5 Mbps를 나타내고, 일반적으로 고화질 또는 고속 스트리밍에 적합한 대역폭 수준입니다.
-	// 필요한 대역폭이나 최적의 값은 특정 상황에 따라 다를 수 있으므로, 실제 테스트 및 성능 모니터링을 통해 적절한 값을 결정하는 것이 중요합니다.
-	c.setPeerBandwidth(5000000, 2)
-	c.Writer.Flush()
+	// 필요한 대역폭이나 최적의 값은 특정 상황에 따라 다를 수 있으므로, 실제 테스트 및 성능 모니터링을 통해 적절한 값을 설정 파일(rtmp.peer_bandwidth)로 정합니다.
+	c.setPeerBandwidth(conf.PeerBandwidth, 2)
 
 	cmd := "_result"
 	transID := connectCommand["transId"]
@@ -421,10 +690,8 @@ func (c *Connection) onConnect(connectCommand map[string]interface{}) {
 		bytes:    0,
 		payload:  amfPayload,
 	}
-	for _, ch := range c.create(chunk) {
-		c.Writer.Write(ch)
-	}
-	c.Writer.Flush()
+	// 제어 메시지는 버퍼에만 쓰였으므로 응답과 함께 Flush 됩니다.
+	c.writeMessage(chunk)
 
 	c.ConnectionStatus.ConnectionPrepareDone = true
 }
@@ -464,10 +731,7 @@ func (c *Connection) onCreateStream(command map[string]interface{}) {
 		payload:  amfPayload,
 	}
 
-	for _, ch := range c.create(chunk) {
-		c.Writer.Write(ch)
-	}
-	c.Writer.Flush()
+	c.writeMessage(chunk)
 }
 
 func (c *Connection) onPublish(command map[string]interface{}, messageStreamID uint32) {
@@ -499,94 +763,263 @@ func (c *Connection) onPublish(command map[string]interface{}, messageStreamID u
 		payload:  amfPayload,
 	}
 
-	c.Context.set(command["streamName"].(string), c) // 서버세션에 저장
-	c.StreamKey = command["streamName"].(string)     // 스트림키 저장
+	rawName, _ := command["streamName"].(string)
+	streamName, query := splitStreamName(rawName)
+	// app, 스트림 이름은 CMAF, HLS, 녹화 파일 경로에 사용하므로 디렉터리를 벗어날 수 있는 이름은 받지 않습니다.
+	if !validPathName(c.AppName) || !validPathName(streamName) {
+		log.Printf("Publish rejected for %q: invalid app or stream name", rawName)
+		c.sendStatus(messageStreamID, "error", "NetStream.Publish.BadName", "Invalid stream name: "+streamName)
+		return
+	}
+	// 연결한 뒤 설정을 다시 읽으면서 app이 빠졌으면 새로 퍼블리시할 수 없습니다.
+	if !c.Context.appExists(c.AppName) {
+		log.Printf("Publish rejected for %s: app %s removed", streamName, c.AppName)
+		c.sendStatus(messageStreamID, "error", "NetStream.Publish.Denied", "Application not found: "+c.AppName)
+		return
+	}
+	if err := c.Context.checkAccess(c.AppName, AccessActionPublish, c.clientIP()); err != nil {
+		c.sendStatus(messageStreamID, "error", "NetStream.Publish.Denied", "Access denied")
+		return
+	}
+	if conf := c.Context.app(c.AppName); !conf.Auth.allowsPublish(streamName) {
+		log.Printf("Publish rejected for %s: invalid stream key", streamName)
+		c.sendStatus(messageStreamID, "error", "NetStream.Publish.Denied", "Invalid stream key")
+		return
+	}
+	if err := c.checkToken(TokenActionPublish, streamName, query); err != nil {
+		log.Printf("Publish rejected for %s: %s", streamName, err.Error())
+		c.sendStatus(messageStreamID, "error", "NetStream.Publish.Denied", "Invalid token: "+err.Error())
+		return
+	}
+	// 웹훅을 부르기 전에 먼저 확인합니다. (등록할 때 다시 확인합니다.)
+	if c.Context.get(sessionKey(c.AppName, streamName)) != nil {
+		c.rejectPublishInUse(messageStreamID, streamName)
+		return
+	}
+	if err := c.Context.checkPublisherLimit(c.AppName); err != nil {
+		c.sendStatus(messageStreamID, "error", "NetStream.Publish.Denied", err.Error())
+		return
+	}
+	if hooks := c.Context.Hooks; hooks != nil {
+		if err := hooks.OnPublish(c, streamName); err != nil {
+			log.Printf("Publish rejected for %s: %s", streamName, err.Error())
+			c.sendStatus(messageStreamID, "error", "NetStream.Publish.Denied", err.Error())
+			return
+		}
+	}
+	if err := c.callWebhook(webhookPublish, streamName, query); err != nil {
+		log.Printf("Publish rejected for %s: %s", streamName, err.Error())
+		c.sendStatus(messageStreamID, "error", "NetStream.Publish.Denied", err.Error())
+		return
+	}
+	c.streamQuery = query
+
+	c.StreamKey = streamName // 스트림키 저장
+	c.Hub = NewStreamHub()
+	// 서버세션에 저장합니다. 이미 퍼블리시 중인 스트림은 다른 퍼블리셔가 가로챌 수 없습니다.
+	if err := c.Context.addPublisher(c); err != nil {
+		c.StreamKey, c.Hub = "", nil
+		if errors.Is(err, errStreamInUse) {
+			c.rejectPublishInUse(messageStreamID, streamName)
+		} else {
+			c.sendStatus(messageStreamID, "error", "NetStream.Publish.Denied", err.Error())
+		}
+		return
+	}
+	c.Context.Metrics.AddGauge("rtmp_publishers", 1, "app", c.AppName)
+	c.lastMedia = time.Now()
+	c.attachOutputs()
 
 	// 채널을 통해 데이터를 전송하여 FFMPEG를 CMD 형태로 실행합니다. (HLS로 변환하기 위함)
-	c.Context.Preview <- c.StreamKey
-
-	for _, ch := range c.create(chunk) {
-		c.Writer.Write(ch)
+	// 라이브러리로 사용하는 경우처럼 미리보기 서버가 없으면 건너뜁니다.
+	if c.Context.Preview != nil {
+		c.Context.Preview <- sessionKey(c.AppName, c.StreamKey)
 	}
-	c.Writer.Flush()
+
+	c.writeMessage(chunk)
+	c.publishStreamID.Store(messageStreamID)
 
 	c.ConnectionStatus.ConnectionComplete = true
 }
 
+// rejectPublishInUse 이미 퍼블리시 중인 스트림에 대한 publish를 NetStream.Publish.BadName으로 거절합니다.
+func (c *Connection) rejectPublishInUse(messageStreamID uint32, streamName string) {
+	log.Printf("Publish rejected for %s/%s: %s", c.AppName, streamName, errStreamInUse.Error())
+	c.sendStatus(messageStreamID, "error", "NetStream.Publish.BadName", "Stream already publishing: "+streamName)
+}
+
+// attachOutputs app 설정에 따라 퍼블리셔 허브에 CMAF 패키저, 녹화기, 푸시 릴레이를 연결합니다.
+func (c *Connection) attachOutputs() {
+	conf := c.Context.app(c.AppName)
+	if conf.CMAF {
+		if p, err := newCMAFPackager(c.Context.Paths.CMAF, c.AppName, c.StreamKey, conf.DVRWindow); err != nil {
+			log.Printf("CMAF disabled for %s/%s: %s", c.AppName, c.StreamKey, err.Error())
+		} else {
+			c.Hub.Subscribe(p)
+		}
+	}
+	if conf.DVRWindow > 0 {
+		c.dvr = newDVRBuffer(conf.DVRWindow)
+		c.Hub.Subscribe(c.dvr)
+	}
+	if conf.Record {
+		c.startRecording()
+	}
+	c.startRelays(conf)
+}
+
 // handleDataMessages 데이터 메시지를 처리합니다.
-func (c *Connection) handleDataMessages(chunk *rtmpChunk) {
-	command := amf.Decode(chunk.payload)
-	dataObj := command["dataObj"].(map[string]interface{})
+func (c *Connection) handleDataMessages(chunk *rtmpChunk) error {
+	command, err := amf.Decode(chunk.payload)
+	if err != nil {
+		return protocolError("invalid data message: %s", err.Error())
+	}
+	dataObj, _ := command["dataObj"].(map[string]interface{})
 	log.Printf("Data Object: %v", dataObj)
 	switch command["cmd"] {
 	case "@setDataFrame":
 		log.Println("Set Data Frame")
 		c.MetaData = append(c.MetaData, chunk.payload...)
+		// 시청자에게는 "@setDataFrame"을 제외한 onMetaData 부분만 전달합니다.
+		if c.Hub != nil && chunk.payload[0] == 0x02 {
+			skip := 3 + int(binary.BigEndian.Uint16(chunk.payload[1:3]))
+			c.publishPacket(&Packet{Type: MessageTypeData, Timestamp: chunk.clock, Data: chunk.payload[skip:]})
+		}
+	}
+	return nil
+}
+
+// publishPacket 퍼블리셔로부터 받은 패킷을 훅에 알린 뒤 허브로 보냅니다.
+func (c *Connection) publishPacket(p *Packet) {
+	if hooks := c.Context.Hooks; hooks != nil {
+		hooks.OnPacket(c, p)
 	}
+	c.Hub.Write(p)
+}
+
+// rejectConnect connect 요청에 NetConnection.Connect.Rejected로 응답하고 연결을 닫습니다.
+func (c *Connection) rejectConnect(transID interface{}, description string) {
+	info := flvio.AMFMap{
+		"level":       "error",
+		"code":        "NetConnection.Connect.Rejected",
+		"description": description,
+	}
+	amfPayload, length := amf.Encode("_error", transID, nil, info)
+	// 청크 크기를 알리기 전이므로, 128바이트보다 긴 description(adobe 인증 등)을 보낼 수 있도록 먼저 알립니다.
+	c.setMaxWriteChunkSize(c.Context.rtmpConfig().ChunkSize)
+	c.writeMessage(&rtmpChunk{
+		header: &chunkHeader{
+			fmt:         0,
+			csID:        3,
+			messageType: 20,
+			length:      uint32(length),
+		},
+		payload: amfPayload,
+	})
+	c.Conn.Close()
 }
 
 // handleAudioData 오디오 데이터를 처리합니다.
 func (c *Connection) handleAudioData(chunk *rtmpChunk) {
 	chunk.header.timestamp = chunk.clock
+	c.lastMedia = time.Now()
 	if !c.GotFirstAudio {
 		c.FirstAudio = append(c.FirstAudio, chunk.payload...)
 	}
 	c.GotFirstAudio = true
+
+	if c.Hub != nil {
+		c.publishPacket(&Packet{Type: MessageTypeAudio, Timestamp: chunk.clock, Data: chunk.payload})
+	}
 }
 
 // handleVideoData 비디오 데이터를 처리합니다.
 func (c *Connection) handleVideoData(chunk *rtmpChunk) {
 	chunk.header.timestamp = chunk.clock
+	c.lastMedia = time.Now()
 	if !c.GotFirstVideo {
 		c.FirstVideo = append(c.FirstVideo, chunk.payload...)
 		c.GotFirstVideo = true
-		log.Printf("First audio data received for stream key %s", c.StreamKey)
-	}
-
-	// 기다리는 클라이언트가 있을 경우, 클라이언트에게 데이터를 전송합니다. (ffmpeg에게 전송하여 HLS로 변환합니다.)
-	if len(c.WaitingClients) > 0 {
-		frameType := chunk.payload[0] >> 4
-		if frameType == 1 {
-			for i, client := range c.WaitingClients {
-				for _, ch := range c.create(chunk) {
-					client.Send <- ch
-				}
-				c.Clients = append(c.Clients, client)
-				c.WaitingClients = append(c.WaitingClients[:i], c.WaitingClients[i+1:]...)
-			}
-		}
+		log.Printf("First video data received for stream key %s", c.StreamKey)
+	}
+
+	// 허브를 통해 시청자, 패키저에게 전달합니다. 키프레임을 기다리는 처리는 허브의 GOP 캐시가 담당합니다.
+	if c.Hub != nil {
+		c.publishPacket(&Packet{Type: MessageTypeVideo, Timestamp: chunk.clock, Data: chunk.payload})
 	}
 }
 
 func (c *Connection) onPlay(command map[string]interface{}, playChunk *rtmpChunk) {
-	fmt.Println(command["streamName"])
-	co := c.Context.get(command["streamName"].(string))
-	if co == nil {
-		fmt.Println("Stream not found")
+	log.Printf("on Play Command: %v", command)
+	streamID := playChunk.header.messageStreamID
+	// 쿼리 파라미터(토큰 등)는 인증에만 사용하고, 스트림을 찾을 때는 이름만 사용합니다.
+	rawName, _ := command["streamName"].(string)
+	streamName, query := splitStreamName(rawName)
+	command["streamName"] = streamName
+	if !c.Context.appExists(c.AppName) {
+		log.Printf("Play rejected: app %s not configured", c.AppName)
+		c.sendStatus(streamID, "error", "NetStream.Play.Failed", "Application not found: "+c.AppName)
+		return
+	}
+	if err := c.Context.checkAccess(c.AppName, AccessActionPlay, c.clientIP()); err != nil {
+		c.sendStatus(streamID, "error", "NetStream.Play.Failed", "Access denied")
 		return
 	}
+	if err := c.checkToken(TokenActionPlay, streamName, query); err != nil {
+		log.Printf("Play rejected for %s: %s", streamName, err.Error())
+		c.sendStatus(streamID, "error", "NetStream.Play.Failed", "Invalid token: "+err.Error())
+		return
+	}
+	if hooks := c.Context.Hooks; hooks != nil {
+		if err := hooks.OnPlay(c, streamName); err != nil {
+			log.Printf("Play rejected for %s: %s", streamName, err.Error())
+			c.sendStatus(streamID, "error", "NetStream.Play.Failed", err.Error())
+			return
+		}
+	}
+	if err := c.callWebhook(webhookPlay, streamName, query); err != nil {
+		log.Printf("Play rejected for %s: %s", streamName, err.Error())
+		c.sendStatus(streamID, "error", webhookPlayStatus(err), err.Error())
+		return
+	}
+	c.streamQuery = query
 
-	b := make([]byte, 6)
-	binary.BigEndian.PutUint16(b[:2], 0)
-	binary.BigEndian.PutUint32(b[2:], playChunk.header.messageStreamID)
-	chunk := &rtmpChunk{
-		header: &chunkHeader{
-			fmt:             0,
-			csID:            2,
-			messageType:     4,
-			messageStreamID: 0,
-			timestamp:       0,
-			length:          uint32(6),
-		},
-		clock:    0,
-		delta:    0,
-		capacity: 0,
-		bytes:    0,
-		payload:  b,
+	// VOD app은 라이브 세션 대신 파일을 재생합니다.
+	if conf := c.Context.app(c.AppName); conf.VOD {
+		c.onPlayVOD(command, streamID, conf)
+		return
 	}
-	for _, ch := range c.create(chunk) {
-		c.Writer.Write(ch)
+
+	// 다른 app의 같은 이름 스트림은 재생하지 않습니다. 토큰, 웹훅, 접근 규칙은 이 연결의 app 기준으로 검사했기 때문입니다.
+	co := c.Context.lookupStream(c.AppName, streamName)
+	// 엣지 모드에서는 로컬에 없는 스트림을 오리진에서 받아옵니다.
+	if conf := c.Context.app(c.AppName); co == nil && len(conf.Origins) > 0 {
+		var err error
+		if co, err = c.Context.pullStream(conf, streamName); err != nil {
+			log.Printf("Edge pull failed for %s: %s", streamName, err.Error())
+		}
+	}
+	if co == nil || co.Hub == nil {
+		log.Printf("Play failed: stream %s/%s not found", c.AppName, streamName)
+		c.sendStatus(streamID, "error", "NetStream.Play.StreamNotFound", "Stream not found: "+streamName)
+		return
 	}
+	if err := c.Context.addViewer(co); err != nil {
+		c.sendStatus(streamID, "error", "NetStream.Play.Failed", err.Error())
+		return
+	}
+	if c.viewing != nil {
+		c.Context.removeViewer(c.viewing)
+	}
+	c.viewing = co
+
+	// 타임시프트 버퍼가 있는 스트림은 start 값에 따라 과거 시점부터 재생할 수 있습니다.
+	if co.dvr != nil && isDVRStart(command) {
+		c.onPlayDVR(command, streamID, co)
+		return
+	}
+
+	c.sendUserControl(userControlStreamBegin, streamID)
 
 	info := flvio.AMFMap{
 		"level":       "status",
@@ -594,28 +1027,20 @@ func (c *Connection) onPlay(command map[string]interface{}, playChunk *rtmpChunk
 		"description": "Start live",
 	}
 	amfPayload, _ := amf.Encode("onStatus", 4, nil, info)
-
-	chunk = &rtmpChunk{
+	c.writeMessage(&rtmpChunk{
 		header: &chunkHeader{
 			fmt:             0,
 			csID:            3,
 			messageType:     20,
-			messageStreamID: playChunk.header.messageStreamID,
+			messageStreamID: streamID,
 			timestamp:       0,
 			length:          uint32(len(amfPayload)),
 		},
-		clock:    0,
-		delta:    0,
-		capacity: 0,
-		bytes:    0,
-		payload:  amfPayload,
-	}
-	for _, ch := range c.create(chunk) {
-		c.Writer.Write(ch)
-	}
+		payload: amfPayload,
+	})
 
 	amfPayload, _ = amf.Encode("|RtmpSampleAccess", false, false)
-	chunk = &rtmpChunk{
+	c.writeMessage(&rtmpChunk{
 		header: &chunkHeader{
 			fmt:             0,
 			csID:            6,
@@ -624,108 +1049,38 @@ func (c *Connection) onPlay(command map[string]interface{}, playChunk *rtmpChunk
 			timestamp:       0,
 			length:          uint32(len(amfPayload)),
 		},
-		clock:    0,
-		delta:    0,
-		capacity: 0,
-		bytes:    0,
-		payload:  amfPayload,
-	}
-	for _, ch := range c.create(chunk) {
-		c.Writer.Write(ch)
-	}
+		payload: amfPayload,
+	})
 
-	c.Writer.Flush()
 	c.ConnectionStatus.ConnectionComplete = true
 
-	chunk = &rtmpChunk{
-		header: &chunkHeader{
-			fmt:             0,
-			csID:            6,
-			messageType:     18,
-			messageStreamID: playChunk.header.messageStreamID,
-			timestamp:       0,
-			length:          uint32(len(co.MetaData)),
-		},
-		clock:    0,
-		delta:    0,
-		capacity: 0,
-		bytes:    0,
-		payload:  co.MetaData,
-	}
-	for _, ch := range c.create(chunk) {
-		c.Writer.Write(ch)
-		fmt.Println(len(ch))
-	}
-	c.Writer.Flush()
-	fmt.Println("Sent Meta Data")
+	// 메타데이터, 시퀀스 헤더, GOP 캐시를 먼저 받은 뒤 라이브 패킷을 받습니다.
+	c.playSubscription = co.Hub.Subscribe(&rtmpPlayWriter{c: c, streamID: streamID})
+	c.playStreamID.Store(streamID)
+	c.playName = streamName
+	c.startPing()
+}
 
-	chunk = &rtmpChunk{
-		header: &chunkHeader{
-			fmt:             0,
-			csID:            4,
-			messageType:     8,
-			messageStreamID: playChunk.header.messageStreamID,
-			timestamp:       0,
-			length:          uint32(len(co.FirstAudio)),
-		},
-		clock:    0,
-		delta:    0,
-		capacity: 0,
-		bytes:    0,
-		payload:  co.FirstAudio,
-	}
-	fmt.Println("Sent Audio Data")
-	for _, ch := range c.create(chunk) {
-		c.Writer.Write(ch)
-	}
-	c.Writer.Flush()
+// rtmpPlayWriter 허브로부터 받은 패킷을 RTMP 시청자에게 메시지로 씁니다.
+type rtmpPlayWriter struct {
+	c        *Connection
+	streamID uint32
+}
 
-	chunk = &rtmpChunk{
+func (w *rtmpPlayWriter) WritePacket(p *Packet) error {
+	csID := uint32(4)
+	if p.IsMetaData() {
+		csID = 6
+	}
+	return w.c.writeMessage(&rtmpChunk{
 		header: &chunkHeader{
 			fmt:             0,
-			csID:            4,
-			messageType:     9,
-			messageStreamID: playChunk.header.messageStreamID,
-			timestamp:       0,
-			length:          uint32(len(co.FirstVideo)),
+			csID:            csID,
+			messageType:     p.Type,
+			messageStreamID: w.streamID,
+			timestamp:       p.Timestamp,
+			length:          uint32(len(p.Data)),
 		},
-		clock:    0,
-		delta:    0,
-		capacity: 0,
-		bytes:    0,
-		payload:  co.FirstVideo,
-	}
-	fmt.Println("Sent Video Data")
-	for _, ch := range c.create(chunk) {
-		c.Writer.Write(ch)
-	}
-
-	c.Writer.Flush()
-
-	// for _, d := range co.GOP {
-	// 	c.Writer.Write(d)
-	// }
-
-	ch := Channel{
-		ChannelID: -1,
-		Send:      make(chan []byte, 100),
-		Exit:      make(chan bool, 5),
-	}
-	co.WaitingClients = append(co.WaitingClients, ch)
-	// co.Clients = append(co.Clients, ch)
-	func(client *Connection) {
-		clientWriter := bufio.NewWriter(c.Conn)
-		for {
-			// fmt.Println("Waiting data to play*********************************************************")
-			select {
-			case chunk := <-ch.Send:
-				clientWriter.Write(chunk)
-			case <-ch.Exit:
-				client.Conn.Close()
-				return
-			}
-			// fmt.Println("___________________________________")
-			clientWriter.Flush()
-		}
-	}(c)
+		payload: p.Data,
+	})
 }
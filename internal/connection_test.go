@@ -0,0 +1,272 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"example/hello/internal/amf"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// expectStatus err가 code의 onStatus 에러인지 확인합니다.
+func expectStatus(t *testing.T, err error, code string) {
+	t.Helper()
+	var status *RTMPStatusError
+	if !errors.As(err, &status) || status.Code != code {
+		t.Fatalf("error %v, want %s", err, code)
+	}
+}
+
+func TestPlayDoesNotCrossApps(t *testing.T) {
+	_, addr := startTestServer(t, map[string]*AppConfig{
+		"secure": {Auth: AuthConfig{Token: TokenConfig{Secrets: []string{"0123456789abcdef"}, Play: true}}},
+		"open":   {},
+	})
+	publishTestStream(t, "rtmp://"+addr+"/secure/protected")
+
+	// 토큰이 필요 없는 app으로 접속해 같은 이름을 재생해도 보호된 스트림을 받을 수 없습니다.
+	client := dialTestClient(t, "rtmp://"+addr+"/open/protected")
+	expectStatus(t, client.Play(context.Background(), client.Stream), "NetStream.Play.StreamNotFound")
+
+	client = dialTestClient(t, "rtmp://"+addr+"/secure/protected")
+	expectStatus(t, client.Play(context.Background(), client.Stream), "NetStream.Play.Failed")
+}
+
+func TestPublishSessionsPerApp(t *testing.T) {
+	_, addr := startTestServer(t, map[string]*AppConfig{"live": {}, "ingest": {}})
+	publishTestStream(t, "rtmp://"+addr+"/live/same")
+
+	// 다른 app의 같은 이름은 별개의 스트림입니다.
+	publishTestStream(t, "rtmp://"+addr+"/ingest/same")
+	expectTestKeyFrame(t, playTestStream(t, "rtmp://"+addr+"/live/same"))
+	expectTestKeyFrame(t, playTestStream(t, "rtmp://"+addr+"/ingest/same"))
+
+	// 이미 퍼블리시 중인 스트림은 가로챌 수 없습니다.
+	client := dialTestClient(t, "rtmp://"+addr+"/live/same")
+	expectStatus(t, client.Publish(context.Background(), client.Stream), "NetStream.Publish.BadName")
+	expectTestKeyFrame(t, playTestStream(t, "rtmp://"+addr+"/live/same"))
+}
+
+// chunkTestConn bytes.Reader의 데이터를 읽고 쓰기는 버리는 연결입니다.
+type chunkTestConn struct {
+	*bytes.Reader
+}
+
+// chunkTestAddr chunkTestConn의 주소입니다.
+var chunkTestAddr = &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 50000}
+
+func (chunkTestConn) Write(b []byte) (int, error)        { return len(b), nil }
+func (chunkTestConn) Close() error                       { return nil }
+func (chunkTestConn) LocalAddr() net.Addr                { return chunkTestAddr }
+func (chunkTestConn) RemoteAddr() net.Addr               { return chunkTestAddr }
+func (chunkTestConn) SetDeadline(t time.Time) error      { return nil }
+func (chunkTestConn) SetReadDeadline(t time.Time) error  { return nil }
+func (chunkTestConn) SetWriteDeadline(t time.Time) error { return nil }
+
+// newChunkTestContext 출력 파일을 dir에 쓰는 서버 컨텍스트를 만듭니다.
+func newChunkTestContext(dir string) *StreamContext {
+	cfg := DefaultConfig()
+	cfg.Paths = PathConfig{HLS: dir, CMAF: dir, Record: dir, VOD: dir}
+	cfg.HTTPListen = ""
+	return NewStreamContext(cfg)
+}
+
+// readTestChunks 핸드셰이크를 마친 연결처럼 data를 청크로 읽습니다. 데이터가 끝나거나 에러가 나면 멈춥니다.
+func readTestChunks(ctx *StreamContext, data []byte) (*Connection, error) {
+	c := NewConnection(chunkTestConn{bytes.NewReader(data)}, ctx)
+	c.ConnectionStatus.HandShakeDone = true
+	for {
+		if err := c.readChunk(); err != nil {
+			return c, err
+		}
+	}
+}
+
+// testMessage payload를 chunkSize 단위의 청크로 나눈 메시지입니다. (fmt 0, 메시지 스트림 ID 0)
+func testMessage(csID uint32, messageType uint8, payload []byte, chunkSize int) []byte {
+	c := &Connection{WriteMaxChunkSize: chunkSize}
+	chunk := &rtmpChunk{
+		header:  &chunkHeader{csID: csID, messageType: messageType, length: uint32(len(payload))},
+		payload: payload,
+	}
+	return bytes.Join(c.create(chunk), nil)
+}
+
+// testMessageHeader 데이터 없이 length 길이의 메시지를 시작하는 첫 청크의 헤더입니다.
+func testMessageHeader(csID uint32, messageType uint8, length uint32) []byte {
+	chunk := &rtmpChunk{header: &chunkHeader{csID: csID, messageType: messageType, length: length}}
+	return append(chunk.createBasicHeader(), chunk.createMessageHeader()...)
+}
+
+func testChunkSize(size uint32) []byte {
+	return testMessage(2, 1, binary.BigEndian.AppendUint32(nil, size), 128)
+}
+
+// maxAMFTestDepth amf 패키지의 최대 중첩 깊이(32)를 넘는 깊이입니다.
+const maxAMFTestDepth = 40
+
+// testNestedCommand depth 단계로 중첩된 객체를 인자로 가진 connect 명령입니다.
+func testNestedCommand(depth int) []byte {
+	b, _ := amf.Encode("connect", 1.0)
+	for i := 0; i < depth; i++ {
+		b = append(b, 0x03, 0x00, 0x01, 'a') // 객체 시작, 키 "a"
+	}
+	b = append(b, 0x05) // 가장 안쪽 값 null
+	for i := 0; i < depth; i++ {
+		b = append(b, 0x00, 0x00, 0x09) // 객체 끝
+	}
+	return b
+}
+
+func expectProtocolError(t *testing.T, err error) {
+	t.Helper()
+	if !errors.Is(err, errProtocol) {
+		t.Fatalf("error %v, want a protocol error", err)
+	}
+}
+
+func FuzzReadChunk(f *testing.F) {
+	connect, _ := amf.Encode("connect", 1.0, map[string]interface{}{"app": "live", "tcUrl": "rtmp://localhost/live"})
+	f.Add(append(testChunkSize(4096), testMessage(3, 20, connect, 4096)...))
+	f.Add(testMessage(3, 20, connect, 128))
+	f.Add(testMessage(65599, 20, connect, 128))
+	f.Add(testMessageHeader(65599, MessageTypeVideo, 0xFFFFFF))
+	f.Add(append(testChunkSize(maxPeerChunkSize), testMessageHeader(4, MessageTypeVideo, 0xFFFFFF)...))
+	f.Add(testMessageHeader(3, 20, 0xFFFFFF))
+	f.Add(testChunkSize(0))
+	f.Add(testChunkSize(0x80000000))
+	f.Add(testMessage(3, 20, testNestedCommand(maxAMFTestDepth), 128))
+	f.Add([]byte{0x03, 0xFF, 0xFF, 0xFF})
+	f.Add([]byte{0xC3, 0x01})
+
+	ctx := newChunkTestContext(f.TempDir())
+	f.Fuzz(func(t *testing.T, data []byte) {
+		c, err := readTestChunks(ctx, data)
+		if err == nil {
+			t.Fatal("readChunk stopped without an error")
+		}
+		if len(c.csMap) > maxChunkStreams {
+			t.Errorf("%d chunk streams, limit %d", len(c.csMap), maxChunkStreams)
+		}
+		if c.pendingMessageSize < 0 || c.pendingMessageSize > maxPendingMessageSize {
+			t.Errorf("pending message size %d, limit %d", c.pendingMessageSize, maxPendingMessageSize)
+		}
+		if c.ReadMaxChunkSize < 1 || c.ReadMaxChunkSize > maxPeerChunkSize {
+			t.Errorf("read chunk size %d", c.ReadMaxChunkSize)
+		}
+		// 헤더에 적힌 길이가 아니라 실제로 받은 데이터만큼만 할당해야 합니다.
+		for csID, chunk := range c.csMap {
+			if limit := 2*len(chunk.payload) + 2*payloadReadStep; cap(chunk.payload) > limit {
+				t.Errorf("csID %d: payload capacity %d for %d bytes received", csID, cap(chunk.payload), len(chunk.payload))
+			}
+		}
+	})
+}
+
+func TestReadChunkLimits(t *testing.T) {
+	ctx := newChunkTestContext(t.TempDir())
+
+	t.Run("chunk streams", func(t *testing.T) {
+		var data []byte
+		for csID := uint32(3); csID < 3+maxChunkStreams; csID++ {
+			data = append(data, testMessage(csID, 3, []byte{0, 0, 0, 1}, 128)...)
+		}
+		c, err := readTestChunks(ctx, data)
+		if err != io.EOF || len(c.csMap) != maxChunkStreams {
+			t.Fatalf("%d chunk streams: %v", len(c.csMap), err)
+		}
+		_, err = readTestChunks(ctx, append(data, testMessage(65599, 3, []byte{0, 0, 0, 1}, 128)...))
+		expectProtocolError(t, err)
+	})
+
+	t.Run("pending message size", func(t *testing.T) {
+		// 16MB 비디오 메시지 두 개까지는 함께 조립할 수 있고, 세 번째는 거절합니다.
+		var data []byte
+		for csID := uint32(4); csID < 6; csID++ {
+			data = append(data, testMessageHeader(csID, MessageTypeVideo, 0xFFFFFF)...)
+			data = append(data, make([]byte, 128)...)
+		}
+		c, err := readTestChunks(ctx, data)
+		if err != io.EOF || c.pendingMessageSize != 2*0xFFFFFF {
+			t.Fatalf("pending %d: %v", c.pendingMessageSize, err)
+		}
+		_, err = readTestChunks(ctx, append(data, testMessageHeader(6, MessageTypeVideo, 0xFFFFFF)...))
+		expectProtocolError(t, err)
+	})
+
+	t.Run("message types", func(t *testing.T) {
+		for _, tc := range []struct {
+			messageType uint8
+			length      uint32
+			ok          bool
+		}{
+			{1, 4, true}, {1, 3, false}, {1, 5, false},
+			{4, 6, true}, {4, 1, false}, {4, 65, false},
+			{6, 5, true}, {6, 4, false},
+			{MessageTypeAudio, 1 << 20, true}, {MessageTypeAudio, 1<<20 + 1, false},
+			{MessageTypeVideo, 0xFFFFFF, true},
+			{MessageTypeData, 1 << 20, true}, {MessageTypeData, 1<<20 + 1, false},
+			{20, 64 << 10, true}, {20, 64<<10 + 1, false},
+			{99, otherMessageSizeLimit, true}, {99, otherMessageSizeLimit + 1, false},
+		} {
+			c := NewConnection(chunkTestConn{bytes.NewReader(nil)}, ctx)
+			if err := c.checkMessageLength(tc.messageType, tc.length); (err == nil) != tc.ok {
+				t.Errorf("type %d length %d: %v", tc.messageType, tc.length, err)
+			}
+		}
+		// 헤더만 보고 거절하므로 데이터를 기다리지 않습니다.
+		_, err := readTestChunks(ctx, testMessageHeader(3, 20, 0xFFFFFF))
+		expectProtocolError(t, err)
+	})
+
+	t.Run("set chunk size", func(t *testing.T) {
+		for _, size := range []uint32{0, 0x80000000, 0xFFFFFFFF} {
+			_, err := readTestChunks(ctx, testChunkSize(size))
+			expectProtocolError(t, err)
+		}
+		for size, want := range map[uint32]int{1: 1, 4096: 4096, 0x7FFFFFFF: maxPeerChunkSize} {
+			c, err := readTestChunks(ctx, testChunkSize(size))
+			if err != io.EOF || c.ReadMaxChunkSize != want {
+				t.Errorf("chunk size %d: got %d, %v", size, c.ReadMaxChunkSize, err)
+			}
+		}
+	})
+
+	t.Run("abort message", func(t *testing.T) {
+		// 16MB 비디오 메시지를 받던 중에 Abort Message를 받으면 조립 중인 데이터를 버립니다.
+		data := append(testMessageHeader(4, MessageTypeVideo, 0xFFFFFF), make([]byte, 128)...)
+		data = append(data, testMessage(2, 2, binary.BigEndian.AppendUint32(nil, 4), 128)...)
+		// 조립 중인 메시지가 없는 청크 스트림은 무시합니다.
+		data = append(data, testMessage(2, 2, binary.BigEndian.AppendUint32(nil, 9), 128)...)
+		c, err := readTestChunks(ctx, data)
+		if err != io.EOF || c.pendingMessageSize != 0 || c.csMap[4].bytes != 0 || len(c.csMap[4].payload) != 0 {
+			t.Fatalf("pending %d, csID 4 has %d bytes: %v", c.pendingMessageSize, c.csMap[4].bytes, err)
+		}
+		// 같은 청크 스트림에서 새 메시지를 fmt 0으로 시작할 수 있습니다.
+		c, err = readTestChunks(ctx, append(data, testMessage(4, 1, binary.BigEndian.AppendUint32(nil, 4096), 128)...))
+		if err != io.EOF || c.ReadMaxChunkSize != 4096 {
+			t.Errorf("message after abort: chunk size %d, %v", c.ReadMaxChunkSize, err)
+		}
+	})
+
+	t.Run("large chunk size", func(t *testing.T) {
+		// 청크 크기를 최대로 올려도 받지 않은 데이터만큼 미리 할당하지 않습니다.
+		data := append(testChunkSize(maxPeerChunkSize), testMessageHeader(4, MessageTypeVideo, 0xFFFFFF)...)
+		c, err := readTestChunks(ctx, append(data, make([]byte, 100)...))
+		if err != io.ErrUnexpectedEOF {
+			t.Fatalf("error %v, want unexpected EOF", err)
+		}
+		if chunk := c.csMap[4]; chunk == nil || cap(chunk.payload) > 2*payloadReadStep {
+			t.Errorf("payload allocated before the data arrived")
+		}
+	})
+
+	t.Run("nested command", func(t *testing.T) {
+		_, err := readTestChunks(ctx, testMessage(3, 20, testNestedCommand(maxAMFTestDepth), 128))
+		expectProtocolError(t, err)
+	})
+}
@@ -0,0 +1,239 @@
+package internal
+
+import (
+	"errors"
+	"io"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// errLiveEdge 타임시프트 재생이 라이브 시점까지 따라잡아 아직 읽을 패킷이 없을 때 반환합니다.
+var errLiveEdge = errors.New("live edge reached")
+
+// liveSource 라이브 시점에서 새 패킷을 기다릴 수 있는 playbackSource 입니다.
+type liveSource interface {
+	// Updated ReadPacket이 errLiveEdge를 반환한 뒤, 새 패킷이 들어오면 닫히는 채널입니다.
+	Updated() <-chan struct{}
+}
+
+// dvrBuffer 라이브 스트림의 최근 window 만큼의 패킷을 메모리에 보관하는 링 버퍼입니다. (타임시프트/DVR)
+// 항상 키프레임부터 보관하고, window가 지나면 다음 키프레임 이전의 패킷을 버립니다.
+type dvrBuffer struct {
+	mu     sync.Mutex
+	window uint32 // ms
+
+	metaData    *Packet
+	videoHeader *Packet
+	audioHeader *Packet
+
+	packets   []*Packet
+	first     int64   // packets[0]의 일련번호
+	keyframes []int64 // 탐색 가능한 패킷(키프레임)의 일련번호 (오름차순)
+
+	updated chan struct{}
+	closed  bool
+}
+
+func newDVRBuffer(window time.Duration) *dvrBuffer {
+	return &dvrBuffer{
+		window:  uint32(window / time.Millisecond),
+		updated: make(chan struct{}),
+	}
+}
+
+func (b *dvrBuffer) WritePacket(p *Packet) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case p.IsMetaData():
+		b.metaData = p
+		return nil
+	case p.IsSequenceHeader() && p.IsVideo():
+		b.videoHeader = p
+		return nil
+	case p.IsSequenceHeader() && p.IsAudio():
+		b.audioHeader = p
+		return nil
+	}
+
+	// 비디오가 없는 스트림은 모든 오디오 패킷에서 재생을 시작할 수 있습니다.
+	if p.IsKeyFrame() || (b.videoHeader == nil && p.IsAudio()) {
+		b.keyframes = append(b.keyframes, b.first+int64(len(b.packets)))
+	}
+	if len(b.keyframes) == 0 {
+		// 첫 키프레임 전의 패킷은 디코딩할 수 없으므로 보관하지 않습니다.
+		return nil
+	}
+	b.packets = append(b.packets, p)
+	b.expire(p.Timestamp)
+
+	close(b.updated)
+	b.updated = make(chan struct{})
+	return nil
+}
+
+// expire 두 번째 키프레임만으로도 window를 채울 수 있으면 첫 번째 키프레임부터 그 전까지의 패킷을 버립니다.
+func (b *dvrBuffer) expire(now uint32) {
+	for len(b.keyframes) > 1 && int64(now)-int64(b.packet(b.keyframes[1]).Timestamp) >= int64(b.window) {
+		n := int(b.keyframes[1] - b.first)
+		for i := 0; i < n; i++ {
+			b.packets[i] = nil
+		}
+		b.packets = b.packets[n:]
+		b.first += int64(n)
+		b.keyframes = b.keyframes[1:]
+	}
+}
+
+func (b *dvrBuffer) packet(seq int64) *Packet {
+	return b.packets[seq-b.first]
+}
+
+// Close 퍼블리셔가 종료되면 호출됩니다. 남은 패킷을 모두 읽은 시청자는 io.EOF를 받습니다.
+func (b *dvrBuffer) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.closed {
+		b.closed = true
+		close(b.updated)
+	}
+	return nil
+}
+
+// dvrSource dvrBuffer를 읽는 시청자별 playbackSource 입니다. 시간은 퍼블리셔 스트림의 타임스탬프(ms) 기준입니다.
+type dvrSource struct {
+	buf    *dvrBuffer
+	cursor int64 // 다음에 읽을 패킷의 일련번호
+	offset int64
+	wait   chan struct{}
+}
+
+func newDVRSource(buf *dvrBuffer) *dvrSource {
+	return &dvrSource{buf: buf}
+}
+
+func (s *dvrSource) Headers() []*Packet {
+	s.buf.mu.Lock()
+	defer s.buf.mu.Unlock()
+	var res []*Packet
+	for _, p := range []*Packet{s.buf.metaData, s.buf.videoHeader, s.buf.audioHeader} {
+		if p != nil {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+// SeekKeyframe timestamp 이전의 가장 가까운 키프레임으로 이동합니다. 이미 버려진 시간이면 가장 오래된 키프레임으로 이동합니다.
+func (s *dvrSource) SeekKeyframe(timestamp uint32) (uint32, error) {
+	b := s.buf
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.keyframes) == 0 {
+		s.cursor = b.first + int64(len(b.packets))
+		return timestamp, nil
+	}
+	i := sort.Search(len(b.keyframes), func(i int) bool {
+		return b.packet(b.keyframes[i]).Timestamp > timestamp
+	})
+	if i > 0 {
+		i--
+	}
+	s.cursor = b.keyframes[i]
+	return b.packet(s.cursor).Timestamp, nil
+}
+
+// LiveTimestamp 가장 최근 패킷의 타임스탬프입니다.
+func (s *dvrSource) LiveTimestamp() uint32 {
+	b := s.buf
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.packets) == 0 {
+		return 0
+	}
+	return b.packets[len(b.packets)-1].Timestamp
+}
+
+func (s *dvrSource) ReadPacket() (*Packet, error) {
+	b := s.buf
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// 일시정지 중에 window가 지나 버려진 위치라면 가장 오래된 키프레임부터 이어서 읽습니다.
+	if s.cursor < b.first {
+		log.Printf("DVR position expired, skipping to the oldest keyframe")
+		s.cursor = b.first
+	}
+	if s.cursor < b.first+int64(len(b.packets)) {
+		p := b.packet(s.cursor)
+		s.cursor++
+		s.offset += int64(len(p.Data))
+		return p, nil
+	}
+	if b.closed {
+		return nil, io.EOF
+	}
+	s.wait = b.updated
+	return nil, errLiveEdge
+}
+
+func (s *dvrSource) Updated() <-chan struct{} {
+	return s.wait
+}
+
+func (s *dvrSource) Duration() uint32 {
+	return s.LiveTimestamp()
+}
+
+func (s *dvrSource) Offset() int64 {
+	return s.offset
+}
+
+func (s *dvrSource) Close() error {
+	return nil
+}
+
+// isDVRStart play 명령의 start 값이 타임시프트 재생을 요청하는지 확인합니다.
+//   - start > 0: 퍼블리셔 스트림의 타임스탬프(ms) 위치부터 재생합니다.
+//   - start < -2: 라이브 시점보다 -start(ms) 만큼 이전부터 재생합니다.
+//
+// 0, -1, -2와 librtmp, ffmpeg가 라이브 재생에 보내는 -1000, -2000은 라이브 재생으로 처리합니다.
+func isDVRStart(command map[string]interface{}) bool {
+	start, ok := command["start"].(float64)
+	if !ok {
+		return false
+	}
+	switch start {
+	case -1000, -2000:
+		return false
+	}
+	return start > 0 || start < -2
+}
+
+// onPlayDVR 타임시프트 버퍼에서 재생을 시작합니다.
+func (c *Connection) onPlayDVR(command map[string]interface{}, streamID uint32, publisher *Connection) {
+	streamName, _ := command["streamName"].(string)
+	source := newDVRSource(publisher.dvr)
+
+	start, _ := command["start"].(float64)
+	if start < -2 {
+		start = float64(source.LiveTimestamp()) + start
+		if start < 0 {
+			start = 0
+		}
+	}
+
+	// startPlayback이 읽는 start를 스트림 타임스탬프로 바꾼 복사본을 넘깁니다.
+	cmd := make(map[string]interface{}, len(command))
+	for k, v := range command {
+		cmd[k] = v
+	}
+	cmd["start"] = start
+
+	log.Printf("DVR play started %s at %dms", streamName, int64(start))
+	c.startPlayback(cmd, streamID, streamName, source)
+}
@@ -0,0 +1,126 @@
+package internal
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+func TestDVRBufferWindow(t *testing.T) {
+	b := newDVRBuffer(2 * time.Second)
+	// 첫 키프레임 전의 패킷은 보관하지 않습니다.
+	b.WritePacket(testAVCFrame(0, false, 0))
+	b.WritePacket(testMetaData)
+	writeTestGOPs(t, b, 5)
+	s := newDVRSource(b)
+
+	if headers := s.Headers(); len(headers) != 3 || headers[0] != testMetaData || headers[1] != testAVCSequenceHeader || headers[2] != testAACSequenceHeader {
+		t.Errorf("headers %v", headers)
+	}
+	if live := s.LiveTimestamp(); live != 4960 {
+		t.Errorf("live timestamp %d, want 4960", live)
+	}
+	// 4960ms에서 window(2초)를 채울 수 있는 가장 늦은 키프레임은 2000ms 이므로, 그 전의 패킷은 버립니다.
+	for _, tt := range []struct{ seek, keyframe uint32 }{{0, 2000}, {1999, 2000}, {2000, 2000}, {3500, 3000}, {9000, 4000}} {
+		position, err := s.SeekKeyframe(tt.seek)
+		if err != nil || position != tt.keyframe {
+			t.Errorf("SeekKeyframe(%d) = %d, %v, want %d", tt.seek, position, err, tt.keyframe)
+		}
+	}
+
+	s.SeekKeyframe(0)
+	p, err := s.ReadPacket()
+	if err != nil || !p.IsKeyFrame() || p.Timestamp != 2000 {
+		t.Fatalf("oldest packet %v, %v, want the keyframe at 2000ms", p, err)
+	}
+	for {
+		next, err := s.ReadPacket()
+		if err == errLiveEdge {
+			break
+		}
+		if err != nil || next.Timestamp+100 < p.Timestamp {
+			t.Fatalf("packet after %dms: %v, %v", p.Timestamp, next, err)
+		}
+		p = next
+	}
+	if p.Timestamp != 4960 {
+		t.Errorf("last packet at %dms, want 4960ms", p.Timestamp)
+	}
+
+	// 새 패킷이 들어오면 기다리던 시청자를 깨웁니다.
+	updated := s.Updated()
+	b.WritePacket(testAVCFrame(5000, true, 0))
+	select {
+	case <-updated:
+	default:
+		t.Fatal("Updated is not closed by a new packet")
+	}
+	if p, err := s.ReadPacket(); err != nil || p.Timestamp != 5000 {
+		t.Errorf("got %v, %v, want the packet at 5000ms", p, err)
+	}
+	b.Close()
+	if _, err := s.ReadPacket(); err != io.EOF {
+		t.Errorf("after Close: %v, want io.EOF", err)
+	}
+}
+
+// TestDVRExpiredPosition 일시정지 중에 window가 지나 읽던 위치가 버려지면 가장 오래된 키프레임부터 읽습니다.
+func TestDVRExpiredPosition(t *testing.T) {
+	b := newDVRBuffer(time.Second)
+	writeTestGOPs(t, b, 2)
+	s := newDVRSource(b)
+	if position, _ := s.SeekKeyframe(0); position != 0 {
+		t.Fatalf("oldest keyframe at %dms, want 0", position)
+	}
+	for frame := 50; frame < 100; frame++ {
+		b.WritePacket(testAVCFrame(uint32(frame*40), frame%25 == 0, 0))
+	}
+	if p, err := s.ReadPacket(); err != nil || !p.IsKeyFrame() || p.Timestamp != 2000 {
+		t.Errorf("got %v, %v, want the keyframe at 2000ms", p, err)
+	}
+}
+
+func TestDVRPlay(t *testing.T) {
+	server, addr := startTestServer(t, map[string]*AppConfig{"live": {DVRWindow: 2 * time.Second}})
+	publisher := dialTestClient(t, "rtmp://"+addr+"/live/cam")
+	if err := publisher.Publish(contextWithTestTimeout(t), publisher.Stream); err != nil {
+		t.Fatal(err)
+	}
+	if err := publisher.WritePacket(testMetaData); err != nil {
+		t.Fatal(err)
+	}
+	writeTestGOPs(t, publisher, 5)
+	waitFor(t, "packets to reach the DVR buffer", func() bool {
+		co := server.Context.lookupStream("live", "cam")
+		return co != nil && newDVRSource(co.dvr).LiveTimestamp() == 4960
+	})
+
+	for _, tt := range []struct {
+		name     string
+		start    float64
+		keyframe uint32
+	}{
+		{"stream time", 3500, 3000},
+		// 라이브 시점(4960ms)보다 1500ms 이전
+		{"before live", -1500, 3000},
+		// window보다 오래된 데이터는 버렸으므로 가장 오래된 키프레임부터 재생합니다.
+		{"expired", 500, 2000},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			client := dialTestClient(t, "rtmp://"+addr+"/live/cam")
+			sendTestCommand(t, client, "play", "cam", tt.start)
+			waitTestStatus(t, client, "NetStream.Play.Start")
+			expectTestHeaders(t, client, tt.keyframe)
+			// 퍼블리셔가 보낸 원래 시간 그대로, 키프레임부터 라이브 시점까지 이어집니다.
+			expectTestVideoFrom(t, client, tt.keyframe, int(4960-tt.keyframe)/40+1)
+		})
+	}
+
+	// start가 라이브 재생 값이면 DVR이 아니라 허브의 라이브 스트림을 받습니다.
+	client := dialTestClient(t, "rtmp://"+addr+"/live/cam")
+	if err := client.Play(contextWithTestTimeout(t), client.Stream); err != nil {
+		t.Fatal(err)
+	}
+	sendTestCommand(t, client, "seek", 3000.0)
+	waitTestStatus(t, client, "NetStream.Seek.Failed")
+}
@@ -0,0 +1,232 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	edgeConnectTimeout = 10 * time.Second
+	// edgeDefaultIdleTimeout EdgeIdleTimeout이 설정되지 않았을 때 마지막 시청자가 나간 뒤 업스트림을 유지하는 시간입니다.
+	edgeDefaultIdleTimeout = 30 * time.Second
+	edgeIdleCheckInterval  = time.Second
+	// edgeVirtualNodes 해시 링에서 오리진 하나가 차지하는 가상 노드 수입니다. 많을수록 스트림이 고르게 나뉩니다.
+	edgeVirtualNodes = 64
+)
+
+// errNoOrigin 오리진이 설정되지 않은 app에서 엣지 풀을 시도할 때 반환합니다.
+var errNoOrigin = errors.New("no origin configured")
+
+// edgePull 오리진에서 play로 받아온 스트림을 로컬 허브에 넣어 시청자에게 나누어 줍니다.
+// 로컬 퍼블리셔와 똑같이 세션에 등록되므로 시청자는 차이 없이 재생할 수 있습니다.
+type edgePull struct {
+	conn   *Connection // 세션에 등록되는 가상 퍼블리셔 (네트워크 연결 없음)
+	client *RTMPClient
+	origin string
+	idle   time.Duration
+
+	ready chan struct{} // 업스트림 재생이 시작되거나 실패하면 닫힙니다.
+	err   error
+
+	// 서버가 종료되거나 풀이 끝나면 취소됩니다.
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// originURLs 스트림을 받아올 오리진 주소를 시도할 순서대로 반환합니다.
+// OriginHash가 설정되어 있으면 해시 링에서 스트림이 속한 오리진부터, 아니면 설정된 순서대로 시도합니다.
+func (conf *AppConfig) originURLs(stream string) []string {
+	origins := conf.Origins
+	if conf.OriginHash {
+		origins = hashRingOrder(origins, stream)
+	}
+	res := make([]string, 0, len(origins))
+	for _, origin := range origins {
+		u, err := url.Parse(origin)
+		if err != nil {
+			continue
+		}
+		// 오리진 주소에 app이 없으면 엣지와 같은 app을 사용합니다.
+		if strings.Trim(u.Path, "/") == "" {
+			u.Path = "/" + conf.Name
+		}
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + stream
+		res = append(res, u.String())
+	}
+	return res
+}
+
+func ringHash(s string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(s))
+	return h.Sum32()
+}
+
+// hashRingOrder 일관된 해시 링에서 key 위치부터 시계 방향으로 만나는 오리진 순서를 반환합니다.
+// 오리진이 추가되거나 빠져도 대부분의 스트림은 같은 오리진에 남습니다.
+func hashRingOrder(origins []string, key string) []string {
+	type node struct {
+		hash   uint32
+		origin string
+	}
+	ring := make([]node, 0, len(origins)*edgeVirtualNodes)
+	for _, origin := range origins {
+		for i := 0; i < edgeVirtualNodes; i++ {
+			ring = append(ring, node{ringHash(fmt.Sprintf("%s#%d", origin, i)), origin})
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool { return ring[i].hash < ring[j].hash })
+
+	h := ringHash(key)
+	start := sort.Search(len(ring), func(i int) bool { return ring[i].hash >= h })
+	res := make([]string, 0, len(origins))
+	seen := make(map[string]bool, len(origins))
+	for i := 0; i < len(ring) && len(res) < len(origins); i++ {
+		n := ring[(start+i)%len(ring)]
+		if !seen[n.origin] {
+			seen[n.origin] = true
+			res = append(res, n.origin)
+		}
+	}
+	return res
+}
+
+// pullStream 로컬에 없는 스트림을 오리진에서 받아옵니다. 이미 받아오는 중이면 기존 풀을 기다립니다.
+// 재생할 수 있게 되면 세션에 등록된 가상 퍼블리셔를 반환합니다.
+func (ctx *StreamContext) pullStream(conf *AppConfig, stream string) (*Connection, error) {
+	if len(conf.Origins) == 0 {
+		return nil, errNoOrigin
+	}
+
+	key := sessionKey(conf.Name, stream)
+	ctx.mu.Lock()
+	co := ctx.Sessions[key]
+	if co == nil {
+		idle := conf.EdgeIdleTimeout
+		if idle <= 0 {
+			idle = edgeDefaultIdleTimeout
+		}
+		co = &Connection{
+			Context:   ctx,
+			AppName:   conf.Name,
+			StreamKey: stream,
+			Hub:       NewStreamHub(),
+		}
+		pullCtx, cancel := context.WithCancel(context.Background())
+		co.edge = &edgePull{conn: co, idle: idle, ready: make(chan struct{}), ctx: pullCtx, cancel: cancel}
+		ctx.Sessions[key] = co
+		go co.edge.run(conf.originURLs(stream))
+	}
+	ctx.mu.Unlock()
+
+	if co.edge == nil {
+		// 그 사이 로컬 퍼블리셔가 들어온 경우입니다.
+		if co.Hub == nil {
+			return nil, ErrStreamNotFound
+		}
+		return co, nil
+	}
+	<-co.edge.ready
+	if co.edge.err != nil {
+		return nil, co.edge.err
+	}
+	return co, nil
+}
+
+func (e *edgePull) run(origins []string) {
+	defer e.teardown()
+
+	for _, origin := range origins {
+		client, err := e.connect(origin)
+		if err != nil {
+			log.Printf("Edge pull from %s failed: %s", origin, err.Error())
+			e.err = err
+			continue
+		}
+		e.client, e.origin, e.err = client, origin, nil
+		break
+	}
+	if e.client == nil && e.err == nil {
+		e.err = errNoOrigin
+	}
+	close(e.ready)
+	if e.err != nil {
+		return
+	}
+	log.Printf("Edge pull started %s", e.origin)
+
+	go e.watchIdle()
+	for {
+		p, err := e.client.ReadPacket(e.ctx)
+		if err != nil {
+			log.Printf("Edge pull from %s ended: %s", e.origin, err.Error())
+			return
+		}
+		e.conn.Hub.Write(p)
+	}
+}
+
+func (e *edgePull) connect(origin string) (*RTMPClient, error) {
+	ctx, cancel := context.WithTimeout(e.ctx, edgeConnectTimeout)
+	defer cancel()
+
+	client, err := DialRTMP(ctx, origin)
+	if err != nil {
+		return nil, err
+	}
+	if err = client.Connect(ctx); err == nil {
+		if err = client.CreateStream(ctx); err == nil {
+			err = client.Play(ctx, client.Stream)
+		}
+	}
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// watchIdle 마지막 시청자가 나간 뒤 idle 동안 새 시청자가 없으면 업스트림 연결을 닫습니다.
+// idle이 검사 주기보다 짧으면 idle의 절반마다 검사합니다.
+func (e *edgePull) watchIdle() {
+	ticker := time.NewTicker(min(edgeIdleCheckInterval, max(e.idle/2, time.Millisecond)))
+	defer ticker.Stop()
+	var idleSince time.Time
+	for {
+		select {
+		case <-e.ctx.Done():
+			return
+		case <-e.client.Done():
+			return
+		case now := <-ticker.C:
+			if e.conn.Hub.SubscriberCount() > 0 {
+				idleSince = time.Time{}
+				continue
+			}
+			if idleSince.IsZero() {
+				idleSince = now
+			} else if now.Sub(idleSince) >= e.idle {
+				log.Printf("Edge pull idle for %s, closing %s", e.idle, e.origin)
+				e.client.Close()
+				return
+			}
+		}
+	}
+}
+
+// teardown 세션에서 빼고 허브를 닫습니다. 새 시청자는 다시 오리진에서 받아오게 됩니다.
+func (e *edgePull) teardown() {
+	e.conn.Context.remove(sessionKey(e.conn.AppName, e.conn.StreamKey), e.conn)
+	e.conn.Hub.Close()
+	e.cancel()
+	if e.client != nil {
+		e.client.Close()
+	}
+}
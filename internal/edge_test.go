@@ -0,0 +1,150 @@
+package internal
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEdgePull(t *testing.T) {
+	_, origin := startTestServer(t, map[string]*AppConfig{"live": {}})
+	edge, addr := startTestServer(t, map[string]*AppConfig{
+		"live": {Origins: []string{"rtmp://" + origin}},
+	})
+
+	publishTestStream(t, "rtmp://"+origin+"/live/pulled")
+	player := playTestStream(t, "rtmp://"+addr+"/live/pulled")
+	expectTestKeyFrame(t, player)
+
+	co := edge.Context.lookupStream("live", "pulled")
+	if co == nil || co.edge == nil {
+		t.Fatal("edge session not registered")
+	}
+	if want := "rtmp://" + origin + "/live/pulled"; co.edge.origin != want {
+		t.Errorf("pulled from %s, want %s", co.edge.origin, want)
+	}
+}
+
+func TestEdgePullFailover(t *testing.T) {
+	down := unusedAddr(t)
+	_, origin := startTestServer(t, map[string]*AppConfig{"live": {}})
+	edge, addr := startTestServer(t, map[string]*AppConfig{
+		"live": {Origins: []string{"rtmp://" + down + "/live", "rtmp://" + origin + "/live"}},
+	})
+
+	publishTestStream(t, "rtmp://"+origin+"/live/failover")
+	player := playTestStream(t, "rtmp://"+addr+"/live/failover")
+	expectTestKeyFrame(t, player)
+
+	co := edge.Context.lookupStream("live", "failover")
+	if co == nil || co.edge == nil {
+		t.Fatal("edge session not registered")
+	}
+	if want := "rtmp://" + origin + "/live/failover"; co.edge.origin != want {
+		t.Errorf("pulled from %s, want %s", co.edge.origin, want)
+	}
+}
+
+func TestEdgePullAllOriginsDown(t *testing.T) {
+	edge, addr := startTestServer(t, map[string]*AppConfig{
+		"live": {Origins: []string{"rtmp://" + unusedAddr(t), "rtmp://" + unusedAddr(t)}},
+	})
+
+	client := dialTestClient(t, "rtmp://"+addr+"/live/missing")
+	err := client.Play(contextWithTestTimeout(t), client.Stream)
+	if status, ok := err.(*RTMPStatusError); !ok || status.Code != "NetStream.Play.StreamNotFound" {
+		t.Fatalf("play error %v, want NetStream.Play.StreamNotFound", err)
+	}
+	// 실패한 풀은 세션에서 빠지므로 다음 시청자가 다시 시도할 수 있습니다.
+	waitFor(t, "failed pull to be removed", func() bool {
+		return edge.Context.lookupStream("live", "missing") == nil
+	})
+}
+
+// originSubscribers 오리진에서 스트림을 재생 중인 연결 수를 반환합니다.
+func originSubscribers(origin *Server, app, stream string) int {
+	co := origin.Context.lookupStream(app, stream)
+	if co == nil || co.Hub == nil {
+		return -1
+	}
+	return co.Hub.SubscriberCount()
+}
+
+func TestEdgePullIdle(t *testing.T) {
+	origin, originAddr := startTestServer(t, map[string]*AppConfig{"live": {}})
+	edge, addr := startTestServer(t, map[string]*AppConfig{
+		"live": {Origins: []string{"rtmp://" + originAddr}, EdgeIdleTimeout: 100 * time.Millisecond},
+	})
+
+	publishTestStream(t, "rtmp://"+originAddr+"/live/idle")
+	player := playTestStream(t, "rtmp://"+addr+"/live/idle")
+	expectTestKeyFrame(t, player)
+	if n := originSubscribers(origin, "live", "idle"); n != 1 {
+		t.Fatalf("origin subscribers %d, want 1", n)
+	}
+
+	// 시청자가 있는 동안에는 idle 시간이 지나도 업스트림을 유지합니다.
+	time.Sleep(300 * time.Millisecond)
+	if edge.Context.lookupStream("live", "idle") == nil {
+		t.Fatal("edge pull closed while a viewer was playing")
+	}
+
+	player.Close()
+	waitFor(t, "idle edge pull to be removed", func() bool {
+		return edge.Context.lookupStream("live", "idle") == nil
+	})
+	waitFor(t, "origin connection to close", func() bool {
+		return originSubscribers(origin, "live", "idle") == 0
+	})
+
+	// 다음 시청자는 오리진에서 다시 받아옵니다.
+	player = playTestStream(t, "rtmp://"+addr+"/live/idle")
+	expectTestKeyFrame(t, player)
+}
+
+func TestShutdownClosesEdgePulls(t *testing.T) {
+	origin, originAddr := startTestServer(t, map[string]*AppConfig{"live": {}})
+	edge, addr := startTestServer(t, map[string]*AppConfig{
+		"live": {Origins: []string{"rtmp://" + originAddr}},
+	})
+
+	publishTestStream(t, "rtmp://"+originAddr+"/live/shutdown")
+	player := playTestStream(t, "rtmp://"+addr+"/live/shutdown")
+	expectTestKeyFrame(t, player)
+
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	if err := edge.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %s", err)
+	}
+	waitFor(t, "edge session to be removed", func() bool {
+		return edge.Context.lookupStream("live", "shutdown") == nil
+	})
+	waitFor(t, "origin connection to close", func() bool {
+		return originSubscribers(origin, "live", "shutdown") == 0
+	})
+}
+
+func TestOriginURLs(t *testing.T) {
+	conf := &AppConfig{Name: "live", Origins: []string{"rtmp://a:1935", "rtmp://b/other", "rtmp://c/"}}
+	want := []string{"rtmp://a:1935/live/s1", "rtmp://b/other/s1", "rtmp://c/live/s1"}
+	if got := conf.originURLs("s1"); !reflect.DeepEqual(got, want) {
+		t.Errorf("originURLs = %v, want %v", got, want)
+	}
+
+	// 해시 링 순서는 스트림마다 정해지고, 오리진이 하나 빠져도 남은 오리진의 순서는 유지됩니다.
+	origins := []string{"rtmp://a", "rtmp://b", "rtmp://c"}
+	for _, stream := range []string{"s1", "s2", "s3", "s4"} {
+		order := hashRingOrder(origins, stream)
+		if len(order) != len(origins) {
+			t.Fatalf("hashRingOrder(%s) = %v", stream, order)
+		}
+		if again := hashRingOrder(origins, stream); !reflect.DeepEqual(order, again) {
+			t.Errorf("hashRingOrder(%s) is not stable: %v, %v", stream, order, again)
+		}
+		if got := hashRingOrder(order[1:], stream); !reflect.DeepEqual(got, order[1:]) {
+			t.Errorf("hashRingOrder(%s) without %s = %v, want %v", stream, order[0], got, order[1:])
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package internal
+
+import (
+	"example/hello/internal/format/flvio"
+	"io"
+)
+
+// flvPacketWriter 허브로부터 받은 패킷을 FLV 태그 스트림으로 씁니다. (HTTP-FLV, WebSocket-FLV에서 사용합니다.)
+// 시청자가 중간에 들어오더라도 타임스탬프가 0부터 시작하도록 첫 미디어 패킷을 기준으로 시간을 맞춥니다.
+type flvPacketWriter struct {
+	w     io.Writer
+	flush func() error
+
+	headerWritten bool
+	hasBase       bool
+	base          uint32
+}
+
+func newFLVPacketWriter(w io.Writer, flush func() error) *flvPacketWriter {
+	return &flvPacketWriter{w: w, flush: flush}
+}
+
+// writeHeader FLV 헤더를 씁니다. 시퀀스 헤더 유무로 오디오, 비디오 플래그를 정합니다.
+func (fw *flvPacketWriter) writeHeader(hub *StreamHub) error {
+	video, audio := hub.SequenceHeaders()
+	if err := flvio.WriteHeader(fw.w, audio != nil, video != nil); err != nil {
+		return err
+	}
+	fw.headerWritten = true
+	return fw.flush()
+}
+
+func (fw *flvPacketWriter) WritePacket(p *Packet) error {
+	var timestamp uint32
+	// 메타데이터와 시퀀스 헤더는 시간 0으로 보냅니다.
+	if !p.IsMetaData() && !p.IsSequenceHeader() {
+		if !fw.hasBase {
+			fw.base = p.Timestamp
+			fw.hasBase = true
+		}
+		if p.Timestamp > fw.base {
+			timestamp = p.Timestamp - fw.base
+		}
+	}
+	if err := flvio.WriteTag(fw.w, p.Type, timestamp, p.Data); err != nil {
+		return err
+	}
+	return fw.flush()
+}
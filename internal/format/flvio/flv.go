@@ -0,0 +1,161 @@
+package flvio
+
+import (
+	"encoding/binary"
+	"errors"
+	"example/hello/internal/util/endian"
+	"io"
+)
+
+// FLV 태그 타입입니다. RTMP 메시지 타입 ID와 같은 값을 사용합니다.
+const (
+	TagAudio  = 8
+	TagVideo  = 9
+	TagScript = 18
+)
+
+const (
+	// HeaderLength FLV 파일 헤더의 길이입니다. ("FLV" + 버전 1바이트 + 플래그 1바이트 + 헤더 길이 4바이트)
+	HeaderLength = 9
+	// TagHeaderLength 태그 헤더의 길이입니다. (타입 1 + 데이터 길이 3 + 타임스탬프 3 + 확장 타임스탬프 1 + 스트림 ID 3)
+	TagHeaderLength = 11
+)
+
+// WriteHeader FLV 파일 헤더와 첫 번째 PreviousTagSize(항상 0)를 씁니다.
+func WriteHeader(w io.Writer, hasAudio, hasVideo bool) error {
+	b := []byte{'F', 'L', 'V', 0x01, 0x00, 0, 0, 0, HeaderLength, 0, 0, 0, 0}
+	if hasAudio {
+		b[4] |= 0x04
+	}
+	if hasVideo {
+		b[4] |= 0x01
+	}
+	_, err := w.Write(b)
+	return err
+}
+
+// WriteTag 하나의 FLV 태그와 그 뒤의 PreviousTagSize(태그 헤더 + 데이터 길이)를 씁니다.
+func WriteTag(w io.Writer, tagType uint8, timestamp uint32, data []byte) error {
+	b := make([]byte, TagHeaderLength+len(data)+4)
+	b[0] = tagType
+	endian.PutU24BE(b[1:4], uint32(len(data)))
+	// 타임스탬프는 하위 24비트를 먼저 쓰고, 상위 8비트를 확장 타임스탬프에 씁니다.
+	endian.PutU24BE(b[4:7], timestamp&0xffffff)
+	b[7] = byte(timestamp >> 24)
+	copy(b[TagHeaderLength:], data)
+	endian.PutU32BE(b[TagHeaderLength+len(data):], uint32(TagHeaderLength+len(data)))
+	_, err := w.Write(b)
+	return err
+}
+
+var ErrInvalidFLV = errors.New("flvio: invalid FLV file")
+
+// Tag FLV 파일에서 읽은 하나의 태그입니다.
+type Tag struct {
+	Type      uint8
+	Timestamp uint32
+	Data      []byte
+	Offset    int64 // 파일에서 태그가 시작되는 위치
+}
+
+// Reader FLV 파일에서 태그를 순서대로 읽습니다.
+type Reader struct {
+	r      io.ReadSeeker
+	offset int64
+
+	HasAudio bool
+	HasVideo bool
+}
+
+// NewReader FLV 헤더를 읽고 첫 번째 태그 위치로 이동합니다.
+func NewReader(r io.ReadSeeker) (*Reader, error) {
+	header := make([]byte, HeaderLength)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	if header[0] != 'F' || header[1] != 'L' || header[2] != 'V' {
+		return nil, ErrInvalidFLV
+	}
+	dataOffset := int64(binary.BigEndian.Uint32(header[5:9]))
+	fr := &Reader{
+		r:        r,
+		HasAudio: header[4]&0x04 != 0,
+		HasVideo: header[4]&0x01 != 0,
+	}
+	// 헤더 뒤의 PreviousTagSize0(4바이트)를 건너뜁니다.
+	if err := fr.SeekTo(dataOffset + 4); err != nil {
+		return nil, err
+	}
+	return fr, nil
+}
+
+// SeekTo 태그가 시작되는 위치로 이동합니다.
+func (fr *Reader) SeekTo(offset int64) error {
+	if _, err := fr.r.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	fr.offset = offset
+	return nil
+}
+
+// Offset 다음에 읽을 태그의 위치입니다.
+func (fr *Reader) Offset() int64 {
+	return fr.offset
+}
+
+// ReadTag 다음 태그를 읽습니다. 파일 끝이면 io.EOF를 반환합니다.
+func (fr *Reader) ReadTag() (*Tag, error) {
+	tag, size, err := fr.readTagHeader()
+	if err != nil {
+		return nil, err
+	}
+	tag.Data = make([]byte, size)
+	if _, err = io.ReadFull(fr.r, tag.Data); err != nil {
+		return nil, err
+	}
+	// PreviousTagSize
+	var prev [4]byte
+	if _, err = io.ReadFull(fr.r, prev[:]); err != nil && err != io.EOF {
+		return nil, err
+	}
+	fr.offset += int64(TagHeaderLength + size + 4)
+	return tag, nil
+}
+
+// SkipTag 태그 헤더와 데이터의 앞 2바이트(코덱 정보, 패킷 타입)만 읽고 다음 태그로 넘어갑니다.
+// 키프레임 인덱스를 만들 때처럼 데이터 전체가 필요 없는 경우에 사용합니다.
+func (fr *Reader) SkipTag() (*Tag, error) {
+	tag, size, err := fr.readTagHeader()
+	if err != nil {
+		return nil, err
+	}
+	n := size
+	if n > 2 {
+		n = 2
+	}
+	tag.Data = make([]byte, n)
+	if _, err = io.ReadFull(fr.r, tag.Data); err != nil {
+		return nil, err
+	}
+	if err = fr.SeekTo(fr.offset + int64(TagHeaderLength+size+4)); err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+func (fr *Reader) readTagHeader() (*Tag, int, error) {
+	header := make([]byte, TagHeaderLength)
+	if _, err := io.ReadFull(fr.r, header); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			// 녹화 중 끊긴 파일처럼 마지막 태그가 잘린 경우도 파일 끝으로 취급합니다.
+			err = io.EOF
+		}
+		return nil, 0, err
+	}
+	tag := &Tag{
+		Type:      header[0],
+		Timestamp: endian.U24BE(header[4:7]) | uint32(header[7])<<24,
+		Offset:    fr.offset,
+	}
+	return tag, int(endian.U24BE(header[1:4])), nil
+}
@@ -0,0 +1,63 @@
+package fmp4
+
+import "encoding/binary"
+
+/*
+	ISO Base Media File Format (ISO/IEC 14496-12)
+	MP4 파일은 [크기(4바이트) + 타입(4바이트) + 바디] 형태의 박스가 트리 구조로 중첩되어 있습니다.
+	풀 박스(full box)는 바디 앞에 버전(1바이트)과 플래그(3바이트)를 추가로 가집니다.
+*/
+
+// box 자식 박스 또는 필드 바이트들을 이어 붙여 하나의 박스를 만듭니다.
+func box(typ string, parts ...[]byte) []byte {
+	size := 8
+	for _, p := range parts {
+		size += len(p)
+	}
+	res := make([]byte, 8, size)
+	binary.BigEndian.PutUint32(res[0:4], uint32(size))
+	copy(res[4:8], typ)
+	for _, p := range parts {
+		res = append(res, p...)
+	}
+	return res
+}
+
+// fullBox 버전과 플래그를 가지는 박스를 만듭니다.
+func fullBox(typ string, version uint8, flags uint32, parts ...[]byte) []byte {
+	header := []byte{version, byte(flags >> 16), byte(flags >> 8), byte(flags)}
+	return box(typ, append([][]byte{header}, parts...)...)
+}
+
+func u8(v uint8) []byte {
+	return []byte{v}
+}
+
+func u16(v uint16) []byte {
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, v)
+	return b
+}
+
+func u32(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, v)
+	return b
+}
+
+func u64(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
+
+func zeros(n int) []byte {
+	return make([]byte, n)
+}
+
+// matrix tkhd, mvhd에서 사용하는 단위 변환 행렬입니다.
+var matrix = []byte{
+	0x00, 0x01, 0x00, 0x00, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0x00, 0x01, 0x00, 0x00, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0x40, 0x00, 0x00, 0x00,
+}
@@ -0,0 +1,80 @@
+package fmp4
+
+import "io"
+
+// 샘플 플래그 (ISO/IEC 14496-12 8.8.3.1)
+const (
+	syncSampleFlags    = 0x02000000 // sample_depends_on = 2 (다른 샘플에 의존하지 않음)
+	nonSyncSampleFlags = 0x01010000 // sample_depends_on = 1, sample_is_non_sync_sample = 1
+)
+
+// Sample 하나의 미디어 샘플(비디오 프레임 또는 오디오 프레임) 입니다.
+type Sample struct {
+	Duration          uint32
+	CompositionOffset int32
+	KeyFrame          bool
+	Data              []byte
+}
+
+// TrackFragment 하나의 트랙에 대한 프래그먼트 샘플 목록입니다.
+type TrackFragment struct {
+	Track    *Track
+	BaseTime uint64 // 첫 샘플의 디코딩 시간 (트랙 timescale 기준)
+	Samples  []Sample
+}
+
+// WriteFragment moof + mdat 로 이루어진 미디어 프래그먼트를 씁니다.
+func WriteFragment(w io.Writer, sequence uint32, fragments ...*TrackFragment) error {
+	// data_offset은 moof 크기에 따라 달라지므로, 먼저 크기를 계산한 뒤 실제 값으로 다시 만듭니다.
+	offsets := make([]uint32, len(fragments))
+	moofSize := len(moof(sequence, fragments, offsets))
+
+	var mdatSize int
+	for i, f := range fragments {
+		offsets[i] = uint32(moofSize + 8 + mdatSize)
+		for _, s := range f.Samples {
+			mdatSize += len(s.Data)
+		}
+	}
+
+	if _, err := w.Write(moof(sequence, fragments, offsets)); err != nil {
+		return err
+	}
+	if _, err := w.Write(append(u32(uint32(8+mdatSize)), "mdat"...)); err != nil {
+		return err
+	}
+	for _, f := range fragments {
+		for _, s := range f.Samples {
+			if _, err := w.Write(s.Data); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func moof(sequence uint32, fragments []*TrackFragment, offsets []uint32) []byte {
+	parts := [][]byte{fullBox("mfhd", 0, 0, u32(sequence))}
+	for i, f := range fragments {
+		parts = append(parts, traf(f, offsets[i]))
+	}
+	return box("moof", parts...)
+}
+
+func traf(f *TrackFragment, dataOffset uint32) []byte {
+	// trun 플래그: data-offset | sample-duration | sample-size | sample-flags | sample-composition-time-offset
+	entries := [][]byte{u32(uint32(len(f.Samples))), u32(dataOffset)}
+	for _, s := range f.Samples {
+		flags := uint32(nonSyncSampleFlags)
+		if s.KeyFrame || f.Track.Kind == AudioTrack {
+			flags = syncSampleFlags
+		}
+		entries = append(entries, u32(s.Duration), u32(uint32(len(s.Data))), u32(flags), u32(uint32(s.CompositionOffset)))
+	}
+
+	return box("traf",
+		fullBox("tfhd", 0, 0x020000, u32(f.Track.ID)), // default-base-is-moof
+		fullBox("tfdt", 1, 0, u64(f.BaseTime)),
+		fullBox("trun", 1, 0x000f01, entries...),
+	)
+}
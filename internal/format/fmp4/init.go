@@ -0,0 +1,201 @@
+package fmp4
+
+import "io"
+
+// TrackKind 트랙의 종류 (비디오 / 오디오) 입니다.
+type TrackKind int
+
+const (
+	VideoTrack TrackKind = iota
+	AudioTrack
+)
+
+// Track 초기화 세그먼트(moov)를 만들기 위한 트랙 정보입니다.
+type Track struct {
+	ID        uint32
+	Kind      TrackKind
+	Timescale uint32
+
+	// 비디오 트랙: 해상도와 AVCDecoderConfigurationRecord
+	Width  int
+	Height int
+	AVCC   []byte
+
+	// 오디오 트랙: 샘플레이트, 채널 수와 AudioSpecificConfig
+	SampleRate   int
+	ChannelCount int
+	ASC          []byte
+}
+
+// WriteInit ftyp + moov 로 이루어진 초기화 세그먼트를 씁니다. (CMAF 헤더)
+func WriteInit(w io.Writer, tracks ...*Track) error {
+	var traks, trexs [][]byte
+	var nextTrackID uint32
+	for _, t := range tracks {
+		traks = append(traks, trak(t, nil, 0, 0))
+		trexs = append(trexs, fullBox("trex", 0, 0, u32(t.ID), u32(1), u32(0), u32(0), u32(0)))
+		if t.ID >= nextTrackID {
+			nextTrackID = t.ID + 1
+		}
+	}
+
+	moovParts := [][]byte{mvhd(1000, 0, nextTrackID)}
+	moovParts = append(moovParts, traks...)
+	moovParts = append(moovParts, box("mvex", trexs...))
+
+	if _, err := w.Write(ftyp("iso6", "iso6", "cmfc", "mp41")); err != nil {
+		return err
+	}
+	_, err := w.Write(box("moov", moovParts...))
+	return err
+}
+
+func ftyp(major string, compatible ...string) []byte {
+	parts := [][]byte{[]byte(major), u32(0)}
+	for _, c := range compatible {
+		parts = append(parts, []byte(c))
+	}
+	return box("ftyp", parts...)
+}
+
+func mvhd(timescale uint32, duration uint32, nextTrackID uint32) []byte {
+	return fullBox("mvhd", 0, 0,
+		u32(0), u32(0), // creation, modification time
+		u32(timescale),
+		u32(duration),
+		u32(0x00010000), // rate 1.0
+		u16(0x0100),     // volume 1.0
+		zeros(10),       // reserved
+		matrix,
+		zeros(24), // pre_defined
+		u32(nextTrackID),
+	)
+}
+
+// trak 트랙 박스를 만듭니다. stbl이 nil이면 fMP4용 빈 샘플 테이블을 사용합니다.
+// movieDuration은 mvhd timescale(1000), mediaDuration은 트랙 timescale 기준입니다.
+func trak(t *Track, stbl []byte, movieDuration uint32, mediaDuration uint32) []byte {
+	if stbl == nil {
+		stbl = box("stbl",
+			fullBox("stsd", 0, 0, u32(1), sampleEntry(t)),
+			fullBox("stts", 0, 0, u32(0)),
+			fullBox("stsc", 0, 0, u32(0)),
+			fullBox("stsz", 0, 0, u32(0), u32(0)),
+			fullBox("stco", 0, 0, u32(0)),
+		)
+	}
+	return box("trak",
+		tkhd(t, movieDuration),
+		box("mdia",
+			mdhd(t.Timescale, mediaDuration),
+			hdlr(t.Kind),
+			box("minf",
+				mediaHeader(t.Kind),
+				box("dinf", fullBox("dref", 0, 0, u32(1), fullBox("url ", 0, 1))),
+				stbl,
+			),
+		),
+	)
+}
+
+func tkhd(t *Track, duration uint32) []byte {
+	var volume uint16
+	if t.Kind == AudioTrack {
+		volume = 0x0100
+	}
+	return fullBox("tkhd", 0, 0x000003, // track_enabled | track_in_movie
+		u32(0), u32(0), // creation, modification time
+		u32(t.ID),
+		u32(0), // reserved
+		u32(duration),
+		zeros(8),       // reserved
+		u16(0), u16(0), // layer, alternate_group
+		u16(volume),
+		u16(0), // reserved
+		matrix,
+		u32(uint32(t.Width)<<16),
+		u32(uint32(t.Height)<<16),
+	)
+}
+
+func mdhd(timescale uint32, duration uint32) []byte {
+	return fullBox("mdhd", 0, 0,
+		u32(0), u32(0), // creation, modification time
+		u32(timescale),
+		u32(duration),
+		u16(0x55c4), // language: und
+		u16(0),
+	)
+}
+
+func hdlr(kind TrackKind) []byte {
+	handler, name := "vide", "VideoHandler"
+	if kind == AudioTrack {
+		handler, name = "soun", "SoundHandler"
+	}
+	return fullBox("hdlr", 0, 0,
+		u32(0), // pre_defined
+		[]byte(handler),
+		zeros(12), // reserved
+		append([]byte(name), 0),
+	)
+}
+
+func mediaHeader(kind TrackKind) []byte {
+	if kind == AudioTrack {
+		return fullBox("smhd", 0, 0, u16(0), u16(0))
+	}
+	return fullBox("vmhd", 0, 1, u16(0), zeros(6))
+}
+
+func sampleEntry(t *Track) []byte {
+	if t.Kind == AudioTrack {
+		return box("mp4a",
+			zeros(6), u16(1), // reserved, data_reference_index
+			zeros(8), // reserved
+			u16(uint16(t.ChannelCount)),
+			u16(16),        // sample size
+			u16(0), u16(0), // pre_defined, reserved
+			u32(uint32(t.SampleRate)<<16),
+			esds(t),
+		)
+	}
+	return box("avc1",
+		zeros(6), u16(1), // reserved, data_reference_index
+		u16(0), u16(0), zeros(12), // pre_defined, reserved
+		u16(uint16(t.Width)), u16(uint16(t.Height)),
+		u32(0x00480000), u32(0x00480000), // 72 dpi
+		u32(0),      // reserved
+		u16(1),      // frame_count
+		zeros(32),   // compressorname
+		u16(0x18),   // depth
+		u16(0xffff), // pre_defined
+		box("avcC", t.AVCC),
+	)
+}
+
+// esds MPEG-4 Elementary Stream Descriptor 입니다. AudioSpecificConfig를 DecoderSpecificInfo로 담습니다.
+func esds(t *Track) []byte {
+	decSpecificInfo := descriptor(0x05, t.ASC)
+	decoderConfig := descriptor(0x04,
+		u8(0x40),       // objectTypeIndication: MPEG-4 Audio
+		u8(0x15),       // streamType: audio (0x05 << 2 | 1)
+		zeros(3),       // bufferSizeDB
+		u32(0), u32(0), // maxBitrate, avgBitrate
+		decSpecificInfo,
+	)
+	slConfig := descriptor(0x06, u8(0x02))
+	esDescriptor := descriptor(0x03, u16(uint16(t.ID)), u8(0), decoderConfig, slConfig)
+	return fullBox("esds", 0, 0, esDescriptor)
+}
+
+func descriptor(tag uint8, parts ...[]byte) []byte {
+	var body []byte
+	for _, p := range parts {
+		body = append(body, p...)
+	}
+	// 길이는 7비트씩 최대 4바이트로 표현합니다.
+	size := len(body)
+	res := []byte{tag, byte(size>>21)&0x7f | 0x80, byte(size>>14)&0x7f | 0x80, byte(size>>7)&0x7f | 0x80, byte(size) & 0x7f}
+	return append(res, body...)
+}
@@ -0,0 +1,151 @@
+package fmp4
+
+import (
+	"bytes"
+	"io"
+)
+
+// SampleInfo 일반(progressive) MP4의 샘플 테이블을 만들기 위한 샘플 정보입니다.
+type SampleInfo struct {
+	Duration          uint32
+	CompositionOffset int32
+	KeyFrame          bool
+	Size              uint32
+	Offset            int64 // mdat 페이로드 시작 기준 위치
+}
+
+// TrackSamples 하나의 트랙과 그 트랙의 전체 샘플 목록입니다.
+type TrackSamples struct {
+	Track   *Track
+	Samples []SampleInfo
+}
+
+// duration 트랙 timescale 기준 전체 길이입니다.
+func (ts *TrackSamples) duration() uint64 {
+	var d uint64
+	for _, s := range ts.Samples {
+		d += uint64(s.Duration)
+	}
+	return d
+}
+
+// WriteMovieHeader faststart MP4 의 앞부분(ftyp + moov + mdat 헤더)을 씁니다.
+// 이어서 mdatSize 바이트의 샘플 데이터를 그대로 쓰면 완성된 MP4 파일이 됩니다.
+func WriteMovieHeader(w io.Writer, mdatSize int64, tracks ...*TrackSamples) error {
+	ftypBox := ftyp("isom", "isom", "iso2", "avc1", "mp41")
+
+	// 4GB가 넘으면 64비트 크기의 mdat과 co64를 사용합니다.
+	large := mdatSize+8 > 0xffffffff
+	mdatHeader := append(u32(uint32(8+mdatSize)), "mdat"...)
+	if large {
+		mdatHeader = append(append(u32(1), "mdat"...), u64(uint64(16+mdatSize))...)
+	}
+
+	// stco 엔트리는 고정 길이이므로 moov 크기는 오프셋 값과 무관합니다. 먼저 크기를 구한 뒤 실제 오프셋으로 다시 만듭니다.
+	moovSize := len(moov(0, large, tracks))
+	base := int64(len(ftypBox)+moovSize) + int64(len(mdatHeader))
+
+	var b bytes.Buffer
+	b.Write(ftypBox)
+	b.Write(moov(base, large, tracks))
+	b.Write(mdatHeader)
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+func moov(base int64, large bool, tracks []*TrackSamples) []byte {
+	var movieDuration uint32
+	var nextTrackID uint32
+	var traks [][]byte
+	for _, ts := range tracks {
+		media := ts.duration()
+		movie := uint32(media * 1000 / uint64(ts.Track.Timescale))
+		if movie > movieDuration {
+			movieDuration = movie
+		}
+		if ts.Track.ID >= nextTrackID {
+			nextTrackID = ts.Track.ID + 1
+		}
+		traks = append(traks, trak(ts.Track, sampleTable(ts, base, large), movie, uint32(media)))
+	}
+	return box("moov", append([][]byte{mvhd(1000, movieDuration, nextTrackID)}, traks...)...)
+}
+
+// sampleTable stts, ctts, stss, stsc, stsz, stco(co64)로 샘플 테이블을 만듭니다.
+// 샘플마다 하나의 청크를 사용합니다.
+func sampleTable(ts *TrackSamples, base int64, large bool) []byte {
+	t := ts.Track
+	parts := [][]byte{fullBox("stsd", 0, 0, u32(1), sampleEntry(t))}
+
+	// stts: 같은 길이가 이어지는 샘플을 묶어서 기록합니다.
+	var stts [][]byte
+	var sttsCount uint32
+	for i := 0; i < len(ts.Samples); {
+		j := i
+		for j < len(ts.Samples) && ts.Samples[j].Duration == ts.Samples[i].Duration {
+			j++
+		}
+		stts = append(stts, u32(uint32(j-i)), u32(ts.Samples[i].Duration))
+		sttsCount++
+		i = j
+	}
+	parts = append(parts, fullBox("stts", 0, 0, append([][]byte{u32(sttsCount)}, stts...)...))
+
+	if t.Kind == VideoTrack {
+		// ctts: B 프레임이 있는 경우에만 기록합니다. (version 1은 음수 오프셋을 허용합니다.)
+		var hasOffset bool
+		for _, s := range ts.Samples {
+			if s.CompositionOffset != 0 {
+				hasOffset = true
+				break
+			}
+		}
+		if hasOffset {
+			var ctts [][]byte
+			var cttsCount uint32
+			for i := 0; i < len(ts.Samples); {
+				j := i
+				for j < len(ts.Samples) && ts.Samples[j].CompositionOffset == ts.Samples[i].CompositionOffset {
+					j++
+				}
+				ctts = append(ctts, u32(uint32(j-i)), u32(uint32(ts.Samples[i].CompositionOffset)))
+				cttsCount++
+				i = j
+			}
+			parts = append(parts, fullBox("ctts", 1, 0, append([][]byte{u32(cttsCount)}, ctts...)...))
+		}
+
+		// stss: 키프레임 샘플 번호 (1부터 시작)
+		var stss [][]byte
+		for i, s := range ts.Samples {
+			if s.KeyFrame {
+				stss = append(stss, u32(uint32(i+1)))
+			}
+		}
+		parts = append(parts, fullBox("stss", 0, 0, append([][]byte{u32(uint32(len(stss)))}, stss...)...))
+	}
+
+	parts = append(parts, fullBox("stsc", 0, 0, u32(1), u32(1), u32(1), u32(1)))
+
+	sizes := [][]byte{u32(0), u32(uint32(len(ts.Samples)))}
+	for _, s := range ts.Samples {
+		sizes = append(sizes, u32(s.Size))
+	}
+	parts = append(parts, fullBox("stsz", 0, 0, sizes...))
+
+	offsets := [][]byte{u32(uint32(len(ts.Samples)))}
+	for _, s := range ts.Samples {
+		if large {
+			offsets = append(offsets, u64(uint64(base+s.Offset)))
+		} else {
+			offsets = append(offsets, u32(uint32(base+s.Offset)))
+		}
+	}
+	if large {
+		parts = append(parts, fullBox("co64", 0, 0, offsets...))
+	} else {
+		parts = append(parts, fullBox("stco", 0, 0, offsets...))
+	}
+
+	return box("stbl", parts...)
+}
@@ -0,0 +1,57 @@
+package internal
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// apiResponse API 응답 형식입니다.
+type apiResponse struct {
+	App       string `json:"app,omitempty"`
+	Stream    string `json:"stream,omitempty"`
+	Recording bool   `json:"recording"`
+	Error     string `json:"error,omitempty"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// serveRecordAPI POST /api/record/start?app=..&stream=.. , POST /api/record/stop?app=..&stream=..
+// GET /api/record?app=..&stream=.. 는 녹화 여부를 반환합니다.
+func (ctx *StreamContext) serveRecordAPI(w http.ResponseWriter, r *http.Request) {
+	app, stream := r.URL.Query().Get("app"), r.URL.Query().Get("stream")
+	res := apiResponse{App: app, Stream: stream}
+	// 녹화 파일 경로에 사용하는 이름이므로 디렉터리를 벗어날 수 있는 이름은 받지 않습니다.
+	if !validPathName(app) || !validPathName(stream) {
+		res.Error = errInvalidName.Error()
+		writeJSON(w, http.StatusBadRequest, res)
+		return
+	}
+
+	var err error
+	switch {
+	case r.URL.Path == "/api/record" && r.Method == http.MethodGet:
+	case r.URL.Path == "/api/record/start" && r.Method == http.MethodPost:
+		err = ctx.StartRecording(app, stream)
+	case r.URL.Path == "/api/record/stop" && r.Method == http.MethodPost:
+		err = ctx.StopRecording(app, stream)
+	default:
+		writeJSON(w, http.StatusNotFound, apiResponse{Error: "not found"})
+		return
+	}
+
+	res.Recording = ctx.IsRecording(app, stream)
+	switch err {
+	case nil:
+		writeJSON(w, http.StatusOK, res)
+	case ErrStreamNotFound:
+		res.Error = err.Error()
+		writeJSON(w, http.StatusNotFound, res)
+	default:
+		res.Error = err.Error()
+		writeJSON(w, http.StatusConflict, res)
+	}
+}
@@ -0,0 +1,89 @@
+package internal
+
+import (
+	"log"
+	"net/http"
+	"time"
+)
+
+// httpFLVWriter 태그를 쓸 때마다 timeout만큼 쓰기 제한 시간을 설정해, 받지 않는 시청자의 구독이 끝나도록 합니다. (0이면 제한 없음)
+// 보낸 바이트 수는 meter에 더해 대역폭 제한에 반영합니다.
+type httpFLVWriter struct {
+	w       http.ResponseWriter
+	rc      *http.ResponseController
+	timeout time.Duration
+	meter   *rateMeter
+}
+
+func (w *httpFLVWriter) Write(b []byte) (int, error) {
+	if w.timeout > 0 {
+		w.rc.SetWriteDeadline(time.Now().Add(w.timeout))
+	}
+	n, err := w.w.Write(b)
+	w.meter.Add(n)
+	return n, err
+}
+
+// serveHTTPFLV GET /{app}/{stream}.flv 요청에 라이브 FLV 스트림을 chunked 전송으로 응답합니다.
+// FLV 헤더, onMetaData, 시퀀스 헤더, GOP 캐시를 보낸 뒤 라이브 태그를 계속 보냅니다.
+func (ctx *StreamContext) serveHTTPFLV(w http.ResponseWriter, r *http.Request, app, stream string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := ctx.checkAccess(app, AccessActionPlay, httpClientIP(r)); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err := ctx.checkToken(TokenActionPlay, app, stream, httpClientIP(r), r.URL.Query()); err != nil {
+		log.Printf("HTTP-FLV play rejected for %s/%s: %s", app, stream, err.Error())
+		http.Error(w, "invalid token: "+err.Error(), http.StatusForbidden)
+		return
+	}
+	hook := httpWebhookRequest(r, "http", app, stream)
+	if code, err := ctx.httpPlayWebhook(r, hook); err != nil {
+		log.Printf("HTTP-FLV play rejected for %s/%s: %s", app, stream, err.Error())
+		http.Error(w, err.Error(), code)
+		return
+	}
+	publisher := ctx.lookupStream(app, stream)
+	if publisher == nil {
+		http.NotFound(w, r)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	if err := ctx.addViewer(publisher); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	defer ctx.removeViewer(publisher)
+
+	w.Header().Set("Content-Type", "video/x-flv")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(http.StatusOK)
+
+	out := &httpFLVWriter{w: w, rc: http.NewResponseController(w), timeout: ctx.timeouts().Write, meter: &ctx.outgoing}
+	fw := newFLVPacketWriter(out, func() error {
+		flusher.Flush()
+		return nil
+	})
+	if err := fw.writeHeader(publisher.Hub); err != nil {
+		return
+	}
+
+	// 재생이 시작된 요청만 끝날 때 on_play_done으로 알립니다.
+	defer ctx.notifyWebhook(webhookPlayDone, hook)
+	log.Printf("HTTP-FLV play started %s/%s from %s", app, stream, r.RemoteAddr)
+	sub := publisher.Hub.Subscribe(fw)
+	select {
+	case <-r.Context().Done():
+		sub.Close()
+	case <-sub.Done():
+	}
+	log.Printf("HTTP-FLV play finished %s/%s from %s", app, stream, r.RemoteAddr)
+}
@@ -0,0 +1,165 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"example/hello/internal/amf"
+	"example/hello/internal/format/flvio"
+	"example/hello/internal/util/endian"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// testMetaData 퍼블리셔가 보내는 onMetaData 패킷입니다.
+var testMetaData = func() *Packet {
+	data, _ := amf.Encode("onMetaData", flvio.AMFECMAArray{"width": 320.0, "height": 240.0})
+	return &Packet{Type: MessageTypeData, Data: data}
+}()
+
+// publishMediaTestStream rawURL로 퍼블리시를 시작해 onMetaData와 시퀀스 헤더, 키프레임을 보냅니다.
+// video, audio가 false이면 그 트랙의 패킷은 보내지 않습니다. 허브에 모두 도착할 때까지 기다립니다.
+func publishMediaTestStream(t *testing.T, server *Server, rawURL, app, stream string, video, audio bool) *RTMPClient {
+	t.Helper()
+	client := dialTestClient(t, rawURL)
+	if err := client.Publish(contextWithTestTimeout(t), client.Stream); err != nil {
+		t.Fatalf("publish %s: %s", rawURL, err)
+	}
+	packets := []*Packet{testMetaData}
+	if video {
+		packets = append(packets, testAVCSequenceHeader, testAVCFrame(1000, true, 0))
+	}
+	if audio {
+		packets = append(packets, testAACSequenceHeader, testAACFrame(1000))
+	}
+	for _, p := range packets {
+		if err := client.WritePacket(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitFor(t, "media to reach the hub", func() bool {
+		co := server.Context.lookupStream(app, stream)
+		if co == nil || co.Hub.MetaData() == nil {
+			return false
+		}
+		videoHeader, audioHeader := co.Hub.SequenceHeaders()
+		return (videoHeader != nil) == video && (audioHeader != nil) == audio
+	})
+	return client
+}
+
+// readTestFLVTag FLV 스트림에서 태그 하나와 PreviousTagSize를 읽습니다.
+func readTestFLVTag(t *testing.T, r io.Reader) *flvio.Tag {
+	t.Helper()
+	header := make([]byte, flvio.TagHeaderLength)
+	if _, err := io.ReadFull(r, header); err != nil {
+		t.Fatalf("reading tag header: %s", err)
+	}
+	size := int(endian.U24BE(header[1:4]))
+	tag := &flvio.Tag{Type: header[0], Timestamp: endian.U24BE(header[4:7]) | uint32(header[7])<<24, Data: make([]byte, size+4)}
+	if _, err := io.ReadFull(r, tag.Data); err != nil {
+		t.Fatalf("reading tag data: %s", err)
+	}
+	if prev := binary.BigEndian.Uint32(tag.Data[size:]); prev != uint32(flvio.TagHeaderLength+size) {
+		t.Errorf("PreviousTagSize %d, want %d", prev, flvio.TagHeaderLength+size)
+	}
+	tag.Data = tag.Data[:size]
+	return tag
+}
+
+func TestHTTPFLV(t *testing.T) {
+	server, addr := startTestServer(t, map[string]*AppConfig{"live": {}})
+	srv := httptest.NewServer(http.HandlerFunc(server.Context.serveHTTP))
+	t.Cleanup(srv.Close)
+
+	publishMediaTestStream(t, server, "rtmp://"+addr+"/live/cam", "live", "cam", true, true)
+	res, err := http.Get(srv.URL + "/live/cam.flv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK || res.Header.Get("Content-Type") != "video/x-flv" {
+		t.Fatalf("status %d, Content-Type %q", res.StatusCode, res.Header.Get("Content-Type"))
+	}
+
+	header := make([]byte, flvio.HeaderLength+4)
+	if _, err := io.ReadFull(res.Body, header); err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{'F', 'L', 'V', 1, 0x05, 0, 0, 0, 9, 0, 0, 0, 0}; !bytes.Equal(header, want) {
+		t.Errorf("FLV header % x, want % x", header, want)
+	}
+
+	// onMetaData, 시퀀스 헤더를 먼저 보내고, 캐시된 GOP는 첫 미디어 패킷을 0으로 맞춘 타임스탬프로 보냅니다.
+	want := []struct {
+		p         *Packet
+		timestamp uint32
+	}{
+		{testMetaData, 0},
+		{testAVCSequenceHeader, 0},
+		{testAACSequenceHeader, 0},
+		{testAVCFrame(1000, true, 0), 0},
+		{testAACFrame(1000), 0},
+	}
+	for i, w := range want {
+		tag := readTestFLVTag(t, res.Body)
+		if tag.Type != w.p.Type || tag.Timestamp != w.timestamp || !bytes.Equal(tag.Data, w.p.Data) {
+			t.Errorf("tag %d: type %d at %dms % x, want type %d at %dms % x", i, tag.Type, tag.Timestamp, tag.Data, w.p.Type, w.timestamp, w.p.Data)
+		}
+	}
+}
+
+func TestHTTPFLVHeaderFlags(t *testing.T) {
+	server, addr := startTestServer(t, map[string]*AppConfig{"live": {}})
+	srv := httptest.NewServer(http.HandlerFunc(server.Context.serveHTTP))
+	t.Cleanup(srv.Close)
+
+	for _, tt := range []struct {
+		stream       string
+		video, audio bool
+		flags        byte
+	}{
+		{"video", true, false, 0x01},
+		{"audio", false, true, 0x04},
+	} {
+		publishMediaTestStream(t, server, "rtmp://"+addr+"/live/"+tt.stream, "live", tt.stream, tt.video, tt.audio)
+		res, err := http.Get(srv.URL + "/live/" + tt.stream + ".flv")
+		if err != nil {
+			t.Fatal(err)
+		}
+		header := make([]byte, flvio.HeaderLength)
+		_, err = io.ReadFull(res.Body, header)
+		res.Body.Close()
+		if err != nil || header[4] != tt.flags {
+			t.Errorf("%s: flags %02x, %v, want %02x", tt.stream, header[4], err, tt.flags)
+		}
+	}
+}
+
+func TestHTTPFLVNotFound(t *testing.T) {
+	server, addr := startTestServer(t, map[string]*AppConfig{"live": {}, "other": {}})
+	srv := httptest.NewServer(http.HandlerFunc(server.Context.serveHTTP))
+	t.Cleanup(srv.Close)
+	publishTestStream(t, "rtmp://"+addr+"/live/cam")
+	waitFor(t, "publisher", func() bool { return server.Context.lookupStream("live", "cam") != nil })
+
+	for _, path := range []string{"/live/missing.flv", "/other/cam.flv", "/live/cam", "/live/cam/x.flv", "/live/.flv"} {
+		res, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusNotFound {
+			t.Errorf("GET %s: %d, want 404", path, res.StatusCode)
+		}
+	}
+	res, err := http.Post(srv.URL+"/live/cam.flv", "video/x-flv", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("POST: %d, want 405", res.StatusCode)
+	}
+}
@@ -0,0 +1,69 @@
+package internal
+
+import (
+	"example/hello/internal/websocket"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// InitHTTPServer HTTP-FLV 재생 등을 위한 HTTP 서버를 시작합니다.
+func InitHTTPServer(ctx *StreamContext) {
+	ListenAndServeHTTP(NewHTTPServer(ctx))
+}
+
+// NewHTTPServer HTTP-FLV, WebSocket-FLV, RTMPT 경로를 등록한 HTTP 서버를 만듭니다. 종료할 때는 Shutdown을 호출합니다.
+// 관리 API와 /metrics는 NewAdminServer의 별도 리스너에서 제공합니다.
+func NewHTTPServer(ctx *StreamContext) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", ctx.serveHTTP)
+	return &http.Server{Addr: ctx.HTTPListen, Handler: mux}
+}
+
+// ListenAndServeHTTP srv를 시작하고, Shutdown으로 멈출 때까지 기다립니다.
+func ListenAndServeHTTP(srv *http.Server) {
+	log.Printf("HTTP Server started at %s", srv.Addr)
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Printf("Error starting HTTP server %s", err.Error())
+	}
+}
+
+// serveHTTP 경로에 따라 요청을 나눕니다.
+func (ctx *StreamContext) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	// RTMPT는 POST만 사용하므로 같은 모양의 HTTP-FLV 경로(GET /open/x.flv 등)와 겹치지 않습니다.
+	if ctx.serveRTMPT(w, r) {
+		return
+	}
+	if app, stream, ok := parseFLVPath(r.URL.Path); ok {
+		// 같은 경로로 WebSocket 업그레이드 요청이 오면 WebSocket-FLV로 응답합니다.
+		if websocket.IsUpgrade(r) {
+			ctx.serveWSFLV(w, r, app, stream)
+		} else {
+			ctx.serveHTTPFLV(w, r, app, stream)
+		}
+		return
+	}
+	http.NotFound(w, r)
+}
+
+// parseFLVPath /{app}/{stream}.flv 형식의 경로에서 app과 스트림 키를 추출합니다.
+func parseFLVPath(path string) (app string, stream string, ok bool) {
+	parts := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	if len(parts) != 2 || !strings.HasSuffix(parts[1], ".flv") {
+		return "", "", false
+	}
+	app, stream = parts[0], strings.TrimSuffix(parts[1], ".flv")
+	if app == "" || stream == "" {
+		return "", "", false
+	}
+	return app, stream, true
+}
+
+// lookupStream app과 스트림 키에 해당하는 퍼블리셔를 찾습니다.
+func (ctx *StreamContext) lookupStream(app, stream string) *Connection {
+	c := ctx.get(sessionKey(app, stream))
+	if c == nil || c.Hub == nil {
+		return nil
+	}
+	return c
+}
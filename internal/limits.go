@@ -0,0 +1,165 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 제한 종류입니다. rtmp_limit_rejections_total 메트릭의 limit 라벨로도 사용합니다.
+const (
+	limitConnections      = "connections"
+	limitConnectionsPerIP = "connections_per_ip"
+	limitPublishers       = "publishers"
+	limitViewers          = "viewers"
+	limitBandwidth        = "bandwidth"
+)
+
+var (
+	// errTooManyConnectionsPerIP 클라이언트 IP 하나의 동시 연결 수가 limits.max_connections_per_ip에 도달했습니다.
+	errTooManyConnectionsPerIP = errors.New("too many connections from this address")
+	// errBandwidthLimit 시청자에게 보내는 전체 대역폭이 limits.max_outgoing_bandwidth에 도달했습니다.
+	errBandwidthLimit = errors.New("bandwidth limit reached: the server is not accepting new viewers")
+)
+
+// rateMeter 보낸 바이트 수를 세고, 최근 1초 동안의 초당 전송량을 계산합니다.
+// 1초를 rateBucket 단위 구간으로 나누어 더하므로, Rate를 언제 호출하더라도 직전 1초의 전송량을 반환합니다.
+// 값이 0인 변수로 바로 사용할 수 있습니다.
+type rateMeter struct {
+	total atomic.Uint64
+
+	mu      sync.Mutex
+	buckets [rateBuckets]uint64
+	last    int64 // 마지막으로 더한 구간 번호 (시각 / rateBucket)
+}
+
+const (
+	rateBucket  = 100 * time.Millisecond
+	rateBuckets = int64(time.Second / rateBucket)
+)
+
+// Add 보낸 바이트 수를 더합니다.
+func (m *rateMeter) Add(n int) {
+	m.add(time.Now(), n)
+}
+
+func (m *rateMeter) add(now time.Time, n int) {
+	m.total.Add(uint64(n))
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.advance(now)
+	m.buckets[m.last%rateBuckets] += uint64(n)
+}
+
+// Rate 최근 1초 동안의 초당 바이트 수를 반환합니다.
+func (m *rateMeter) Rate() float64 {
+	return m.rate(time.Now())
+}
+
+func (m *rateMeter) rate(now time.Time) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.advance(now)
+	var sum uint64
+	for _, n := range m.buckets {
+		sum += n
+	}
+	// 구간을 모두 더하면 1초이므로 합이 초당 바이트 수입니다.
+	return float64(sum)
+}
+
+// advance 1초가 지난 구간을 비우고 now의 구간으로 옮깁니다. 시계가 뒤로 가면 마지막 구간에 계속 더합니다.
+func (m *rateMeter) advance(now time.Time) {
+	current := now.UnixNano() / int64(rateBucket)
+	if current <= m.last {
+		return
+	}
+	for i := m.last + 1; i <= current && i <= m.last+rateBuckets; i++ {
+		m.buckets[i%rateBuckets] = 0
+	}
+	m.last = current
+}
+
+// limitRejected 제한에 걸려 거절한 요청을 기록합니다.
+func (ctx *StreamContext) limitRejected(limit string) {
+	ctx.Metrics.Inc("rtmp_limit_rejections_total", "limit", limit)
+}
+
+// maxPublishers app에 적용되는 동시 퍼블리시 스트림 수 제한입니다. app 설정이 우선합니다. (0이면 제한 없음)
+func (ctx *StreamContext) maxPublishers(app string) int {
+	if max := ctx.app(app).MaxPublishers; max > 0 {
+		return max
+	}
+	return ctx.limits().MaxPublishersPerApp
+}
+
+// maxViewers app의 스트림 하나에 적용되는 동시 시청자 수 제한입니다. app 설정이 우선합니다. (0이면 제한 없음)
+func (ctx *StreamContext) maxViewers(app string) int {
+	if max := ctx.app(app).MaxViewers; max > 0 {
+		return max
+	}
+	return ctx.limits().MaxViewersPerStream
+}
+
+// checkPublisherLimit app에서 퍼블리시 중인 스트림(엣지 풀 제외) 수가 제한에 도달했는지 확인합니다.
+// 웹훅 호출 전에 미리 거절하기 위한 확인이며, 등록할 때 addPublisher가 같은 잠금 안에서 다시 확인합니다.
+func (ctx *StreamContext) checkPublisherLimit(app string) error {
+	max := ctx.maxPublishers(app)
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+	return ctx.publisherLimitLocked(app, max)
+}
+
+// publisherLimitLocked app의 퍼블리시 스트림 수가 max에 도달했으면 에러를 반환합니다. ctx.mu를 잡은 채로 호출해야 합니다.
+func (ctx *StreamContext) publisherLimitLocked(app string, max int) error {
+	if max <= 0 {
+		return nil
+	}
+	var n int
+	for _, c := range ctx.Sessions {
+		if c.AppName == app && c.edge == nil && c.Hub != nil {
+			n++
+		}
+	}
+	if n < max {
+		return nil
+	}
+	log.Printf("Publish rejected: app %s has reached its limit of %d publishers", app, max)
+	ctx.limitRejected(limitPublishers)
+	return fmt.Errorf("too many publishers: app %s has reached its limit of %d streams", app, max)
+}
+
+// addViewer 시청자 수, 대역폭 제한 안에서 퍼블리셔 co의 스트림에 시청자를 하나 더합니다.
+// 성공하면 시청이 끝날 때 removeViewer를 호출해야 합니다.
+func (ctx *StreamContext) addViewer(co *Connection) error {
+	n := co.viewers.Add(1)
+	if max := ctx.maxViewers(co.AppName); max > 0 && n > int32(max) {
+		co.viewers.Add(-1)
+		log.Printf("Play rejected: stream %s has reached its limit of %d viewers", co.StreamKey, max)
+		ctx.limitRejected(limitViewers)
+		return fmt.Errorf("too many viewers: stream %s has reached its limit of %d viewers", co.StreamKey, max)
+	}
+	if max := ctx.limits().MaxOutgoingBandwidth; max > 0 && ctx.outgoing.Rate() >= float64(max) {
+		co.viewers.Add(-1)
+		log.Printf("Play rejected for %s: outgoing bandwidth budget of %d bytes/s reached", co.StreamKey, max)
+		ctx.limitRejected(limitBandwidth)
+		return errBandwidthLimit
+	}
+	ctx.Metrics.AddGauge("rtmp_viewers", 1, "app", co.AppName)
+	return nil
+}
+
+// removeViewer addViewer로 더한 시청자를 뺍니다.
+func (ctx *StreamContext) removeViewer(co *Connection) {
+	co.viewers.Add(-1)
+	ctx.Metrics.AddGauge("rtmp_viewers", -1, "app", co.AppName)
+}
+
+// updateRateMetrics 스크레이프할 때 보낸 바이트 수와 현재 전송량을 메트릭에 반영합니다.
+func (ctx *StreamContext) updateRateMetrics() {
+	ctx.Metrics.Set("rtmp_outgoing_bytes_total", ctx.outgoing.total.Load())
+	ctx.Metrics.SetGauge("rtmp_outgoing_bytes_per_second", int64(ctx.outgoing.Rate()))
+}
@@ -0,0 +1,102 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPublisherLimitConcurrent(t *testing.T) {
+	server, addr := startTestServer(t, map[string]*AppConfig{"live": {MaxPublishers: 1}})
+
+	// 동시에 퍼블리시해도 제한을 넘어 등록되지 않아야 합니다.
+	const n = 8
+	clients := make([]*RTMPClient, n)
+	for i := range clients {
+		clients[i] = dialTestClient(t, fmt.Sprintf("rtmp://%s/live/stream%d", addr, i))
+	}
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i, client := range clients {
+		wg.Add(1)
+		go func(i int, client *RTMPClient) {
+			defer wg.Done()
+			errs[i] = client.Publish(contextWithTestTimeout(t), client.Stream)
+		}(i, client)
+	}
+	wg.Wait()
+
+	var published int
+	for _, err := range errs {
+		if err == nil {
+			published++
+		} else {
+			expectStatus(t, err, "NetStream.Publish.Denied")
+		}
+	}
+	if published != 1 {
+		t.Errorf("%d publishers accepted, want 1", published)
+	}
+	server.Context.mu.RLock()
+	sessions := len(server.Context.Sessions)
+	server.Context.mu.RUnlock()
+	if sessions != 1 {
+		t.Errorf("%d sessions registered, want 1", sessions)
+	}
+}
+
+func TestRateMeter(t *testing.T) {
+	var m rateMeter
+	start := time.Unix(1700000000, 0)
+	at := func(d time.Duration) time.Time { return start.Add(d) }
+
+	if rate := m.rate(at(0)); rate != 0 {
+		t.Errorf("empty meter: %v", rate)
+	}
+	// Rate를 호출하지 않은 동안 보낸 양도 바로 반영합니다.
+	m.add(at(0), 1000)
+	m.add(at(450*time.Millisecond), 500)
+	if rate := m.rate(at(500 * time.Millisecond)); rate != 1500 {
+		t.Errorf("rate after a burst: %v, want 1500", rate)
+	}
+	// 1초가 지난 구간은 빠집니다.
+	if rate := m.rate(at(1050 * time.Millisecond)); rate != 500 {
+		t.Errorf("rate after the first bucket expired: %v, want 500", rate)
+	}
+	if rate := m.rate(at(10 * time.Second)); rate != 0 {
+		t.Errorf("rate after idle: %v, want 0", rate)
+	}
+	if total := m.total.Load(); total != 1500 {
+		t.Errorf("total %d, want 1500", total)
+	}
+}
+
+func TestBandwidthLimitRejectsViewer(t *testing.T) {
+	const budget = 1 << 20
+	server, addr := startTestServer(t, map[string]*AppConfig{"live": {}}, func(cfg *Config) {
+		cfg.Limits.MaxOutgoingBandwidth = budget
+	})
+	publishTestStream(t, "rtmp://"+addr+"/live/cam")
+	waitFor(t, "publisher", func() bool { return server.Context.lookupStream("live", "cam") != nil })
+	expectTestKeyFrame(t, playTestStream(t, "rtmp://"+addr+"/live/cam"))
+
+	// 예산을 넘는 전송이 있은 직후의 시청자는 거절합니다.
+	server.Context.outgoing.Add(2 * budget)
+	client := dialTestClient(t, "rtmp://"+addr+"/live/cam")
+	err := client.Play(contextWithTestTimeout(t), client.Stream)
+	expectStatus(t, err, "NetStream.Play.Failed")
+
+	srv := httptest.NewServer(http.HandlerFunc(server.Context.serveHTTP))
+	t.Cleanup(srv.Close)
+	res, err := http.Get(srv.URL + "/live/cam.flv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("HTTP-FLV over budget: %d, want 503", res.StatusCode)
+	}
+}
@@ -0,0 +1,96 @@
+package internal
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// errProtocol 상대가 RTMP 스펙을 어기거나 제한을 넘는 메시지를 보냈습니다. 연결을 닫습니다.
+var errProtocol = errors.New("protocol error")
+
+func protocolError(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", errProtocol, fmt.Sprintf(format, args...))
+}
+
+const (
+	// maxChunkStreams 연결 하나가 사용할 수 있는 청크 스트림(csID) 수입니다. 실제 클라이언트는 10개를 넘지 않습니다.
+	maxChunkStreams = 64
+	// maxPendingMessageSize 연결 하나에서 여러 청크 스트림으로 동시에 조립 중인 메시지 길이의 합입니다.
+	maxPendingMessageSize = 32 << 20
+	// maxPeerChunkSize 받을 청크 크기의 상한입니다. 메시지 길이는 24비트이므로 이보다 큰 청크 크기는 같은 의미입니다.
+	maxPeerChunkSize = 0xFFFFFF
+	// payloadReadStep 메시지 데이터를 읽을 때 한 번에 늘리는 payload 크기입니다. 받지 않은 데이터만큼 미리 할당하지 않습니다.
+	payloadReadStep = 64 << 10
+)
+
+// messageSizeLimits 메시지 타입별 길이 범위(최소, 최대)입니다. 목록에 없는 타입은 최대 otherMessageSizeLimit 입니다.
+var messageSizeLimits = map[uint8][2]uint32{
+	1:                {4, 4},  // Set Chunk Size
+	2:                {4, 4},  // Abort Message
+	3:                {4, 4},  // Acknowledgement
+	4:                {2, 64}, // User Control
+	5:                {4, 4},  // Window Acknowledgement Size
+	6:                {5, 5},  // Set Peer Bandwidth
+	MessageTypeAudio: {0, 1 << 20},
+	MessageTypeVideo: {0, 0xFFFFFF}, // 고화질 키프레임도 담을 수 있도록 24비트 최대값
+	15:               {0, 1 << 20},  // AMF3 Data
+	16:               {0, 64 << 10}, // AMF3 Shared Object
+	17:               {0, 64 << 10}, // AMF3 Command
+	MessageTypeData:  {0, 1 << 20},
+	19:               {0, 64 << 10}, // AMF0 Shared Object
+	20:               {0, 64 << 10}, // AMF0 Command
+	22:               {0, 0xFFFFFF}, // Aggregate
+}
+
+const otherMessageSizeLimit = 64 << 10
+
+// checkMessageLength 메시지 첫 청크의 헤더에 적힌 길이를 타입별 범위와 연결의 조립 중인 메시지 크기로 검사합니다.
+func (c *Connection) checkMessageLength(messageType uint8, length uint32) error {
+	limits, ok := messageSizeLimits[messageType]
+	if !ok {
+		limits[1] = otherMessageSizeLimit
+	}
+	if length < limits[0] || length > limits[1] {
+		return protocolError("message type %d length %d out of range [%d, %d]", messageType, length, limits[0], limits[1])
+	}
+	if c.pendingMessageSize+int(length) > maxPendingMessageSize {
+		return protocolError("too much data in unfinished messages (%d bytes)", c.pendingMessageSize+int(length))
+	}
+	return nil
+}
+
+// setReadChunkSize Set Chunk Size 메시지로 받은 값을 검사해 적용합니다.
+// 스펙상 0과 최상위 비트가 1인 값은 허용하지 않고, 메시지 최대 길이보다 큰 값은 최대 길이로 취급합니다.
+func (c *Connection) setReadChunkSize(payload []byte) error {
+	size := binary.BigEndian.Uint32(payload)
+	if size == 0 || size&0x80000000 != 0 {
+		return protocolError("invalid chunk size %d", size)
+	}
+	if size > maxPeerChunkSize {
+		size = maxPeerChunkSize
+	}
+	c.ReadMaxChunkSize = int(size)
+	return nil
+}
+
+// abortMessage Abort Message로 받은 청크 스트림에서 조립 중인 메시지를 버립니다.
+// 조립 중인 메시지가 없는 청크 스트림이면 무시합니다.
+func (c *Connection) abortMessage(payload []byte) {
+	csID := binary.BigEndian.Uint32(payload)
+	chunk, ok := c.csMap[csID]
+	if !ok || chunk.bytes == 0 {
+		return
+	}
+	log.Printf("Message on csID %d aborted after %d of %d bytes", csID, chunk.bytes, chunk.header.length)
+	c.pendingMessageSize -= int(chunk.header.length)
+	chunk.bytes = 0
+	chunk.payload = nil
+}
+
+// onProtocolError 프로토콜 에러로 연결을 닫을 때 기록합니다.
+func (c *Connection) onProtocolError(err error) {
+	log.Printf("Protocol error from %s, closing connection: %s", c.clientIP(), err.Error())
+	c.Context.Metrics.Inc("rtmp_protocol_errors_total")
+}
@@ -0,0 +1,138 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Metrics 서버 카운터와 게이지입니다. GET /metrics에서 Prometheus 텍스트 형식으로 제공합니다.
+// 값이 0인 변수로 바로 사용할 수 있습니다.
+type Metrics struct {
+	mu       sync.Mutex
+	counters map[string]map[string]uint64 // 이름 → 라벨 → 값
+	gauges   map[string]map[string]int64
+}
+
+// Inc name 카운터를 1 늘립니다. labels는 이름, 값 순서의 쌍입니다. (예: "action", "publish")
+func (m *Metrics) Inc(name string, labels ...string) {
+	m.Add(name, 1, labels...)
+}
+
+// Add name 카운터에 n을 더합니다.
+func (m *Metrics) Add(name string, n uint64, labels ...string) {
+	key := formatLabels(labels)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.counters == nil {
+		m.counters = make(map[string]map[string]uint64)
+	}
+	series := m.counters[name]
+	if series == nil {
+		series = make(map[string]uint64)
+		m.counters[name] = series
+	}
+	series[key] += n
+}
+
+// Set 다른 곳에서 센 name 카운터의 값을 설정합니다. (보낸 바이트 수처럼 자주 바뀌는 값)
+func (m *Metrics) Set(name string, v uint64, labels ...string) {
+	m.Add(name, 0, labels...)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counters[name][formatLabels(labels)] = v
+}
+
+// AddGauge name 게이지에 delta를 더합니다. (현재 연결 수 등, 줄어들 수 있는 값)
+func (m *Metrics) AddGauge(name string, delta int64, labels ...string) {
+	key := formatLabels(labels)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.gauges == nil {
+		m.gauges = make(map[string]map[string]int64)
+	}
+	series := m.gauges[name]
+	if series == nil {
+		series = make(map[string]int64)
+		m.gauges[name] = series
+	}
+	series[key] += delta
+}
+
+// SetGauge name 게이지를 v로 설정합니다.
+func (m *Metrics) SetGauge(name string, v int64, labels ...string) {
+	m.AddGauge(name, 0, labels...)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.gauges[name][formatLabels(labels)] = v
+}
+
+// Gauge name 게이지의 현재 값을 반환합니다.
+func (m *Metrics) Gauge(name string, labels ...string) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.gauges[name][formatLabels(labels)]
+}
+
+// Value name 카운터의 현재 값을 반환합니다.
+func (m *Metrics) Value(name string, labels ...string) uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.counters[name][formatLabels(labels)]
+}
+
+// WriteTo 모든 카운터, 게이지를 이름, 라벨 순서로 정렬해 씁니다.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	m.mu.Lock()
+	for _, name := range sortedKeys(m.counters) {
+		fmt.Fprintf(&b, "# TYPE %s counter\n", name)
+		series := m.counters[name]
+		for _, key := range sortedKeys(series) {
+			fmt.Fprintf(&b, "%s%s %d\n", name, key, series[key])
+		}
+	}
+	for _, name := range sortedKeys(m.gauges) {
+		fmt.Fprintf(&b, "# TYPE %s gauge\n", name)
+		series := m.gauges[name]
+		for _, key := range sortedKeys(series) {
+			fmt.Fprintf(&b, "%s%s %d\n", name, key, series[key])
+		}
+	}
+	m.mu.Unlock()
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// sortedKeys 이름 또는 라벨을 정렬해 반환합니다. (map[string]map[string]uint64, map[string]uint64 등)
+func sortedKeys(m interface{}) []string {
+	v := reflect.ValueOf(m)
+	keys := make([]string, 0, v.Len())
+	for _, k := range v.MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatLabels(labels []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", labels[i], labels[i+1]))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// serveMetrics GET /metrics
+func (ctx *StreamContext) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	ctx.updateRateMetrics()
+	ctx.Metrics.WriteTo(w)
+}
@@ -0,0 +1,78 @@
+package internal
+
+import "example/hello/internal/util/endian"
+
+// RTMP 메시지 타입 ID 입니다.
+const (
+	MessageTypeAudio = 8
+	MessageTypeVideo = 9
+	MessageTypeData  = 18
+)
+
+// Packet 퍼블리셔로부터 받은 하나의 미디어 메시지(오디오, 비디오, 메타데이터)를 나타냅니다.
+// Data는 FLV 태그 바디와 동일한 형식이므로 RTMP, FLV, fMP4 출력에서 그대로 사용할 수 있습니다.
+type Packet struct {
+	Type      uint8
+	Timestamp uint32 // 스트림 시작 기준 절대 시간 (ms)
+	Data      []byte
+}
+
+func (p *Packet) IsAudio() bool {
+	return p.Type == MessageTypeAudio
+}
+
+func (p *Packet) IsVideo() bool {
+	return p.Type == MessageTypeVideo
+}
+
+func (p *Packet) IsMetaData() bool {
+	return p.Type == MessageTypeData
+}
+
+// IsKeyFrame 비디오 태그 헤더의 상위 4비트(frame type)가 1이면 키프레임입니다.
+func (p *Packet) IsKeyFrame() bool {
+	return p.IsVideo() && len(p.Data) > 0 && p.Data[0]>>4 == 1
+}
+
+// IsAVC 비디오 태그 헤더의 하위 4비트(codec id)가 7이면 H.264(AVC) 입니다.
+func (p *Packet) IsAVC() bool {
+	return p.IsVideo() && len(p.Data) > 1 && p.Data[0]&0x0f == 7
+}
+
+// IsAAC 오디오 태그 헤더의 상위 4비트(sound format)가 10이면 AAC 입니다.
+func (p *Packet) IsAAC() bool {
+	return p.IsAudio() && len(p.Data) > 1 && p.Data[0]>>4 == 10
+}
+
+// IsSequenceHeader AVCDecoderConfigurationRecord 또는 AudioSpecificConfig를 담은 패킷인지 확인합니다.
+// AVC/AAC 모두 두 번째 바이트(packet type)가 0이면 시퀀스 헤더입니다.
+func (p *Packet) IsSequenceHeader() bool {
+	return (p.IsAVC() || p.IsAAC()) && p.Data[1] == 0
+}
+
+// CompositionTime AVC 비디오 태그의 composition time offset (ms)을 반환합니다. (PTS = DTS + CTS)
+func (p *Packet) CompositionTime() int32 {
+	if !p.IsAVC() || len(p.Data) < 5 {
+		return 0
+	}
+	cts := int32(endian.U24BE(p.Data[2:5]))
+	// 24비트 부호 있는 정수이므로 부호를 확장합니다.
+	if cts&0x800000 != 0 {
+		cts -= 0x1000000
+	}
+	return cts
+}
+
+// Payload 태그 헤더를 제외한 실제 코덱 데이터를 반환합니다.
+// AVC는 5바이트(헤더 1 + packet type 1 + CTS 3), AAC는 2바이트(헤더 1 + packet type 1)를 건너뜁니다.
+func (p *Packet) Payload() []byte {
+	switch {
+	case p.IsAVC() && len(p.Data) >= 5:
+		return p.Data[5:]
+	case p.IsAAC():
+		return p.Data[2:]
+	case len(p.Data) > 0:
+		return p.Data[1:]
+	}
+	return nil
+}
@@ -8,25 +8,30 @@ import (
 	"os/exec"
 )
 
-var HLSOutputBasePath = "/hls-preview/"
-
 // InitPreviewServer
 func InitPreviewServer(ctx *StreamContext) {
 	for {
 		select {
-		case streamKey := <-ctx.Preview:
-			go makeHls(ctx, streamKey)
+		case key := <-ctx.Preview:
+			go makeHls(ctx, key)
 		}
 	}
 }
 
-func makeHls(ctx *StreamContext, streamKey string) {
+// makeHls key(app/스트림 이름)의 퍼블리셔를 ffmpeg로 받아 HLS 미리보기를 만듭니다.
+func makeHls(ctx *StreamContext, key string) {
 
-	c, ok := ctx.Sessions[streamKey]
-	if !ok {
-		fmt.Println("Not Found", streamKey)
+	c := ctx.get(key)
+	if c == nil {
+		fmt.Println("Not Found", key)
+		return
+	}
+	streamKey := c.StreamKey
+	output, err := outputPath(ctx.Paths.HLS, streamKey)
+	if err != nil {
+		fmt.Println("Invalid stream key", streamKey)
+		return
 	}
-	output := HLSOutputBasePath + streamKey
 	if _, err := os.Stat(output); err != nil {
 		if os.IsNotExist(err) {
 			os.MkdirAll(output, os.ModePerm)
@@ -41,7 +46,7 @@ func makeHls(ctx *StreamContext, streamKey string) {
 	f.Close()
 	cmd := exec.Command("ffmpeg",
 		"-v", "debug", // 'verbose' 대신 원래 명령의 'debug' 레벨을 사용
-		"-i", "rtmp://localhost/"+c.AppName+"/"+streamKey,
+		"-i", "rtmp://"+ctx.previewHost+"/"+c.AppName+"/"+streamKey,
 		"-c:v", "libx264",
 		"-c:a", "aac",
 		"-ac", "1",
@@ -0,0 +1,311 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// proxyDefaultTimeout ProxyProtocolConfig.Timeout이 0일 때 헤더를 기다리는 시간입니다.
+const proxyDefaultTimeout = 5 * time.Second
+
+// PROXY protocol 시그니처입니다. v2는 12바이트 바이너리, v1은 "PROXY " 텍스트로 시작합니다.
+var (
+	proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+	proxyV1Prefix    = []byte("PROXY ")
+)
+
+// proxyV1MaxLength v1 헤더의 최대 길이입니다. (CRLF 포함)
+const proxyV1MaxLength = 107
+
+// PROXY protocol v2 TLV 타입입니다.
+const (
+	ProxyTLVALPN      = 0x01
+	ProxyTLVAuthority = 0x02 // 클라이언트가 TLS SNI로 요청한 호스트 이름
+	ProxyTLVCRC32C    = 0x03
+	ProxyTLVNoop      = 0x04
+	ProxyTLVUniqueID  = 0x05
+	ProxyTLVSSL       = 0x20
+	ProxyTLVNetNS     = 0x30
+)
+
+var (
+	errProxyHeaderMissing = errors.New("proxy protocol header required")
+	errProxyHeaderInvalid = errors.New("invalid proxy protocol header")
+	errProxyChecksum      = errors.New("proxy protocol header checksum mismatch")
+)
+
+// ProxyProtocolConfig 로드 밸런서(HAProxy, AWS NLB 등)가 연결 앞에 붙이는 PROXY protocol 헤더 설정입니다.
+//
+//	proxy_protocol:
+//	  enabled: true
+//	  trusted: ["10.0.0.0/8"]
+//
+// Trusted의 주소에서 온 연결은 반드시 헤더(v1 또는 v2)를 보내야 하고, 헤더의 주소를 클라이언트 주소로 사용합니다.
+// 그 밖의 주소에서 온 연결은 헤더를 읽지 않고 직접 연결로 처리하므로, 클라이언트가 주소를 속일 수 없습니다.
+type ProxyProtocolConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Trusted 헤더를 보내는 로드 밸런서의 주소(CIDR 또는 IP)입니다. 비어 있으면 모든 연결이 헤더를 보내야 합니다.
+	Trusted []string `yaml:"trusted"`
+	// Timeout 헤더를 기다리는 시간입니다. (0이면 5초)
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+func (conf *ProxyProtocolConfig) validate() error {
+	for _, addr := range conf.Trusted {
+		if _, err := parseNetwork(addr); err != nil {
+			return fmt.Errorf("trusted: %w", err)
+		}
+	}
+	if conf.Timeout < 0 {
+		return errors.New("timeout: must not be negative")
+	}
+	return nil
+}
+
+// trusts addr에서 온 연결이 헤더를 보내야 하는지 확인합니다.
+func (conf *ProxyProtocolConfig) trusts(addr string) bool {
+	if len(conf.Trusted) == 0 {
+		return true
+	}
+	ip := net.ParseIP(addr)
+	for _, s := range conf.Trusted {
+		if network, err := parseNetwork(s); err == nil && ip != nil && network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ProxyHeader PROXY protocol 헤더로 받은 원래 연결 정보입니다.
+// LOCAL 명령(로드 밸런서의 헬스 체크)이나 UNKNOWN 주소이면 Source, Destination이 nil 입니다.
+type ProxyHeader struct {
+	Version     int
+	Source      net.Addr
+	Destination net.Addr
+	TLVs        map[byte][]byte // v2 TLV (타입 → 값)
+}
+
+// Authority 클라이언트가 요청한 호스트 이름(SNI)입니다. (v2 PP2_TYPE_AUTHORITY)
+func (h *ProxyHeader) Authority() string {
+	return string(h.TLVs[ProxyTLVAuthority])
+}
+
+// UniqueID 로드 밸런서가 붙인 연결 ID입니다. (v2 PP2_TYPE_UNIQUE_ID)
+func (h *ProxyHeader) UniqueID() []byte {
+	return h.TLVs[ProxyTLVUniqueID]
+}
+
+// proxyConn 헤더의 주소를 RemoteAddr, LocalAddr로 돌려주는 연결입니다.
+// 헤더를 읽을 때 함께 읽힌 데이터(RTMP 핸드셰이크, TLS ClientHello)는 r에 남아 이후의 Read로 전달됩니다.
+type proxyConn struct {
+	net.Conn
+	r      *bufio.Reader
+	header *ProxyHeader
+}
+
+func newProxyConn(conn net.Conn) *proxyConn {
+	return &proxyConn{Conn: conn, r: bufio.NewReader(conn)}
+}
+
+func (pc *proxyConn) Read(b []byte) (int, error) {
+	return pc.r.Read(b)
+}
+
+func (pc *proxyConn) RemoteAddr() net.Addr {
+	if pc.header != nil && pc.header.Source != nil {
+		return pc.header.Source
+	}
+	return pc.Conn.RemoteAddr()
+}
+
+func (pc *proxyConn) LocalAddr() net.Addr {
+	if pc.header != nil && pc.header.Destination != nil {
+		return pc.header.Destination
+	}
+	return pc.Conn.LocalAddr()
+}
+
+// ProxyHeader PROXY protocol로 받은 연결 정보입니다. 직접 연결이면 nil 입니다.
+func (c *Connection) ProxyHeader() *ProxyHeader {
+	conn := c.Conn
+	if tc, ok := conn.(*tls.Conn); ok {
+		conn = tc.NetConn()
+	}
+	if pc, ok := conn.(*proxyConn); ok {
+		return pc.header
+	}
+	return nil
+}
+
+// readHeader 신뢰하는 주소에서 온 연결이면 핸드셰이크(RTMPS는 TLS 핸드셰이크) 전에 PROXY protocol 헤더를 읽습니다.
+func (pc *proxyConn) readHeader(conf ProxyProtocolConfig) error {
+	if !conf.trusts(hostIP(pc.Conn.RemoteAddr())) {
+		return nil
+	}
+	timeout := conf.Timeout
+	if timeout <= 0 {
+		timeout = proxyDefaultTimeout
+	}
+	pc.SetReadDeadline(time.Now().Add(timeout))
+	defer pc.SetReadDeadline(time.Time{})
+
+	header, err := parseProxyHeader(pc.r)
+	if err != nil {
+		return err
+	}
+	pc.header = header
+	return nil
+}
+
+// parseProxyHeader v1, v2 헤더를 읽습니다.
+func parseProxyHeader(r *bufio.Reader) (*ProxyHeader, error) {
+	// v1의 가장 짧은 헤더("PROXY UNKNOWN\r\n")도 시그니처보다 길므로 12바이트를 먼저 확인합니다.
+	sig, err := r.Peek(len(proxyV2Signature))
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case bytes.Equal(sig, proxyV2Signature):
+		return parseProxyV2(r)
+	case bytes.HasPrefix(sig, proxyV1Prefix):
+		return parseProxyV1(r)
+	}
+	return nil, errProxyHeaderMissing
+}
+
+// parseProxyV1 "PROXY TCP4 192.0.2.1 198.51.100.1 56324 1935\r\n"
+func parseProxyV1(r *bufio.Reader) (*ProxyHeader, error) {
+	var line []byte
+	for len(line) < proxyV1MaxLength {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, errProxyHeaderInvalid
+	}
+	fields := strings.Split(string(line[:len(line)-2]), " ")
+	header := &ProxyHeader{Version: 1}
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return header, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, errProxyHeaderInvalid
+	}
+	src, dst := net.ParseIP(fields[2]), net.ParseIP(fields[3])
+	srcPort, err1 := strconv.ParseUint(fields[4], 10, 16)
+	dstPort, err2 := strconv.ParseUint(fields[5], 10, 16)
+	if src == nil || dst == nil || err1 != nil || err2 != nil || (src.To4() != nil) != (fields[1] == "TCP4") {
+		return nil, errProxyHeaderInvalid
+	}
+	header.Source = &net.TCPAddr{IP: src, Port: int(srcPort)}
+	header.Destination = &net.TCPAddr{IP: dst, Port: int(dstPort)}
+	return header, nil
+}
+
+// parseProxyV2 12바이트 시그니처, 버전/명령, 주소 체계/프로토콜, 길이(2바이트) 뒤에 주소와 TLV가 옵니다.
+func parseProxyV2(r *bufio.Reader) (*ProxyHeader, error) {
+	raw := make([]byte, 16)
+	if _, err := io.ReadFull(r, raw); err != nil {
+		return nil, err
+	}
+	if raw[12]>>4 != 2 {
+		return nil, errProxyHeaderInvalid
+	}
+	length := binary.BigEndian.Uint16(raw[14:16])
+	raw = append(raw, make([]byte, length)...)
+	if _, err := io.ReadFull(r, raw[16:]); err != nil {
+		return nil, err
+	}
+	payload := raw[16:]
+
+	header := &ProxyHeader{Version: 2}
+	var addrLen int
+	switch family := raw[13]; family >> 4 {
+	case 0x0: // AF_UNSPEC
+	case 0x1: // AF_INET
+		addrLen = 12
+	case 0x2: // AF_INET6
+		addrLen = 36
+	case 0x3: // AF_UNIX
+		addrLen = 216
+	default:
+		return nil, errProxyHeaderInvalid
+	}
+	if len(payload) < addrLen {
+		return nil, errProxyHeaderInvalid
+	}
+
+	switch command := raw[12] & 0x0f; command {
+	case 0x0: // LOCAL: 로드 밸런서가 직접 연결한 것이므로 주소를 바꾸지 않습니다.
+	case 0x1: // PROXY
+		// 스트림(TCP) 연결의 IPv4, IPv6 주소만 사용합니다.
+		if transport := raw[13] & 0x0f; transport == 0x1 && (addrLen == 12 || addrLen == 36) {
+			ipLen := (addrLen - 4) / 2
+			header.Source = &net.TCPAddr{
+				IP:   net.IP(append([]byte(nil), payload[:ipLen]...)),
+				Port: int(binary.BigEndian.Uint16(payload[2*ipLen:])),
+			}
+			header.Destination = &net.TCPAddr{
+				IP:   net.IP(append([]byte(nil), payload[ipLen:2*ipLen]...)),
+				Port: int(binary.BigEndian.Uint16(payload[2*ipLen+2:])),
+			}
+		}
+	default:
+		return nil, errProxyHeaderInvalid
+	}
+
+	tlvs := payload[addrLen:]
+	for len(tlvs) > 0 {
+		if len(tlvs) < 3 {
+			return nil, errProxyHeaderInvalid
+		}
+		typ, n := tlvs[0], int(binary.BigEndian.Uint16(tlvs[1:3]))
+		if len(tlvs) < 3+n {
+			return nil, errProxyHeaderInvalid
+		}
+		value := tlvs[3 : 3+n]
+		if typ == ProxyTLVCRC32C {
+			if err := checkProxyCRC32C(raw, len(raw)-len(tlvs)+3, value); err != nil {
+				return nil, err
+			}
+		}
+		if typ != ProxyTLVNoop {
+			if header.TLVs == nil {
+				header.TLVs = make(map[byte][]byte)
+			}
+			header.TLVs[typ] = append([]byte(nil), value...)
+		}
+		tlvs = tlvs[3+n:]
+	}
+	return header, nil
+}
+
+// checkProxyCRC32C 체크섬 값을 0으로 두고 헤더 전체의 CRC32c를 계산해 비교합니다.
+func checkProxyCRC32C(raw []byte, offset int, value []byte) error {
+	if len(value) != 4 {
+		return errProxyHeaderInvalid
+	}
+	want := binary.BigEndian.Uint32(value)
+	buf := append([]byte(nil), raw...)
+	copy(buf[offset:offset+4], []byte{0, 0, 0, 0})
+	if crc32.Checksum(buf, crc32.MakeTable(crc32.Castagnoli)) != want {
+		return errProxyChecksum
+	}
+	return nil
+}
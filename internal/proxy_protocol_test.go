@@ -0,0 +1,205 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+)
+
+// proxyV2TestHeader v2 헤더를 만듭니다. command는 0(LOCAL) 또는 1(PROXY), family는 주소 체계와 프로토콜 바이트입니다.
+func proxyV2TestHeader(command, family byte, addr []byte, tlvs ...[]byte) []byte {
+	payload := append([]byte(nil), addr...)
+	for _, tlv := range tlvs {
+		payload = append(payload, tlv...)
+	}
+	header := append([]byte(nil), proxyV2Signature...)
+	header = append(header, 0x20|command, family, 0, 0)
+	binary.BigEndian.PutUint16(header[14:], uint16(len(payload)))
+	return append(header, payload...)
+}
+
+func proxyTestTLV(typ byte, value []byte) []byte {
+	return append([]byte{typ, byte(len(value) >> 8), byte(len(value))}, value...)
+}
+
+// withProxyCRC32C 체크섬 TLV를 붙이고 올바른 CRC32c를 채웁니다.
+func withProxyCRC32C(command, family byte, addr []byte) []byte {
+	header := proxyV2TestHeader(command, family, addr, proxyTestTLV(ProxyTLVCRC32C, make([]byte, 4)))
+	crc := crc32.Checksum(header, crc32.MakeTable(crc32.Castagnoli))
+	binary.BigEndian.PutUint32(header[len(header)-4:], crc)
+	return header
+}
+
+var (
+	proxyTestIPv4 = []byte{192, 0, 2, 1, 198, 51, 100, 1, 0xdc, 0x04, 0x07, 0x8f} // 192.0.2.1:56324 → 198.51.100.1:1935
+	proxyTestIPv6 = append(append(append([]byte(nil), net.ParseIP("2001:db8::1")...), net.ParseIP("2001:db8::2")...), 0xdc, 0x04, 0x07, 0x8f)
+)
+
+func TestParseProxyHeader(t *testing.T) {
+	withCRC := withProxyCRC32C(1, 0x11, proxyTestIPv4)
+	badCRC := append([]byte(nil), withCRC...)
+	badCRC[len(badCRC)-1] ^= 0xff
+	oversized := proxyV2TestHeader(1, 0x11, proxyTestIPv4)
+	binary.BigEndian.PutUint16(oversized[14:], 0xffff)
+	badVersion := proxyV2TestHeader(1, 0x11, proxyTestIPv4)
+	badVersion[12] = 0x11
+
+	tests := []struct {
+		name     string
+		in       []byte
+		version  int
+		src, dst string
+		tlvs     map[byte][]byte
+		err      error
+	}{
+		{name: "v1 TCP4", in: []byte("PROXY TCP4 192.0.2.1 198.51.100.1 56324 1935\r\n"), version: 1, src: "192.0.2.1:56324", dst: "198.51.100.1:1935"},
+		{name: "v1 TCP6", in: []byte("PROXY TCP6 2001:db8::1 2001:db8::2 56324 1935\r\n"), version: 1, src: "[2001:db8::1]:56324", dst: "[2001:db8::2]:1935"},
+		{name: "v1 UNKNOWN", in: []byte("PROXY UNKNOWN ff:ff::1 ff:ff::2 1 2\r\n"), version: 1},
+		{name: "v1 family mismatch", in: []byte("PROXY TCP4 2001:db8::1 2001:db8::2 56324 1935\r\n"), err: errProxyHeaderInvalid},
+		{name: "v1 bad port", in: []byte("PROXY TCP4 192.0.2.1 198.51.100.1 70000 1935\r\n"), err: errProxyHeaderInvalid},
+		{name: "v1 without CRLF", in: append([]byte("PROXY TCP4 "), bytes.Repeat([]byte("1"), 120)...), err: errProxyHeaderInvalid},
+		{name: "v1 truncated", in: []byte("PROXY TCP4 192.0.2.1"), err: io.EOF},
+
+		{name: "v2 IPv4", in: proxyV2TestHeader(1, 0x11, proxyTestIPv4, proxyTestTLV(ProxyTLVAuthority, []byte("live.example.com")), proxyTestTLV(ProxyTLVNoop, []byte{0})),
+			version: 2, src: "192.0.2.1:56324", dst: "198.51.100.1:1935", tlvs: map[byte][]byte{ProxyTLVAuthority: []byte("live.example.com")}},
+		{name: "v2 IPv6", in: proxyV2TestHeader(1, 0x21, proxyTestIPv6), version: 2, src: "[2001:db8::1]:56324", dst: "[2001:db8::2]:1935"},
+		{name: "v2 UNIX", in: proxyV2TestHeader(1, 0x31, make([]byte, 216)), version: 2},
+		{name: "v2 UDP", in: proxyV2TestHeader(1, 0x12, proxyTestIPv4), version: 2},
+		{name: "v2 LOCAL", in: proxyV2TestHeader(0, 0x00, nil), version: 2},
+		{name: "v2 LOCAL with address", in: proxyV2TestHeader(0, 0x11, proxyTestIPv4), version: 2},
+		{name: "v2 CRC32c", in: withCRC, version: 2, src: "192.0.2.1:56324", dst: "198.51.100.1:1935",
+			tlvs: map[byte][]byte{ProxyTLVCRC32C: withCRC[len(withCRC)-4:]}},
+		{name: "v2 bad CRC32c", in: badCRC, err: errProxyChecksum},
+		{name: "v2 truncated", in: proxyV2TestHeader(1, 0x11, proxyTestIPv4)[:20], err: io.ErrUnexpectedEOF},
+		{name: "v2 truncated fixed header", in: proxyV2TestHeader(0, 0, nil)[:14], err: io.ErrUnexpectedEOF},
+		{name: "v2 oversized length", in: oversized, err: io.ErrUnexpectedEOF},
+		{name: "v2 short address", in: proxyV2TestHeader(1, 0x21, proxyTestIPv4), err: errProxyHeaderInvalid},
+		{name: "v2 bad version", in: badVersion, err: errProxyHeaderInvalid},
+		{name: "v2 bad command", in: proxyV2TestHeader(2, 0x11, proxyTestIPv4), err: errProxyHeaderInvalid},
+		{name: "v2 bad family", in: proxyV2TestHeader(1, 0x41, proxyTestIPv4), err: errProxyHeaderInvalid},
+		{name: "v2 truncated TLV", in: proxyV2TestHeader(1, 0x11, proxyTestIPv4, []byte{ProxyTLVAuthority, 0, 9, 'a'}), err: errProxyHeaderInvalid},
+
+		{name: "missing header", in: append([]byte{3}, make([]byte, 1536)...), err: errProxyHeaderMissing},
+		{name: "short connection", in: []byte("PROX"), err: io.EOF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 헤더 뒤의 데이터(RTMP 핸드셰이크)는 읽지 않고 남겨야 합니다.
+			r := bufio.NewReader(bytes.NewReader(append(append([]byte(nil), tt.in...), "rest"...)))
+			if tt.err == io.EOF || tt.err == io.ErrUnexpectedEOF {
+				r = bufio.NewReader(bytes.NewReader(tt.in))
+			}
+			header, err := parseProxyHeader(r)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("got %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if header.Version != tt.version || addrString(header.Source) != tt.src || addrString(header.Destination) != tt.dst {
+				t.Errorf("got v%d %s → %s, want v%d %s → %s", header.Version, header.Source, header.Destination, tt.version, tt.src, tt.dst)
+			}
+			if !reflect.DeepEqual(header.TLVs, tt.tlvs) {
+				t.Errorf("TLVs %q, want %q", header.TLVs, tt.tlvs)
+			}
+			if rest, _ := io.ReadAll(r); string(rest) != "rest" {
+				t.Errorf("data after the header %q", rest)
+			}
+		})
+	}
+}
+
+func addrString(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	return addr.String()
+}
+
+// proxyTestConn RemoteAddr를 바꾼 연결입니다.
+type proxyTestConn struct {
+	net.Conn
+	remote net.Addr
+}
+
+func (c *proxyTestConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+func TestProxyConnReadHeader(t *testing.T) {
+	conf := ProxyProtocolConfig{Enabled: true, Trusted: []string{"10.0.0.0/8"}}
+	header := []byte("PROXY TCP4 192.0.2.1 198.51.100.1 56324 1935\r\n")
+	tests := []struct {
+		name   string
+		remote string
+		in     []byte
+		src    string
+		err    error
+	}{
+		{"trusted with header", "10.1.2.3:4000", header, "192.0.2.1:56324", nil},
+		{"trusted without header", "10.1.2.3:4000", bytes.Repeat([]byte{3}, 16), "", errProxyHeaderMissing},
+		// 신뢰하지 않는 주소의 헤더는 읽지 않으므로 주소를 속일 수 없습니다.
+		{"untrusted with header", "192.0.2.9:4000", header, "192.0.2.9:4000", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer client.Close()
+			go client.Write(append(append([]byte(nil), tt.in...), "rest"...))
+
+			remote, _ := net.ResolveTCPAddr("tcp", tt.remote)
+			pc := newProxyConn(&proxyTestConn{Conn: server, remote: remote})
+			defer pc.Close()
+			err := pc.readHeader(conf)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if got := pc.RemoteAddr().String(); got != tt.src {
+				t.Errorf("RemoteAddr %s, want %s", got, tt.src)
+			}
+			want := append([]byte(nil), tt.in...)
+			if pc.header != nil {
+				want = nil
+			}
+			want = append(want, "rest"...)
+			got := make([]byte, len(want))
+			if _, err := io.ReadFull(pc, got); err != nil || !bytes.Equal(got, want) {
+				t.Errorf("read %q, %v, want %q", got, err, want)
+			}
+		})
+	}
+}
+
+func FuzzParseProxyHeader(f *testing.F) {
+	f.Add([]byte("PROXY TCP4 192.0.2.1 198.51.100.1 56324 1935\r\n"))
+	f.Add([]byte("PROXY UNKNOWN\r\n"))
+	f.Add(proxyV2TestHeader(1, 0x11, proxyTestIPv4, proxyTestTLV(ProxyTLVAuthority, []byte("a"))))
+	f.Add(proxyV2TestHeader(1, 0x21, proxyTestIPv6))
+	f.Add(proxyV2TestHeader(0, 0x00, nil))
+	f.Add(withProxyCRC32C(1, 0x11, proxyTestIPv4))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r := bufio.NewReader(bytes.NewReader(data))
+		header, err := parseProxyHeader(r)
+		if err != nil {
+			return
+		}
+		if header.Version != 1 && header.Version != 2 {
+			t.Fatalf("version %d", header.Version)
+		}
+		if (header.Source == nil) != (header.Destination == nil) {
+			t.Fatalf("source %v, destination %v", header.Source, header.Destination)
+		}
+	})
+}
@@ -0,0 +1,81 @@
+package internal
+
+import (
+	"errors"
+	"log"
+)
+
+var (
+	ErrStreamNotFound   = errors.New("stream not found")
+	ErrAlreadyRecording = errors.New("stream is already being recorded")
+	ErrNotRecording     = errors.New("stream is not being recorded")
+)
+
+// StartRecording 퍼블리시 중인 스트림의 녹화를 시작합니다.
+func (ctx *StreamContext) StartRecording(app, stream string) error {
+	if !validPathName(app) || !validPathName(stream) {
+		return errInvalidName
+	}
+	c := ctx.lookupStream(app, stream)
+	if c == nil {
+		return ErrStreamNotFound
+	}
+	return c.startRecording()
+}
+
+// StopRecording 녹화를 멈추고 녹화 파일을 마무리합니다.
+func (ctx *StreamContext) StopRecording(app, stream string) error {
+	c := ctx.lookupStream(app, stream)
+	if c == nil {
+		return ErrStreamNotFound
+	}
+	return c.stopRecording()
+}
+
+// IsRecording 스트림이 녹화 중인지 확인합니다.
+func (ctx *StreamContext) IsRecording(app, stream string) bool {
+	c := ctx.lookupStream(app, stream)
+	if c == nil {
+		return false
+	}
+	c.recordMu.Lock()
+	defer c.recordMu.Unlock()
+	return c.recording != nil
+}
+
+// newRecorder app 설정의 녹화 형식에 맞는 녹화기를 만듭니다. 파일은 dir/{app} 아래에 씁니다.
+func newRecorder(dir, app, stream string, conf *AppConfig) PacketWriter {
+	switch conf.RecordFormat {
+	case RecordFormatMP4:
+		return newMP4Recorder(dir, app, stream, conf, false)
+	case RecordFormatFMP4:
+		return newMP4Recorder(dir, app, stream, conf, true)
+	default:
+		return newFLVRecorder(dir, app, stream, conf)
+	}
+}
+
+func (c *Connection) startRecording() error {
+	c.recordMu.Lock()
+	defer c.recordMu.Unlock()
+	if c.recording != nil {
+		return ErrAlreadyRecording
+	}
+	c.recording = c.Hub.Subscribe(newRecorder(c.Context.Paths.Record, c.AppName, c.StreamKey, c.Context.app(c.AppName)))
+	log.Printf("Recording started for %s/%s", c.AppName, c.StreamKey)
+	return nil
+}
+
+func (c *Connection) stopRecording() error {
+	c.recordMu.Lock()
+	sub := c.recording
+	c.recording = nil
+	c.recordMu.Unlock()
+	if sub == nil {
+		return ErrNotRecording
+	}
+	// 구독이 끝나면 녹화기의 Close가 호출되어 파일이 마무리됩니다.
+	sub.Close()
+	log.Printf("Recording stopped for %s/%s", c.AppName, c.StreamKey)
+	return nil
+}
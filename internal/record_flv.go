@@ -0,0 +1,260 @@
+package internal
+
+import (
+	"example/hello/internal/amf"
+	"example/hello/internal/format/flvio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// recordFilePath {dir}/{app}/{stream}-{timestamp}.{ext} 형식의 녹화 파일 경로를 만듭니다.
+// 같은 밀리초에 파일을 나누어 이름이 겹치면 {timestamp} 뒤에 -1, -2 ...를 붙입니다.
+// app, 스트림 이름이 녹화 디렉터리를 벗어나면 errInvalidName을 반환합니다.
+func recordFilePath(dir, app, stream, ext string) (string, error) {
+	if !validPathName(stream) {
+		return "", errInvalidName
+	}
+	name := fmt.Sprintf("%s-%s", stream, time.Now().Format("20060102-150405.000"))
+	path, err := outputPath(dir, app, name+"."+ext)
+	for i := 1; err == nil && recordFileExists(path); i++ {
+		path, err = outputPath(dir, app, fmt.Sprintf("%s-%d.%s", name, i, ext))
+	}
+	return path, err
+}
+
+// recordFileExists 녹화 파일 또는 녹화 중인 .part 파일이 있는지 확인합니다.
+func recordFileExists(path string) bool {
+	for _, name := range []string{path, path + ".part"} {
+		if _, err := os.Stat(name); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// flvRecorder 허브로부터 받은 스크립트, 오디오, 비디오 메시지를 FLV 파일로 기록합니다.
+// 녹화 중에는 {파일}.part 에 유효한 FLV로 쓰고, 종료 시 duration, filesize, keyframes를 채운 onMetaData로
+// 다시 써서 최종 파일을 만듭니다.
+type flvRecorder struct {
+	dir    string // 녹화 디렉터리 (paths.record)
+	app    string
+	stream string
+	conf   *AppConfig
+
+	metaData    map[string]interface{}
+	videoHeader *Packet
+	audioHeader *Packet
+
+	file      *os.File
+	path      string
+	size      int64  // .part 파일의 현재 크기
+	dataStart int64  // .part 파일에서 미디어 태그가 시작되는 위치 (첫 onMetaData 다음)
+	base      uint32 // 파일의 첫 미디어 패킷 시간 (파일 안의 시간은 0부터 시작합니다.)
+	hasBase   bool
+	lastTime  uint32
+	startedAt time.Time
+
+	keyframeTimes     []float64
+	keyframePositions []int64 // .part 파일 기준 위치
+}
+
+func newFLVRecorder(dir, app, stream string, conf *AppConfig) *flvRecorder {
+	return &flvRecorder{dir: dir, app: app, stream: stream, conf: conf}
+}
+
+func (r *flvRecorder) WritePacket(p *Packet) error {
+	switch {
+	case p.IsMetaData():
+		r.metaData = decodeMetaData(p.Data)
+	case p.IsSequenceHeader() && p.IsVideo():
+		r.videoHeader = p
+	case p.IsSequenceHeader() && p.IsAudio():
+		r.audioHeader = p
+	}
+
+	if r.file != nil && p.IsKeyFrame() && r.conf.recordLimitReached(r.size, time.Duration(r.lastTime-r.base)*time.Millisecond) {
+		if err := r.finish(); err != nil {
+			return err
+		}
+	}
+	if r.file == nil {
+		// 재생 가능한 파일이 되도록 첫 키프레임(비디오가 없으면 첫 오디오)부터 기록합니다.
+		// 시퀀스 헤더는 open에서 따로 쓰므로 시작 조건에서 제외합니다.
+		if p.IsSequenceHeader() || !(p.IsKeyFrame() || p.IsAudio() && r.videoHeader == nil) {
+			return nil
+		}
+		if err := r.open(); err != nil {
+			return err
+		}
+	}
+	return r.writeTag(p)
+}
+
+// open 새 녹화 파일을 열고 FLV 헤더, onMetaData, 시퀀스 헤더를 씁니다.
+func (r *flvRecorder) open() (err error) {
+	if r.path, err = recordFilePath(r.dir, r.app, r.stream, "flv"); err != nil {
+		return
+	}
+	if err = os.MkdirAll(filepath.Dir(r.path), os.ModePerm); err != nil {
+		return
+	}
+	if r.file, err = os.Create(r.path + ".part"); err != nil {
+		return
+	}
+	r.size, r.hasBase, r.lastTime = 0, false, 0
+	r.keyframeTimes, r.keyframePositions = nil, nil
+	r.startedAt = time.Now()
+
+	if err = flvio.WriteHeader(r, r.audioHeader != nil, r.videoHeader != nil); err != nil {
+		return
+	}
+	if r.metaData != nil {
+		data, _ := amf.Encode("onMetaData", toAMFValue(r.metaData))
+		if err = flvio.WriteTag(r, flvio.TagScript, 0, data); err != nil {
+			return
+		}
+	}
+	r.dataStart = r.size
+	for _, header := range []*Packet{r.videoHeader, r.audioHeader} {
+		if header != nil {
+			if err = flvio.WriteTag(r, header.Type, 0, header.Data); err != nil {
+				return
+			}
+		}
+	}
+	log.Printf("Recording %s/%s to %s", r.app, r.stream, r.path)
+	return
+}
+
+func (r *flvRecorder) writeTag(p *Packet) error {
+	var timestamp uint32
+	if !p.IsMetaData() && !p.IsSequenceHeader() {
+		if !r.hasBase {
+			r.base, r.lastTime, r.hasBase = p.Timestamp, p.Timestamp, true
+		}
+		if p.Timestamp > r.base {
+			timestamp = p.Timestamp - r.base
+		}
+		if p.Timestamp > r.lastTime {
+			r.lastTime = p.Timestamp
+		}
+	}
+	if p.IsKeyFrame() && !p.IsSequenceHeader() {
+		r.keyframeTimes = append(r.keyframeTimes, float64(timestamp)/1000)
+		r.keyframePositions = append(r.keyframePositions, r.size)
+	}
+	return flvio.WriteTag(r, p.Type, timestamp, p.Data)
+}
+
+// Write .part 파일에 쓰고 크기를 계산합니다.
+func (r *flvRecorder) Write(b []byte) (int, error) {
+	n, err := r.file.Write(b)
+	r.size += int64(n)
+	return n, err
+}
+
+// finish .part 파일의 onMetaData를 duration, filesize, keyframes를 채운 onMetaData로 바꾸어 최종 파일을 만듭니다.
+func (r *flvRecorder) finish() error {
+	part := r.file
+	r.file = nil
+	defer part.Close()
+
+	duration := float64(r.lastTime-r.base) / 1000
+	meta := func(filesize int64, positions []interface{}) []byte {
+		obj := flvio.AMFECMAArray{}
+		for k, v := range r.metaData {
+			obj[k] = toAMFValue(v)
+		}
+		times := make(flvio.AMFArray, len(r.keyframeTimes))
+		for i, t := range r.keyframeTimes {
+			times[i] = t
+		}
+		obj["duration"] = duration
+		obj["filesize"] = filesize
+		obj["hasKeyframes"] = len(times) > 0
+		obj["keyframes"] = flvio.AMFMap{"filepositions": flvio.AMFArray(positions), "times": times}
+		data, _ := amf.Encode("onMetaData", obj)
+		return data
+	}
+
+	// 숫자는 항상 9바이트로 인코딩되므로, 빈 값으로 먼저 크기를 구한 뒤 실제 위치를 계산합니다.
+	placeholder := make([]interface{}, len(r.keyframePositions))
+	for i := range placeholder {
+		placeholder[i] = 0
+	}
+	metaSize := int64(flvio.TagHeaderLength + len(meta(0, placeholder)) + 4)
+	shift := flvio.HeaderLength + 4 + metaSize - r.dataStart
+	positions := make([]interface{}, len(r.keyframePositions))
+	for i, pos := range r.keyframePositions {
+		positions[i] = pos + shift
+	}
+	filesize := r.size + shift
+
+	out, err := os.Create(r.path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	header := make([]byte, flvio.HeaderLength+4)
+	if _, err = part.ReadAt(header, 0); err != nil {
+		return err
+	}
+	if _, err = out.Write(header); err != nil {
+		return err
+	}
+	if err = flvio.WriteTag(out, flvio.TagScript, 0, meta(filesize, positions)); err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, io.NewSectionReader(part, r.dataStart, r.size-r.dataStart)); err != nil {
+		return err
+	}
+
+	if err = os.Remove(part.Name()); err != nil {
+		return err
+	}
+	log.Printf("Recording finished %s (%.1fs, %d bytes)", r.path, duration, filesize)
+	return nil
+}
+
+// Close 녹화를 마치고 최종 파일을 만듭니다.
+func (r *flvRecorder) Close() error {
+	if r.file == nil {
+		return nil
+	}
+	return r.finish()
+}
+
+// decodeMetaData onMetaData 스크립트 태그 바디에서 메타데이터 객체를 꺼냅니다.
+func decodeMetaData(data []byte) map[string]interface{} {
+	command, err := amf.Decode(data)
+	if err != nil {
+		return nil
+	}
+	obj, _ := command["dataObj"].(map[string]interface{})
+	return obj
+}
+
+// toAMFValue amf.Decode가 반환한 map을 다시 인코딩할 수 있도록 AMFMap으로 바꿉니다.
+func toAMFValue(v interface{}) interface{} {
+	if m, ok := v.(map[string]interface{}); ok {
+		res := flvio.AMFECMAArray{}
+		for k, val := range m {
+			res[k] = toAMFValue(val)
+		}
+		return res
+	}
+	// strict array도 요소를 같은 방식으로 바꿉니다.
+	if a, ok := v.([]interface{}); ok {
+		res := make(flvio.AMFArray, len(a))
+		for i, val := range a {
+			res[i] = toAMFValue(val)
+		}
+		return res
+	}
+	return v
+}
@@ -0,0 +1,259 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"example/hello/internal/format/flvio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+// testFLVFile 녹화된 FLV 파일을 읽은 결과입니다.
+type testFLVFile struct {
+	path     string
+	size     int64
+	reader   *flvio.Reader
+	metaData map[string]interface{}
+	tags     []*flvio.Tag // onMetaData 다음의 태그
+}
+
+// readTestFLVFile 녹화 파일을 flvio.Reader로 끝까지 읽습니다. 첫 태그는 onMetaData여야 합니다.
+func readTestFLVFile(t *testing.T, path string) *testFLVFile {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	info, _ := f.Stat()
+	fr, err := flvio.NewReader(f)
+	if err != nil {
+		t.Fatalf("%s: %s", path, err)
+	}
+	res := &testFLVFile{path: path, size: info.Size(), reader: fr}
+	for {
+		tag, err := fr.ReadTag()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("%s: %s", path, err)
+		}
+		if res.metaData == nil {
+			if tag.Type != flvio.TagScript {
+				t.Fatalf("%s: first tag is type %d, want onMetaData", path, tag.Type)
+			}
+			res.metaData = decodeMetaData(tag.Data)
+			continue
+		}
+		res.tags = append(res.tags, tag)
+	}
+	return res
+}
+
+// keyFrames 키프레임 태그의 파일 안 시간(ms)과 퍼블리셔가 보낸 원래 시간(ms)을 반환합니다.
+// testAVCFrame은 NALU에 원래 시간의 하위 16비트를 넣습니다.
+func (f *testFLVFile) keyFrames() (times, original []uint32) {
+	for _, tag := range f.tags {
+		p := &Packet{Type: tag.Type, Data: tag.Data}
+		if p.IsKeyFrame() && !p.IsSequenceHeader() {
+			times = append(times, tag.Timestamp)
+			original = append(original, uint32(binary.LittleEndian.Uint16(p.Payload()[6:])))
+		}
+	}
+	return
+}
+
+// checkTestFLVRecording 녹화 파일의 헤더, onMetaData, 시퀀스 헤더와 키프레임 인덱스를 확인합니다.
+func checkTestFLVRecording(t *testing.T, f *testFLVFile) {
+	t.Helper()
+	if !f.reader.HasAudio || !f.reader.HasVideo {
+		t.Errorf("%s: audio %v, video %v", f.path, f.reader.HasAudio, f.reader.HasVideo)
+	}
+	if size, _ := f.metaData["filesize"].(float64); int64(size) != f.size {
+		t.Errorf("%s: filesize %v, file has %d bytes", f.path, f.metaData["filesize"], f.size)
+	}
+	if len(f.tags) < 3 || f.tags[0].Timestamp != 0 || f.tags[1].Timestamp != 0 ||
+		!(&Packet{Type: f.tags[0].Type, Data: f.tags[0].Data}).IsSequenceHeader() || !(&Packet{Type: f.tags[1].Type, Data: f.tags[1].Data}).IsSequenceHeader() {
+		t.Fatalf("%s: recording does not start with the sequence headers", f.path)
+	}
+	// 시퀀스 헤더 다음은 시간 0의 키프레임입니다.
+	if first := (&Packet{Type: f.tags[2].Type, Data: f.tags[2].Data}); !first.IsKeyFrame() || f.tags[2].Timestamp != 0 {
+		t.Errorf("%s: first media tag type %d at %dms", f.path, f.tags[2].Type, f.tags[2].Timestamp)
+	}
+
+	keyframes, _ := f.metaData["keyframes"].(map[string]interface{})
+	times, _ := keyframes["times"].([]interface{})
+	positions, _ := keyframes["filepositions"].([]interface{})
+	keyTimes, _ := f.keyFrames()
+	if len(times) != len(keyTimes) || len(positions) != len(keyTimes) {
+		t.Fatalf("%s: keyframes %v for %d keyframes", f.path, keyframes, len(keyTimes))
+	}
+	offsets := map[int64]*flvio.Tag{}
+	for _, tag := range f.tags {
+		offsets[tag.Offset] = tag
+	}
+	for i := range keyTimes {
+		tag := offsets[int64(positions[i].(float64))]
+		if tag == nil || !(&Packet{Type: tag.Type, Data: tag.Data}).IsKeyFrame() || times[i].(float64) != float64(tag.Timestamp)/1000 {
+			t.Errorf("%s: keyframe %d at %v (%vs) is not a keyframe tag", f.path, i, positions[i], times[i])
+		}
+	}
+	last := f.tags[len(f.tags)-1].Timestamp
+	if duration, _ := f.metaData["duration"].(float64); duration != float64(last)/1000 {
+		t.Errorf("%s: duration %v, last tag at %dms", f.path, f.metaData["duration"], last)
+	}
+}
+
+// testFLVContains 녹화 중인 FLV 파일에 p와 같은 데이터의 태그가 쓰였는지 확인합니다.
+func testFLVContains(path string, p *Packet) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	fr, err := flvio.NewReader(f)
+	if err != nil {
+		return false
+	}
+	for {
+		tag, err := fr.ReadTag()
+		if err != nil {
+			return false
+		}
+		if tag.Type == p.Type && bytes.Equal(tag.Data, p.Data) {
+			return true
+		}
+	}
+}
+
+// readTestRecordings dir의 녹화 파일을 모두 읽어 퍼블리셔가 보낸 시간 순서로 반환합니다.
+func readTestRecordings(t *testing.T, dir, ext string) []*testFLVFile {
+	t.Helper()
+	paths, _ := filepath.Glob(filepath.Join(dir, "*."+ext))
+	var files []*testFLVFile
+	for _, path := range paths {
+		files = append(files, readTestFLVFile(t, path))
+	}
+	sort.Slice(files, func(i, j int) bool {
+		_, a := files[i].keyFrames()
+		_, b := files[j].keyFrames()
+		return len(a) > 0 && len(b) > 0 && a[0] < b[0]
+	})
+	return files
+}
+
+func TestFLVRecorderRollover(t *testing.T) {
+	tests := []struct {
+		name string
+		conf *AppConfig
+		// 파일마다 담기는 키프레임의 원래 시간입니다.
+		files [][]uint32
+	}{
+		{"no limit", &AppConfig{}, [][]uint32{{0, 1000, 2000, 3000, 4000}}},
+		// 키프레임에서만 나누므로 2초가 지난 뒤 첫 키프레임에서 새 파일을 시작합니다.
+		{"duration", &AppConfig{RecordMaxDuration: 2 * time.Second}, [][]uint32{{0, 1000, 2000}, {3000, 4000}}},
+		{"size", &AppConfig{RecordMaxSize: 1}, [][]uint32{{0}, {1000}, {2000}, {3000}, {4000}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			r := newFLVRecorder(dir, "live", "cam", tt.conf)
+			r.WritePacket(testMetaData)
+			writeTestGOPs(t, r, 5)
+			if err := r.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if parts, _ := filepath.Glob(filepath.Join(dir, "live", "*.part")); len(parts) > 0 {
+				t.Errorf("unfinished files %v", parts)
+			}
+
+			files := readTestRecordings(t, filepath.Join(dir, "live"), "flv")
+			if len(files) != len(tt.files) {
+				t.Fatalf("%d files, want %d", len(files), len(tt.files))
+			}
+			for i, f := range files {
+				checkTestFLVRecording(t, f)
+				times, original := f.keyFrames()
+				if len(original) != len(tt.files[i]) {
+					t.Errorf("file %d: keyframes %v, want %v", i, original, tt.files[i])
+					continue
+				}
+				for j := range original {
+					// 파일마다 시간은 0부터 시작합니다.
+					if original[j] != tt.files[i][j] || times[j] != tt.files[i][j]-tt.files[i][0] {
+						t.Errorf("file %d: keyframe %d at %dms (sent at %dms), want %v", i, j, times[j], original[j], tt.files[i])
+					}
+				}
+				if width, _ := f.metaData["width"].(float64); width != 320 {
+					t.Errorf("file %d: onMetaData %v does not keep the publisher's metadata", i, f.metaData)
+				}
+			}
+		})
+	}
+}
+
+func TestRecordAPI(t *testing.T) {
+	server, addr := startTestServer(t, map[string]*AppConfig{"live": {}})
+	srv := httptest.NewServer(http.HandlerFunc(server.Context.serveRecordAPI))
+	t.Cleanup(srv.Close)
+	publisher := publishMediaTestStream(t, server, "rtmp://"+addr+"/live/cam", "live", "cam", true, true)
+
+	request := func(method, path, query string, wantStatus int, wantRecording bool) {
+		t.Helper()
+		req, _ := http.NewRequest(method, srv.URL+path+"?"+query, nil)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		var body apiResponse
+		if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+			t.Fatalf("%s %s: %s", method, path, err)
+		}
+		if res.StatusCode != wantStatus || body.Recording != wantRecording {
+			t.Errorf("%s %s?%s: %d %+v, want %d recording %v", method, path, query, res.StatusCode, body, wantStatus, wantRecording)
+		}
+	}
+
+	request(http.MethodGet, "/api/record", "app=live&stream=cam", http.StatusOK, false)
+	request(http.MethodPost, "/api/record/start", "app=live&stream=cam", http.StatusOK, true)
+	request(http.MethodPost, "/api/record/start", "app=live&stream=cam", http.StatusConflict, true)
+	request(http.MethodGet, "/api/record", "app=live&stream=cam", http.StatusOK, true)
+	request(http.MethodPost, "/api/record/start", "app=live&stream=missing", http.StatusNotFound, false)
+	request(http.MethodPost, "/api/record/start", "app=live&stream=..", http.StatusBadRequest, false)
+	request(http.MethodGet, "/api/record/start", "app=live&stream=cam", http.StatusNotFound, false)
+
+	// 녹화는 허브에 캐시된 GOP(1000ms 키프레임)부터 시작합니다.
+	for _, p := range []*Packet{testAVCFrame(1040, false, 0), testAVCFrame(2000, true, 0), testAACFrame(2010), testAVCFrame(3000, true, 0)} {
+		if err := publisher.WritePacket(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dir := filepath.Join(server.Context.Paths.Record, "live")
+	waitFor(t, "packets to be recorded", func() bool {
+		parts, _ := filepath.Glob(filepath.Join(dir, "cam-*.flv.part"))
+		return len(parts) == 1 && testFLVContains(parts[0], testAVCFrame(3000, true, 0))
+	})
+	request(http.MethodPost, "/api/record/stop", "app=live&stream=cam", http.StatusOK, false)
+	request(http.MethodPost, "/api/record/stop", "app=live&stream=cam", http.StatusConflict, false)
+
+	// 녹화기는 구독 고루틴에서 파일을 마무리합니다.
+	waitFor(t, "recording to finish", func() bool {
+		parts, _ := filepath.Glob(filepath.Join(dir, "*.part"))
+		files, _ := filepath.Glob(filepath.Join(dir, "cam-*.flv"))
+		return len(parts) == 0 && len(files) == 1
+	})
+	files := readTestRecordings(t, dir, "flv")
+	checkTestFLVRecording(t, files[0])
+	if _, original := files[0].keyFrames(); len(original) != 3 || original[0] != 1000 || original[2] != 3000 {
+		t.Errorf("recorded keyframes %v, want 1000, 2000, 3000", original)
+	}
+}
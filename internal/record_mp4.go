@@ -0,0 +1,307 @@
+package internal
+
+import (
+	"example/hello/internal/codec/aac"
+	"example/hello/internal/codec/h264"
+	"example/hello/internal/format/fmp4"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// mp4Recorder 허브로부터 받은 AVC/AAC 패킷을 MP4 파일로 기록합니다.
+//   - fragmented: 초기화 세그먼트(moov) 뒤에 GOP마다 moof + mdat을 이어 씁니다. 녹화 중 서버가 죽어도 파일을 재생할 수 있습니다.
+//   - progressive: 샘플 데이터를 {파일}.part 에 모아두었다가, 종료 시 moov를 앞에 둔 faststart MP4를 만듭니다.
+//
+// 샘플 시간은 스트림 클럭(Packet.Timestamp)을 파일 시작 기준으로 옮긴 값을 사용하고,
+// 비디오 태그 헤더의 composition time offset을 ctts/trun에 기록합니다.
+type mp4Recorder struct {
+	dir        string // 녹화 디렉터리 (paths.record)
+	app        string
+	stream     string
+	conf       *AppConfig
+	fragmented bool
+
+	videoConf *h264.DecoderConfig
+	audioConf *aac.Config
+	video     *fmp4.Track
+	audio     *fmp4.Track
+
+	file      *os.File
+	path      string
+	size      int64
+	base      uint32
+	lastTime  uint32
+	hasAudio  bool
+	audioTime uint64 // 다음 오디오 샘플의 디코딩 시간 (오디오 timescale 기준)
+	sequence  uint32
+
+	videoBuf     []*Packet // 아직 기록하지 않은 GOP
+	audioBuf     []*Packet
+	lastDuration uint32
+
+	videoSamples []fmp4.SampleInfo
+	audioSamples []fmp4.SampleInfo
+}
+
+func newMP4Recorder(dir, app, stream string, conf *AppConfig, fragmented bool) *mp4Recorder {
+	return &mp4Recorder{dir: dir, app: app, stream: stream, conf: conf, fragmented: fragmented}
+}
+
+func (r *mp4Recorder) WritePacket(p *Packet) error {
+	switch {
+	case p.IsSequenceHeader() && p.IsVideo():
+		if conf, err := h264.ParseDecoderConfig(p.Payload()); err == nil {
+			r.videoConf = conf
+		}
+		return nil
+	case p.IsSequenceHeader() && p.IsAudio():
+		if conf, err := aac.ParseConfig(p.Payload()); err == nil {
+			r.audioConf = conf
+		}
+		return nil
+	case !p.IsAVC() && !p.IsAAC():
+		return nil
+	}
+
+	// MP4는 AVC 비디오를 기준으로 GOP 단위로 기록하므로 첫 키프레임부터 시작합니다.
+	if p.IsVideo() && p.IsKeyFrame() && r.videoConf != nil {
+		if r.file != nil {
+			if err := r.flush(p.Timestamp); err != nil {
+				return err
+			}
+			if r.conf.recordLimitReached(r.size, time.Duration(p.Timestamp-r.base)*time.Millisecond) {
+				if err := r.finish(); err != nil {
+					return err
+				}
+			}
+		}
+		if r.file == nil {
+			if err := r.open(p.Timestamp); err != nil {
+				return err
+			}
+		}
+	}
+	if r.file == nil {
+		return nil
+	}
+
+	if p.Timestamp > r.lastTime {
+		r.lastTime = p.Timestamp
+	}
+	if p.IsVideo() {
+		r.videoBuf = append(r.videoBuf, p)
+	} else if r.audio != nil {
+		if !r.hasAudio {
+			// 오디오가 비디오보다 늦게 시작하면 그만큼 뒤에서 시작합니다.
+			if p.Timestamp > r.base {
+				r.audioTime = uint64(p.Timestamp-r.base) * uint64(r.audio.Timescale) / 1000
+			}
+			r.hasAudio = true
+		}
+		r.audioBuf = append(r.audioBuf, p)
+	}
+	return nil
+}
+
+// open 새 녹화 파일을 엽니다. fragmented 모드에서는 초기화 세그먼트를 바로 씁니다.
+func (r *mp4Recorder) open(timestamp uint32) (err error) {
+	if r.path, err = recordFilePath(r.dir, r.app, r.stream, "mp4"); err != nil {
+		return
+	}
+	if err = os.MkdirAll(filepath.Dir(r.path), os.ModePerm); err != nil {
+		return
+	}
+
+	r.video = &fmp4.Track{
+		ID:        1,
+		Kind:      fmp4.VideoTrack,
+		Timescale: 1000,
+		Width:     r.videoConf.Width,
+		Height:    r.videoConf.Height,
+		AVCC:      r.videoConf.Record,
+	}
+	r.audio = nil
+	if r.audioConf != nil {
+		r.audio = &fmp4.Track{
+			ID:           2,
+			Kind:         fmp4.AudioTrack,
+			Timescale:    uint32(r.audioConf.SampleRate),
+			SampleRate:   r.audioConf.SampleRate,
+			ChannelCount: r.audioConf.ChannelCount,
+			ASC:          r.audioConf.Record,
+		}
+	}
+	r.base, r.lastTime, r.size = timestamp, timestamp, 0
+	r.hasAudio, r.audioTime, r.sequence = false, 0, 0
+	r.videoSamples, r.audioSamples = nil, nil
+	// 이전 파일에서 넘어온 오디오가 있으면 그 시간부터 시작합니다.
+	if len(r.audioBuf) > 0 && r.audio != nil {
+		if first := r.audioBuf[0].Timestamp; first > r.base {
+			r.audioTime = uint64(first-r.base) * uint64(r.audio.Timescale) / 1000
+		}
+		r.hasAudio = true
+	}
+
+	name := r.path
+	if !r.fragmented {
+		name += ".part"
+	}
+	if r.file, err = os.Create(name); err != nil {
+		return
+	}
+	if r.fragmented {
+		if err = fmp4.WriteInit(r, r.tracks()...); err != nil {
+			return
+		}
+	}
+	log.Printf("Recording %s/%s to %s", r.app, r.stream, r.path)
+	return
+}
+
+func (r *mp4Recorder) tracks() []*fmp4.Track {
+	if r.audio != nil {
+		return []*fmp4.Track{r.video, r.audio}
+	}
+	return []*fmp4.Track{r.video}
+}
+
+// flush end(ms) 이전의 버퍼된 샘플을 기록합니다.
+func (r *mp4Recorder) flush(end uint32) error {
+	video := &fmp4.TrackFragment{Track: r.video}
+	for i, p := range r.videoBuf {
+		next := end
+		if i+1 < len(r.videoBuf) {
+			next = r.videoBuf[i+1].Timestamp
+		}
+		if i == 0 {
+			video.BaseTime = uint64(p.Timestamp - r.base)
+		}
+		r.lastDuration = next - p.Timestamp
+		video.Samples = append(video.Samples, fmp4.Sample{
+			Duration:          r.lastDuration,
+			CompositionOffset: p.CompositionTime(),
+			KeyFrame:          p.IsKeyFrame(),
+			Data:              p.Payload(),
+		})
+	}
+	r.videoBuf = r.videoBuf[:0]
+
+	var audio *fmp4.TrackFragment
+	if r.audio != nil {
+		audio = &fmp4.TrackFragment{Track: r.audio, BaseTime: r.audioTime}
+		var rest []*Packet
+		for _, p := range r.audioBuf {
+			if p.Timestamp >= end {
+				rest = append(rest, p)
+				continue
+			}
+			audio.Samples = append(audio.Samples, fmp4.Sample{Duration: aac.SamplesPerFrame, KeyFrame: true, Data: p.Payload()})
+		}
+		r.audioBuf = rest
+		r.audioTime += uint64(len(audio.Samples)) * aac.SamplesPerFrame
+	}
+
+	var fragments []*fmp4.TrackFragment
+	for _, f := range []*fmp4.TrackFragment{video, audio} {
+		if f != nil && len(f.Samples) > 0 {
+			fragments = append(fragments, f)
+		}
+	}
+	if len(fragments) == 0 {
+		return nil
+	}
+
+	if r.fragmented {
+		r.sequence++
+		return fmp4.WriteFragment(r, r.sequence, fragments...)
+	}
+
+	// progressive: 샘플 데이터만 .part 에 쓰고 위치를 기록해 둡니다.
+	for _, f := range fragments {
+		for _, s := range f.Samples {
+			info := fmp4.SampleInfo{
+				Duration:          s.Duration,
+				CompositionOffset: s.CompositionOffset,
+				KeyFrame:          s.KeyFrame,
+				Size:              uint32(len(s.Data)),
+				Offset:            r.size,
+			}
+			if _, err := r.Write(s.Data); err != nil {
+				return err
+			}
+			if f.Track.Kind == fmp4.VideoTrack {
+				r.videoSamples = append(r.videoSamples, info)
+			} else {
+				r.audioSamples = append(r.audioSamples, info)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *mp4Recorder) Write(b []byte) (int, error) {
+	n, err := r.file.Write(b)
+	r.size += int64(n)
+	return n, err
+}
+
+// finish 남은 샘플을 기록하고 파일을 마무리합니다. progressive 모드는 moov를 앞에 둔 최종 파일을 만듭니다.
+func (r *mp4Recorder) finish() error {
+	if len(r.videoBuf) > 0 {
+		// 마지막 프레임의 길이는 알 수 없으므로 직전 프레임 길이를 사용합니다.
+		duration := r.lastDuration
+		if duration == 0 {
+			duration = 1
+		}
+		end := r.videoBuf[len(r.videoBuf)-1].Timestamp + duration
+		if r.lastTime+1 > end {
+			end = r.lastTime + 1
+		}
+		if err := r.flush(end); err != nil {
+			return err
+		}
+	}
+
+	part := r.file
+	r.file = nil
+	defer part.Close()
+	duration := time.Duration(r.lastTime-r.base) * time.Millisecond
+
+	if r.fragmented {
+		log.Printf("Recording finished %s (%s, %d bytes)", r.path, duration, r.size)
+		return nil
+	}
+
+	out, err := os.Create(r.path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	tracks := []*fmp4.TrackSamples{{Track: r.video, Samples: r.videoSamples}}
+	if r.audio != nil && len(r.audioSamples) > 0 {
+		tracks = append(tracks, &fmp4.TrackSamples{Track: r.audio, Samples: r.audioSamples})
+	}
+	if err = fmp4.WriteMovieHeader(out, r.size, tracks...); err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, io.NewSectionReader(part, 0, r.size)); err != nil {
+		return err
+	}
+	if err = os.Remove(part.Name()); err != nil {
+		return err
+	}
+	log.Printf("Recording finished %s (%s, %d bytes)", r.path, duration, r.size)
+	return nil
+}
+
+// Close 녹화를 마치고 최종 파일을 만듭니다.
+func (r *mp4Recorder) Close() error {
+	if r.file == nil {
+		return nil
+	}
+	return r.finish()
+}
@@ -0,0 +1,265 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+// testU32s b[offset:]부터 32비트 값 n개를 읽습니다.
+func testU32s(b []byte, offset, n int) []uint32 {
+	res := make([]uint32, n)
+	for i := range res {
+		res[i] = binary.BigEndian.Uint32(b[offset+i*4:])
+	}
+	return res
+}
+
+// testMP4Track 일반 MP4 트랙 하나의 헤더와 샘플 테이블입니다.
+type testMP4Track struct {
+	handler          string
+	timescale        uint32
+	duration         uint32 // mdhd, 트랙 timescale 기준
+	movieDuration    uint32 // tkhd, ms
+	durations, sizes []uint32
+	offsets          []uint32
+	ctts             []uint32 // (개수, 오프셋) 쌍
+	keyFrames        []uint32 // stss가 없으면 nil
+}
+
+// parseTestMP4 faststart MP4 파일에서 ftyp, moov, mdat 순서와 트랙별 샘플 테이블을 읽습니다.
+func parseTestMP4(t *testing.T, data []byte) (movieDuration uint32, tracks []testMP4Track) {
+	t.Helper()
+	var types []string
+	for _, box := range parseTestBoxes(t, data) {
+		types = append(types, box.typ)
+	}
+	if len(types) != 3 || types[0] != "ftyp" || types[1] != "moov" || types[2] != "mdat" {
+		t.Fatalf("top-level boxes %v, want ftyp, moov, mdat", types)
+	}
+	if major := findTestBox(t, data, "ftyp")[:4]; string(major) != "isom" {
+		t.Errorf("major brand %q", major)
+	}
+	movieDuration = binary.BigEndian.Uint32(findTestBox(t, data, "moov", "mvhd")[16:])
+
+	for _, trak := range findTestBoxes(t, data, "moov", "trak") {
+		mdhd := findTestBox(t, trak, "mdia", "mdhd")
+		track := testMP4Track{
+			handler:       string(findTestBox(t, trak, "mdia", "hdlr")[8:12]),
+			timescale:     binary.BigEndian.Uint32(mdhd[12:]),
+			duration:      binary.BigEndian.Uint32(mdhd[16:]),
+			movieDuration: binary.BigEndian.Uint32(findTestBox(t, trak, "tkhd")[20:]),
+		}
+		stbl := findTestBox(t, trak, "mdia", "minf", "stbl")
+		boxes := map[string][]byte{}
+		for _, box := range parseTestBoxes(t, stbl) {
+			boxes[box.typ] = box.body
+		}
+		// stts는 (개수, 길이) 쌍이므로 샘플마다 풀어 둡니다.
+		stts := boxes["stts"]
+		pairs := testU32s(stts, 8, 2*int(binary.BigEndian.Uint32(stts[4:])))
+		for i := 0; i < len(pairs); i += 2 {
+			for j := uint32(0); j < pairs[i]; j++ {
+				track.durations = append(track.durations, pairs[i+1])
+			}
+		}
+		stsz := boxes["stsz"]
+		track.sizes = testU32s(stsz, 12, int(binary.BigEndian.Uint32(stsz[8:])))
+		stco := boxes["stco"]
+		track.offsets = testU32s(stco, 8, int(binary.BigEndian.Uint32(stco[4:])))
+		if ctts, ok := boxes["ctts"]; ok {
+			track.ctts = testU32s(ctts, 8, 2*int(binary.BigEndian.Uint32(ctts[4:])))
+		}
+		if stss, ok := boxes["stss"]; ok {
+			track.keyFrames = testU32s(stss, 8, int(binary.BigEndian.Uint32(stss[4:])))
+		}
+		if len(track.durations) != len(track.sizes) || len(track.sizes) != len(track.offsets) {
+			t.Fatalf("%s: %d durations, %d sizes, %d offsets", track.handler, len(track.durations), len(track.sizes), len(track.offsets))
+		}
+		tracks = append(tracks, track)
+	}
+	return
+}
+
+// checkTestMP4Samples 샘플 테이블이 가리키는 데이터가 want와 같은지 확인합니다.
+func checkTestMP4Samples(t *testing.T, data []byte, track testMP4Track, want []*Packet) {
+	t.Helper()
+	if len(track.offsets) != len(want) {
+		t.Fatalf("%s: %d samples, want %d", track.handler, len(track.offsets), len(want))
+	}
+	for i, p := range want {
+		sample := data[track.offsets[i] : track.offsets[i]+track.sizes[i]]
+		if !bytes.Equal(sample, p.Payload()) {
+			t.Errorf("%s sample %d: % x, want % x", track.handler, i, sample, p.Payload())
+		}
+	}
+}
+
+// testGOPPackets writeTestGOPs가 보내는 gops개 GOP의 비디오, 오디오 프레임입니다.
+func testGOPPackets(gops int) (video, audio []*Packet) {
+	for frame := 0; frame < gops*25; frame++ {
+		video = append(video, testAVCFrame(uint32(frame*40), frame%25 == 0, 40))
+	}
+	for n := 0; testAACTimestamp(n) < uint32((gops*25-1)*40); n++ {
+		audio = append(audio, testAACFrame(testAACTimestamp(n)))
+	}
+	return
+}
+
+func TestMP4Recorder(t *testing.T) {
+	dir := t.TempDir()
+	r := newMP4Recorder(dir, "live", "cam", &AppConfig{}, false)
+	writeTestGOPs(t, r, 3)
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if parts, _ := filepath.Glob(filepath.Join(dir, "live", "*.part")); len(parts) > 0 {
+		t.Errorf("unfinished files %v", parts)
+	}
+	paths, _ := filepath.Glob(filepath.Join(dir, "live", "cam-*.mp4"))
+	if len(paths) != 1 {
+		t.Fatalf("recordings %v", paths)
+	}
+	data := readTestFile(t, paths[0])
+	movieDuration, tracks := parseTestMP4(t, data)
+	if len(tracks) != 2 || tracks[0].handler != "vide" || tracks[1].handler != "soun" {
+		t.Fatalf("tracks %+v", tracks)
+	}
+	video, audio := tracks[0], tracks[1]
+	wantVideo, wantAudio := testGOPPackets(3)
+
+	// 마지막 프레임의 길이는 직전 프레임 길이(40ms)로 채웁니다.
+	if movieDuration != 3000 || video.timescale != 1000 || video.duration != 3000 || video.movieDuration != 3000 {
+		t.Errorf("movie %dms, video %d/%d, tkhd %dms", movieDuration, video.duration, video.timescale, video.movieDuration)
+	}
+	for i, d := range video.durations {
+		if d != 40 {
+			t.Errorf("video sample %d duration %d", i, d)
+		}
+	}
+	if len(video.ctts) != 2 || video.ctts[0] != 75 || video.ctts[1] != 40 {
+		t.Errorf("ctts %v, want 75 samples at 40ms", video.ctts)
+	}
+	if len(video.keyFrames) != 3 || video.keyFrames[0] != 1 || video.keyFrames[1] != 26 || video.keyFrames[2] != 51 {
+		t.Errorf("stss %v, want 1, 26, 51", video.keyFrames)
+	}
+	checkTestMP4Samples(t, data, video, wantVideo)
+
+	if want := uint32(len(wantAudio) * 1024); audio.timescale != 44100 || audio.duration != want || audio.movieDuration != want*1000/44100 {
+		t.Errorf("audio %d/%d, tkhd %dms, want %d", audio.duration, audio.timescale, audio.movieDuration, want)
+	}
+	if audio.keyFrames != nil || audio.ctts != nil {
+		t.Errorf("audio track has stss %v, ctts %v", audio.keyFrames, audio.ctts)
+	}
+	checkTestMP4Samples(t, data, audio, wantAudio)
+}
+
+func TestMP4RecorderRollover(t *testing.T) {
+	dir := t.TempDir()
+	r := newMP4Recorder(dir, "live", "cam", &AppConfig{RecordMaxDuration: 2 * time.Second}, false)
+	writeTestGOPs(t, r, 5)
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	paths, _ := filepath.Glob(filepath.Join(dir, "live", "cam-*.mp4"))
+	var durations []int
+	for _, path := range paths {
+		_, tracks := parseTestMP4(t, readTestFile(t, path))
+		if tracks[0].keyFrames[0] != 1 {
+			t.Errorf("%s does not start with a keyframe", path)
+		}
+		durations = append(durations, int(tracks[0].movieDuration))
+	}
+	// 키프레임에서 2초가 지나면 새 파일을 시작합니다. (비디오 트랙 길이)
+	sort.Ints(durations)
+	if len(durations) != 3 || durations[0] != 1000 || durations[1] != 2000 || durations[2] != 2000 {
+		t.Errorf("durations %v, want 1000, 2000, 2000", durations)
+	}
+}
+
+func TestFragmentedMP4Recorder(t *testing.T) {
+	dir := t.TempDir()
+	r := newMP4Recorder(dir, "live", "cam", &AppConfig{RecordFormat: RecordFormatFMP4}, true)
+	writeTestGOPs(t, r, 3)
+
+	// 녹화 중에도 이미 끝난 GOP까지는 재생할 수 있는 파일입니다.
+	paths, _ := filepath.Glob(filepath.Join(dir, "live", "cam-*.mp4"))
+	if len(paths) != 1 {
+		t.Fatalf("recordings %v", paths)
+	}
+	if boxes := parseTestBoxes(t, readTestFile(t, paths[0])); len(boxes) != 6 {
+		t.Errorf("%d boxes while recording, want ftyp, moov and 2 fragments", len(boxes))
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data := readTestFile(t, paths[0])
+	boxes := parseTestBoxes(t, data)
+	if len(boxes) != 8 || boxes[0].typ != "ftyp" || boxes[1].typ != "moov" {
+		t.Fatalf("%d boxes, want ftyp, moov and 3 fragments", len(boxes))
+	}
+	if traks := findTestBoxes(t, data, "moov", "trak"); len(traks) != 2 {
+		t.Errorf("%d tracks", len(traks))
+	}
+	if trex := findTestBoxes(t, data, "moov", "mvex", "trex"); len(trex) != 2 {
+		t.Errorf("%d trex boxes", len(trex))
+	}
+
+	wantVideo, wantAudio := testGOPPackets(3)
+	var videoTime, audioTime uint64
+	var videoSamples, audioSamples int
+	for i := 2; i < len(boxes); i += 2 {
+		moof, mdat := boxes[i], boxes[i+1]
+		if moof.typ != "moof" || mdat.typ != "mdat" {
+			t.Fatalf("fragment %d: %s, %s", i/2, moof.typ, mdat.typ)
+		}
+		if seq := binary.BigEndian.Uint32(findTestBox(t, moof.body, "mfhd")[4:]); int(seq) != i/2 {
+			t.Errorf("fragment %d: sequence %d", i/2, seq)
+		}
+		offset := 0
+		for _, traf := range findTestBoxes(t, moof.body, "traf") {
+			id := binary.BigEndian.Uint32(findTestBox(t, traf, "tfhd")[4:])
+			baseTime := binary.BigEndian.Uint64(findTestBox(t, traf, "tfdt")[4:])
+			trun := findTestBox(t, traf, "trun")
+			count := int(binary.BigEndian.Uint32(trun[4:]))
+			// data_offset은 moof 시작 기준이고, 트랙 데이터는 mdat 안에 trak 순서대로 이어집니다.
+			if dataOffset := int(binary.BigEndian.Uint32(trun[8:])); dataOffset != len(moof.body)+16+offset {
+				t.Errorf("fragment %d track %d: data_offset %d", i/2, id, dataOffset)
+			}
+			want, base := wantVideo[videoSamples:], &videoTime
+			if id == 2 {
+				want, base = wantAudio[audioSamples:], &audioTime
+			}
+			if baseTime != *base {
+				t.Errorf("fragment %d track %d: base time %d, want %d", i/2, id, baseTime, *base)
+			}
+			for j := 0; j < count; j++ {
+				e := trun[12+j*16:]
+				duration, size := binary.BigEndian.Uint32(e), int(binary.BigEndian.Uint32(e[4:]))
+				if !bytes.Equal(mdat.body[offset:offset+size], want[j].Payload()) {
+					t.Errorf("fragment %d track %d sample %d: data mismatch", i/2, id, j)
+				}
+				offset += size
+				*base += uint64(duration)
+			}
+			if id == 1 {
+				videoSamples += count
+			} else {
+				audioSamples += count
+			}
+		}
+		if offset != len(mdat.body) {
+			t.Errorf("fragment %d: samples cover %d of %d mdat bytes", i/2, offset, len(mdat.body))
+		}
+	}
+	if videoSamples != len(wantVideo) || videoTime != 3000 {
+		t.Errorf("video: %d samples, %dms", videoSamples, videoTime)
+	}
+	if audioSamples != len(wantAudio) || audioTime != uint64(len(wantAudio))*1024 {
+		t.Errorf("audio: %d samples, duration %d", audioSamples, audioTime)
+	}
+}
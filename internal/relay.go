@@ -0,0 +1,271 @@
+package internal
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	relayMinBackoff = time.Second
+	relayMaxBackoff = 30 * time.Second
+	// relayStableDuration 연결이 이 시간 이상 유지되었다가 끊어지면 대기 시간을 처음부터 다시 늘립니다.
+	relayStableDuration = 30 * time.Second
+	relayConnectTimeout = 10 * time.Second
+)
+
+// 릴레이 상태입니다.
+const (
+	RelayStateConnecting = "connecting"
+	RelayStatePublishing = "publishing"
+	RelayStateRetrying   = "retrying"
+	RelayStateStopped    = "stopped"
+)
+
+// RelayStatus 푸시 대상 하나의 상태입니다. (GET /api/relay)
+type RelayStatus struct {
+	App         string    `json:"app"`
+	Stream      string    `json:"stream"`
+	URL         string    `json:"url"`
+	State       string    `json:"state"`
+	Error       string    `json:"error,omitempty"`
+	ConnectedAt time.Time `json:"connectedAt,omitempty"`
+	Reconnects  int       `json:"reconnects"`
+	Bytes       int64     `json:"bytes"`
+}
+
+// pushTargets app 설정에서 stream을 보낼 RTMP 주소 목록을 만듭니다. 주소의 {stream}은 스트림 이름으로 바뀝니다.
+func (conf *AppConfig) pushTargets(stream string) []string {
+	var res []string
+	for _, targets := range [][]string{conf.Push, conf.StreamPush[stream]} {
+		for _, target := range targets {
+			res = append(res, strings.ReplaceAll(target, "{stream}", stream))
+		}
+	}
+	return res
+}
+
+// pushRelay 퍼블리셔 허브를 구독해 다른 RTMP 서버로 스트림을 그대로 보냅니다.
+// 연결이 끊어지면 대기 시간을 늘려가며 다시 접속하고, 그동안의 패킷은 버립니다.
+type pushRelay struct {
+	hub *StreamHub
+	url string
+	sub *Subscription // 설정을 다시 읽어 푸시 대상에서 빠지면 구독을 해제해 멈춥니다.
+
+	mu     sync.Mutex
+	status RelayStatus
+	client *RTMPClient
+	// 접속할 때마다 첫 키프레임부터 보내고, 타임스탬프를 0부터 다시 시작합니다.
+	waitKey bool
+	base    uint32
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newPushRelay(hub *StreamHub, app, stream, url string) *pushRelay {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &pushRelay{
+		hub:    hub,
+		url:    url,
+		status: RelayStatus{App: app, Stream: stream, URL: url, State: RelayStateConnecting},
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go r.run()
+	return r
+}
+
+func (r *pushRelay) run() {
+	defer close(r.done)
+	backoff := relayMinBackoff
+	for {
+		r.setState(RelayStateConnecting, nil)
+		client, err := r.connect()
+		if err == nil {
+			connectedAt := time.Now()
+			r.mu.Lock()
+			r.client, r.waitKey = client, true
+			r.status.State, r.status.Error, r.status.ConnectedAt = RelayStatePublishing, "", connectedAt
+			r.mu.Unlock()
+			log.Printf("Relay connected %s", r.url)
+
+			select {
+			case <-client.Done():
+				err = client.closedErr()
+			case <-r.ctx.Done():
+			}
+			r.mu.Lock()
+			r.client = nil
+			r.mu.Unlock()
+			client.Close()
+			if time.Since(connectedAt) >= relayStableDuration {
+				backoff = relayMinBackoff
+			}
+		}
+		if r.ctx.Err() != nil {
+			r.setState(RelayStateStopped, nil)
+			return
+		}
+
+		log.Printf("Relay to %s failed: %s, retrying in %s", r.url, err.Error(), backoff)
+		r.setState(RelayStateRetrying, err)
+		r.mu.Lock()
+		r.status.Reconnects++
+		r.mu.Unlock()
+		select {
+		case <-time.After(backoff):
+		case <-r.ctx.Done():
+			r.setState(RelayStateStopped, nil)
+			return
+		}
+		if backoff *= 2; backoff > relayMaxBackoff {
+			backoff = relayMaxBackoff
+		}
+	}
+}
+
+func (r *pushRelay) connect() (*RTMPClient, error) {
+	ctx, cancel := context.WithTimeout(r.ctx, relayConnectTimeout)
+	defer cancel()
+
+	client, err := DialRTMP(ctx, r.url)
+	if err != nil {
+		return nil, err
+	}
+	if err = client.Connect(ctx); err == nil {
+		if err = client.CreateStream(ctx); err == nil {
+			err = client.Publish(ctx, client.Stream)
+		}
+	}
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+func (r *pushRelay) setState(state string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status.State = state
+	if err != nil {
+		r.status.Error = err.Error()
+	}
+}
+
+// Status 현재 상태를 복사해 반환합니다.
+func (r *pushRelay) Status() RelayStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status
+}
+
+// WritePacket 연결되어 있을 때만 패킷을 보냅니다. 보내기에 실패하면 연결을 닫고 다시 접속합니다.
+func (r *pushRelay) WritePacket(p *Packet) error {
+	r.mu.Lock()
+	client := r.client
+	if client == nil {
+		r.mu.Unlock()
+		return nil
+	}
+	if r.waitKey {
+		// 비디오가 없는 스트림은 아무 오디오 패킷부터 보낼 수 있습니다.
+		video, _ := r.hub.SequenceHeaders()
+		if p.IsSequenceHeader() || !(p.IsKeyFrame() || (video == nil && p.IsAudio())) {
+			r.mu.Unlock()
+			return nil
+		}
+		// 새 연결에는 메타데이터와 시퀀스 헤더를 먼저 보냅니다.
+		r.waitKey, r.base = false, p.Timestamp
+		r.mu.Unlock()
+		if err := r.writeHeaders(client); err != nil {
+			client.Close()
+			return nil
+		}
+		r.mu.Lock()
+	}
+	timestamp := uint32(0)
+	if p.Timestamp > r.base {
+		timestamp = p.Timestamp - r.base
+	}
+	r.mu.Unlock()
+
+	if err := client.WritePacket(&Packet{Type: p.Type, Timestamp: timestamp, Data: p.Data}); err != nil {
+		client.Close()
+		return nil
+	}
+	r.mu.Lock()
+	r.status.Bytes += int64(len(p.Data))
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *pushRelay) writeHeaders(client *RTMPClient) error {
+	video, audio := r.hub.SequenceHeaders()
+	for _, p := range []*Packet{r.hub.MetaData(), video, audio} {
+		if p == nil {
+			continue
+		}
+		if err := client.WritePacket(&Packet{Type: p.Type, Data: p.Data}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close 퍼블리셔가 끝나면 허브 구독이 끝나면서 호출됩니다.
+func (r *pushRelay) Close() error {
+	r.cancel()
+	<-r.done
+	return nil
+}
+
+// startRelays app 설정의 푸시 대상마다 릴레이를 시작합니다.
+func (c *Connection) startRelays(conf *AppConfig) {
+	for _, target := range conf.pushTargets(c.StreamKey) {
+		log.Printf("Relay %s/%s to %s", c.AppName, c.StreamKey, target)
+		c.relays = append(c.relays, c.startRelay(target))
+	}
+}
+
+// startRelay 퍼블리셔 허브를 구독해 target으로 보내는 릴레이를 시작합니다.
+func (c *Connection) startRelay(target string) *pushRelay {
+	r := newPushRelay(c.Hub, c.AppName, c.StreamKey, target)
+	r.sub = c.Hub.Subscribe(r)
+	return r
+}
+
+// RelayStatuses 퍼블리시 중인 모든 스트림의 푸시 상태를 반환합니다.
+func (ctx *StreamContext) RelayStatuses() []RelayStatus {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+	res := []RelayStatus{}
+	for _, c := range ctx.Sessions {
+		for _, r := range c.relays {
+			res = append(res, r.Status())
+		}
+	}
+	return res
+}
+
+// serveRelayAPI GET /api/relay 푸시 대상별 상태를 반환합니다. app, stream 쿼리로 걸러낼 수 있습니다.
+func (ctx *StreamContext) serveRelayAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, apiResponse{Error: "method not allowed"})
+		return
+	}
+	app, stream := r.URL.Query().Get("app"), r.URL.Query().Get("stream")
+	res := []RelayStatus{}
+	for _, status := range ctx.RelayStatuses() {
+		if (app == "" || status.App == app) && (stream == "" || status.Stream == stream) {
+			res = append(res, status)
+		}
+	}
+	writeJSON(w, http.StatusOK, res)
+}
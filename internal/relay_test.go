@@ -0,0 +1,81 @@
+package internal
+
+import (
+	"net"
+	"testing"
+)
+
+func relayStatus(s *Server) (RelayStatus, bool) {
+	statuses := s.Context.RelayStatuses()
+	if len(statuses) != 1 {
+		return RelayStatus{}, false
+	}
+	return statuses[0], true
+}
+
+func TestPushRelay(t *testing.T) {
+	_, target := startTestServer(t, map[string]*AppConfig{"live": {}})
+	source, addr := startTestServer(t, map[string]*AppConfig{
+		"live": {Push: []string{"rtmp://" + target + "/live/{stream}"}},
+	})
+
+	publishTestStream(t, "rtmp://"+addr+"/live/relayed")
+	waitFor(t, "relay to connect", func() bool {
+		status, ok := relayStatus(source)
+		return ok && status.State == RelayStatePublishing
+	})
+	player := playTestStream(t, "rtmp://"+target+"/live/relayed")
+	expectTestKeyFrame(t, player)
+
+	waitFor(t, "relayed bytes", func() bool {
+		status, _ := relayStatus(source)
+		return status.Bytes > 0
+	})
+	if status, _ := relayStatus(source); status.URL != "rtmp://"+target+"/live/relayed" || status.Reconnects != 0 {
+		t.Errorf("unexpected relay status %+v", status)
+	}
+}
+
+func TestPushRelayRetry(t *testing.T) {
+	target := unusedAddr(t)
+	source, addr := startTestServer(t, map[string]*AppConfig{
+		"live": {Push: []string{"rtmp://" + target + "/live/{stream}"}},
+	})
+
+	publishTestStream(t, "rtmp://"+addr+"/live/retried")
+	waitFor(t, "relay to fail", func() bool {
+		status, ok := relayStatus(source)
+		return ok && status.State == RelayStateRetrying && status.Reconnects >= 1 && status.Error != ""
+	})
+
+	// 대상 서버가 올라오면 다음 시도(relayMinBackoff 뒤)에 연결됩니다.
+	l, err := net.Listen("tcp", target)
+	if err != nil {
+		t.Skipf("unable to listen on %s again: %s", target, err)
+	}
+	serveTestServer(t, l, map[string]*AppConfig{"live": {}})
+	waitFor(t, "relay to reconnect", func() bool {
+		status, _ := relayStatus(source)
+		return status.State == RelayStatePublishing
+	})
+	player := playTestStream(t, "rtmp://"+target+"/live/retried")
+	expectTestKeyFrame(t, player)
+}
+
+func TestPushRelayStopsWithPublisher(t *testing.T) {
+	_, target := startTestServer(t, map[string]*AppConfig{"live": {}})
+	source, addr := startTestServer(t, map[string]*AppConfig{
+		"live": {Push: []string{"rtmp://" + target + "/live/{stream}"}},
+	})
+
+	publisher := publishTestStream(t, "rtmp://"+addr+"/live/stopped")
+	waitFor(t, "relay to connect", func() bool {
+		status, ok := relayStatus(source)
+		return ok && status.State == RelayStatePublishing
+	})
+	publisher.Close()
+	waitFor(t, "relay to stop", func() bool {
+		_, ok := relayStatus(source)
+		return !ok
+	})
+}
@@ -0,0 +1,174 @@
+package internal
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"reflect"
+	"sort"
+)
+
+// errNoConfigSource 설정을 다시 읽을 방법(ConfigSource)이 없을 때 반환합니다.
+var errNoConfigSource = errors.New("config reload is not available")
+
+// ReloadResult 설정을 다시 적용한 결과입니다. (POST /api/reload 응답)
+type ReloadResult struct {
+	AddedApps     []string `json:"addedApps"`
+	RemovedApps   []string `json:"removedApps"`
+	ChangedApps   []string `json:"changedApps"`
+	RelaysStarted []string `json:"relaysStarted"`
+	RelaysStopped []string `json:"relaysStopped"`
+	// RestartRequired 바뀌었지만 서버를 다시 시작해야 적용되는 설정입니다. (리스닝 주소, 출력 경로, TLS 설정, 종료 대기 시간)
+	RestartRequired []string `json:"restartRequired,omitempty"`
+	Error           string   `json:"error,omitempty"`
+}
+
+// ReloadConfig ConfigSource로 설정을 다시 읽어 적용합니다. 설정이 잘못되었으면 기존 설정을 그대로 유지합니다.
+func (ctx *StreamContext) ReloadConfig() (*ReloadResult, error) {
+	if ctx.ConfigSource == nil {
+		return nil, errNoConfigSource
+	}
+	cfg, err := ctx.ConfigSource()
+	if err != nil {
+		return nil, err
+	}
+	return ctx.Reload(cfg)
+}
+
+// Reload 실행 중인 연결을 끊지 않고 새 설정을 적용합니다.
+//   - 새 app은 바로 사용할 수 있습니다.
+//   - 빠진 app은 새 connect, publish, play를 거절하지만 이미 퍼블리시, 재생 중인 세션은 유지합니다.
+//   - 인증, 접근 규칙, 관리 API 토큰, 웹훅, RTMP 제어 값, 제한, 시간 제한, PROXY protocol 설정은 이후의 요청과 새 연결부터 적용됩니다.
+//   - 푸시 대상이 바뀐 app은 퍼블리시 중인 스트림마다 빠진 대상의 릴레이를 멈추고 새 대상의 릴레이를 시작합니다.
+func (ctx *StreamContext) Reload(cfg *Config) (*ReloadResult, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	res := &ReloadResult{AddedApps: []string{}, RemovedApps: []string{}, ChangedApps: []string{}, RelaysStarted: []string{}, RelaysStopped: []string{}}
+	var stopped []*pushRelay
+
+	ctx.mu.Lock()
+	if ctx.config != nil {
+		res.RestartRequired = restartRequired(ctx.config, cfg)
+	}
+	changed := make(map[string]bool)
+	for name, conf := range cfg.Apps {
+		old, ok := ctx.Apps[name]
+		switch {
+		case !ok:
+			res.AddedApps = append(res.AddedApps, name)
+		case !reflect.DeepEqual(old, conf):
+			res.ChangedApps = append(res.ChangedApps, name)
+			changed[name] = true
+		}
+	}
+	for name := range ctx.Apps {
+		if _, ok := cfg.Apps[name]; !ok {
+			res.RemovedApps = append(res.RemovedApps, name)
+		}
+	}
+	ctx.Apps, ctx.RTMP, ctx.Limits, ctx.Timeouts, ctx.Webhooks, ctx.Access, ctx.Admin, ctx.Proxy, ctx.config = cfg.Apps, cfg.RTMP, cfg.Limits, cfg.Timeouts, cfg.Webhooks, cfg.Access, cfg.Admin, cfg.ProxyProtocol, cfg
+
+	for _, c := range ctx.Sessions {
+		if c.edge != nil || c.Hub == nil || !changed[c.AppName] {
+			continue
+		}
+		started, removed := c.updateRelays(cfg.Apps[c.AppName])
+		res.RelaysStarted = append(res.RelaysStarted, started...)
+		for _, r := range removed {
+			res.RelaysStopped = append(res.RelaysStopped, r.url)
+		}
+		stopped = append(stopped, removed...)
+	}
+	ctx.mu.Unlock()
+
+	// 릴레이가 멈출 때 대상 서버와의 연결을 닫느라 기다릴 수 있으므로 잠금 밖에서 멈춥니다.
+	for _, r := range stopped {
+		r.sub.Close()
+	}
+	for _, list := range [][]string{res.AddedApps, res.RemovedApps, res.ChangedApps} {
+		sort.Strings(list)
+	}
+	log.Printf("Config reloaded: added %v, removed %v, changed %v, relays started %d, stopped %d",
+		res.AddedApps, res.RemovedApps, res.ChangedApps, len(res.RelaysStarted), len(res.RelaysStopped))
+	for _, name := range res.RestartRequired {
+		log.Printf("Config reload: %s changed, restart the server to apply it", name)
+	}
+	return res, nil
+}
+
+// updateRelays 퍼블리셔의 릴레이를 conf의 푸시 대상과 맞춥니다. 시작한 주소와 멈춰야 할 릴레이를 반환합니다.
+// ctx.mu를 잡은 상태에서 호출합니다.
+func (c *Connection) updateRelays(conf *AppConfig) (started []string, removed []*pushRelay) {
+	want := make(map[string]bool)
+	for _, target := range conf.pushTargets(c.StreamKey) {
+		want[target] = true
+	}
+	have := make(map[string]bool)
+	relays := c.relays[:0:0]
+	for _, r := range c.relays {
+		if want[r.url] && !have[r.url] {
+			have[r.url] = true
+			relays = append(relays, r)
+		} else {
+			removed = append(removed, r)
+		}
+	}
+	for _, target := range conf.pushTargets(c.StreamKey) {
+		if have[target] {
+			continue
+		}
+		have[target] = true
+		log.Printf("Relay %s/%s to %s", c.AppName, c.StreamKey, target)
+		relays = append(relays, c.startRelay(target))
+		started = append(started, target)
+	}
+	c.relays = relays
+	return
+}
+
+// restartRequired 실행 중에 바꿀 수 없는 설정 중 바뀐 항목의 이름을 반환합니다.
+func restartRequired(old, cfg *Config) []string {
+	var res []string
+	if !reflect.DeepEqual(old.Listen, cfg.Listen) {
+		res = append(res, "listen")
+	}
+	if old.HTTPListen != cfg.HTTPListen {
+		res = append(res, "http_listen")
+	}
+	if old.Admin.Listen != cfg.Admin.Listen {
+		res = append(res, "admin.listen")
+	}
+	if old.Paths != cfg.Paths {
+		res = append(res, "paths")
+	}
+	if old.RTMPT != cfg.RTMPT {
+		res = append(res, "rtmpt")
+	}
+	if !reflect.DeepEqual(old.TLS, cfg.TLS) {
+		// 인증서 파일의 내용이 바뀐 것은 다시 시작하지 않아도 적용됩니다.
+		res = append(res, "tls")
+	}
+	if old.ShutdownTimeout != cfg.ShutdownTimeout {
+		res = append(res, "shutdown_timeout")
+	}
+	return res
+}
+
+// serveReloadAPI POST /api/reload 설정 파일을 다시 읽어 적용합니다. (SIGHUP과 같음)
+func (ctx *StreamContext) serveReloadAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, ReloadResult{Error: "method not allowed"})
+		return
+	}
+	res, err := ctx.ReloadConfig()
+	switch {
+	case err == errNoConfigSource:
+		writeJSON(w, http.StatusNotImplemented, ReloadResult{Error: err.Error()})
+	case err != nil:
+		log.Printf("Config reload failed: %s", err.Error())
+		writeJSON(w, http.StatusBadRequest, ReloadResult{Error: err.Error()})
+	default:
+		writeJSON(w, http.StatusOK, res)
+	}
+}
@@ -0,0 +1,498 @@
+package internal
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"example/hello/internal/amf"
+	"example/hello/internal/format/flvio"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrClientClosed 연결이 끊어진 클라이언트를 사용하려 할 때 반환합니다.
+var ErrClientClosed = errors.New("rtmp client closed")
+
+// RTMPStatusError 서버가 _error 또는 level이 error인 onStatus로 응답했을 때 반환합니다.
+type RTMPStatusError struct {
+	Code        string
+	Description string
+}
+
+func (e *RTMPStatusError) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Description
+}
+
+// RTMPClient 다른 RTMP 서버에 접속하는 클라이언트입니다. 푸시 릴레이, 엣지 풀에서 사용합니다.
+// 청크를 읽고 쓰는 부분은 서버 연결과 같은 Connection 구현을 그대로 사용합니다.
+type RTMPClient struct {
+	conn *Connection
+
+	App    string
+	Stream string // 주소에 쿼리가 있으면 쿼리를 포함합니다. (예: key?token=...)
+	TcURL  string
+
+	mu       sync.Mutex
+	transID  float64
+	calls    map[float64]chan map[string]interface{}
+	streamID uint32
+
+	status  chan map[string]interface{}
+	packets chan *Packet
+
+	// 받은 바이트 수를 세어 서버가 알려준 윈도우 크기마다 Acknowledgement를 보냅니다.
+	received  uint32
+	acked     uint32
+	ackWindow uint32
+
+	done chan struct{}
+	err  error
+}
+
+// clientCounter 읽은 바이트 수를 세는 io.Reader 입니다.
+type clientCounter struct {
+	r io.Reader
+	n *uint32
+}
+
+func (c clientCounter) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	*c.n += uint32(n)
+	return n, err
+}
+
+// parseRTMPURL rtmp(s)://host[:port]/app/stream 형식의 주소를 나눕니다. 경로의 첫 부분이 app, 나머지가 stream 입니다.
+// 포트가 없으면 rtmp는 1935, rtmps는 443을 사용합니다.
+func parseRTMPURL(raw string) (host, app, stream, tcURL string, err error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return
+	}
+	port := "1935"
+	switch u.Scheme {
+	case "rtmp":
+	case "rtmps":
+		port = "443"
+	default:
+		err = fmt.Errorf("unsupported scheme %q", u.Scheme)
+		return
+	}
+	host = u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), port)
+	}
+	path := strings.TrimPrefix(u.Path, "/")
+	app, stream, _ = strings.Cut(path, "/")
+	if app == "" {
+		err = fmt.Errorf("missing app in %q", raw)
+		return
+	}
+	if u.RawQuery != "" {
+		stream += "?" + u.RawQuery
+	}
+	tcURL = u.Scheme + "://" + u.Host + "/" + app
+	return
+}
+
+// DialRTMP 주소의 서버에 TCP로 접속해 핸드셰이크까지 마칩니다. 이어서 Connect를 호출해야 합니다.
+// rtmps:// 주소는 TLS로 접속하고 호스트 이름으로 서버 인증서를 검증합니다.
+func DialRTMP(ctx context.Context, rawURL string) (*RTMPClient, error) {
+	host, app, stream, tcURL, err := parseRTMPURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	var netConn net.Conn
+	if strings.HasPrefix(tcURL, "rtmps://") {
+		var d tls.Dialer
+		netConn, err = d.DialContext(ctx, "tcp", host)
+	} else {
+		var d net.Dialer
+		netConn, err = d.DialContext(ctx, "tcp", host)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	client := &RTMPClient{
+		conn:    NewConnection(netConn, nil),
+		App:     app,
+		Stream:  stream,
+		TcURL:   tcURL,
+		calls:   make(map[float64]chan map[string]interface{}),
+		status:  make(chan map[string]interface{}, 16),
+		packets: make(chan *Packet, subscriberQueueSize),
+		done:    make(chan struct{}),
+	}
+	client.conn.client = client
+	client.conn.Reader.Reset(clientCounter{r: netConn, n: &client.received})
+
+	stop := context.AfterFunc(ctx, func() { netConn.SetDeadline(time.Now()) })
+	err = client.conn.clientHandshake()
+	if !stop() {
+		err = ctx.Err()
+	}
+	if err != nil {
+		netConn.Close()
+		return nil, err
+	}
+
+	go client.readLoop()
+	return client, nil
+}
+
+// clientHandshake 클라이언트 쪽 단순 핸드셰이크입니다. C0C1을 보내고 S0S1을 받은 뒤 S1을 C2로 되돌려 보냅니다.
+func (c *Connection) clientHandshake() (err error) {
+	c0c1 := make([]byte, 1+1536)
+	c0c1[0] = 3
+	binary.BigEndian.PutUint32(c0c1[1:5], uint32(time.Now().Unix()))
+	rand.Read(c0c1[9:])
+	if _, err = c.Writer.Write(c0c1); err != nil {
+		return
+	}
+	if err = c.Writer.Flush(); err != nil {
+		return
+	}
+
+	s0s1 := make([]byte, 1+1536)
+	if _, err = io.ReadFull(c.Reader, s0s1); err != nil {
+		return
+	}
+	if s0s1[0] != 3 {
+		return fmt.Errorf("unsupported RTMP version %d", s0s1[0])
+	}
+	if _, err = c.Writer.Write(s0s1[1:]); err != nil {
+		return
+	}
+	if err = c.Writer.Flush(); err != nil {
+		return
+	}
+
+	s2 := make([]byte, 1536)
+	_, err = io.ReadFull(c.Reader, s2)
+	c.ConnectionStatus.HandShakeDone = true
+	return
+}
+
+func (client *RTMPClient) readLoop() {
+	defer close(client.done)
+	defer close(client.packets)
+	for {
+		if err := client.conn.readChunk(); err != nil {
+			client.mu.Lock()
+			client.err = err
+			client.mu.Unlock()
+			client.conn.Conn.Close()
+			return
+		}
+		if client.ackWindow > 0 && client.received-client.acked >= client.ackWindow/2 {
+			client.acked = client.received
+			client.sendControl(3, client.received)
+		}
+	}
+}
+
+// handleChunk 클라이언트 연결로 받은 메시지를 처리합니다. 서버 연결의 handleChunk 대신 호출됩니다.
+func (client *RTMPClient) handleChunk(chunk *rtmpChunk) error {
+	c := client.conn
+	switch chunk.header.messageType {
+	case 1: // Set Chunk Size
+		return c.setReadChunkSize(chunk.payload)
+	case 2: // Abort Message
+		c.abortMessage(chunk.payload)
+	case 5: // Window Acknowledgement Size
+		client.ackWindow = binary.BigEndian.Uint32(chunk.payload)
+	case 4: // User Control
+		// 서버가 재생 중인 연결이 살아 있는지 Ping Request로 확인하므로 응답해야 합니다.
+		c.handleUserControl(chunk.payload)
+	case 3, 6:
+		// Acknowledgement, Set Peer Bandwidth는 사용하지 않습니다.
+	case MessageTypeAudio, MessageTypeVideo, MessageTypeData:
+		p := &Packet{Type: chunk.header.messageType, Timestamp: chunk.clock, Data: chunk.payload}
+		if p.IsMetaData() {
+			if !client.handleData(p) {
+				return nil
+			}
+		}
+		client.packets <- p
+	case 20:
+		command, err := amf.Decode(chunk.payload)
+		if err != nil {
+			return protocolError("invalid command: %s", err.Error())
+		}
+		client.handleCommand(command)
+	default:
+		log.Printf("RTMP client: unknown message type %d", chunk.header.messageType)
+	}
+	return nil
+}
+
+// handleData 데이터 메시지 중 onMetaData만 패킷으로 전달합니다. (@setDataFrame 접두사는 제거합니다.)
+func (client *RTMPClient) handleData(p *Packet) bool {
+	command, err := amf.Decode(p.Data)
+	if err != nil {
+		log.Printf("RTMP client: dropping invalid data message: %s", err.Error())
+		return false
+	}
+	switch command["cmd"] {
+	case "onMetaData":
+		return true
+	case "@setDataFrame":
+		_, n := amf.Encode("@setDataFrame")
+		p.Data = p.Data[n:]
+		return true
+	}
+	return false
+}
+
+func (client *RTMPClient) handleCommand(command map[string]interface{}) {
+	switch command["cmd"] {
+	case "_result", "_error":
+		transID, _ := command["transId"].(float64)
+		client.mu.Lock()
+		ch := client.calls[transID]
+		delete(client.calls, transID)
+		client.mu.Unlock()
+		if ch != nil {
+			ch <- command
+		}
+	case "onStatus":
+		info, _ := command["info"].(map[string]interface{})
+		select {
+		case client.status <- info:
+		default:
+		}
+	}
+}
+
+// send 명령을 보냅니다. wait가 true이면 _result, _error 응답을 받을 채널을 반환합니다.
+func (client *RTMPClient) send(streamID uint32, wait bool, name string, args ...interface{}) (chan map[string]interface{}, error) {
+	client.mu.Lock()
+	client.transID++
+	transID := client.transID
+	var ch chan map[string]interface{}
+	if wait {
+		ch = make(chan map[string]interface{}, 1)
+		client.calls[transID] = ch
+	}
+	client.mu.Unlock()
+
+	amfPayload, length := amf.Encode(append([]interface{}{name, transID}, args...)...)
+	err := client.conn.writeMessage(&rtmpChunk{
+		header: &chunkHeader{
+			fmt:             0,
+			csID:            3,
+			messageType:     20,
+			messageStreamID: streamID,
+			length:          uint32(length),
+		},
+		payload: amfPayload,
+	})
+	return ch, err
+}
+
+// call 명령을 보내고 응답을 기다립니다.
+func (client *RTMPClient) call(ctx context.Context, name string, args ...interface{}) (map[string]interface{}, error) {
+	ch, err := client.send(0, true, name, args...)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case res := <-ch:
+		if res["cmd"] == "_error" {
+			return nil, statusError(res["info"])
+		}
+		return res, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-client.done:
+		// 서버가 _error를 보내고 바로 연결을 닫으면 두 경우가 동시에 준비될 수 있으므로 받은 응답을 먼저 확인합니다.
+		select {
+		case res := <-ch:
+			if res["cmd"] == "_error" {
+				return nil, statusError(res["info"])
+			}
+			return res, nil
+		default:
+		}
+		return nil, client.closedErr()
+	}
+}
+
+// waitStatus 원하는 code의 onStatus가 올 때까지 기다립니다. level이 error인 상태를 받으면 에러를 반환합니다.
+func (client *RTMPClient) waitStatus(ctx context.Context, code string) error {
+	for {
+		select {
+		case info := <-client.status:
+			if info["level"] == "error" {
+				return statusError(info)
+			}
+			if info["code"] == code {
+				return nil
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-client.done:
+			return client.closedErr()
+		}
+	}
+}
+
+func statusError(v interface{}) error {
+	info, _ := v.(map[string]interface{})
+	code, _ := info["code"].(string)
+	description, _ := info["description"].(string)
+	if code == "" {
+		code = "NetConnection.Call.Failed"
+	}
+	return &RTMPStatusError{Code: code, Description: description}
+}
+
+// closedErr 연결이 끊어진 이유를 반환합니다. 서버가 닫았거나 Close로 닫았으면 ErrClientClosed 입니다.
+func (client *RTMPClient) closedErr() error {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if client.err != nil && client.err != io.EOF && !errors.Is(client.err, net.ErrClosed) {
+		return client.err
+	}
+	return ErrClientClosed
+}
+
+// sendControl 4바이트 값을 가지는 프로토콜 제어 메시지(Set Chunk Size, Acknowledgement 등)를 보냅니다.
+func (client *RTMPClient) sendControl(messageType uint8, value uint32) error {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, value)
+	return client.conn.writeMessage(&rtmpChunk{
+		header: &chunkHeader{
+			fmt:         0,
+			csID:        2,
+			messageType: messageType,
+			length:      uint32(len(b)),
+		},
+		payload: b,
+	})
+}
+
+// Connect connect 명령으로 app에 접속합니다.
+func (client *RTMPClient) Connect(ctx context.Context) error {
+	if err := client.sendControl(1, uint32(client.conn.WriteMaxChunkSize)); err != nil {
+		return err
+	}
+	cmdObj := flvio.AMFMap{
+		"app":            client.App,
+		"type":           "nonprivate",
+		"flashVer":       "FMLE/3.0 (compatible; hello)",
+		"tcUrl":          client.TcURL,
+		"fpad":           false,
+		"capabilities":   15,
+		"audioCodecs":    0x0fff,
+		"videoCodecs":    0x00ff,
+		"videoFunction":  1,
+		"objectEncoding": 0,
+	}
+	res, err := client.call(ctx, "connect", cmdObj)
+	if err != nil {
+		return err
+	}
+	if info, _ := res["info"].(map[string]interface{}); info["level"] == "error" {
+		return statusError(info)
+	}
+	return nil
+}
+
+// CreateStream 메시지 스트림을 만듭니다. Publish, Play 전에 호출해야 합니다.
+func (client *RTMPClient) CreateStream(ctx context.Context) error {
+	res, err := client.call(ctx, "createStream", nil)
+	if err != nil {
+		return err
+	}
+	streamID, ok := res["info"].(float64)
+	if !ok {
+		return errors.New("invalid createStream response")
+	}
+	client.streamID = uint32(streamID)
+	return nil
+}
+
+// Publish stream 이름으로 퍼블리시를 시작하고 NetStream.Publish.Start를 기다립니다.
+func (client *RTMPClient) Publish(ctx context.Context, stream string) error {
+	// FMLE, OBS와 같은 순서로 releaseStream, FCPublish를 먼저 보냅니다. (응답은 기다리지 않습니다.)
+	if _, err := client.send(0, false, "releaseStream", nil, stream); err != nil {
+		return err
+	}
+	if _, err := client.send(0, false, "FCPublish", nil, stream); err != nil {
+		return err
+	}
+	if _, err := client.send(client.streamID, false, "publish", nil, stream, "live"); err != nil {
+		return err
+	}
+	return client.waitStatus(ctx, "NetStream.Publish.Start")
+}
+
+// Play stream 이름의 라이브 스트림 재생을 시작하고 NetStream.Play.Start를 기다립니다. 받은 패킷은 ReadPacket으로 읽습니다.
+func (client *RTMPClient) Play(ctx context.Context, stream string) error {
+	if _, err := client.send(client.streamID, false, "play", nil, stream, float64(-2000)); err != nil {
+		return err
+	}
+	return client.waitStatus(ctx, "NetStream.Play.Start")
+}
+
+// WritePacket 퍼블리시 중인 스트림에 패킷을 보냅니다. 메타데이터는 @setDataFrame을 붙여 보냅니다.
+func (client *RTMPClient) WritePacket(p *Packet) error {
+	data := p.Data
+	csID := uint32(4)
+	if p.IsMetaData() {
+		prefix, _ := amf.Encode("@setDataFrame")
+		data = append(prefix, data...)
+		csID = 6
+	}
+	return client.conn.writeMessage(&rtmpChunk{
+		header: &chunkHeader{
+			fmt:             0,
+			csID:            csID,
+			messageType:     p.Type,
+			messageStreamID: client.streamID,
+			timestamp:       p.Timestamp,
+			length:          uint32(len(data)),
+		},
+		payload: data,
+	})
+}
+
+// ReadPacket 재생 중인 스트림의 다음 패킷을 읽습니다. 연결이 끊어지거나 ctx가 끝나면 에러를 반환합니다.
+func (client *RTMPClient) ReadPacket(ctx context.Context) (*Packet, error) {
+	select {
+	case p, ok := <-client.packets:
+		if !ok {
+			return nil, client.closedErr()
+		}
+		return p, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Done 연결이 끊어지면 닫히는 채널입니다.
+func (client *RTMPClient) Done() <-chan struct{} {
+	return client.done
+}
+
+// Close 연결을 닫고 읽기 고루틴이 끝날 때까지 기다립니다.
+func (client *RTMPClient) Close() error {
+	err := client.conn.Conn.Close()
+	// 패킷을 읽는 쪽이 없어도 읽기 고루틴이 끝날 수 있도록 남은 패킷을 비웁니다.
+	for range client.packets {
+	}
+	<-client.done
+	return err
+}
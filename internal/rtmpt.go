@@ -0,0 +1,435 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// rtmptDefaultSessionTimeout RTMPTConfig.SessionTimeout이 0일 때, 요청이 없는 세션을 닫기까지의 시간입니다.
+	rtmptDefaultSessionTimeout = 30 * time.Second
+	// rtmptPollWait 보낼 데이터가 없을 때 응답 전에 기다리는 시간입니다. 빈 응답과 폴링 횟수를 줄입니다.
+	rtmptPollWait = 50 * time.Millisecond
+	// rtmptMaxBody 요청 하나로 받는 최대 데이터 크기입니다.
+	rtmptMaxBody = 1 << 20
+	// rtmptMaxPending 클라이언트가 가져가지 않은 데이터의 최대 크기입니다. 넘으면 느린 클라이언트로 보고 세션을 닫습니다.
+	rtmptMaxPending  = 8 << 20
+	rtmptContentType = "application/x-fcs"
+)
+
+// rtmptIntervals 응답 첫 바이트로 보내는 폴링 간격 힌트입니다. 데이터가 없을수록 뒤의 값(긴 간격)을 보냅니다.
+var rtmptIntervals = []byte{0x01, 0x03, 0x05, 0x09, 0x11, 0x21}
+
+var errRTMPTBufferFull = errors.New("rtmpt: client is not polling fast enough")
+
+// RTMPTConfig HTTP 리스너에서 받는 RTMPT(HTTP로 터널링한 RTMP) 설정입니다.
+// 클라이언트는 POST /open/1로 세션을 만들고, /send/{id}/{seq}로 데이터를 보내며, /idle/{id}/{seq}로 서버 데이터를 가져가고,
+// /close/{id}/{seq}로 세션을 닫습니다. seq는 요청마다 1씩 늘어나며, 같은 seq의 재전송은 무시하고 빠진 seq가 있으면 세션을 닫습니다.
+// 세션마다 가상 연결을 만들어 RTMP 연결과 같은 방식으로 처리합니다.
+type RTMPTConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// SessionTimeout 요청이 없는 세션을 닫기까지의 시간입니다. (0이면 30초)
+	SessionTimeout time.Duration `yaml:"session_timeout"`
+}
+
+func (conf *RTMPTConfig) validate(httpListen string) error {
+	if conf.Enabled && httpListen == "" {
+		return errors.New("enabled: requires http_listen")
+	}
+	if conf.SessionTimeout < 0 {
+		return errors.New("session_timeout: must not be negative")
+	}
+	return nil
+}
+
+// rtmptListener /open 요청으로 만든 세션을 Accept로 돌려주는 가상 리스너입니다. Server.Serve에 넘겨 사용합니다.
+type rtmptListener struct {
+	timeout time.Duration
+	conns   chan *rtmptConn
+	done    chan struct{}
+	once    sync.Once
+
+	mu       sync.Mutex
+	sessions map[string]*rtmptConn
+}
+
+// ListenRTMPT RTMPT 세션을 받는 리스너를 만듭니다. 반환한 리스너를 Server.Serve에 넘기면 HTTP 리스너의 RTMPT 요청을 처리합니다.
+func (ctx *StreamContext) ListenRTMPT(conf RTMPTConfig) net.Listener {
+	timeout := conf.SessionTimeout
+	if timeout <= 0 {
+		timeout = rtmptDefaultSessionTimeout
+	}
+	l := &rtmptListener{
+		timeout:  timeout,
+		conns:    make(chan *rtmptConn),
+		done:     make(chan struct{}),
+		sessions: make(map[string]*rtmptConn),
+	}
+	ctx.mu.Lock()
+	ctx.rtmpt = l
+	ctx.mu.Unlock()
+	return l
+}
+
+func (l *rtmptListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close 새 세션을 받지 않습니다. 이미 만든 세션은 연결을 닫을 때 함께 닫힙니다.
+func (l *rtmptListener) Close() error {
+	l.once.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *rtmptListener) Addr() net.Addr {
+	return rtmptAddr("rtmpt")
+}
+
+// open 새 세션을 만들어 Accept를 기다리는 서버에 넘깁니다.
+func (l *rtmptListener) open(r *http.Request) (*rtmptConn, error) {
+	id := make([]byte, 8)
+	rand.Read(id)
+	c := &rtmptConn{
+		id:       hex.EncodeToString(id),
+		listener: l,
+		remote:   parseTCPAddr(r.RemoteAddr),
+		local:    rtmptAddr(r.Host),
+		inReady:  make(chan struct{}, 1),
+		outReady: make(chan struct{}, 1),
+		closed:   make(chan struct{}),
+	}
+	c.idle = time.AfterFunc(l.timeout, func() {
+		if !c.isClosed() {
+			log.Printf("RTMPT session %s from %s timed out", c.id, c.remote)
+		}
+		c.Close()
+		c.remove()
+	})
+	l.mu.Lock()
+	l.sessions[c.id] = c
+	l.mu.Unlock()
+
+	select {
+	case l.conns <- c:
+		return c, nil
+	case <-l.done:
+	case <-r.Context().Done():
+	}
+	c.Close()
+	return nil, net.ErrClosed
+}
+
+func (l *rtmptListener) session(id string) *rtmptConn {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.sessions[id]
+}
+
+func (l *rtmptListener) remove(id string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.sessions, id)
+}
+
+// rtmptConn RTMPT 세션 하나를 net.Conn으로 보여 줍니다.
+// 클라이언트가 보낸 데이터는 Read로 읽고, Write로 쓴 데이터는 다음 /send, /idle 응답으로 클라이언트에 전달됩니다.
+type rtmptConn struct {
+	id       string
+	listener *rtmptListener
+	remote   net.Addr
+	local    net.Addr
+	idle     *time.Timer
+
+	mu           sync.Mutex
+	in           bytes.Buffer
+	out          bytes.Buffer
+	interval     int    // rtmptIntervals의 인덱스
+	seq          uint64 // 마지막으로 처리한 요청의 seq
+	seqStarted   bool
+	readDeadline time.Time
+	inReady      chan struct{}
+	outReady     chan struct{}
+
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (c *rtmptConn) Read(b []byte) (int, error) {
+	for {
+		c.mu.Lock()
+		if c.in.Len() > 0 {
+			n, _ := c.in.Read(b)
+			c.mu.Unlock()
+			return n, nil
+		}
+		deadline := c.readDeadline
+		c.mu.Unlock()
+
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if !deadline.IsZero() {
+			d := time.Until(deadline)
+			if d <= 0 {
+				return 0, os.ErrDeadlineExceeded
+			}
+			timer = time.NewTimer(d)
+			timeout = timer.C
+		}
+		select {
+		case <-c.inReady:
+		case <-c.closed:
+			return 0, io.EOF
+		case <-timeout:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// Write 보낼 데이터를 쌓아 둡니다. 클라이언트가 폴링하지 않아 너무 많이 쌓이면 세션을 닫습니다.
+func (c *rtmptConn) Write(b []byte) (int, error) {
+	if c.isClosed() {
+		return 0, net.ErrClosed
+	}
+	c.mu.Lock()
+	if c.out.Len()+len(b) > rtmptMaxPending {
+		c.mu.Unlock()
+		c.Close()
+		return 0, errRTMPTBufferFull
+	}
+	c.out.Write(b)
+	c.mu.Unlock()
+	notify(c.outReady)
+	return len(b), nil
+}
+
+// Close 세션을 닫습니다. 닫기 전에 쓴 데이터(연결 거절 응답 등)가 남아 있으면 클라이언트가 가져갈 때까지 세션을 남겨 둡니다.
+func (c *rtmptConn) Close() error {
+	c.closeOnce.Do(func() { close(c.closed) })
+	c.mu.Lock()
+	pending := c.out.Len() > 0
+	c.mu.Unlock()
+	if !pending {
+		c.remove()
+	}
+	return nil
+}
+
+// remove 세션 목록에서 지웁니다. 이후의 요청은 404를 받습니다.
+func (c *rtmptConn) remove() {
+	c.idle.Stop()
+	c.listener.remove(c.id)
+}
+
+func (c *rtmptConn) isClosed() bool {
+	select {
+	case <-c.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *rtmptConn) LocalAddr() net.Addr  { return c.local }
+func (c *rtmptConn) RemoteAddr() net.Addr { return c.remote }
+
+func (c *rtmptConn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+func (c *rtmptConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.readDeadline = t
+	c.mu.Unlock()
+	notify(c.inReady)
+	return nil
+}
+
+// SetWriteDeadline 쓰기는 버퍼에 쌓기만 하므로 막히지 않습니다.
+func (c *rtmptConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+// receive 클라이언트가 보낸 데이터를 Read로 읽을 수 있게 합니다.
+func (c *rtmptConn) receive(data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	c.mu.Lock()
+	c.in.Write(data)
+	c.mu.Unlock()
+	notify(c.inReady)
+}
+
+// poll 클라이언트에 보낼 응답(폴링 간격 힌트 1바이트 + 쌓인 데이터)을 만듭니다.
+// 쌓인 데이터가 없으면 잠시 기다리고, 그래도 없으면 다음 폴링 간격을 늘립니다.
+func (c *rtmptConn) poll(received bool) []byte {
+	c.mu.Lock()
+	empty := c.out.Len() == 0
+	c.mu.Unlock()
+	if empty {
+		timer := time.NewTimer(rtmptPollWait)
+		select {
+		case <-c.outReady:
+		case <-c.closed:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.out.Len() > 0 || received {
+		c.interval = 0
+	} else if c.interval < len(rtmptIntervals)-1 {
+		c.interval++
+	}
+	res := make([]byte, 1+c.out.Len())
+	res[0] = rtmptIntervals[c.interval]
+	copy(res[1:], c.out.Bytes())
+	c.out.Reset()
+	if c.isClosed() {
+		defer c.remove()
+	}
+	return res
+}
+
+// checkSeq 요청의 seq를 확인합니다. 첫 요청의 seq부터 1씩 늘어나야 합니다.
+// 이미 처리한 seq(응답을 받지 못한 클라이언트의 재전송)면 false를, 중간 seq가 빠졌으면 에러를 반환합니다.
+func (c *rtmptConn) checkSeq(seq uint64) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case !c.seqStarted || seq == c.seq+1:
+		c.seq, c.seqStarted = seq, true
+		return true, nil
+	case seq <= c.seq:
+		return false, nil
+	}
+	return false, fmt.Errorf("expected sequence %d, got %d", c.seq+1, seq)
+}
+
+// currentInterval 데이터 없이 돌려줄 현재 폴링 간격 힌트입니다.
+func (c *rtmptConn) currentInterval() byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return rtmptIntervals[c.interval]
+}
+
+// notify 신호가 이미 있으면 더하지 않는 채널 알림입니다.
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// serveRTMPT POST /open/1, /send/{id}/{seq}, /idle/{id}/{seq}, /close/{id}/{seq}, /fcs/ident2
+// RTMPT 요청이 아니면 false를 반환합니다.
+func (ctx *StreamContext) serveRTMPT(w http.ResponseWriter, r *http.Request) bool {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	command := parts[0]
+	switch {
+	case r.Method != http.MethodPost:
+		return false
+	case command == "fcs" && len(parts) == 2 && parts[1] == "ident2":
+	case command == "open" && len(parts) == 2:
+	case (command == "send" || command == "idle" || command == "close") && len(parts) == 3:
+	default:
+		return false
+	}
+
+	ctx.mu.RLock()
+	l := ctx.rtmpt
+	ctx.mu.RUnlock()
+	if l == nil || command == "fcs" {
+		// Flash Player는 /fcs/ident2의 404 응답을 받으면 /open으로 넘어갑니다.
+		http.NotFound(w, r)
+		return true
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, rtmptMaxBody+1))
+	if err != nil || len(body) > rtmptMaxBody {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return true
+	}
+	w.Header().Set("Content-Type", rtmptContentType)
+	w.Header().Set("Cache-Control", "no-cache")
+
+	if command == "open" {
+		c, err := l.open(r)
+		if err != nil {
+			http.Error(w, "server closed", http.StatusServiceUnavailable)
+			return true
+		}
+		io.WriteString(w, c.id+"\n")
+		return true
+	}
+
+	seq, err := strconv.ParseUint(parts[2], 10, 64)
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return true
+	}
+	c := l.session(parts[1])
+	if c == nil {
+		http.NotFound(w, r)
+		return true
+	}
+	c.idle.Reset(l.timeout)
+	if ok, err := c.checkSeq(seq); err != nil {
+		// 빠진 요청의 데이터는 되살릴 수 없으므로 RTMP 스트림이 깨진 세션을 닫습니다.
+		log.Printf("RTMPT session %s from %s: %s, closing", c.id, c.remote, err.Error())
+		c.Close()
+		c.remove()
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return true
+	} else if !ok {
+		// 재전송된 요청은 데이터를 다시 받거나 넘기지 않고 폴링 간격만 알려 줍니다.
+		w.Write([]byte{c.currentInterval()})
+		return true
+	}
+	switch command {
+	case "send":
+		c.receive(body)
+		w.Write(c.poll(len(body) > 0))
+	case "idle":
+		w.Write(c.poll(false))
+	case "close":
+		c.Close()
+		w.Write([]byte{0})
+	}
+	return true
+}
+
+// rtmptAddr 가상 연결의 주소입니다.
+type rtmptAddr string
+
+func (a rtmptAddr) Network() string { return "rtmpt" }
+func (a rtmptAddr) String() string  { return string(a) }
+
+// parseTCPAddr HTTP 요청의 RemoteAddr(host:port)를 net.Addr로 바꿉니다. 클라이언트 IP를 접근 규칙, 인증에 그대로 사용합니다.
+func parseTCPAddr(addr string) net.Addr {
+	if tcpAddr, err := net.ResolveTCPAddr("tcp", addr); err == nil {
+		return tcpAddr
+	}
+	return rtmptAddr(addr)
+}
@@ -0,0 +1,81 @@
+package internal
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// rtmptTestSession RTMPT 세션을 열고, 서버 쪽 가상 연결과 세션 ID를 반환합니다.
+func rtmptTestSession(t *testing.T, ctx *StreamContext) (net.Conn, string) {
+	t.Helper()
+	l := ctx.ListenRTMPT(RTMPTConfig{Enabled: true})
+	t.Cleanup(func() { l.Close() })
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, _ := l.Accept()
+		accepted <- conn
+	}()
+	res := rtmptTestRequest(t, ctx, "/open/1", "")
+	if res.Code != http.StatusOK {
+		t.Fatalf("open: %d", res.Code)
+	}
+	conn := <-accepted
+	t.Cleanup(func() { conn.Close() })
+	return conn, strings.TrimSpace(res.Body.String())
+}
+
+func rtmptTestRequest(t *testing.T, ctx *StreamContext, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	if !ctx.serveRTMPT(w, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))) {
+		t.Fatalf("%s: not handled as RTMPT", path)
+	}
+	return w
+}
+
+// rtmptTestReceived 서버 연결이 지금까지 받은 데이터입니다.
+func rtmptTestReceived(conn net.Conn) string {
+	c := conn.(*rtmptConn)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.in.String()
+}
+
+func TestRTMPTSequence(t *testing.T) {
+	ctx := NewStreamContext(DefaultConfig())
+	conn, id := rtmptTestSession(t, ctx)
+
+	if res := rtmptTestRequest(t, ctx, "/send/"+id+"/1", "abc"); res.Code != http.StatusOK {
+		t.Fatalf("send 1: %d", res.Code)
+	}
+	// 응답을 받지 못해 다시 보낸 요청의 데이터는 두 번 받지 않습니다.
+	if res := rtmptTestRequest(t, ctx, "/send/"+id+"/1", "abc"); res.Code != http.StatusOK || res.Body.Len() != 1 {
+		t.Fatalf("duplicate send: %d %q", res.Code, res.Body.String())
+	}
+	if res := rtmptTestRequest(t, ctx, "/idle/"+id+"/2", ""); res.Code != http.StatusOK {
+		t.Fatalf("idle 2: %d", res.Code)
+	}
+	if res := rtmptTestRequest(t, ctx, "/send/"+id+"/3", "def"); res.Code != http.StatusOK {
+		t.Fatalf("send 3: %d", res.Code)
+	}
+	if got := rtmptTestReceived(conn); got != "abcdef" {
+		t.Errorf("received %q, want %q", got, "abcdef")
+	}
+
+	if res := rtmptTestRequest(t, ctx, "/send/"+id+"/x", "ghi"); res.Code != http.StatusBadRequest {
+		t.Errorf("invalid seq: %d, want 400", res.Code)
+	}
+	// 중간 요청이 빠지면 스트림을 이어 붙일 수 없으므로 세션을 닫습니다.
+	if res := rtmptTestRequest(t, ctx, "/send/"+id+"/5", "jkl"); res.Code != http.StatusBadRequest {
+		t.Errorf("gap: %d, want 400", res.Code)
+	}
+	if res := rtmptTestRequest(t, ctx, "/idle/"+id+"/6", ""); res.Code != http.StatusNotFound {
+		t.Errorf("idle after gap: %d, want 404", res.Code)
+	}
+	if !conn.(*rtmptConn).isClosed() {
+		t.Error("session not closed")
+	}
+}
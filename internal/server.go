@@ -0,0 +1,290 @@
+package internal
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrServerClosed Shutdown 이후 Serve가 반환하는 에러입니다.
+var ErrServerClosed = errors.New("rtmp: server closed")
+
+// Hooks 연결 이벤트를 전달받습니다. 서버를 라이브러리로 사용할 때 인증, 통계 등에 사용합니다.
+// OnConnect, OnPublish, OnPlay가 에러를 반환하면 해당 요청을 거절합니다.
+type Hooks interface {
+	OnConnect(c *Connection) error
+	OnPublish(c *Connection, stream string) error
+	OnPlay(c *Connection, stream string) error
+	// OnPacket 퍼블리셔로부터 받은 패킷을 허브에 넣기 전에 호출됩니다. 연결의 읽기 고루틴에서 호출되므로 오래 막으면 안 됩니다.
+	OnPacket(c *Connection, p *Packet)
+	OnClose(c *Connection)
+}
+
+// Server 리스너에서 RTMP 연결을 받아 처리합니다.
+type Server struct {
+	Context *StreamContext
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[*Connection]string // 연결 → 연결 수를 센 클라이언트 IP (accept 전이면 "")
+	ips       map[string]int         // 클라이언트 IP → 연결 수
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+func NewServer(ctx *StreamContext) *Server {
+	return &Server{
+		Context:   ctx,
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[*Connection]string),
+		ips:       make(map[string]int),
+	}
+}
+
+// Serve 리스너에서 연결을 받아 연결마다 고루틴으로 처리합니다. Shutdown 되면 ErrServerClosed를 반환합니다.
+func (s *Server) Serve(l net.Listener) error {
+	return s.serve(l, nil)
+}
+
+// ServeTLS Serve와 같지만 연결마다 config로 TLS 핸드셰이크를 한 뒤 RTMP를 처리합니다. (RTMPS)
+// l은 TCP 리스너여야 합니다. PROXY protocol 헤더는 TLS 핸드셰이크 전에 읽습니다.
+func (s *Server) ServeTLS(l net.Listener, config *tls.Config) error {
+	return s.serve(l, config)
+}
+
+func (s *Server) serve(l net.Listener, tlsConfig *tls.Config) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		l.Close()
+		return ErrServerClosed
+	}
+	s.listeners[l] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.listeners, l)
+		s.mu.Unlock()
+	}()
+
+	var delay time.Duration
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return ErrServerClosed
+			}
+			// 파일 디스크립터 부족 같은 일시적인 에러는 잠시 기다렸다가 다시 시도합니다.
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				if delay == 0 {
+					delay = 5 * time.Millisecond
+				} else if delay *= 2; delay > time.Second {
+					delay = time.Second
+				}
+				log.Printf("Unable to accept connection %s, retrying in %s", err.Error(), delay)
+				time.Sleep(delay)
+				continue
+			}
+			log.Printf("Unable to accept connection %s", err.Error())
+			return err
+		}
+		delay = 0
+
+		proxy := s.Context.proxyProtocol()
+		var pc *proxyConn
+		// RTMPT 세션은 HTTP 요청의 주소를 이미 가지고 있으므로 PROXY protocol 헤더를 읽지 않습니다.
+		if _, tunnel := conn.(*rtmptConn); proxy.Enabled && !tunnel {
+			pc = newProxyConn(conn)
+			conn = pc
+		}
+		if tlsConfig != nil {
+			conn = tls.Server(conn, tlsConfig)
+		}
+		c := NewConnection(conn, s.Context)
+		if err := s.track(c); err != nil {
+			conn.Close()
+			if err == ErrServerClosed {
+				return err
+			}
+			log.Printf("Rejected connection from %s: %s", conn.RemoteAddr(), err.Error())
+			s.Context.limitRejected(limitConnections)
+			continue
+		}
+		go func() {
+			defer s.untrack(c)
+			if err := s.accept(c, pc, proxy); err != nil {
+				conn.Close()
+				return
+			}
+			c.Serve()
+		}()
+	}
+}
+
+// accept RTMP 핸드셰이크 전에 PROXY protocol 헤더를 읽어 클라이언트 주소를 정하고, 전역 accept 접근 규칙과 IP별 연결 수를 검사한 뒤
+// RTMPS 연결이면 TLS 핸드셰이크를 합니다. 기다리느라 Accept 루프가 막히지 않도록 연결 고루틴에서 호출합니다.
+func (s *Server) accept(c *Connection, pc *proxyConn, proxy ProxyProtocolConfig) error {
+	if pc != nil {
+		if err := pc.readHeader(proxy); err != nil {
+			log.Printf("Rejected connection from %s: %s", pc.Conn.RemoteAddr(), err.Error())
+			s.Context.Metrics.Inc("rtmp_proxy_protocol_errors_total")
+			return err
+		}
+		if h := pc.header; h != nil && h.Source != nil {
+			log.Printf("Accepted connection from %s via proxy %s", h.Source, pc.Conn.RemoteAddr())
+		}
+	}
+	if err := s.Context.checkAccess("", AccessActionAccept, c.clientIP()); err != nil {
+		return err
+	}
+	if err := s.trackIP(c); err != nil {
+		log.Printf("Rejected connection from %s: %s", c.clientIP(), err.Error())
+		s.Context.limitRejected(limitConnectionsPerIP)
+		return err
+	}
+	if tc, ok := c.Conn.(*tls.Conn); ok {
+		tc.SetDeadline(time.Now().Add(tlsHandshakeTimeout))
+		err := tc.Handshake()
+		tc.SetDeadline(time.Time{})
+		if err != nil {
+			log.Printf("TLS handshake failed from %s: %s", c.clientIP(), err.Error())
+			s.Context.Metrics.Inc("rtmp_tls_handshake_errors_total")
+			return err
+		}
+		log.Printf("RTMPS connection from %s (server name %q)", c.clientIP(), tc.ConnectionState().ServerName)
+	}
+	return nil
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// errTooManyConnections 동시 연결 수가 limits.max_connections에 도달했을 때 새 연결을 거절합니다.
+var errTooManyConnections = errors.New("too many connections")
+
+func (s *Server) track(c *Connection) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrServerClosed
+	}
+	if max := s.Context.limits().MaxConnections; max > 0 && len(s.conns) >= max {
+		return errTooManyConnections
+	}
+	s.conns[c] = ""
+	s.wg.Add(1)
+	s.Context.Metrics.SetGauge("rtmp_connections", int64(len(s.conns)))
+	return nil
+}
+
+// trackIP 클라이언트 IP의 연결 수를 늘립니다. PROXY protocol 헤더로 원래 주소를 알게 된 뒤에 호출합니다.
+func (s *Server) trackIP(c *Connection) error {
+	ip := c.clientIP()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if max := s.Context.limits().MaxConnectionsPerIP; max > 0 && s.ips[ip] >= max {
+		return errTooManyConnectionsPerIP
+	}
+	s.ips[ip]++
+	s.conns[c] = ip
+	return nil
+}
+
+func (s *Server) untrack(c *Connection) {
+	s.mu.Lock()
+	if ip := s.conns[c]; ip != "" {
+		if s.ips[ip]--; s.ips[ip] <= 0 {
+			delete(s.ips, ip)
+		}
+	}
+	delete(s.conns, c)
+	s.Context.Metrics.SetGauge("rtmp_connections", int64(len(s.conns)))
+	s.mu.Unlock()
+	s.wg.Done()
+}
+
+// Shutdown 서버를 정상 종료합니다.
+//  1. 리스너를 닫아 새 연결을 받지 않습니다.
+//  2. 퍼블리셔에게 NetStream.Unpublish.Success, 시청자에게 NetStream.Play.Stop을 보냅니다.
+//  3. 모든 연결을 닫고, 연결 고루틴과 녹화기, 패키저가 파일을 마무리할 때까지 기다립니다.
+//
+// ctx가 먼저 끝나면 더 기다리지 않고 ctx의 에러를 반환합니다.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	for l := range s.listeners {
+		l.Close()
+	}
+	conns := make([]*Connection, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	s.mu.Unlock()
+
+	log.Printf("Shutting down, closing %d connections", len(conns))
+	hubs := s.Context.publisherHubs()
+	// 엣지 풀은 알릴 연결이 없으므로 업스트림을 닫고, 허브가 닫히면 시청자 연결도 끝납니다.
+	for _, e := range s.Context.edgePulls() {
+		e.cancel()
+		hubs = append(hubs, e.conn.Hub)
+	}
+
+	// 응답하지 않는 클라이언트가 종료를 막지 않도록 알림은 동시에, 제한 시간 안에 보냅니다.
+	var notified sync.WaitGroup
+	for _, c := range conns {
+		notified.Add(1)
+		go func(c *Connection) {
+			defer notified.Done()
+			c.notifyShutdown()
+			c.Conn.Close()
+		}(c)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		notified.Wait()
+		s.wg.Wait()
+		// 허브가 닫힌 뒤 녹화 파일, 플레이리스트를 마무리할 때까지 기다립니다.
+		for _, hub := range hubs {
+			hub.Wait()
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+		log.Printf("Shutdown complete")
+		return nil
+	case <-ctx.Done():
+		// 알림을 보내지 못한 연결도 모두 끊습니다.
+		for _, c := range conns {
+			c.Conn.Close()
+		}
+		log.Printf("Shutdown deadline exceeded: %s", ctx.Err().Error())
+		return ctx.Err()
+	}
+}
+
+// shutdownNoticeTimeout 서버 종료 알림을 보낼 때 연결 하나에 허용하는 시간입니다.
+const shutdownNoticeTimeout = time.Second
+
+// notifyShutdown 퍼블리셔, 시청자에게 서버가 종료되어 스트림이 끝났음을 알립니다.
+func (c *Connection) notifyShutdown() {
+	c.shuttingDown.Store(true)
+	if streamID := c.publishStreamID.Load(); streamID != 0 {
+		c.sendStatus(streamID, "status", "NetStream.Unpublish.Success", "Server is shutting down")
+	}
+	if streamID := c.playStreamID.Load(); streamID != 0 {
+		c.sendStatus(streamID, "status", "NetStream.Play.UnpublishNotify", "Server is shutting down")
+		c.sendUserControl(userControlStreamEOF, streamID)
+		c.sendStatus(streamID, "status", "NetStream.Play.Stop", "Server is shutting down")
+	}
+}
@@ -0,0 +1,191 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// testTimeout 테스트에서 연결, 패킷을 기다리는 최대 시간입니다.
+const testTimeout = 5 * time.Second
+
+// testKeyFrame 코덱 파싱이 필요 없는 Sorenson H.263 키프레임입니다. 허브, 릴레이는 키프레임부터 전달합니다.
+var testKeyFrame = &Packet{Type: MessageTypeVideo, Data: []byte{0x12, 0xAB, 0xCD}}
+
+// startTestServer 127.0.0.1의 임의 포트에서 apps 설정으로 RTMP 서버를 시작합니다.
+// configure로 시간 제한 등 나머지 설정을 바꿀 수 있습니다.
+func startTestServer(t *testing.T, apps map[string]*AppConfig, configure ...func(*Config)) (*Server, string) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return serveTestServer(t, l, apps, configure...), l.Addr().String()
+}
+
+// serveTestServer l에서 apps 설정으로 RTMP 서버를 시작하고, 테스트가 끝나면 종료합니다.
+func serveTestServer(t *testing.T, l net.Listener, apps map[string]*AppConfig, configure ...func(*Config)) *Server {
+	t.Helper()
+	cfg := DefaultConfig()
+	dir := t.TempDir()
+	cfg.Paths = PathConfig{HLS: dir, CMAF: dir, Record: dir, VOD: dir}
+	cfg.HTTPListen = ""
+	// apps가 nil이면 기본 app 설정을 사용합니다.
+	if apps != nil {
+		for name, app := range apps {
+			app.Name = name
+		}
+		cfg.Apps = apps
+	}
+	for _, f := range configure {
+		f(cfg)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(NewStreamContext(cfg))
+	go s.Serve(l)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+		defer cancel()
+		s.Shutdown(ctx)
+	})
+	return s
+}
+
+// dialTestClient rawURL의 서버에 접속해 connect, createStream까지 마칩니다.
+func dialTestClient(t *testing.T, rawURL string) *RTMPClient {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	client, err := DialRTMP(ctx, rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("connect %s: %s", rawURL, err)
+	}
+	if err := client.CreateStream(ctx); err != nil {
+		t.Fatalf("createStream %s: %s", rawURL, err)
+	}
+	return client
+}
+
+// publishTestStream rawURL로 퍼블리시를 시작하고, 테스트가 끝날 때까지 주기적으로 키프레임을 보냅니다.
+func publishTestStream(t *testing.T, rawURL string) *RTMPClient {
+	t.Helper()
+	client := dialTestClient(t, rawURL)
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	if err := client.Publish(ctx, client.Stream); err != nil {
+		t.Fatalf("publish %s: %s", rawURL, err)
+	}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(20 * time.Millisecond)
+		defer ticker.Stop()
+		for timestamp := uint32(0); ; timestamp += 20 {
+			if client.WritePacket(&Packet{Type: testKeyFrame.Type, Timestamp: timestamp, Data: testKeyFrame.Data}) != nil {
+				return
+			}
+			select {
+			case <-ticker.C:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	t.Cleanup(func() {
+		close(stop)
+		<-done
+	})
+	return client
+}
+
+// playTestStream rawURL의 스트림 재생을 시작합니다.
+func playTestStream(t *testing.T, rawURL string) *RTMPClient {
+	t.Helper()
+	client := dialTestClient(t, rawURL)
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	if err := client.Play(ctx, client.Stream); err != nil {
+		t.Fatalf("play %s: %s", rawURL, err)
+	}
+	return client
+}
+
+// expectTestKeyFrame 재생 중인 클라이언트가 publishTestStream이 보낸 키프레임을 받는지 확인합니다.
+func expectTestKeyFrame(t *testing.T, client *RTMPClient) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	for {
+		p, err := client.ReadPacket(ctx)
+		if err != nil {
+			t.Fatalf("no media received: %s", err)
+		}
+		if p.IsVideo() && bytes.Equal(p.Data, testKeyFrame.Data) {
+			return
+		}
+	}
+}
+
+// waitFor cond가 true가 될 때까지 기다립니다.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(testTimeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// unusedAddr 연결을 받지 않는 127.0.0.1 주소를 반환합니다.
+func unusedAddr(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	return addr
+}
+
+// contextWithTestTimeout testTimeout 뒤에 끝나는 컨텍스트를 만듭니다.
+func contextWithTestTimeout(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestDefaultConfigAcceptsAnyApp(t *testing.T) {
+	server, addr := startTestServer(t, nil)
+	publishTestStream(t, "rtmp://"+addr+"/anything/cam")
+	waitFor(t, "publisher", func() bool { return server.Context.lookupStream("anything", "cam") != nil })
+	expectTestKeyFrame(t, playTestStream(t, "rtmp://"+addr+"/anything/cam"))
+
+	if conf := server.Context.app("anything"); conf.Name != "anything" {
+		t.Errorf("wildcard app name %q", conf.Name)
+	}
+}
+
+func TestStreamContextsKeepOwnPaths(t *testing.T) {
+	a, b := DefaultConfig(), DefaultConfig()
+	a.Paths.Record, b.Paths.Record = "/a/", "/b/"
+	b.Listen = nil
+	ctxA, ctxB := NewStreamContext(a), NewStreamContext(b)
+	if ctxA.Paths.Record != "/a/" || ctxB.Paths.Record != "/b/" {
+		t.Errorf("record paths %q, %q", ctxA.Paths.Record, ctxB.Paths.Record)
+	}
+	if ctxA.previewHost != "localhost:1935" || ctxB.previewHost != "localhost" {
+		t.Errorf("preview hosts %q, %q", ctxA.previewHost, ctxB.previewHost)
+	}
+}
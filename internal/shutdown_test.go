@@ -0,0 +1,208 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"example/hello/internal/amf"
+	"example/hello/internal/format/flvio"
+	"example/hello/internal/util/endian"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// rawTestPlayer 청크를 직접 읽는 시청자입니다. RTMPClient가 드러내지 않는 User Control 메시지를 확인하거나,
+// 받지 않는 시청자를 흉내 낼 때 사용합니다.
+type rawTestPlayer struct {
+	conn      net.Conn
+	r         *bufio.Reader
+	chunkSize int
+}
+
+// rawTestMessage rawTestPlayer가 받은 메시지입니다.
+type rawTestMessage struct {
+	messageType uint8
+	streamID    uint32
+	payload     []byte
+}
+
+// dialRawTestPlayer addr의 서버에 접속해 app/stream 재생을 시작하고 NetStream.Play.Start까지 읽습니다.
+func dialRawTestPlayer(t *testing.T, addr, app, stream string) *rawTestPlayer {
+	t.Helper()
+	netConn, err := net.DialTimeout("tcp", addr, testTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { netConn.Close() })
+	netConn.SetDeadline(time.Now().Add(testTimeout))
+	c := NewConnection(netConn, nil)
+	if err := c.clientHandshake(); err != nil {
+		t.Fatal(err)
+	}
+	p := &rawTestPlayer{conn: netConn, r: c.Reader, chunkSize: 128}
+
+	connect, _ := amf.Encode("connect", 1.0, flvio.AMFMap{"app": app, "tcUrl": "rtmp://" + addr + "/" + app})
+	createStream, _ := amf.Encode("createStream", 2.0, nil)
+	play, _ := amf.Encode("play", 0.0, nil, stream, -2000.0)
+	for _, m := range []rawTestMessage{{20, 0, connect}, {20, 0, createStream}, {20, 1, play}} {
+		chunk := &rtmpChunk{
+			header:  &chunkHeader{csID: 3, messageType: m.messageType, messageStreamID: m.streamID, length: uint32(len(m.payload))},
+			payload: m.payload,
+		}
+		if _, err := netConn.Write(bytes.Join((&Connection{WriteMaxChunkSize: 128}).create(chunk), nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for {
+		m, err := p.readMessage()
+		if err != nil {
+			t.Fatalf("waiting for NetStream.Play.Start: %s", err)
+		}
+		if m.event() == "NetStream.Play.Start" {
+			return p
+		}
+	}
+}
+
+// readMessage 메시지 하나를 읽습니다. 서버는 메시지마다 fmt 0 청크로 시작하고 나머지는 fmt 3 청크로 보냅니다.
+func (p *rawTestPlayer) readMessage() (*rawTestMessage, error) {
+	p.conn.SetReadDeadline(time.Now().Add(testTimeout))
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(p.r, header); err != nil {
+		return nil, err
+	}
+	if header[0]>>6 != 0 {
+		return nil, fmt.Errorf("chunk fmt %d, want the first chunk of a message", header[0]>>6)
+	}
+	if endian.U24BE(header[1:4]) == 0xffffff {
+		if _, err := p.r.Discard(4); err != nil {
+			return nil, err
+		}
+	}
+	m := &rawTestMessage{
+		messageType: header[7],
+		streamID:    binary.LittleEndian.Uint32(header[8:]),
+		payload:     make([]byte, endian.U24BE(header[4:7])),
+	}
+	for n := 0; n < len(m.payload); {
+		if n > 0 {
+			if _, err := p.r.Discard(1); err != nil {
+				return nil, err
+			}
+		}
+		size := min(p.chunkSize, len(m.payload)-n)
+		if _, err := io.ReadFull(p.r, m.payload[n:n+size]); err != nil {
+			return nil, err
+		}
+		n += size
+	}
+	if m.messageType == 1 {
+		p.chunkSize = int(binary.BigEndian.Uint32(m.payload))
+	}
+	return m, nil
+}
+
+// event onStatus는 code를, User Control은 "UserControl <이벤트> <스트림 ID>"를 반환합니다. 나머지 메시지는 빈 문자열입니다.
+func (m *rawTestMessage) event() string {
+	switch m.messageType {
+	case 4:
+		return testUserControlEvent(binary.BigEndian.Uint16(m.payload), binary.BigEndian.Uint32(m.payload[2:]))
+	case 20:
+		command, _ := amf.Decode(m.payload)
+		if command["cmd"] == "onStatus" {
+			info, _ := command["info"].(map[string]interface{})
+			code, _ := info["code"].(string)
+			return code
+		}
+	}
+	return ""
+}
+
+func testUserControlEvent(event uint16, streamID uint32) string {
+	return fmt.Sprintf("UserControl %d %d", event, streamID)
+}
+
+func TestShutdownNotifiesClients(t *testing.T) {
+	server, addr := startTestServer(t, map[string]*AppConfig{"live": {Record: true}})
+	publisher := publishMediaTestStream(t, server, "rtmp://"+addr+"/live/cam", "live", "cam", true, true)
+	player := dialRawTestPlayer(t, addr, "live", "cam")
+
+	if err := server.Shutdown(contextWithTestTimeout(t)); err != nil {
+		t.Fatalf("Shutdown: %s", err)
+	}
+	waitTestStatus(t, publisher, "NetStream.Unpublish.Success")
+
+	// 시청자는 스트림이 끝났다는 알림을 순서대로 받은 뒤 연결이 닫힙니다.
+	var events []string
+	for {
+		m, err := player.readMessage()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("player connection was not closed: %s", err)
+		}
+		if e := m.event(); e != "" {
+			events = append(events, e)
+		}
+	}
+	want := []string{"NetStream.Play.UnpublishNotify", testUserControlEvent(userControlStreamEOF, 1), "NetStream.Play.Stop"}
+	next := 0
+	for _, e := range events {
+		if next < len(want) && e == want[next] {
+			next++
+		}
+	}
+	if next != len(want) {
+		t.Errorf("player got %v, want %v in order", events, want)
+	}
+
+	// Shutdown은 녹화 파일을 마무리한 뒤에 반환합니다.
+	dir := filepath.Join(server.Context.Paths.Record, "live")
+	if parts, _ := filepath.Glob(filepath.Join(dir, "*.part")); len(parts) > 0 {
+		t.Errorf("unfinished recordings %v", parts)
+	}
+	files := readTestRecordings(t, dir, "flv")
+	if len(files) != 1 {
+		t.Fatalf("%d recordings, want 1", len(files))
+	}
+	if _, original := files[0].keyFrames(); len(original) != 1 || original[0] != 1000 {
+		t.Errorf("recorded keyframes %v, want the one sent at 1000ms", original)
+	}
+}
+
+func TestShutdownClosesStuckClient(t *testing.T) {
+	server, addr := startTestServer(t, map[string]*AppConfig{"live": {}})
+	publisher := dialTestClient(t, "rtmp://"+addr+"/live/cam")
+	if err := publisher.Publish(contextWithTestTimeout(t), publisher.Stream); err != nil {
+		t.Fatal(err)
+	}
+	stuck := dialRawTestPlayer(t, addr, "live", "cam")
+
+	// 시청자가 읽지 않으므로 소켓 버퍼가 가득 차면 서버의 쓰기가 멈추고, 종료 알림도 보낼 수 없습니다.
+	frame := &Packet{Type: MessageTypeVideo, Data: append(bytes.Clone(testKeyFrame.Data), make([]byte, 1<<20)...)}
+	for i := 0; i < 64; i++ {
+		if err := publisher.WritePacket(frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Shutdown = %v, want %v", err, context.DeadlineExceeded)
+	}
+	// 제한 시간이 지나면 알림을 받지 못한 연결도 닫습니다. 시청자가 데이터를 읽지 않아도 서버는 연결을 정리합니다.
+	waitFor(t, "stuck connection to close", func() bool {
+		return server.Context.Metrics.Gauge("rtmp_connections") == 0
+	})
+	stuck.conn.SetReadDeadline(time.Now().Add(testTimeout))
+	if _, err := io.Copy(io.Discard, stuck.r); err != nil {
+		t.Errorf("stuck connection was not closed: %s", err)
+	}
+}
@@ -1,14 +1,181 @@
 package internal
 
+import (
+	"errors"
+	"sync"
+)
+
 type StreamContext struct {
 	Sessions map[string]*Connection
 	Preview  chan string
+	Apps     map[string]*AppConfig
+	// Hooks 연결 이벤트를 전달받습니다. (nil이면 사용하지 않음)
+	Hooks Hooks
+	// RTMP connect 응답 전에 보내는 프로토콜 제어 값입니다. (0인 값은 기본값)
+	RTMP RTMPConfig
+	// Limits 연결 수 등의 제한입니다.
+	Limits LimitConfig
+	// Timeouts 핸드셰이크, 유휴, 쓰기 시간 제한입니다. 새 연결부터 적용됩니다.
+	Timeouts TimeoutConfig
+	// Webhooks 모든 app에 적용되는 웹훅입니다.
+	Webhooks WebhookConfig
+	// Access 전역 접근 규칙입니다. app에 규칙이 없는 동작에 적용됩니다.
+	Access AccessConfig
+	// Admin 관리 API의 토큰, 접근 규칙입니다.
+	Admin AdminConfig
+	// Proxy RTMP 리스너의 PROXY protocol 설정입니다.
+	Proxy ProxyProtocolConfig
+	// Metrics 거절된 연결 수 등의 카운터입니다.
+	Metrics Metrics
+	// Paths HLS, CMAF 출력, 녹화, VOD 디렉터리입니다. 바꾸려면 재시작해야 합니다.
+	Paths PathConfig
+	// HTTPListen NewHTTPServer가 사용하는 HTTP-FLV, WebSocket-FLV, RTMPT 리스닝 주소입니다.
+	HTTPListen string
+	// ConfigSource SIGHUP, POST /api/reload 때 설정을 다시 읽는 함수입니다. (nil이면 다시 읽을 수 없음)
+	ConfigSource func() (*Config, error)
+
+	// config 마지막으로 적용한 설정입니다. 다시 읽을 때 재시작이 필요한 변경을 찾는 데 사용합니다.
+	config *Config
+	// challenges connect 인증으로 발급한 챌린지입니다. (opaque 또는 nonce → 챌린지)
+	challenges  map[string]*connectChallenge
+	challengeMu sync.Mutex
+	// outgoing 클라이언트에게 보낸 바이트 수입니다. 대역폭 제한에 사용합니다.
+	outgoing rateMeter
+	// previewHost ffmpeg가 미리보기 스트림을 받아올 이 서버의 RTMP 주소입니다. (기본 포트가 아니면 host:port)
+	previewHost string
+	// rtmpt ListenRTMPT로 만든 RTMPT 세션 리스너입니다. (nil이면 RTMPT 요청에 404로 응답)
+	rtmpt *rtmptListener
+
+	mu sync.RWMutex
+}
+
+// errStreamInUse 같은 app, 스트림 이름으로 이미 퍼블리시 중입니다.
+var errStreamInUse = errors.New("stream is already being published")
+
+// sessionKey Sessions의 키입니다. app마다 인증, 접근 규칙이 다르므로 스트림 이름만으로 구분하지 않습니다.
+func sessionKey(app, stream string) string {
+	return app + "/" + stream
 }
 
-func (ctx *StreamContext) set(key string, c *Connection) {
+// addPublisher c를 c.AppName, c.StreamKey의 퍼블리셔로 등록합니다.
+// 같은 스트림이 이미 퍼블리시(또는 엣지 풀) 중이면 기존 세션을 덮어쓰지 않고 errStreamInUse를,
+// app의 퍼블리셔 수 제한에 도달했으면 제한 에러를 반환합니다. 확인과 등록은 같은 잠금 안에서 이루어집니다.
+func (ctx *StreamContext) addPublisher(c *Connection) error {
+	max := ctx.maxPublishers(c.AppName)
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	key := sessionKey(c.AppName, c.StreamKey)
+	if _, ok := ctx.Sessions[key]; ok {
+		return errStreamInUse
+	}
+	if err := ctx.publisherLimitLocked(c.AppName, max); err != nil {
+		return err
+	}
 	ctx.Sessions[key] = c
+	return nil
 }
 
 func (ctx *StreamContext) get(key string) *Connection {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
 	return ctx.Sessions[key]
 }
+
+// remove 세션이 c일 때만 삭제합니다. 같은 키로 새 퍼블리셔가 들어온 경우 지우지 않기 위함입니다.
+func (ctx *StreamContext) remove(key string, c *Connection) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	if ctx.Sessions[key] == c {
+		delete(ctx.Sessions, key)
+	}
+}
+
+// lookupApp app 이름에 해당하는 설정을 찾습니다. 설정에 없는 이름은 와일드카드 app("*")이 있을 때만 그 설정을 사용합니다.
+// 둘 다 없으면 false를 반환하고, 이 app의 connect, publish, play는 거절합니다.
+func (ctx *StreamContext) lookupApp(name string) (*AppConfig, bool) {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+	if conf, ok := ctx.Apps[name]; ok {
+		return conf, true
+	}
+	wildcard, ok := ctx.Apps[WildcardAppName]
+	if !ok || !validPathName(name) {
+		return nil, false
+	}
+	conf := *wildcard
+	conf.Name = name
+	return &conf, true
+}
+
+// app app 이름에 해당하는 설정을 반환합니다. 설정이 없으면(다시 읽으면서 빠진 app 등) 기본 설정을 반환합니다.
+func (ctx *StreamContext) app(name string) *AppConfig {
+	if conf, ok := ctx.lookupApp(name); ok {
+		return conf
+	}
+	conf := defaultAppConfig
+	conf.Name = name
+	return &conf
+}
+
+// publisherHubs RTMP 퍼블리셔의 허브 목록을 반환합니다. (엣지 풀은 제외)
+func (ctx *StreamContext) publisherHubs() []*StreamHub {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+	var hubs []*StreamHub
+	for _, c := range ctx.Sessions {
+		if c.Hub != nil && c.edge == nil {
+			hubs = append(hubs, c.Hub)
+		}
+	}
+	return hubs
+}
+
+// edgePulls 진행 중인 엣지 풀 목록을 반환합니다.
+func (ctx *StreamContext) edgePulls() []*edgePull {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+	var pulls []*edgePull
+	for _, c := range ctx.Sessions {
+		if c.edge != nil {
+			pulls = append(pulls, c.edge)
+		}
+	}
+	return pulls
+}
+
+// appExists app이 설정에 있거나 와일드카드 app으로 받을 수 있는지 확인합니다.
+func (ctx *StreamContext) appExists(name string) bool {
+	_, ok := ctx.lookupApp(name)
+	return ok
+}
+
+// proxyProtocol 현재 적용 중인 PROXY protocol 설정을 반환합니다.
+func (ctx *StreamContext) proxyProtocol() ProxyProtocolConfig {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+	return ctx.Proxy
+}
+
+// limits 현재 적용 중인 제한을 반환합니다.
+func (ctx *StreamContext) limits() LimitConfig {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+	return ctx.Limits
+}
+
+// rtmpConfig 설정하지 않은 값을 기본값으로 채운 프로토콜 제어 값을 반환합니다.
+func (ctx *StreamContext) rtmpConfig() RTMPConfig {
+	ctx.mu.RLock()
+	conf := ctx.RTMP
+	ctx.mu.RUnlock()
+	if conf.ChunkSize == 0 {
+		conf.ChunkSize = defaultRTMPConfig.ChunkSize
+	}
+	if conf.WindowAckSize == 0 {
+		conf.WindowAckSize = defaultRTMPConfig.WindowAckSize
+	}
+	if conf.PeerBandwidth == 0 {
+		conf.PeerBandwidth = defaultRTMPConfig.PeerBandwidth
+	}
+	return conf
+}
@@ -0,0 +1,221 @@
+package internal
+
+import (
+	"io"
+	"log"
+	"sync"
+)
+
+// subscriberQueueSize 시청자 한 명당 쌓아둘 수 있는 최대 패킷 수입니다.
+// 큐가 가득 차면 다음 키프레임까지 패킷을 버려 느린 시청자가 퍼블리셔를 막지 않도록 합니다.
+const subscriberQueueSize = 512
+
+// maxGOPCacheSize GOP 캐시에 보관할 최대 패킷 수입니다. 키프레임 간격이 너무 긴 스트림에서 메모리가 계속 늘어나는 것을 막습니다.
+const maxGOPCacheSize = 4096
+
+// PacketWriter 허브로부터 미디어 패킷을 전달받는 출력(RTMP 시청자, HTTP-FLV, 패키저, 녹화기 등) 입니다.
+// WritePacket이 에러를 반환하면 해당 구독은 종료됩니다.
+// io.Closer를 함께 구현하면 구독이 끝날 때 Close가 호출되어 파일, 플레이리스트 등을 마무리할 수 있습니다.
+type PacketWriter interface {
+	WritePacket(p *Packet) error
+}
+
+// StreamHub 하나의 퍼블리셔가 보내는 패킷을 여러 구독자에게 나누어 줍니다.
+// 새 구독자가 바로 재생할 수 있도록 메타데이터, 시퀀스 헤더, 마지막 GOP를 캐시합니다.
+type StreamHub struct {
+	mu sync.RWMutex
+
+	metaData    *Packet
+	videoHeader *Packet
+	audioHeader *Packet
+	gop         []*Packet
+
+	subscribers map[*Subscription]struct{}
+	closed      bool
+	// wg 구독 고루틴이 모두 끝날 때까지 기다리기 위해 사용합니다.
+	wg sync.WaitGroup
+}
+
+// Subscription 허브와 PacketWriter 사이의 연결입니다. 구독자마다 별도의 고루틴과 큐를 가집니다.
+type Subscription struct {
+	hub     *StreamHub
+	w       PacketWriter
+	queue   chan *Packet
+	waitKey bool
+	done    chan struct{}
+	once    sync.Once
+}
+
+func NewStreamHub() *StreamHub {
+	return &StreamHub{
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Write 퍼블리셔로부터 받은 패킷을 캐시에 반영하고 모든 구독자에게 전달합니다.
+func (h *StreamHub) Write(p *Packet) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return
+	}
+
+	switch {
+	case p.IsMetaData():
+		h.metaData = p
+	case p.IsSequenceHeader() && p.IsVideo():
+		h.videoHeader = p
+	case p.IsSequenceHeader() && p.IsAudio():
+		h.audioHeader = p
+	case p.IsKeyFrame():
+		h.gop = append(h.gop[:0:0], p)
+	case len(h.gop) >= maxGOPCacheSize:
+		h.gop = nil
+	case len(h.gop) > 0:
+		h.gop = append(h.gop, p)
+	}
+
+	for s := range h.subscribers {
+		s.push(p)
+	}
+}
+
+// Subscribe 구독자를 등록합니다. 캐시된 메타데이터, 시퀀스 헤더, GOP가 먼저 전달됩니다.
+func (h *StreamHub) Subscribe(w PacketWriter) *Subscription {
+	s := &Subscription{
+		hub:   h,
+		w:     w,
+		queue: make(chan *Packet, subscriberQueueSize),
+		done:  make(chan struct{}),
+	}
+
+	h.mu.Lock()
+	for _, p := range h.cached() {
+		s.push(p)
+	}
+	if h.closed {
+		close(s.queue)
+	} else {
+		h.subscribers[s] = struct{}{}
+	}
+	h.wg.Add(1)
+	h.mu.Unlock()
+
+	go s.run()
+	return s
+}
+
+// Unsubscribe 구독을 해제합니다. 이미 큐에 들어간 패킷은 버려집니다.
+func (h *StreamHub) Unsubscribe(s *Subscription) {
+	h.mu.Lock()
+	if _, ok := h.subscribers[s]; ok {
+		delete(h.subscribers, s)
+		close(s.queue)
+	}
+	h.mu.Unlock()
+}
+
+// Close 퍼블리셔가 종료되었을 때 호출합니다. 구독자는 남은 큐를 모두 처리한 뒤 종료됩니다.
+func (h *StreamHub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return
+	}
+	h.closed = true
+	for s := range h.subscribers {
+		close(s.queue)
+	}
+	h.subscribers = make(map[*Subscription]struct{})
+}
+
+// Wait 모든 구독이 끝날 때까지 기다립니다. Close 이후 녹화기, 패키저가 파일을 마무리하는 것을 기다릴 때 사용합니다.
+func (h *StreamHub) Wait() {
+	h.wg.Wait()
+}
+
+// MetaData 캐시된 onMetaData 패킷을 반환합니다.
+func (h *StreamHub) MetaData() *Packet {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.metaData
+}
+
+// SequenceHeaders 캐시된 비디오, 오디오 시퀀스 헤더를 반환합니다. (없으면 nil)
+func (h *StreamHub) SequenceHeaders() (video *Packet, audio *Packet) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.videoHeader, h.audioHeader
+}
+
+// SubscriberCount 현재 구독자 수를 반환합니다.
+func (h *StreamHub) SubscriberCount() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subscribers)
+}
+
+func (h *StreamHub) cached() []*Packet {
+	var res []*Packet
+	for _, p := range []*Packet{h.metaData, h.videoHeader, h.audioHeader} {
+		if p != nil {
+			res = append(res, p)
+		}
+	}
+	return append(res, h.gop...)
+}
+
+// push 큐에 여유가 없으면 패킷을 버리고, 다음 키프레임부터 다시 전달합니다.
+// 시퀀스 헤더와 메타데이터는 디코딩에 꼭 필요하므로 버리지 않습니다.
+func (s *Subscription) push(p *Packet) {
+	essential := p.IsMetaData() || p.IsSequenceHeader()
+	if s.waitKey && !essential {
+		if !p.IsKeyFrame() {
+			return
+		}
+		s.waitKey = false
+	}
+
+	select {
+	case s.queue <- p:
+	default:
+		if !s.waitKey {
+			log.Printf("Subscriber queue full, dropping packets until next keyframe")
+		}
+		s.waitKey = true
+	}
+}
+
+func (s *Subscription) run() {
+	defer s.hub.wg.Done()
+	defer close(s.done)
+	if closer, ok := s.w.(io.Closer); ok {
+		defer func() {
+			if err := closer.Close(); err != nil {
+				log.Printf("Error while closing subscriber: %s", err.Error())
+			}
+		}()
+	}
+	for p := range s.queue {
+		if err := s.w.WritePacket(p); err != nil {
+			s.hub.Unsubscribe(s)
+			// 남은 패킷은 버립니다.
+			for range s.queue {
+			}
+			return
+		}
+	}
+}
+
+// Done 구독이 끝나면 닫히는 채널을 반환합니다.
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Close 구독을 해제하고 구독 고루틴이 끝날 때까지 기다립니다.
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.hub.Unsubscribe(s)
+	})
+	<-s.done
+}
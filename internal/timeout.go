@@ -0,0 +1,139 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"time"
+)
+
+// 시간 제한 종류입니다. rtmp_timeouts_total 메트릭의 type 라벨로도 사용합니다.
+const (
+	timeoutHandshake   = "handshake"
+	timeoutIdle        = "idle"
+	timeoutPublishIdle = "publish_idle"
+	timeoutWrite       = "write"
+)
+
+// TimeoutConfig 응답하지 않거나 멈춘 클라이언트의 연결을 정리하는 시간 제한입니다. 0은 제한 없음입니다.
+//
+//	timeouts:
+//	  handshake: 10s
+//	  idle: 60s
+//	  publish_idle: 30s
+//	  write: 30s
+//
+// 시간 제한에 걸리면 연결을 닫고, 퍼블리셔였다면 세션과 허브(시청자, 녹화기, 패키저 구독)를 정리합니다.
+type TimeoutConfig struct {
+	// Handshake 연결을 받은 뒤 RTMP 핸드셰이크(C0, C1, C2)를 마칠 때까지의 시간입니다.
+	Handshake time.Duration `yaml:"handshake"`
+	// Idle 퍼블리셔가 아닌 연결에서 메시지 하나를 받을 때까지 기다리는 시간입니다. 시청자에게는 Idle의 절반마다 Ping을 보냅니다.
+	Idle time.Duration `yaml:"idle"`
+	// PublishIdle 퍼블리셔가 오디오, 비디오를 보내지 않고 있을 수 있는 시간입니다. 제어 메시지만 보내는 퍼블리셔도 정리합니다.
+	PublishIdle time.Duration `yaml:"publish_idle"`
+	// Write 메시지 하나를 보내는 시간입니다. 받지 않는 시청자(HTTP-FLV, WebSocket-FLV 포함)를 정리합니다.
+	Write time.Duration `yaml:"write"`
+}
+
+// defaultTimeoutConfig 설정하지 않았을 때 사용하는 시간 제한입니다.
+var defaultTimeoutConfig = TimeoutConfig{
+	Handshake:   10 * time.Second,
+	Idle:        60 * time.Second,
+	PublishIdle: 30 * time.Second,
+	Write:       30 * time.Second,
+}
+
+func (conf *TimeoutConfig) validate() error {
+	for name, d := range map[string]time.Duration{timeoutHandshake: conf.Handshake, timeoutIdle: conf.Idle, timeoutPublishIdle: conf.PublishIdle, timeoutWrite: conf.Write} {
+		if d < 0 {
+			return fmt.Errorf("%s: must not be negative", name)
+		}
+	}
+	return nil
+}
+
+// timeouts 현재 적용 중인 시간 제한을 반환합니다.
+func (ctx *StreamContext) timeouts() TimeoutConfig {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+	return ctx.Timeouts
+}
+
+// isTimeout 읽기, 쓰기 제한 시간이 지나 실패했는지 확인합니다.
+func isTimeout(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+// setReadDeadline 다음 메시지를 읽기 전에 연결 상태에 맞는 읽기 제한 시간을 설정합니다.
+//   - 퍼블리셔: 마지막으로 오디오, 비디오를 받은 시각부터 PublishIdle
+//   - 그 밖(connect 후, 시청자 포함): 지금부터 Idle
+//
+// 시청자는 Acknowledgement 말고는 보내는 메시지가 거의 없으므로, 재생 중에는 startPing으로 Idle의 절반마다
+// Ping Request를 보내 Ping Response를 받습니다. 응답하지 않는 시청자는 Idle 뒤에 정리됩니다.
+//
+// 메시지를 읽기 시작할 때 정하므로, 청크를 조금씩 보내며 메시지를 끝내지 않는 클라이언트도 정리됩니다.
+func (c *Connection) setReadDeadline() {
+	var deadline time.Time
+	switch {
+	case c.Hub != nil:
+		if c.timeouts.PublishIdle > 0 {
+			deadline = c.lastMedia.Add(c.timeouts.PublishIdle)
+		}
+	case c.timeouts.Idle > 0:
+		deadline = time.Now().Add(c.timeouts.Idle)
+	}
+	c.Conn.SetReadDeadline(deadline)
+}
+
+// startPing 재생을 시작한 연결에 Idle의 절반마다 Ping Request를 보냅니다. 연결이 닫히면 멈춥니다.
+func (c *Connection) startPing() {
+	if c.timeouts.Idle <= 0 || c.pingStop != nil {
+		return
+	}
+	c.pingStop = make(chan struct{})
+	go c.pingLoop(c.timeouts.Idle/2, c.pingStop)
+}
+
+func (c *Connection) pingLoop(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			// Ping Request의 인자는 스트림 ID 대신 타임스탬프(밀리초)입니다.
+			if c.sendUserControl(userControlPingRequest, uint32(time.Now().UnixMilli())) != nil {
+				return
+			}
+		}
+	}
+}
+
+// readTimeoutKind 읽기 제한 시간에 걸렸을 때 어느 단계였는지 반환합니다.
+func (c *Connection) readTimeoutKind() string {
+	switch {
+	case !c.ConnectionStatus.HandShakeDone:
+		return timeoutHandshake
+	case c.Hub != nil:
+		return timeoutPublishIdle
+	}
+	return timeoutIdle
+}
+
+// writeTimeout 메시지 하나를 보내는 제한 시간입니다. 서버 종료 알림을 보내는 중이면 종료 알림 제한 시간을 사용합니다.
+func (c *Connection) writeTimeout() time.Duration {
+	if c.shuttingDown.Load() {
+		return shutdownNoticeTimeout
+	}
+	return c.timeouts.Write
+}
+
+// onTimeout 시간 제한에 걸린 연결을 기록하고 닫습니다. 읽기 고루틴이 끝나면서 세션과 구독이 정리됩니다.
+func (c *Connection) onTimeout(kind string) {
+	log.Printf("Connection from %s timed out (%s), closing", c.clientIP(), kind)
+	c.Context.Metrics.Inc("rtmp_timeouts_total", "type", kind)
+	c.Conn.Close()
+}
@@ -0,0 +1,25 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPlayerIdleTimeoutWithPing(t *testing.T) {
+	server, addr := startTestServer(t, map[string]*AppConfig{"live": {}}, func(cfg *Config) {
+		cfg.Timeouts.Idle = 200 * time.Millisecond
+	})
+	publishTestStream(t, "rtmp://"+addr+"/live/pinged")
+	player := playTestStream(t, "rtmp://"+addr+"/live/pinged")
+
+	// 시청자는 Acknowledgement 창(5MB)을 채우지 못해도 Ping에 응답하는 동안 Idle 뒤에도 연결이 유지됩니다.
+	ctx := contextWithTestTimeout(t)
+	for end := time.Now().Add(5 * server.Context.timeouts().Idle); time.Now().Before(end); {
+		if _, err := player.ReadPacket(ctx); err != nil {
+			t.Fatalf("player disconnected: %s", err)
+		}
+	}
+	if n := server.Context.Metrics.Value("rtmp_timeouts_total", "type", timeoutIdle); n != 0 {
+		t.Errorf("%d idle timeouts, want 0", n)
+	}
+}
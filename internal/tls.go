@@ -0,0 +1,188 @@
+package internal
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// tlsHandshakeTimeout RTMPS 연결의 TLS 핸드셰이크를 기다리는 시간입니다.
+	tlsHandshakeTimeout = 10 * time.Second
+	// tlsDefaultReloadInterval TLSConfig.ReloadInterval이 0일 때 인증서 파일을 확인하는 주기입니다.
+	tlsDefaultReloadInterval = 30 * time.Second
+)
+
+// TLSConfig RTMPS(TLS 위의 RTMP) 리스너 설정입니다. 연결은 TLS 핸드셰이크 뒤 RTMP 리스너와 같은 방식으로 처리합니다.
+//
+//	tls:
+//	  listen: [":443"]
+//	  certificates:
+//	    - cert: /etc/letsencrypt/live/live.example.com/fullchain.pem
+//	      key: /etc/letsencrypt/live/live.example.com/privkey.pem
+//
+// 인증서가 여러 개이면 클라이언트가 보낸 SNI 호스트 이름에 맞는 인증서를 사용하고, 맞는 것이 없으면 첫 번째 인증서를 사용합니다.
+// 인증서 파일이 바뀌면(갱신) 다시 시작하지 않아도 새 연결부터 새 인증서를 사용합니다.
+type TLSConfig struct {
+	Listen       []string            `yaml:"listen"`
+	Certificates []CertificateConfig `yaml:"certificates"`
+	// ReloadInterval 인증서 파일이 바뀌었는지 확인하는 주기입니다. (0이면 30초)
+	ReloadInterval time.Duration `yaml:"reload_interval"`
+}
+
+// CertificateConfig PEM 인증서(체인 포함)와 개인 키 파일 경로입니다.
+type CertificateConfig struct {
+	Cert string `yaml:"cert"`
+	Key  string `yaml:"key"`
+}
+
+func (conf *TLSConfig) validate() []error {
+	var errs []error
+	for _, addr := range conf.Listen {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			errs = append(errs, fmt.Errorf("listen: invalid address %q: %s", addr, err.Error()))
+		}
+	}
+	if len(conf.Listen) > 0 && len(conf.Certificates) == 0 {
+		errs = append(errs, errors.New("certificates: at least one certificate is required for RTMPS"))
+	}
+	for i, files := range conf.Certificates {
+		if _, err := loadCertificate(files); err != nil {
+			errs = append(errs, fmt.Errorf("certificates[%d]: %w", i, err))
+		}
+	}
+	if conf.ReloadInterval < 0 {
+		errs = append(errs, errors.New("reload_interval: must not be negative"))
+	}
+	return errs
+}
+
+// CertStore 인증서 파일을 읽어 두고 SNI에 맞는 인증서를 고릅니다. 파일이 바뀌면 Reload로 다시 읽습니다.
+type CertStore struct {
+	mu    sync.RWMutex
+	certs []*storedCert
+}
+
+type storedCert struct {
+	files   CertificateConfig
+	modTime time.Time // 인증서, 키 파일 중 늦은 수정 시각
+	cert    *tls.Certificate
+}
+
+// NewCertStore 인증서 파일을 모두 읽습니다. 하나라도 읽지 못하면 에러를 반환합니다.
+func NewCertStore(files []CertificateConfig) (*CertStore, error) {
+	if len(files) == 0 {
+		return nil, errors.New("no certificates")
+	}
+	s := &CertStore{}
+	for _, f := range files {
+		cert, err := loadCertificate(f)
+		if err != nil {
+			return nil, err
+		}
+		s.certs = append(s.certs, &storedCert{files: f, modTime: certModTime(f), cert: cert})
+	}
+	return s, nil
+}
+
+// TLSConfig RTMPS 리스너에 사용할 tls.Config를 만듭니다.
+func (s *CertStore) TLSConfig() *tls.Config {
+	return &tls.Config{
+		GetCertificate: s.GetCertificate,
+		MinVersion:     tls.VersionTLS12,
+	}
+}
+
+// GetCertificate 클라이언트가 요청한 호스트 이름(SNI)을 지원하는 인증서를 고릅니다.
+func (s *CertStore) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, sc := range s.certs {
+		if hello.SupportsCertificate(sc.cert) == nil {
+			return sc.cert, nil
+		}
+	}
+	return s.certs[0].cert, nil
+}
+
+// Reload 수정 시각이 바뀐 인증서 파일을 다시 읽습니다. 읽지 못하면(갱신 중 등) 기존 인증서를 계속 사용합니다.
+func (s *CertStore) Reload() {
+	s.mu.RLock()
+	certs := append([]*storedCert(nil), s.certs...)
+	s.mu.RUnlock()
+
+	for i, sc := range certs {
+		modTime := certModTime(sc.files)
+		if modTime.Equal(sc.modTime) {
+			continue
+		}
+		cert, err := loadCertificate(sc.files)
+		if err != nil {
+			log.Printf("Failed to reload certificate %s, keeping the current one: %s", sc.files.Cert, err.Error())
+			continue
+		}
+		log.Printf("Reloaded certificate %s (%s)", sc.files.Cert, cert.Leaf.Subject.CommonName)
+		s.mu.Lock()
+		s.certs[i] = &storedCert{files: sc.files, modTime: modTime, cert: cert}
+		s.mu.Unlock()
+	}
+}
+
+// Watch ctx가 끝날 때까지 interval마다 Reload를 호출합니다. interval이 0이면 30초마다 확인합니다.
+func (s *CertStore) Watch(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = tlsDefaultReloadInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.Reload()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// loadCertificate 인증서, 키 파일을 읽고 SNI 비교에 사용할 Leaf를 채웁니다.
+func loadCertificate(files CertificateConfig) (*tls.Certificate, error) {
+	if files.Cert == "" || files.Key == "" {
+		return nil, errors.New("cert and key are required")
+	}
+	cert, err := tls.LoadX509KeyPair(files.Cert, files.Key)
+	if err != nil {
+		return nil, err
+	}
+	if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
+
+func certModTime(files CertificateConfig) time.Time {
+	var latest time.Time
+	for _, path := range []string{files.Cert, files.Key} {
+		if info, err := os.Stat(path); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
+
+// TLSState RTMPS 연결이면 TLS 연결 정보(SNI 호스트 이름 등)를, 아니면 nil을 반환합니다.
+func (c *Connection) TLSState() *tls.ConnectionState {
+	tc, ok := c.Conn.(*tls.Conn)
+	if !ok {
+		return nil
+	}
+	state := tc.ConnectionState()
+	return &state
+}
@@ -0,0 +1,162 @@
+package internal
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestCertificate commonName, dnsName의 자체 서명 인증서와 키를 dir에 name.crt, name.key로 씁니다.
+func writeTestCertificate(t *testing.T, dir, name, commonName, dnsName string) CertificateConfig {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{dnsName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := CertificateConfig{Cert: filepath.Join(dir, name+".crt"), Key: filepath.Join(dir, name+".key")}
+	writeTestFile(t, files.Cert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	writeTestFile(t, files.Key, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	return files
+}
+
+// writeTestFile data를 쓰고, 파일 시스템의 수정 시각 단위와 상관없이 바뀐 것으로 보이도록 수정 시각을 뒤로 옮깁니다.
+func writeTestFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	var modTime time.Time
+	if info, err := os.Stat(path); err == nil {
+		modTime = info.ModTime().Add(time.Second)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if !modTime.IsZero() {
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// serveTestTLS store의 인증서로 TLS 핸드셰이크만 하는 서버를 시작합니다.
+func serveTestTLS(t *testing.T, store *CertStore) string {
+	t.Helper()
+	l, err := tls.Listen("tcp", "127.0.0.1:0", store.TLSConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+	return l.Addr().String()
+}
+
+// servedCommonName serverName(SNI)으로 접속했을 때 서버가 보낸 인증서의 CommonName입니다.
+func servedCommonName(t *testing.T, addr, serverName string) string {
+	t.Helper()
+	dialer := &net.Dialer{Timeout: testTimeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: serverName, InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatalf("dial %s: %s", serverName, err)
+	}
+	defer conn.Close()
+	return conn.ConnectionState().PeerCertificates[0].Subject.CommonName
+}
+
+func TestCertStoreSNI(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewCertStore([]CertificateConfig{
+		writeTestCertificate(t, dir, "a", "a", "a.example.com"),
+		writeTestCertificate(t, dir, "b", "b", "b.example.com"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := serveTestTLS(t, store)
+
+	for serverName, want := range map[string]string{
+		"a.example.com": "a",
+		"b.example.com": "b",
+		// 맞는 인증서가 없거나 SNI를 보내지 않으면 첫 번째 인증서를 사용합니다.
+		"c.example.com": "a",
+		"":              "a",
+	} {
+		if got := servedCommonName(t, addr, serverName); got != want {
+			t.Errorf("SNI %q: got certificate %q, want %q", serverName, got, want)
+		}
+	}
+}
+
+func TestCertStoreReload(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewCertStore([]CertificateConfig{writeTestCertificate(t, dir, "live", "v1", "live.example.com")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := serveTestTLS(t, store)
+
+	// 바뀌지 않았으면 다시 읽지 않습니다.
+	store.Reload()
+	if got := servedCommonName(t, addr, "live.example.com"); got != "v1" {
+		t.Fatalf("got certificate %q, want v1", got)
+	}
+
+	// 갱신 중이라 읽을 수 없는 파일은 무시하고 기존 인증서를 계속 사용합니다.
+	writeTestFile(t, filepath.Join(dir, "live.crt"), []byte("not a certificate"))
+	store.Reload()
+	if got := servedCommonName(t, addr, "live.example.com"); got != "v1" {
+		t.Fatalf("after a broken file: got certificate %q, want v1", got)
+	}
+
+	writeTestCertificate(t, dir, "live", "v2", "live.example.com")
+	store.Reload()
+	if got := servedCommonName(t, addr, "live.example.com"); got != "v2" {
+		t.Errorf("after renewal: got certificate %q, want v2", got)
+	}
+}
+
+func TestNewCertStoreErrors(t *testing.T) {
+	if _, err := NewCertStore(nil); err == nil {
+		t.Error("no certificates: no error")
+	}
+	dir := t.TempDir()
+	files := writeTestCertificate(t, dir, "a", "a", "a.example.com")
+	files.Key = filepath.Join(dir, "missing.key")
+	if _, err := NewCertStore([]CertificateConfig{files}); err == nil {
+		t.Error("missing key: no error")
+	}
+}
@@ -0,0 +1,136 @@
+package internal
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// 토큰으로 허용하는 동작입니다. 퍼블리시 토큰으로 재생하거나 그 반대로 사용할 수 없습니다.
+const (
+	TokenActionPublish = "publish"
+	TokenActionPlay    = "play"
+)
+
+// tokenDefaultClockSkew ClockSkew가 설정되지 않았을 때 만료 시각에 더해 주는 여유 시간입니다.
+const tokenDefaultClockSkew = 30 * time.Second
+
+var (
+	errTokenMissing   = errors.New("token required")
+	errTokenMalformed = errors.New("malformed token")
+	errTokenExpired   = errors.New("token expired")
+	errTokenSignature = errors.New("invalid token signature")
+	errTokenIP        = errors.New("token is bound to another address")
+)
+
+// TokenConfig 스트림 이름의 쿼리(key?exp=..&sig=..)로 전달되는 서명된 토큰 설정입니다.
+// 콜백 서비스 없이 서버에서 바로 검증합니다.
+type TokenConfig struct {
+	// Secrets 서명 키 목록입니다. 어느 키로 서명해도 통과하므로, 새 키를 추가하고 옛 키를 나중에 빼는 식으로 교체합니다.
+	// 토큰을 만들 때는 첫 번째 키를 사용합니다.
+	Secrets []string `yaml:"secrets"`
+	// Publish, Play 퍼블리시, 재생에 토큰을 요구합니다.
+	Publish bool `yaml:"publish"`
+	Play    bool `yaml:"play"`
+	// ClockSkew 서버 간 시각 차이를 고려해 만료 뒤에도 허용하는 시간입니다. (0이면 30초)
+	ClockSkew time.Duration `yaml:"clock_skew"`
+}
+
+func (conf *TokenConfig) validate() error {
+	if (conf.Publish || conf.Play) && len(conf.Secrets) == 0 {
+		return errors.New("secrets: at least one secret is required when publish or play tokens are enabled")
+	}
+	for _, secret := range conf.Secrets {
+		if len(secret) < 16 {
+			return errors.New("secrets: each secret must be at least 16 characters")
+		}
+	}
+	if conf.ClockSkew < 0 {
+		return errors.New("clock_skew: must not be negative")
+	}
+	return nil
+}
+
+// required action에 토큰이 필요한지 확인합니다.
+func (conf *TokenConfig) required(action string) bool {
+	if action == TokenActionPublish {
+		return conf.Publish
+	}
+	return conf.Play
+}
+
+// SignToken app/stream에 대한 action 토큰의 서명을 만듭니다. ip가 비어 있지 않으면 그 주소에서만 쓸 수 있습니다.
+func SignToken(secret, action, app, stream string, exp int64, ip string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%s\n%s/%s\n%d\n%s", action, app, stream, exp, ip)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// TokenQuery 스트림 이름 뒤에 붙일 토큰 쿼리를 만듭니다. (exp=..&sig=.. 또는 exp=..&ip=..&sig=..)
+func TokenQuery(secret, action, app, stream string, exp time.Time, ip string) string {
+	query := url.Values{}
+	query.Set("exp", strconv.FormatInt(exp.Unix(), 10))
+	if ip != "" {
+		query.Set("ip", ip)
+	}
+	query.Set("sig", SignToken(secret, action, app, stream, exp.Unix(), ip))
+	return query.Encode()
+}
+
+// verify 쿼리의 토큰이 action, app, stream, 클라이언트 주소에 대해 유효한지 검사합니다.
+func (conf *TokenConfig) verify(action, app, stream, clientIP string, query url.Values, now time.Time) error {
+	expValue, sig := query.Get("exp"), query.Get("sig")
+	if expValue == "" || sig == "" {
+		return errTokenMissing
+	}
+	exp, err := strconv.ParseInt(expValue, 10, 64)
+	if err != nil {
+		return errTokenMalformed
+	}
+	skew := conf.ClockSkew
+	if skew <= 0 {
+		skew = tokenDefaultClockSkew
+	}
+	if now.After(time.Unix(exp, 0).Add(skew)) {
+		return errTokenExpired
+	}
+	given, err := hex.DecodeString(sig)
+	if err != nil {
+		return errTokenMalformed
+	}
+	ip := query.Get("ip")
+	valid := false
+	for _, secret := range conf.Secrets {
+		want, _ := hex.DecodeString(SignToken(secret, action, app, stream, exp, ip))
+		if hmac.Equal(given, want) {
+			valid = true
+		}
+	}
+	if !valid {
+		return errTokenSignature
+	}
+	if ip != "" && ip != clientIP {
+		return errTokenIP
+	}
+	return nil
+}
+
+// checkToken app 설정이 action에 토큰을 요구하면 쿼리의 토큰을 검증합니다.
+// RTMP, HTTP-FLV, WebSocket-FLV 재생이 같은 규칙으로 검사하도록 모두 이 함수를 사용합니다.
+func (ctx *StreamContext) checkToken(action, app, stream, clientIP string, query url.Values) error {
+	conf := ctx.app(app).Auth.Token
+	if !conf.required(action) {
+		return nil
+	}
+	return conf.verify(action, app, stream, clientIP, query, time.Now())
+}
+
+// checkToken 이 연결의 app, 클라이언트 주소로 토큰을 검증합니다.
+func (c *Connection) checkToken(action, stream string, query url.Values) error {
+	return c.Context.checkToken(action, c.AppName, stream, c.clientIP(), query)
+}
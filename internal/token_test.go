@@ -0,0 +1,101 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+const (
+	testTokenSecret    = "0123456789abcdef"
+	testTokenOldSecret = "fedcba9876543210"
+)
+
+func TestTokenVerify(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	conf := TokenConfig{Secrets: []string{testTokenSecret, testTokenOldSecret}, Play: true, ClockSkew: 10 * time.Second}
+	query := func(secret, action, stream string, exp time.Time, ip string) url.Values {
+		q, _ := url.ParseQuery(TokenQuery(secret, action, "live", stream, exp, ip))
+		return q
+	}
+	tampered := query(testTokenSecret, TokenActionPlay, "cam", now.Add(time.Minute), "")
+	tampered.Set("exp", "1700009999")
+	badSig := query(testTokenSecret, TokenActionPlay, "cam", now.Add(time.Minute), "")
+	badSig.Set("sig", badSig.Get("sig")[:62]+"00")
+
+	for _, tc := range []struct {
+		name  string
+		query url.Values
+		ip    string
+		err   error
+	}{
+		{"valid", query(testTokenSecret, TokenActionPlay, "cam", now.Add(time.Minute), ""), "192.0.2.1", nil},
+		{"expired", query(testTokenSecret, TokenActionPlay, "cam", now.Add(-time.Minute), ""), "192.0.2.1", errTokenExpired},
+		{"in skew", query(testTokenSecret, TokenActionPlay, "cam", now.Add(-5*time.Second), ""), "192.0.2.1", nil},
+		{"bound ip", query(testTokenSecret, TokenActionPlay, "cam", now.Add(time.Minute), "192.0.2.1"), "192.0.2.1", nil},
+		{"wrong ip", query(testTokenSecret, TokenActionPlay, "cam", now.Add(time.Minute), "192.0.2.1"), "198.51.100.7", errTokenIP},
+		{"rotated secret", query(testTokenOldSecret, TokenActionPlay, "cam", now.Add(time.Minute), ""), "192.0.2.1", nil},
+		{"unknown secret", query("0000000000000000", TokenActionPlay, "cam", now.Add(time.Minute), ""), "192.0.2.1", errTokenSignature},
+		{"tampered exp", tampered, "192.0.2.1", errTokenSignature},
+		{"tampered signature", badSig, "192.0.2.1", errTokenSignature},
+		{"other stream", query(testTokenSecret, TokenActionPlay, "other", now.Add(time.Minute), ""), "192.0.2.1", errTokenSignature},
+		{"publish token", query(testTokenSecret, TokenActionPublish, "cam", now.Add(time.Minute), ""), "192.0.2.1", errTokenSignature},
+		{"missing", url.Values{}, "192.0.2.1", errTokenMissing},
+		{"malformed exp", url.Values{"exp": {"soon"}, "sig": {"00"}}, "192.0.2.1", errTokenMalformed},
+		{"malformed sig", url.Values{"exp": {"1700000060"}, "sig": {"xyz"}}, "192.0.2.1", errTokenMalformed},
+	} {
+		if err := conf.verify(TokenActionPlay, "live", "cam", tc.ip, tc.query, now); err != tc.err {
+			t.Errorf("%s: error %v, want %v", tc.name, err, tc.err)
+		}
+	}
+
+	// ClockSkew를 설정하지 않으면 30초까지 허용합니다.
+	conf.ClockSkew = 0
+	if err := conf.verify(TokenActionPlay, "live", "cam", "", query(testTokenSecret, TokenActionPlay, "cam", now.Add(-20*time.Second), ""), now); err != nil {
+		t.Errorf("default skew: %v", err)
+	}
+}
+
+func TestHTTPPlayRequiresToken(t *testing.T) {
+	server, addr := startTestServer(t, map[string]*AppConfig{
+		"secure": {Auth: AuthConfig{Token: TokenConfig{Secrets: []string{testTokenSecret}, Play: true}}},
+	})
+	publishTestStream(t, "rtmp://"+addr+"/secure/cam")
+	waitFor(t, "publisher", func() bool { return server.Context.lookupStream("secure", "cam") != nil })
+	srv := httptest.NewServer(http.HandlerFunc(server.Context.serveHTTP))
+	t.Cleanup(srv.Close)
+
+	get := func(rawQuery string, upgrade bool) int {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/secure/cam.flv?"+rawQuery, nil)
+		if upgrade {
+			req.Header.Set("Connection", "Upgrade")
+			req.Header.Set("Upgrade", "websocket")
+			req.Header.Set("Sec-WebSocket-Version", "13")
+			req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+	valid := TokenQuery(testTokenSecret, TokenActionPlay, "secure", "cam", time.Now().Add(time.Minute), "")
+	expired := TokenQuery(testTokenSecret, TokenActionPlay, "secure", "cam", time.Now().Add(-time.Hour), "")
+	for _, upgrade := range []bool{false, true} {
+		for _, rawQuery := range []string{"", expired} {
+			if code := get(rawQuery, upgrade); code != http.StatusForbidden {
+				t.Errorf("upgrade %v, query %q: %d, want 403", upgrade, rawQuery, code)
+			}
+		}
+	}
+	if code := get(valid, false); code != http.StatusOK {
+		t.Errorf("HTTP-FLV with a valid token: %d", code)
+	}
+	if code := get(valid, true); code != http.StatusSwitchingProtocols {
+		t.Errorf("WebSocket-FLV with a valid token: %d", code)
+	}
+}
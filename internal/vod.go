@@ -0,0 +1,494 @@
+package internal
+
+import (
+	"example/hello/internal/format/flvio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// vodBufferAhead 재생 시간보다 이만큼 먼저 태그를 보내 플레이어 버퍼가 비지 않도록 합니다.
+const vodBufferAhead = 1000 * time.Millisecond
+
+// vodKeyframe 키프레임 태그의 시간과 파일 위치입니다.
+type vodKeyframe struct {
+	timestamp uint32
+	offset    int64
+}
+
+// vodIndex FLV 파일을 한 번 훑어서 만든 인덱스입니다.
+type vodIndex struct {
+	metaData  *flvio.Tag
+	headers   []*flvio.Tag // AVC/AAC 시퀀스 헤더
+	keyframes []vodKeyframe
+	dataStart int64
+	duration  uint32
+}
+
+// buildVODIndex FLV 파일의 태그 헤더만 읽어 메타데이터, 시퀀스 헤더, 키프레임 위치를 수집합니다.
+func buildVODIndex(fr *flvio.Reader) (*vodIndex, error) {
+	index := &vodIndex{dataStart: fr.Offset()}
+	for {
+		offset := fr.Offset()
+		tag, err := fr.SkipTag()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		p := &Packet{Type: tag.Type, Timestamp: tag.Timestamp, Data: tag.Data}
+
+		switch {
+		case p.IsMetaData() && index.metaData == nil, p.IsSequenceHeader():
+			// 메타데이터와 시퀀스 헤더는 재생 시작 시 먼저 보내야 하므로 전체 데이터를 읽어둡니다.
+			if err = fr.SeekTo(offset); err != nil {
+				return nil, err
+			}
+			if tag, err = fr.ReadTag(); err != nil {
+				return nil, err
+			}
+			if p.IsMetaData() {
+				index.metaData = tag
+			} else {
+				index.headers = append(index.headers, tag)
+			}
+		case p.IsKeyFrame():
+			index.keyframes = append(index.keyframes, vodKeyframe{timestamp: tag.Timestamp, offset: offset})
+		}
+		if tag.Timestamp > index.duration {
+			index.duration = tag.Timestamp
+		}
+	}
+	return index, nil
+}
+
+// keyframeBefore timestamp(ms) 이전의 가장 가까운 키프레임 위치를 반환합니다. 없으면 파일의 첫 태그 위치입니다.
+func (index *vodIndex) keyframeBefore(timestamp uint32) vodKeyframe {
+	res := vodKeyframe{offset: index.dataStart}
+	for _, k := range index.keyframes {
+		if k.timestamp > timestamp {
+			break
+		}
+		res = k
+	}
+	return res
+}
+
+// playbackSource 시청자에게 페이싱하여 보낼 수 있는 탐색 가능한 패킷 원본입니다. (VOD 파일, 타임시프트 버퍼)
+type playbackSource interface {
+	// Headers 재생 시작, 탐색 후 먼저 보내야 하는 메타데이터와 시퀀스 헤더입니다.
+	Headers() []*Packet
+	// SeekKeyframe timestamp(ms) 이전의 가장 가까운 키프레임으로 이동하고, 그 키프레임의 시간을 반환합니다.
+	SeekKeyframe(timestamp uint32) (uint32, error)
+	// ReadPacket 다음 패킷을 읽습니다. 끝이면 io.EOF를, 라이브 시점에서 기다려야 하면 errLiveEdge를 반환합니다. (liveSource만 해당)
+	ReadPacket() (*Packet, error)
+	// Duration 전체 길이(ms) 입니다.
+	Duration() uint32
+	// Offset 지금까지 읽은 위치(바이트) 입니다.
+	Offset() int64
+	Close() error
+}
+
+// flvSource FLV 파일과 키프레임 인덱스로 만든 playbackSource 입니다.
+type flvSource struct {
+	file   *os.File
+	reader *flvio.Reader
+	index  *vodIndex
+}
+
+func openFLVSource(path string) (*flvSource, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := flvio.NewReader(file)
+	var index *vodIndex
+	if err == nil {
+		index, err = buildVODIndex(reader)
+	}
+	if err == nil {
+		err = reader.SeekTo(index.dataStart)
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &flvSource{file: file, reader: reader, index: index}, nil
+}
+
+func (s *flvSource) Headers() []*Packet {
+	tags := s.index.headers
+	if s.index.metaData != nil {
+		tags = append([]*flvio.Tag{s.index.metaData}, tags...)
+	}
+	res := make([]*Packet, len(tags))
+	for i, tag := range tags {
+		res[i] = &Packet{Type: tag.Type, Data: tag.Data}
+	}
+	return res
+}
+
+func (s *flvSource) SeekKeyframe(timestamp uint32) (uint32, error) {
+	keyframe := s.index.keyframeBefore(timestamp)
+	return keyframe.timestamp, s.reader.SeekTo(keyframe.offset)
+}
+
+func (s *flvSource) ReadPacket() (*Packet, error) {
+	for {
+		tag, err := s.reader.ReadTag()
+		if err != nil {
+			return nil, err
+		}
+		// 메타데이터는 Headers로 이미 보냈으므로 건너뜁니다.
+		if tag.Type == flvio.TagScript {
+			continue
+		}
+		return &Packet{Type: tag.Type, Timestamp: tag.Timestamp, Data: tag.Data}, nil
+	}
+}
+
+func (s *flvSource) Duration() uint32 {
+	return s.index.duration
+}
+
+func (s *flvSource) Offset() int64 {
+	return s.reader.Offset()
+}
+
+func (s *flvSource) Close() error {
+	return s.file.Close()
+}
+
+// playerControl 읽기 고루틴에서 재생 고루틴으로 전달하는 seek, pause 요청입니다.
+type playerControl struct {
+	seek     bool
+	pause    bool
+	position uint32 // ms
+}
+
+// vodPlayer playbackSource의 패킷을 타임스탬프에 맞추어 실시간 속도로 RTMP 시청자에게 보냅니다.
+type vodPlayer struct {
+	c        *Connection
+	streamID uint32
+	name     string
+	source   playbackSource
+	writer   *rtmpPlayWriter
+
+	start    uint32 // 재생 시작 위치 (ms)
+	duration int64  // 재생할 길이 (ms), 음수면 끝까지
+
+	control chan playerControl
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+func newVODPlayer(c *Connection, streamID uint32, name string, source playbackSource) *vodPlayer {
+	return &vodPlayer{
+		c:        c,
+		streamID: streamID,
+		name:     name,
+		source:   source,
+		writer:   &rtmpPlayWriter{c: c, streamID: streamID},
+		duration: -1,
+		control:  make(chan playerControl),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// vodFilePath streamName에서 VOD 파일 경로를 만듭니다. "flv:" 접두사와 ".flv" 확장자는 생략할 수 있습니다.
+// app에 vod_dir이 없으면 base/{app}에서 찾습니다.
+func vodFilePath(base string, conf *AppConfig, streamName string) (string, bool) {
+	name := strings.TrimSuffix(strings.TrimPrefix(streamName, "flv:"), ".flv")
+	// 상위 디렉터리로 벗어나는 경로는 허용하지 않습니다.
+	if name == "" || strings.Contains(name, "..") || strings.HasPrefix(name, "/") || strings.Contains(name, "\\") {
+		return "", false
+	}
+	dir := conf.VODDir
+	if dir == "" {
+		dir = filepath.Join(base, conf.Name)
+	}
+	return filepath.Join(dir, name+".flv"), true
+}
+
+// onPlayVOD play 명령의 start, duration, reset 인자에 따라 파일 재생을 시작합니다.
+//   - start: 재생 시작 위치(ms). 0 이상이면 그 위치 이전의 가장 가까운 키프레임부터 재생합니다. (-1, -2는 처음부터)
+//   - duration: 재생할 길이(ms). -1이면 파일 끝까지, 0이면 한 프레임만 재생합니다.
+//   - reset: true이면 NetStream.Play.Reset을 먼저 보냅니다.
+func (c *Connection) onPlayVOD(command map[string]interface{}, streamID uint32, conf *AppConfig) {
+	streamName, _ := command["streamName"].(string)
+
+	path, ok := vodFilePath(c.Context.Paths.VOD, conf, streamName)
+	if !ok {
+		log.Printf("VOD file not found for %s", streamName)
+		c.sendStatus(streamID, "error", "NetStream.Play.StreamNotFound", "Stream not found: "+streamName)
+		return
+	}
+	source, err := openFLVSource(path)
+	if os.IsNotExist(err) {
+		log.Printf("VOD file not found for %s", streamName)
+		c.sendStatus(streamID, "error", "NetStream.Play.StreamNotFound", "Stream not found: "+streamName)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to open VOD file %s: %s", path, err.Error())
+		c.sendStatus(streamID, "error", "NetStream.Play.Failed", "Failed to open "+streamName)
+		return
+	}
+
+	log.Printf("VOD play started %s", path)
+	c.startPlayback(command, streamID, streamName, source)
+}
+
+// startPlayback 이전 재생을 멈추고 source 재생을 시작합니다.
+func (c *Connection) startPlayback(command map[string]interface{}, streamID uint32, streamName string, source playbackSource) {
+	if c.vod != nil {
+		c.vod.Close()
+		c.vod = nil
+	}
+
+	p := newVODPlayer(c, streamID, streamName, source)
+	if start, ok := command["start"].(float64); ok && start > 0 {
+		p.start = uint32(start)
+	}
+	if duration, ok := command["duration"].(float64); ok && duration >= 0 {
+		p.duration = int64(duration)
+	}
+
+	c.sendUserControl(userControlStreamIsRecorded, streamID)
+	c.sendUserControl(userControlStreamBegin, streamID)
+	if reset, _ := command["reset"].(bool); reset {
+		c.sendStatus(streamID, "status", "NetStream.Play.Reset", "Playing and resetting "+streamName)
+	}
+	c.sendStatus(streamID, "status", "NetStream.Play.Start", "Started playing "+streamName)
+	c.ConnectionStatus.ConnectionComplete = true
+
+	c.vod = p
+	c.playStreamID.Store(streamID)
+	c.playName = streamName
+	c.startPing()
+	go p.run()
+}
+
+// sendHeaders 메타데이터와 시퀀스 헤더를 보냅니다.
+func (p *vodPlayer) sendHeaders(timestamp uint32) error {
+	for _, header := range p.source.Headers() {
+		if err := p.writer.WritePacket(&Packet{Type: header.Type, Timestamp: timestamp, Data: header.Data}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *vodPlayer) run() {
+	defer close(p.done)
+
+	position, err := p.source.SeekKeyframe(p.start)
+	if err != nil {
+		return
+	}
+	if err = p.sendHeaders(position); err != nil {
+		return
+	}
+
+	// duration은 시작 위치로부터의 길이이므로, 끝나는 위치를 미리 계산해 둡니다.
+	end := int64(-1)
+	if p.duration >= 0 {
+		end = int64(p.start) + p.duration
+	}
+
+	// 기준 패킷의 시간과 벽시계 시간을 맞추어 보냅니다. 탐색, 일시정지 후에는 기준을 다시 잡습니다.
+	var base uint32
+	var hasBase bool
+	var started time.Time
+	var pending *Packet
+	var paused bool
+	// finished 끝까지 재생한 뒤에도 클라이언트가 다시 탐색할 수 있도록 연결을 유지합니다.
+	var finished bool
+
+	// handle seek, pause 요청을 처리합니다. 재생을 멈춰야 하면 false를 반환합니다.
+	handle := func(ctl playerControl) bool {
+		switch {
+		case ctl.seek:
+			if ctl.position > p.source.Duration() {
+				p.c.sendStatus(p.streamID, "error", "NetStream.Seek.InvalidTime", "Seek time is out of range")
+				return true
+			}
+			position, err := p.source.SeekKeyframe(ctl.position)
+			if err != nil {
+				return false
+			}
+			// 탐색한 뒤에는 play 명령의 duration 제한을 적용하지 않습니다.
+			pending, hasBase, finished, end = nil, false, false, -1
+			p.c.sendUserControl(userControlStreamIsRecorded, p.streamID)
+			p.c.sendUserControl(userControlStreamBegin, p.streamID)
+			p.c.sendStatus(p.streamID, "status", "NetStream.Seek.Notify", fmt.Sprintf("Seeking %d (stream ID: %d).", position, p.streamID))
+			p.c.sendStatus(p.streamID, "status", "NetStream.Play.Start", "Started playing "+p.name)
+			if p.sendHeaders(position) != nil {
+				return false
+			}
+		case finished:
+			// 재생이 끝난 뒤에는 seek만 처리합니다.
+		case ctl.pause && !paused:
+			paused = true
+			p.c.sendUserControl(userControlStreamEOF, p.streamID)
+			p.c.sendStatus(p.streamID, "status", "NetStream.Pause.Notify", "Paused "+p.name)
+		case !ctl.pause && paused:
+			paused, hasBase = false, false
+			p.c.sendUserControl(userControlStreamBegin, p.streamID)
+			p.c.sendStatus(p.streamID, "status", "NetStream.Unpause.Notify", "Unpaused "+p.name)
+		}
+		return true
+	}
+
+	for {
+		if paused || finished {
+			select {
+			case <-p.stop:
+				return
+			case ctl := <-p.control:
+				if !handle(ctl) {
+					return
+				}
+			}
+			continue
+		}
+
+		select {
+		case <-p.stop:
+			return
+		case ctl := <-p.control:
+			if !handle(ctl) {
+				return
+			}
+			continue
+		default:
+		}
+
+		if pending == nil {
+			pending, err = p.source.ReadPacket()
+			if err == io.EOF {
+				finished = true
+				p.complete()
+				continue
+			}
+			if err == errLiveEdge {
+				// 라이브 시점에 도달했으면 새 패킷이 들어올 때까지 기다립니다.
+				select {
+				case <-p.stop:
+					return
+				case ctl := <-p.control:
+					if !handle(ctl) {
+						return
+					}
+				case <-p.source.(liveSource).Updated():
+				}
+				pending = nil
+				continue
+			}
+			if err != nil {
+				log.Printf("Error while reading %s: %s", p.name, err.Error())
+				return
+			}
+		}
+		pkt := pending
+
+		if end >= 0 && int64(pkt.Timestamp) > end && !pkt.IsSequenceHeader() {
+			finished = true
+			p.complete()
+			continue
+		}
+		if !hasBase {
+			base, hasBase, started = pkt.Timestamp, true, time.Now()
+		}
+
+		elapsed := time.Duration(int64(pkt.Timestamp)-int64(base)) * time.Millisecond
+		if wait := time.Until(started.Add(elapsed - vodBufferAhead)); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-p.stop:
+				timer.Stop()
+				return
+			case ctl := <-p.control:
+				timer.Stop()
+				if !handle(ctl) {
+					return
+				}
+				continue
+			case <-timer.C:
+			}
+		}
+
+		pending = nil
+		if err = p.writer.WritePacket(pkt); err != nil {
+			return
+		}
+		if p.duration == 0 && end >= 0 && !pkt.IsSequenceHeader() {
+			finished = true
+			p.complete()
+		}
+	}
+}
+
+// seek 재생 위치를 position(ms) 이전의 가장 가까운 키프레임으로 옮깁니다.
+func (p *vodPlayer) seek(position uint32) {
+	p.send(playerControl{seek: true, position: position})
+}
+
+// pause 재생을 멈추거나(pause=true) 다시 시작합니다.
+func (p *vodPlayer) pause(pause bool, position uint32) {
+	p.send(playerControl{pause: pause, position: position})
+}
+
+func (p *vodPlayer) send(ctl playerControl) {
+	select {
+	case p.control <- ctl:
+	case <-p.done:
+	}
+}
+
+// onSeek seek 명령을 재생 중인 플레이어에 전달합니다. 라이브 재생 중에는 탐색할 수 없으므로 NetStream.Seek.Failed를 보냅니다.
+func (c *Connection) onSeek(command map[string]interface{}, streamID uint32) {
+	ms, _ := command["ms"].(float64)
+	if c.vod == nil || ms < 0 {
+		c.sendStatus(streamID, "error", "NetStream.Seek.Failed", "Seek is not supported on this stream")
+		return
+	}
+	c.vod.seek(uint32(ms))
+}
+
+// onPause pause, pauseRaw 명령을 재생 중인 플레이어에 전달합니다. 라이브 재생은 일시정지를 지원하지 않습니다.
+func (c *Connection) onPause(command map[string]interface{}, streamID uint32) {
+	if c.vod == nil {
+		log.Printf("Pause is not supported on live stream %s", c.StreamKey)
+		c.sendStatus(streamID, "error", "NetStream.Pause.Failed", "Pause is not supported on this stream")
+		return
+	}
+	pause, _ := command["pause"].(bool)
+	ms, _ := command["ms"].(float64)
+	c.vod.pause(pause, uint32(ms))
+}
+
+// complete 재생이 끝났음을 알립니다. (NetStream.Play.Complete, NetStream.Play.Stop, StreamEOF)
+func (p *vodPlayer) complete() {
+	log.Printf("Play completed %s", p.name)
+	p.c.sendPlayStatus(p.streamID, "NetStream.Play.Complete", float64(p.source.Duration())/1000, p.source.Offset())
+	p.c.sendStatus(p.streamID, "status", "NetStream.Play.Stop", "Stopped playing "+p.name)
+	p.c.sendUserControl(userControlStreamEOF, p.streamID)
+}
+
+// Close 재생을 멈추고 파일을 닫습니다.
+func (p *vodPlayer) Close() {
+	select {
+	case <-p.stop:
+	default:
+		close(p.stop)
+	}
+	<-p.done
+	p.source.Close()
+}
@@ -0,0 +1,221 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"example/hello/internal/format/flvio"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testFLVWriter 패킷을 FLV 태그로 쓰는 PacketWriter 입니다.
+type testFLVWriter struct {
+	w io.Writer
+}
+
+func (w testFLVWriter) WritePacket(p *Packet) error {
+	return flvio.WriteTag(w.w, p.Type, p.Timestamp, p.Data)
+}
+
+// writeTestVODFile dir/app/name.flv에 onMetaData와 1초 길이의 GOP를 gops개 담은 VOD 파일을 만듭니다.
+func writeTestVODFile(t *testing.T, dir, app, name string, gops int) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Join(dir, app), 0755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(filepath.Join(dir, app, name+".flv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := testFLVWriter{f}
+	if err := flvio.WriteHeader(f, true, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WritePacket(testMetaData); err != nil {
+		t.Fatal(err)
+	}
+	writeTestGOPs(t, w, gops)
+}
+
+// playTestVOD VOD 서버를 시작하고 gops개의 GOP를 가진 movie.flv 재생을 시작합니다.
+func playTestVOD(t *testing.T, gops int) *RTMPClient {
+	t.Helper()
+	server, addr := startTestServer(t, map[string]*AppConfig{"vod": {VOD: true}})
+	writeTestVODFile(t, server.Context.Paths.VOD, "vod", "movie", gops)
+	return playTestStream(t, "rtmp://"+addr+"/vod/movie")
+}
+
+// waitTestStatus code의 onStatus가 올 때까지 기다려 info 객체를 반환합니다. 다른 상태는 건너뜁니다.
+func waitTestStatus(t *testing.T, client *RTMPClient, code string) map[string]interface{} {
+	t.Helper()
+	timeout := time.After(testTimeout)
+	for {
+		select {
+		case info := <-client.status:
+			if info["code"] == code {
+				return info
+			}
+			if info["level"] == "error" {
+				t.Fatalf("got %v, want %s", info["code"], code)
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for %s", code)
+		case <-client.done:
+			// 상태를 보내고 바로 연결을 닫았을 수 있으므로 이미 받은 상태를 먼저 확인합니다.
+			for {
+				select {
+				case info := <-client.status:
+					if info["code"] == code {
+						return info
+					}
+				default:
+					t.Fatalf("connection closed waiting for %s", code)
+				}
+			}
+		}
+	}
+}
+
+// readTestPacket 다음 패킷을 읽습니다.
+func readTestPacket(t *testing.T, client *RTMPClient) *Packet {
+	t.Helper()
+	p, err := client.ReadPacket(contextWithTestTimeout(t))
+	if err != nil {
+		t.Fatalf("reading packet: %s", err)
+	}
+	return p
+}
+
+// sendTestCommand 재생 중인 스트림으로 seek, pause 같은 명령을 보냅니다.
+func sendTestCommand(t *testing.T, client *RTMPClient, name string, args ...interface{}) {
+	t.Helper()
+	if _, err := client.send(client.streamID, false, name, append([]interface{}{nil}, args...)...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// expectTestHeaders 메타데이터와 시퀀스 헤더가 timestamp로 오는지 확인합니다.
+func expectTestHeaders(t *testing.T, client *RTMPClient, timestamp uint32) {
+	t.Helper()
+	for _, want := range []*Packet{testMetaData, testAVCSequenceHeader, testAACSequenceHeader} {
+		if p := readTestPacket(t, client); p.Type != want.Type || p.Timestamp != timestamp || !bytes.Equal(p.Data, want.Data) {
+			t.Fatalf("got type %d at %dms, want type %d header at %dms", p.Type, p.Timestamp, want.Type, timestamp)
+		}
+	}
+}
+
+// expectTestVideoFrom 다음 비디오 패킷들이 timestamp의 프레임부터 40ms 간격으로 이어지는지 확인합니다.
+// 오디오는 파일에서 앞 비디오 프레임 뒤에 있으므로 timestamp-40ms 이후여야 합니다.
+func expectTestVideoFrom(t *testing.T, client *RTMPClient, timestamp uint32, frames int) {
+	t.Helper()
+	start := int64(timestamp) - 40
+	for i := 0; i < frames; {
+		p := readTestPacket(t, client)
+		if !p.IsVideo() {
+			if int64(p.Timestamp) < start {
+				t.Errorf("audio at %dms, want after %dms", p.Timestamp, start)
+			}
+			continue
+		}
+		want := testAVCFrame(timestamp, timestamp%1000 == 0, 40)
+		if p.Timestamp != want.Timestamp || !bytes.Equal(p.Data, want.Data) {
+			t.Fatalf("video at %dms (key %v), want %dms", p.Timestamp, p.IsKeyFrame(), want.Timestamp)
+		}
+		timestamp += 40
+		i++
+	}
+}
+
+func TestVODPlay(t *testing.T) {
+	client := playTestVOD(t, 2)
+	expectTestHeaders(t, client, 0)
+	// 헤더 다음은 시간 0의 키프레임입니다. 파일의 시퀀스 헤더 태그는 다시 보내지 않습니다.
+	expectTestVideoFrom(t, client, 0, 50)
+	waitTestStatus(t, client, "NetStream.Play.Stop")
+}
+
+func TestVODSeek(t *testing.T) {
+	client := playTestVOD(t, 5)
+	expectTestHeaders(t, client, 0)
+
+	// 3500ms 이전의 가장 가까운 키프레임(3000ms)으로 이동해 헤더를 다시 보냅니다.
+	sendTestCommand(t, client, "seek", 3500.0)
+	info := waitTestStatus(t, client, "NetStream.Seek.Notify")
+	if description, _ := info["description"].(string); !strings.Contains(description, "3000") {
+		t.Errorf("Seek.Notify description %q, want the keyframe time", description)
+	}
+	waitTestStatus(t, client, "NetStream.Play.Start")
+	for {
+		p := readTestPacket(t, client)
+		if p.IsSequenceHeader() && p.Timestamp == 3000 {
+			break
+		}
+	}
+	// 시퀀스 헤더를 다시 보낸 뒤 3000ms 키프레임부터 원래 시간으로 이어집니다.
+	if p := readTestPacket(t, client); !p.IsSequenceHeader() || p.Timestamp != 3000 {
+		t.Fatalf("got type %d at %dms, want the audio sequence header", p.Type, p.Timestamp)
+	}
+	expectTestVideoFrom(t, client, 3000, 30)
+
+	// 파일 길이를 넘는 위치로는 이동할 수 없습니다.
+	sendTestCommand(t, client, "seek", 60000.0)
+	waitTestStatus(t, client, "NetStream.Seek.InvalidTime")
+}
+
+func TestVODPause(t *testing.T) {
+	client := playTestVOD(t, 5)
+	expectTestHeaders(t, client, 0)
+
+	sendTestCommand(t, client, "pause", true, 0.0)
+	waitTestStatus(t, client, "NetStream.Pause.Notify")
+	// 상태 메시지보다 먼저 보낸 패킷은 이미 받았으므로, 그 뒤로는 패킷이 오지 않아야 합니다.
+	var last uint32
+	var hasLast bool
+	for drained := false; !drained; {
+		select {
+		case p := <-client.packets:
+			if p.IsVideo() && !p.IsSequenceHeader() {
+				last, hasLast = p.Timestamp, true
+			}
+		default:
+			drained = true
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 1500*time.Millisecond)
+	defer cancel()
+	if p, err := client.ReadPacket(ctx); err == nil {
+		t.Fatalf("got type %d at %dms while paused", p.Type, p.Timestamp)
+	}
+
+	sendTestCommand(t, client, "pause", false, float64(last))
+	waitTestStatus(t, client, "NetStream.Unpause.Notify")
+	// 멈춘 위치 다음 프레임부터 이어서 보냅니다.
+	next := uint32(0)
+	if hasLast {
+		next = last + 40
+	}
+	expectTestVideoFrom(t, client, next, 10)
+}
+
+func TestLiveSeekAndPauseFail(t *testing.T) {
+	_, addr := startTestServer(t, map[string]*AppConfig{"live": {}})
+	publishTestStream(t, "rtmp://"+addr+"/live/cam")
+	client := playTestStream(t, "rtmp://"+addr+"/live/cam")
+
+	for _, tt := range []struct {
+		name string
+		args []interface{}
+		code string
+	}{
+		{"seek", []interface{}{1000.0}, "NetStream.Seek.Failed"},
+		{"pause", []interface{}{true, 0.0}, "NetStream.Pause.Failed"},
+	} {
+		sendTestCommand(t, client, tt.name, tt.args...)
+		waitTestStatus(t, client, tt.code)
+	}
+}
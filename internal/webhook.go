@@ -0,0 +1,287 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// 웹훅 호출 이름입니다. 요청의 call 값으로 전달됩니다.
+const (
+	webhookConnect     = "connect"
+	webhookPublish     = "publish"
+	webhookPlay        = "play"
+	webhookPublishDone = "publish_done"
+	webhookPlayDone    = "play_done"
+)
+
+const (
+	webhookDefaultTimeout = 5 * time.Second
+	webhookRetryDelay     = 500 * time.Millisecond
+)
+
+// WebhookConfig 연결, 퍼블리시, 재생 이벤트를 알릴 HTTP 콜백 주소입니다. 비어 있는 주소는 호출하지 않습니다.
+// 요청은 nginx-rtmp와 같은 application/x-www-form-urlencoded POST 이며,
+// call, app, name, addr, tcurl과 스트림 이름의 쿼리 파라미터(key?token=..의 token 등)를 보냅니다.
+// on_connect, on_publish, on_play가 2xx가 아닌 응답을 보내면 요청을 거절합니다.
+type WebhookConfig struct {
+	OnConnect     string `yaml:"on_connect"`
+	OnPublish     string `yaml:"on_publish"`
+	OnPlay        string `yaml:"on_play"`
+	OnPublishDone string `yaml:"on_publish_done"`
+	OnPlayDone    string `yaml:"on_play_done"`
+
+	Timeout time.Duration `yaml:"timeout"` // 요청 하나의 제한 시간 (0이면 5초)
+	Retries int           `yaml:"retries"` // 연결 실패, 5xx 응답일 때 다시 시도하는 횟수
+}
+
+// merge app 설정에 적힌 값으로 전역 설정을 덮어씁니다.
+func (conf WebhookConfig) merge(app WebhookConfig) WebhookConfig {
+	for _, f := range []struct{ dst, src *string }{
+		{&conf.OnConnect, &app.OnConnect},
+		{&conf.OnPublish, &app.OnPublish},
+		{&conf.OnPlay, &app.OnPlay},
+		{&conf.OnPublishDone, &app.OnPublishDone},
+		{&conf.OnPlayDone, &app.OnPlayDone},
+	} {
+		if *f.src != "" {
+			*f.dst = *f.src
+		}
+	}
+	if app.Timeout > 0 {
+		conf.Timeout = app.Timeout
+	}
+	if app.Retries > 0 {
+		conf.Retries = app.Retries
+	}
+	return conf
+}
+
+func (conf *WebhookConfig) url(call string) string {
+	switch call {
+	case webhookConnect:
+		return conf.OnConnect
+	case webhookPublish:
+		return conf.OnPublish
+	case webhookPlay:
+		return conf.OnPlay
+	case webhookPublishDone:
+		return conf.OnPublishDone
+	case webhookPlayDone:
+		return conf.OnPlayDone
+	}
+	return ""
+}
+
+// validate 설정된 주소가 http(s) 주소인지 확인합니다.
+func (conf *WebhookConfig) validate() error {
+	for _, call := range []string{webhookConnect, webhookPublish, webhookPlay, webhookPublishDone, webhookPlayDone} {
+		target := conf.url(call)
+		if target == "" {
+			continue
+		}
+		if u, err := url.Parse(target); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("on_%s: %q is not an http(s) URL", call, target)
+		}
+	}
+	if conf.Timeout < 0 || conf.Retries < 0 {
+		return fmt.Errorf("timeout, retries: must not be negative")
+	}
+	return nil
+}
+
+// webhookError 웹훅이 2xx가 아닌 응답을 보냈거나 호출에 실패했을 때의 에러입니다.
+type webhookError struct {
+	call   string
+	status int // 응답을 받지 못했으면 0
+	err    error
+}
+
+func (e *webhookError) Error() string {
+	if e.status == 0 {
+		return fmt.Sprintf("on_%s webhook failed: %s", e.call, e.err.Error())
+	}
+	return fmt.Sprintf("Rejected by on_%s webhook (%d)", e.call, e.status)
+}
+
+// webhooks app에 적용되는 웹훅 설정을 반환합니다.
+func (ctx *StreamContext) webhooks(app string) WebhookConfig {
+	conf := ctx.app(app)
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+	return ctx.Webhooks.merge(conf.Webhooks)
+}
+
+// webhookRequest 웹훅으로 알리는 연결 정보입니다. RTMP 연결과 HTTP-FLV, WebSocket-FLV 요청이 함께 사용합니다.
+type webhookRequest struct {
+	app    string
+	stream string
+	addr   string
+	tcURL  string
+	query  url.Values
+}
+
+// webhookRequest 이 연결의 app, 주소, tcUrl로 웹훅 요청 정보를 만듭니다.
+func (c *Connection) webhookRequest(stream string, query url.Values) webhookRequest {
+	return webhookRequest{app: c.AppName, stream: stream, addr: c.clientIP(), tcURL: c.tcURL, query: query}
+}
+
+// callWebhook call 이벤트의 웹훅을 호출합니다. 주소가 없으면 nil을 반환합니다.
+// 연결 실패나 5xx 응답은 Retries 만큼 다시 시도하고, 끝내 성공하지 못하면 요청을 거절할 수 있도록 에러를 반환합니다.
+// 연결의 읽기 고루틴에서 호출하므로, 클라이언트가 응답을 기다리다 끊지 않도록 재시도를 포함한 전체 시간을 webhookContext로 제한합니다.
+func (c *Connection) callWebhook(call, stream string, query url.Values) error {
+	ctx, cancel := webhookContext(context.Background(), c.timeouts)
+	defer cancel()
+	return c.Context.postWebhooks(ctx, call, c.webhookRequest(stream, query))
+}
+
+// callWebhookAsync 결과로 요청을 거절하지 않는 종료 이벤트(publish_done, play_done)를 고루틴에서 호출합니다.
+func (c *Connection) callWebhookAsync(call, stream string, query url.Values) {
+	c.Context.notifyWebhook(call, c.webhookRequest(stream, query))
+}
+
+// notifyWebhook 종료 이벤트의 웹훅을 기다리지 않고 고루틴에서 호출합니다.
+func (ctx *StreamContext) notifyWebhook(call string, req webhookRequest) {
+	go ctx.postWebhooks(context.Background(), call, req)
+}
+
+// webhookContext 핸드셰이크 시간 제한(없으면 유휴 시간 제한)이 지나면 끝나는 컨텍스트를 만듭니다.
+// 클라이언트가 connect, publish, play 응답을 기다리는 시간은 핸드셰이크를 기다리는 시간과 비슷합니다.
+func webhookContext(parent context.Context, timeouts TimeoutConfig) (context.Context, context.CancelFunc) {
+	limit := timeouts.Handshake
+	if limit <= 0 {
+		limit = timeouts.Idle
+	}
+	if limit <= 0 {
+		return context.WithCancel(parent)
+	}
+	return context.WithTimeout(parent, limit)
+}
+
+func (ctx *StreamContext) postWebhooks(reqCtx context.Context, call string, req webhookRequest) error {
+	conf := ctx.webhooks(req.app)
+	target := conf.url(call)
+	if target == "" {
+		return nil
+	}
+	timeout := conf.Timeout
+	if timeout <= 0 {
+		timeout = webhookDefaultTimeout
+	}
+
+	form := url.Values{}
+	for key, values := range req.query {
+		form[key] = values
+	}
+	form.Set("call", call)
+	form.Set("app", req.app)
+	form.Set("name", req.stream)
+	form.Set("addr", req.addr)
+	form.Set("tcurl", req.tcURL)
+	body := form.Encode()
+
+	var err error
+	for attempt := 0; attempt <= conf.Retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(webhookRetryDelay):
+			case <-reqCtx.Done():
+				return &webhookError{call: call, err: reqCtx.Err()}
+			}
+		}
+		var status int
+		if status, err = postWebhook(reqCtx, target, body, timeout); err == nil && status >= 200 && status < 300 {
+			return nil
+		}
+		if err == nil {
+			err = &webhookError{call: call, status: status}
+			if status < 500 {
+				// 4xx 등은 서비스가 거절한 것이므로 다시 시도하지 않습니다.
+				break
+			}
+		} else {
+			err = &webhookError{call: call, err: err}
+		}
+		log.Printf("Webhook on_%s to %s failed (attempt %d/%d): %s", call, target, attempt+1, conf.Retries+1, err.Error())
+		if reqCtx.Err() != nil {
+			break
+		}
+	}
+	return err
+}
+
+// httpPlayWebhook HTTP-FLV, WebSocket-FLV 재생 요청의 on_play 웹훅을 호출합니다.
+// 거절되면 응답할 HTTP 상태 코드(404 또는 403)를 함께 반환합니다.
+func (ctx *StreamContext) httpPlayWebhook(r *http.Request, req webhookRequest) (int, error) {
+	reqCtx, cancel := webhookContext(r.Context(), ctx.timeouts())
+	defer cancel()
+	if err := ctx.postWebhooks(reqCtx, webhookPlay, req); err != nil {
+		if webhookPlayStatus(err) == "NetStream.Play.StreamNotFound" {
+			return http.StatusNotFound, err
+		}
+		return http.StatusForbidden, err
+	}
+	return http.StatusOK, nil
+}
+
+// httpWebhookRequest HTTP 재생 요청의 웹훅 정보입니다. tcurl에는 요청한 주소의 app 경로까지를 보냅니다. (scheme은 http, ws)
+func httpWebhookRequest(r *http.Request, scheme, app, stream string) webhookRequest {
+	if r.TLS != nil {
+		scheme += "s"
+	}
+	return webhookRequest{
+		app:    app,
+		stream: stream,
+		addr:   httpClientIP(r),
+		tcURL:  scheme + "://" + r.Host + "/" + app,
+		query:  r.URL.Query(),
+	}
+}
+
+func postWebhook(ctx context.Context, target, body string, timeout time.Duration) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, strings.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+	return res.StatusCode, nil
+}
+
+// webhookPlayStatus 웹훅 거절을 시청자에게 보낼 onStatus 코드로 바꿉니다. 404는 스트림이 없는 것으로 알립니다.
+func webhookPlayStatus(err error) string {
+	if e, ok := err.(*webhookError); ok && e.status == http.StatusNotFound {
+		return "NetStream.Play.StreamNotFound"
+	}
+	return "NetStream.Play.Failed"
+}
+
+// splitStreamName "key?token=abc" 형태의 스트림 이름을 이름과 쿼리 파라미터로 나눕니다.
+func splitStreamName(name string) (string, url.Values) {
+	name, rawQuery, ok := strings.Cut(name, "?")
+	if !ok {
+		return name, url.Values{}
+	}
+	query, _ := url.ParseQuery(rawQuery)
+	return name, query
+}
+
+// clientIP 클라이언트의 IP 주소입니다.
+func (c *Connection) clientIP() string {
+	if c.Conn == nil {
+		return ""
+	}
+	return hostIP(c.Conn.RemoteAddr())
+}
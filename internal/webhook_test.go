@@ -0,0 +1,236 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// webhookTestServer 요청마다 handler의 상태 코드로 응답하고 요청 수를 세는 웹훅 서버를 시작합니다.
+func webhookTestServer(t *testing.T, handler func(n int32, r *http.Request) int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(handler(calls.Add(1), r))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func webhookTestConnection(conf WebhookConfig, timeouts TimeoutConfig) *Connection {
+	cfg := DefaultConfig()
+	cfg.Webhooks = conf
+	return &Connection{Context: NewStreamContext(cfg), AppName: "live", tcURL: "rtmp://localhost/live", timeouts: timeouts}
+}
+
+func TestWebhookAccepts(t *testing.T) {
+	var form url.Values
+	srv, calls := webhookTestServer(t, func(n int32, r *http.Request) int {
+		r.ParseForm()
+		form = r.PostForm
+		return http.StatusNoContent
+	})
+	c := webhookTestConnection(WebhookConfig{OnPublish: srv.URL}, defaultTimeoutConfig)
+
+	if err := c.callWebhook(webhookPublish, "stream1", url.Values{"token": {"abc"}}); err != nil {
+		t.Fatalf("callWebhook: %s", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("%d calls, want 1", calls.Load())
+	}
+	for key, want := range map[string]string{"call": "publish", "app": "live", "name": "stream1", "tcurl": "rtmp://localhost/live", "token": "abc"} {
+		if got := form.Get(key); got != want {
+			t.Errorf("form %s = %q, want %q", key, got, want)
+		}
+	}
+	// 주소가 없는 이벤트는 호출하지 않고 허용합니다.
+	if err := c.callWebhook(webhookPlay, "stream1", nil); err != nil || calls.Load() != 1 {
+		t.Errorf("unset webhook: err %v, %d calls", err, calls.Load())
+	}
+}
+
+func TestWebhookRejectsWithoutRetry(t *testing.T) {
+	srv, calls := webhookTestServer(t, func(int32, *http.Request) int { return http.StatusNotFound })
+	c := webhookTestConnection(WebhookConfig{OnPlay: srv.URL, Retries: 3}, defaultTimeoutConfig)
+
+	err := c.callWebhook(webhookPlay, "stream1", nil)
+	if e, ok := err.(*webhookError); !ok || e.status != http.StatusNotFound {
+		t.Fatalf("callWebhook = %v, want 404 webhookError", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("%d calls, want 1 (4xx must not be retried)", calls.Load())
+	}
+	if code := webhookPlayStatus(err); code != "NetStream.Play.StreamNotFound" {
+		t.Errorf("webhookPlayStatus = %s", code)
+	}
+}
+
+func TestWebhookRetriesServerErrors(t *testing.T) {
+	// 두 번 실패한 뒤 성공하면 허용합니다.
+	srv, calls := webhookTestServer(t, func(n int32, r *http.Request) int {
+		if n < 3 {
+			return http.StatusBadGateway
+		}
+		return http.StatusOK
+	})
+	c := webhookTestConnection(WebhookConfig{OnConnect: srv.URL, Retries: 2}, defaultTimeoutConfig)
+	if err := c.callWebhook(webhookConnect, "", nil); err != nil {
+		t.Fatalf("callWebhook: %s", err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("%d calls, want 3", calls.Load())
+	}
+
+	// 재시도까지 모두 실패하면 마지막 응답으로 거절합니다.
+	srv, calls = webhookTestServer(t, func(int32, *http.Request) int { return http.StatusBadGateway })
+	c = webhookTestConnection(WebhookConfig{OnConnect: srv.URL, Retries: 1}, defaultTimeoutConfig)
+	err := c.callWebhook(webhookConnect, "", nil)
+	if e, ok := err.(*webhookError); !ok || e.status != http.StatusBadGateway {
+		t.Fatalf("callWebhook = %v, want 502 webhookError", err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("%d calls, want 2", calls.Load())
+	}
+}
+
+func TestWebhookTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv, calls := webhookTestServer(t, func(int32, *http.Request) int {
+		<-release
+		return http.StatusOK
+	})
+	defer close(release)
+	c := webhookTestConnection(WebhookConfig{OnPublish: srv.URL, Timeout: 50 * time.Millisecond}, defaultTimeoutConfig)
+
+	err := c.callWebhook(webhookPublish, "stream1", nil)
+	if e, ok := err.(*webhookError); !ok || e.status != 0 {
+		t.Fatalf("callWebhook = %v, want a failed call", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("%d calls, want 1", calls.Load())
+	}
+}
+
+func TestWebhookBoundedByHandshakeTimeout(t *testing.T) {
+	srv, _ := webhookTestServer(t, func(int32, *http.Request) int { return http.StatusServiceUnavailable })
+	timeouts := defaultTimeoutConfig
+	timeouts.Handshake = 300 * time.Millisecond
+	c := webhookTestConnection(WebhookConfig{OnPublish: srv.URL, Retries: 10}, timeouts)
+
+	start := time.Now()
+	if err := c.callWebhook(webhookPublish, "stream1", nil); err == nil {
+		t.Fatal("callWebhook succeeded")
+	}
+	// 재시도 10번(5초)을 모두 기다리지 않고 핸드셰이크 시간 제한 안에 끝나야 합니다.
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("callWebhook took %s", elapsed)
+	}
+}
+
+// playWebhookTestServer on_play, on_play_done 웹훅을 받아 "call name" 형식으로 기록하는 서버를 시작합니다.
+// 이름이 denied인 스트림은 403, missing인 스트림은 404로 거절합니다.
+func playWebhookTestServer(t *testing.T) (WebhookConfig, func() []string) {
+	var mu sync.Mutex
+	var calls []string
+	srv, _ := webhookTestServer(t, func(_ int32, r *http.Request) int {
+		r.ParseForm()
+		mu.Lock()
+		calls = append(calls, r.PostForm.Get("call")+" "+r.PostForm.Get("name"))
+		mu.Unlock()
+		switch r.PostForm.Get("name") {
+		case "denied":
+			return http.StatusForbidden
+		case "missing":
+			return http.StatusNotFound
+		}
+		return http.StatusOK
+	})
+	return WebhookConfig{OnPlay: srv.URL, OnPlayDone: srv.URL}, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(calls)
+	}
+}
+
+func TestHTTPPlayWebhook(t *testing.T) {
+	hooks, calls := playWebhookTestServer(t)
+	server, addr := startTestServer(t, map[string]*AppConfig{"live": {}}, func(cfg *Config) { cfg.Webhooks = hooks })
+	publishTestStream(t, "rtmp://"+addr+"/live/cam")
+	waitFor(t, "publisher", func() bool { return server.Context.lookupStream("live", "cam") != nil })
+	srv := httptest.NewServer(http.HandlerFunc(server.Context.serveHTTP))
+	t.Cleanup(srv.Close)
+
+	get := func(stream string, upgrade bool) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/live/"+stream+".flv", nil)
+		if upgrade {
+			req.Header.Set("Connection", "Upgrade")
+			req.Header.Set("Upgrade", "websocket")
+			req.Header.Set("Sec-WebSocket-Version", "13")
+			req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	for _, upgrade := range []bool{false, true} {
+		for stream, want := range map[string]int{"denied": http.StatusForbidden, "missing": http.StatusNotFound} {
+			res := get(stream, upgrade)
+			res.Body.Close()
+			if res.StatusCode != want {
+				t.Errorf("upgrade %v, %s: %d, want %d", upgrade, stream, res.StatusCode, want)
+			}
+		}
+	}
+
+	// 허용된 재생은 연결이 끝나면 on_play_done으로 알립니다.
+	for _, upgrade := range []bool{false, true} {
+		before := len(calls())
+		res := get("cam", upgrade)
+		if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusSwitchingProtocols {
+			t.Fatalf("upgrade %v: %d", upgrade, res.StatusCode)
+		}
+		res.Body.Close()
+		waitFor(t, "on_play_done", func() bool { return len(calls()) == before+2 })
+		if got := calls()[before:]; !slices.Equal(got, []string{"play cam", "play_done cam"}) {
+			t.Errorf("upgrade %v: webhook calls %q", upgrade, got)
+		}
+	}
+	// 거절된 재생은 on_play_done을 보내지 않습니다.
+	for _, call := range calls() {
+		if call == "play_done denied" || call == "play_done missing" {
+			t.Errorf("unexpected %q", call)
+		}
+	}
+}
+
+func TestPlayDoneOnlyAfterPlayStarts(t *testing.T) {
+	hooks, calls := playWebhookTestServer(t)
+	server, addr := startTestServer(t, map[string]*AppConfig{"live": {}}, func(cfg *Config) { cfg.Webhooks = hooks })
+	publishTestStream(t, "rtmp://"+addr+"/live/cam")
+	waitFor(t, "publisher", func() bool { return server.Context.lookupStream("live", "cam") != nil })
+
+	// 없는 스트림은 on_play는 허용되지만 재생이 시작되지 않으므로 on_play_done을 보내지 않습니다.
+	client := dialTestClient(t, "rtmp://"+addr+"/live/nothere")
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	if err := client.Play(ctx, client.Stream); err == nil {
+		t.Fatal("play of a missing stream succeeded")
+	}
+	client.Close()
+
+	player := playTestStream(t, "rtmp://"+addr+"/live/cam")
+	player.Close()
+	waitFor(t, "on_play_done", func() bool { return slices.Contains(calls(), "play_done cam") })
+	if got, want := calls(), []string{"play nothere", "play cam", "play_done cam"}; !slices.Equal(got, want) {
+		t.Errorf("webhook calls %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,260 @@
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+	WebSocket (RFC 6455)
+	HTTP 요청을 101 Switching Protocols로 업그레이드한 뒤, 같은 TCP 연결 위에서 프레임 단위로 데이터를 주고받습니다.
+	프레임 = FIN/opcode (1바이트) + MASK/길이 (1, 3, 9바이트) + 마스킹 키 (클라이언트 -> 서버만 4바이트) + 페이로드
+*/
+
+// acceptGUID Sec-WebSocket-Accept 계산에 사용하는 고정 문자열입니다.
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// maxControlPayload 제어 프레임(close, ping, pong)의 최대 페이로드 길이입니다.
+const maxControlPayload = 125
+
+// maxMessageSize 클라이언트로부터 받을 수 있는 데이터 프레임의 최대 길이입니다. (재생 전용이므로 크게 받을 일이 없습니다.)
+const maxMessageSize = 64 * 1024
+
+// 프레임 opcode 입니다.
+const (
+	OpContinuation = 0x0
+	OpText         = 0x1
+	OpBinary       = 0x2
+	OpClose        = 0x8
+	OpPing         = 0x9
+	OpPong         = 0xA
+)
+
+var (
+	ErrNotWebSocket  = errors.New("websocket: not a websocket handshake")
+	ErrFrameTooLarge = errors.New("websocket: frame too large")
+	ErrUnmasked      = errors.New("websocket: client frame is not masked")
+	// ErrProtocol 조각난 메시지의 순서가 잘못되었습니다. (앞선 데이터 프레임이 없는 continuation 등)
+	ErrProtocol = errors.New("websocket: protocol error")
+)
+
+// Conn 업그레이드가 끝난 WebSocket 연결입니다.
+type Conn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+
+	writeMu sync.Mutex
+	closed  bool
+}
+
+// IsUpgrade 요청이 WebSocket 업그레이드 요청인지 확인합니다.
+func IsUpgrade(r *http.Request) bool {
+	return headerContains(r.Header, "Connection", "upgrade") && headerContains(r.Header, "Upgrade", "websocket")
+}
+
+// Upgrade HTTP 연결을 WebSocket 연결로 업그레이드합니다.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != http.MethodGet || !IsUpgrade(r) || key == "" {
+		http.Error(w, "websocket upgrade required", http.StatusBadRequest)
+		return nil, ErrNotWebSocket
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusUpgradeRequired)
+		return nil, ErrNotWebSocket
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket unsupported", http.StatusInternalServerError)
+		return nil, ErrNotWebSocket
+	}
+
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + AcceptKey(key) + "\r\n"
+	// flv.js 등은 서브 프로토콜을 요청하지 않지만, 요청한 경우 첫 번째 값을 그대로 사용합니다.
+	if protocol := r.Header.Get("Sec-WebSocket-Protocol"); protocol != "" {
+		response += "Sec-WebSocket-Protocol: " + strings.TrimSpace(strings.Split(protocol, ",")[0]) + "\r\n"
+	}
+	response += "\r\n"
+
+	if _, err = conn.Write([]byte(response)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &Conn{conn: conn, reader: rw.Reader}, nil
+}
+
+// AcceptKey Sec-WebSocket-Key에 대한 Sec-WebSocket-Accept 값을 계산합니다.
+func AcceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// WriteMessage 하나의 프레임(FIN=1)으로 메시지를 씁니다. 서버가 보내는 프레임은 마스킹하지 않습니다.
+func (c *Conn) WriteMessage(opcode byte, data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closed {
+		return net.ErrClosed
+	}
+
+	header := make([]byte, 2, 10)
+	header[0] = 0x80 | opcode
+	switch {
+	case len(data) < 126:
+		header[1] = byte(len(data))
+	case len(data) <= 0xffff:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(len(data)))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(len(data)))
+	}
+
+	if _, err := c.conn.Write(append(header, data...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// SetWriteDeadline 이후의 WriteMessage가 t까지 끝나지 않으면 타임아웃 에러를 반환하도록 합니다.
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+// ReadMessage 다음 데이터 메시지를 읽습니다. ping에는 pong으로 응답하고, close를 받으면 io.EOF를 반환합니다.
+// 조각난 메시지는 첫 데이터 프레임 뒤에 continuation 프레임만 올 수 있고, 어기면 ErrProtocol을 반환합니다.
+func (c *Conn) ReadMessage() (opcode byte, data []byte, err error) {
+	var message []byte
+	var messageOpcode byte
+	fragmented := false
+	for {
+		fin, op, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch op {
+		case OpClose:
+			c.WriteMessage(OpClose, payload)
+			return 0, nil, io.EOF
+		case OpPing:
+			if err = c.WriteMessage(OpPong, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case OpPong:
+			continue
+		case OpContinuation:
+			if !fragmented {
+				return 0, nil, ErrProtocol
+			}
+		default:
+			if fragmented {
+				return 0, nil, ErrProtocol
+			}
+			messageOpcode = op
+			fragmented = true
+		}
+
+		if len(message)+len(payload) > maxMessageSize {
+			return 0, nil, ErrFrameTooLarge
+		}
+		message = append(message, payload...)
+		if fin {
+			return messageOpcode, message, nil
+		}
+	}
+}
+
+func (c *Conn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err = io.ReadFull(c.reader, header[:]); err != nil {
+		return
+	}
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0f
+	masked := header[1]&0x80 != 0
+	length := uint64(header[1] & 0x7f)
+
+	if !masked {
+		err = ErrUnmasked
+		return
+	}
+
+	switch length {
+	case 126:
+		var b [2]byte
+		if _, err = io.ReadFull(c.reader, b[:]); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		if _, err = io.ReadFull(c.reader, b[:]); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(b[:])
+	}
+
+	if (opcode >= OpClose && length > maxControlPayload) || length > maxMessageSize {
+		err = ErrFrameTooLarge
+		return
+	}
+
+	var mask [4]byte
+	if _, err = io.ReadFull(c.reader, mask[:]); err != nil {
+		return
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.reader, payload); err != nil {
+		return
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return
+}
+
+// Close close 프레임을 보내고 연결을 닫습니다.
+func (c *Conn) Close() error {
+	c.WriteMessage(OpClose, []byte{0x03, 0xe8}) // 1000 Normal Closure
+	c.writeMu.Lock()
+	c.closed = true
+	c.writeMu.Unlock()
+	return c.conn.Close()
+}
+
+// RemoteAddr 클라이언트 주소를 반환합니다.
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+func headerContains(header http.Header, name string, value string) bool {
+	for _, v := range header.Values(name) {
+		for _, token := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), value) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,240 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// testConn 서버 쪽 Conn과 클라이언트 쪽 연결을 만듭니다.
+func testConn(t *testing.T) (*Conn, net.Conn) {
+	t.Helper()
+	client, server := net.Pipe()
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	client.SetDeadline(time.Now().Add(5 * time.Second))
+	server.SetDeadline(time.Now().Add(5 * time.Second))
+	return &Conn{conn: server, reader: bufio.NewReader(server)}, client
+}
+
+// clientFrame 클라이언트가 보내는 마스킹한 프레임을 만듭니다. length64이면 길이를 64비트로 적습니다.
+func clientFrame(fin bool, opcode byte, payload []byte, length64 bool) []byte {
+	b := []byte{opcode, 0x80}
+	if fin {
+		b[0] |= 0x80
+	}
+	switch {
+	case length64:
+		b[1] |= 127
+		b = binary.BigEndian.AppendUint64(b, uint64(len(payload)))
+	case len(payload) < 126:
+		b[1] |= byte(len(payload))
+	default:
+		b[1] |= 126
+		b = binary.BigEndian.AppendUint16(b, uint16(len(payload)))
+	}
+	mask := []byte{0x37, 0xfa, 0x21, 0x3d}
+	b = append(b, mask...)
+	for i, v := range payload {
+		b = append(b, v^mask[i%4])
+	}
+	return b
+}
+
+// readServerFrame 서버가 보낸 마스킹하지 않은 프레임을 읽습니다.
+func readServerFrame(r io.Reader) (header []byte, opcode byte, payload []byte, err error) {
+	header = make([]byte, 2)
+	if _, err = io.ReadFull(r, header); err != nil {
+		return
+	}
+	if header[1]&0x80 != 0 {
+		return nil, 0, nil, errors.New("server frame is masked")
+	}
+	length := uint64(header[1])
+	switch length {
+	case 126:
+		header = append(header, 0, 0)
+		_, err = io.ReadFull(r, header[2:])
+		length = uint64(binary.BigEndian.Uint16(header[2:]))
+	case 127:
+		header = append(header, make([]byte, 8)...)
+		_, err = io.ReadFull(r, header[2:])
+		length = binary.BigEndian.Uint64(header[2:])
+	}
+	if err != nil {
+		return
+	}
+	payload = make([]byte, length)
+	_, err = io.ReadFull(r, payload)
+	return header, header[0] & 0x0f, payload, err
+}
+
+func TestAcceptKey(t *testing.T) {
+	// RFC 6455 1.3의 예
+	if got := AcceptKey("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("AcceptKey = %q", got)
+	}
+}
+
+func TestWriteMessageLengths(t *testing.T) {
+	tests := []struct {
+		length    int
+		headerLen int
+	}{
+		{0, 2}, {125, 2}, {126, 4}, {0xffff, 4}, {0x10000, 10},
+	}
+	for _, tt := range tests {
+		c, client := testConn(t)
+		data := bytes.Repeat([]byte{'x'}, tt.length)
+		go c.WriteMessage(OpBinary, data)
+		header, opcode, payload, err := readServerFrame(client)
+		if err != nil {
+			t.Fatalf("%d bytes: %s", tt.length, err)
+		}
+		if len(header) != tt.headerLen || header[0] != 0x80|OpBinary || opcode != OpBinary || !bytes.Equal(payload, data) {
+			t.Errorf("%d bytes: header % x, %d payload bytes", tt.length, header, len(payload))
+		}
+	}
+}
+
+func TestReadMessage(t *testing.T) {
+	long := bytes.Repeat([]byte("0123456789"), 30)
+	tests := []struct {
+		name   string
+		frames [][]byte
+		opcode byte
+		data   []byte
+		err    error
+	}{
+		{"short", [][]byte{clientFrame(true, OpText, []byte("hello"), false)}, OpText, []byte("hello"), nil},
+		{"16-bit length", [][]byte{clientFrame(true, OpBinary, long, false)}, OpBinary, long, nil},
+		{"64-bit length", [][]byte{clientFrame(true, OpBinary, long, true)}, OpBinary, long, nil},
+		{"fragmented", [][]byte{
+			clientFrame(false, OpText, []byte("ab"), false),
+			clientFrame(false, OpContinuation, []byte("cd"), false),
+			clientFrame(true, OpContinuation, []byte("ef"), false),
+		}, OpText, []byte("abcdef"), nil},
+		{"pong is ignored", [][]byte{clientFrame(true, OpPong, nil, false), clientFrame(true, OpText, []byte("a"), false)}, OpText, []byte("a"), nil},
+		{"unmasked", [][]byte{{0x81, 0x01, 'a'}}, 0, nil, ErrUnmasked},
+		{"continuation without data frame", [][]byte{clientFrame(true, OpContinuation, []byte("a"), false)}, 0, nil, ErrProtocol},
+		{"data frame inside a fragmented message", [][]byte{
+			clientFrame(false, OpText, []byte("a"), false),
+			clientFrame(true, OpBinary, []byte("b"), false),
+		}, 0, nil, ErrProtocol},
+		{"too large", [][]byte{clientFrame(true, OpBinary, make([]byte, maxMessageSize+1), true)[:14]}, 0, nil, ErrFrameTooLarge},
+		{"control frame too large", [][]byte{clientFrame(true, OpPing, make([]byte, 126), false)[:8]}, 0, nil, ErrFrameTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, client := testConn(t)
+			go func() {
+				for _, frame := range tt.frames {
+					if _, err := client.Write(frame); err != nil {
+						return
+					}
+				}
+			}()
+			opcode, data, err := c.ReadMessage()
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got %v, want %v", err, tt.err)
+			}
+			if opcode != tt.opcode || !bytes.Equal(data, tt.data) {
+				t.Errorf("got opcode %d, %q", opcode, data)
+			}
+		})
+	}
+}
+
+func TestReadMessagePingAndClose(t *testing.T) {
+	c, client := testConn(t)
+	go func() {
+		client.Write(clientFrame(false, OpText, []byte("ab"), false))
+		// 조각난 메시지 사이에도 ping이 올 수 있습니다.
+		client.Write(clientFrame(true, OpPing, []byte("p1"), false))
+	}()
+	type result struct {
+		opcode byte
+		data   []byte
+		err    error
+	}
+	results := make(chan result, 2)
+	go func() {
+		for i := 0; i < 2; i++ {
+			opcode, data, err := c.ReadMessage()
+			results <- result{opcode, data, err}
+		}
+	}()
+
+	if _, opcode, payload, err := readServerFrame(client); err != nil || opcode != OpPong || string(payload) != "p1" {
+		t.Fatalf("pong: opcode %d, %q, %v", opcode, payload, err)
+	}
+	go func() {
+		client.Write(clientFrame(true, OpContinuation, []byte("cd"), false))
+		client.Write(clientFrame(true, OpClose, []byte{0x03, 0xe8}, false))
+	}()
+	if r := <-results; r.err != nil || r.opcode != OpText || string(r.data) != "abcd" {
+		t.Errorf("message: opcode %d, %q, %v", r.opcode, r.data, r.err)
+	}
+	// close에는 같은 상태 코드로 응답하고 io.EOF를 반환합니다.
+	if _, opcode, payload, err := readServerFrame(client); err != nil || opcode != OpClose || !bytes.Equal(payload, []byte{0x03, 0xe8}) {
+		t.Errorf("close reply: opcode %d, % x, %v", opcode, payload, err)
+	}
+	if r := <-results; r.err != io.EOF {
+		t.Errorf("after close: %v", r.err)
+	}
+}
+
+func TestUpgrade(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		conn.WriteMessage(OpBinary, []byte("hi"))
+		conn.Close()
+	}))
+	defer srv.Close()
+
+	request := func(version string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		req.Header.Set("Connection", "keep-alive, Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Sec-WebSocket-Version", version)
+		req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		req.Header.Set("Sec-WebSocket-Protocol", "flv, other")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	res := request("13")
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusSwitchingProtocols || res.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" || res.Header.Get("Sec-WebSocket-Protocol") != "flv" {
+		t.Fatalf("status %d, headers %v", res.StatusCode, res.Header)
+	}
+	body, ok := res.Body.(io.ReadWriteCloser)
+	if !ok {
+		t.Fatal("101 response body is not writable")
+	}
+	if _, opcode, payload, err := readServerFrame(body); err != nil || opcode != OpBinary || string(payload) != "hi" {
+		t.Errorf("message: opcode %d, %q, %v", opcode, payload, err)
+	}
+
+	res = request("8")
+	res.Body.Close()
+	if res.StatusCode != http.StatusUpgradeRequired || res.Header.Get("Sec-WebSocket-Version") != "13" {
+		t.Errorf("unsupported version: status %d", res.StatusCode)
+	}
+}
@@ -0,0 +1,93 @@
+package internal
+
+import (
+	"example/hello/internal/websocket"
+	"log"
+	"net/http"
+	"time"
+)
+
+// wsBinaryWriter Write 호출마다 하나의 WebSocket 바이너리 프레임을 보냅니다.
+// flvPacketWriter는 태그 하나를 한 번의 Write로 쓰므로 프레임 하나에 태그 하나가 담깁니다.
+// 프레임마다 timeout만큼 쓰기 제한 시간을 설정해, 받지 않는 시청자의 구독이 끝나도록 합니다. (0이면 제한 없음)
+// 보낸 바이트 수는 meter에 더해 대역폭 제한에 반영합니다.
+type wsBinaryWriter struct {
+	conn    *websocket.Conn
+	timeout time.Duration
+	meter   *rateMeter
+}
+
+func (w *wsBinaryWriter) Write(b []byte) (int, error) {
+	if w.timeout > 0 {
+		w.conn.SetWriteDeadline(time.Now().Add(w.timeout))
+	}
+	if err := w.conn.WriteMessage(websocket.OpBinary, b); err != nil {
+		return 0, err
+	}
+	w.meter.Add(len(b))
+	return len(b), nil
+}
+
+// serveWSFLV ws://host/{app}/{stream}.flv 요청에 HTTP-FLV와 같은 FLV 태그 스트림을 WebSocket 바이너리 프레임으로 보냅니다.
+func (ctx *StreamContext) serveWSFLV(w http.ResponseWriter, r *http.Request, app, stream string) {
+	if err := ctx.checkAccess(app, AccessActionPlay, httpClientIP(r)); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err := ctx.checkToken(TokenActionPlay, app, stream, httpClientIP(r), r.URL.Query()); err != nil {
+		log.Printf("WebSocket-FLV play rejected for %s/%s: %s", app, stream, err.Error())
+		http.Error(w, "invalid token: "+err.Error(), http.StatusForbidden)
+		return
+	}
+	hook := httpWebhookRequest(r, "ws", app, stream)
+	if code, err := ctx.httpPlayWebhook(r, hook); err != nil {
+		log.Printf("WebSocket-FLV play rejected for %s/%s: %s", app, stream, err.Error())
+		http.Error(w, err.Error(), code)
+		return
+	}
+	publisher := ctx.lookupStream(app, stream)
+	if publisher == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if err := ctx.addViewer(publisher); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	defer ctx.removeViewer(publisher)
+
+	conn, err := websocket.Upgrade(w, r)
+	if err != nil {
+		log.Printf("WebSocket upgrade failed: %s", err.Error())
+		return
+	}
+	defer conn.Close()
+
+	fw := newFLVPacketWriter(&wsBinaryWriter{conn: conn, timeout: ctx.timeouts().Write, meter: &ctx.outgoing}, func() error { return nil })
+	if err = fw.writeHeader(publisher.Hub); err != nil {
+		return
+	}
+
+	// 재생이 시작된 요청만 끝날 때 on_play_done으로 알립니다.
+	defer ctx.notifyWebhook(webhookPlayDone, hook)
+	log.Printf("WebSocket-FLV play started %s/%s from %s", app, stream, conn.RemoteAddr())
+	sub := publisher.Hub.Subscribe(fw)
+
+	// 클라이언트가 보내는 프레임을 읽어 close, ping을 처리합니다. 연결이 끊기면 구독을 해제합니다.
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-closed:
+		sub.Close()
+	case <-sub.Done():
+	}
+	log.Printf("WebSocket-FLV play finished %s/%s from %s", app, stream, conn.RemoteAddr())
+}
@@ -0,0 +1,226 @@
+package yaml
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Unmarshal data를 v(구조체, 맵 등의 포인터)에 채웁니다.
+// 구조체 필드 이름은 `yaml:"name"` 태그를 사용하고, 태그가 없으면 소문자로 바꾼 필드 이름을 사용합니다.
+// 구조체에 없는 키가 있으면 오타를 알 수 있도록 에러를 반환합니다.
+// time.Duration은 "30s", "1h30m"처럼 time.ParseDuration 형식으로 적습니다.
+func Unmarshal(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return &Error{Msg: "Unmarshal requires a non-nil pointer"}
+	}
+	n, err := parse(data)
+	if err != nil {
+		return err
+	}
+	return decode(n, rv.Elem(), "")
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// decode n을 v에 채웁니다. path는 에러 메시지에 표시할 키 경로입니다.
+func decode(n *node, v reflect.Value, path string) error {
+	if n.kind == nullNode {
+		// 값이 비어 있으면 기본값을 그대로 둡니다.
+		return nil
+	}
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return decode(n, v.Elem(), path)
+	}
+	if v.Type() == durationType {
+		s, err := scalar(n, path)
+		if err != nil {
+			return err
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return errorf(n.line, "%s: invalid duration %q (use a unit, e.g. 30s or 5m)", path, s)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		return decodeStruct(n, v, path)
+	case reflect.Map:
+		return decodeMap(n, v, path)
+	case reflect.Slice:
+		if n.kind != sequenceNode {
+			return mismatch(n, path, "a sequence")
+		}
+		s := reflect.MakeSlice(v.Type(), len(n.values), len(n.values))
+		for i, item := range n.values {
+			if err := decode(item, s.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+		return nil
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			return errorf(n.line, "%s: cannot decode into %s", displayPath(path), v.Type())
+		}
+		v.Set(reflect.ValueOf(generic(n)))
+		return nil
+	}
+
+	s, err := scalar(n, path)
+	if err != nil {
+		return err
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, ok := parseBool(s)
+		if !ok || n.quoted {
+			return errorf(n.line, "%s: invalid boolean %q", path, s)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(strings.ReplaceAll(s, "_", ""), 0, v.Type().Bits())
+		if err != nil {
+			return errorf(n.line, "%s: invalid integer %q", path, s)
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(strings.ReplaceAll(s, "_", ""), 0, v.Type().Bits())
+		if err != nil {
+			return errorf(n.line, "%s: invalid unsigned integer %q", path, s)
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return errorf(n.line, "%s: invalid number %q", path, s)
+		}
+		v.SetFloat(f)
+	default:
+		return errorf(n.line, "%s: cannot decode into %s", path, v.Type())
+	}
+	return nil
+}
+
+func decodeStruct(n *node, v reflect.Value, path string) error {
+	if n.kind != mappingNode {
+		return mismatch(n, path, "a mapping")
+	}
+	fields := make(map[string]int)
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := strings.Split(f.Tag.Get("yaml"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = i
+	}
+	for i, key := range n.keys {
+		field, ok := fields[key.value]
+		if !ok {
+			return errorf(key.line, "%s: unknown field %q", displayPath(path), key.value)
+		}
+		if err := decode(n.values[i], v.Field(field), join(path, key.value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeMap(n *node, v reflect.Value, path string) error {
+	if n.kind != mappingNode {
+		return mismatch(n, path, "a mapping")
+	}
+	t := v.Type()
+	if t.Key().Kind() != reflect.String {
+		return errorf(n.line, "%s: map keys must be strings", displayPath(path))
+	}
+	if v.IsNil() {
+		v.Set(reflect.MakeMapWithSize(t, len(n.keys)))
+	}
+	for i, key := range n.keys {
+		elem := reflect.New(t.Elem()).Elem()
+		if existing := v.MapIndex(reflect.ValueOf(key.value).Convert(t.Key())); existing.IsValid() {
+			elem.Set(existing)
+		}
+		if err := decode(n.values[i], elem, join(path, key.value)); err != nil {
+			return err
+		}
+		v.SetMapIndex(reflect.ValueOf(key.value).Convert(t.Key()), elem)
+	}
+	return nil
+}
+
+func scalar(n *node, path string) (string, error) {
+	if n.kind != scalarNode {
+		return "", mismatch(n, path, "a scalar value")
+	}
+	return n.value, nil
+}
+
+func mismatch(n *node, path, want string) error {
+	return errorf(n.line, "%s: expected %s, found a %s", displayPath(path), want, n.kind)
+}
+
+func parseBool(s string) (bool, bool) {
+	switch strings.ToLower(s) {
+	case "true", "yes", "on":
+		return true, true
+	case "false", "no", "off":
+		return false, true
+	}
+	return false, false
+}
+
+// generic interface{} 필드에 넣을 값으로 바꿉니다. (map[string]interface{}, []interface{}, string, nil)
+func generic(n *node) interface{} {
+	switch n.kind {
+	case scalarNode:
+		return n.value
+	case mappingNode:
+		m := make(map[string]interface{}, len(n.keys))
+		for i, key := range n.keys {
+			m[key.value] = generic(n.values[i])
+		}
+		return m
+	case sequenceNode:
+		s := make([]interface{}, len(n.values))
+		for i, item := range n.values {
+			s[i] = generic(item)
+		}
+		return s
+	}
+	return nil
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "document"
+	}
+	return path
+}
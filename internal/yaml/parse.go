@@ -0,0 +1,454 @@
+// Package yaml 설정 파일에 필요한 만큼의 YAML 부분 집합을 읽습니다.
+//
+// 지원하는 문법: 블록 매핑, 블록 시퀀스(- 항목), 주석(#), 일반/작은따옴표/큰따옴표 스칼라,
+// 한 줄짜리 흐름 표기([a, b], {k: v}), 문서 시작 표시(---).
+// 앵커, 별칭, 태그, 여러 줄 스칼라(|, >)는 지원하지 않습니다.
+package yaml
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Error 설정 파일의 줄 번호를 포함한 에러입니다.
+type Error struct {
+	Line int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	if e.Line == 0 {
+		return "yaml: " + e.Msg
+	}
+	return fmt.Sprintf("yaml: line %d: %s", e.Line, e.Msg)
+}
+
+func errorf(line int, format string, args ...interface{}) *Error {
+	return &Error{Line: line, Msg: fmt.Sprintf(format, args...)}
+}
+
+type nodeKind int
+
+const (
+	nullNode nodeKind = iota
+	scalarNode
+	mappingNode
+	sequenceNode
+)
+
+func (k nodeKind) String() string {
+	switch k {
+	case scalarNode:
+		return "scalar"
+	case mappingNode:
+		return "mapping"
+	case sequenceNode:
+		return "sequence"
+	}
+	return "null"
+}
+
+// node 파싱된 YAML 값입니다. 매핑은 키 순서를 유지합니다.
+type node struct {
+	kind   nodeKind
+	line   int
+	value  string // scalarNode
+	quoted bool   // 따옴표로 감싼 스칼라 (null, 불리언으로 해석하지 않습니다)
+	keys   []*node
+	values []*node // mappingNode는 keys와 같은 순서, sequenceNode는 항목
+}
+
+type line struct {
+	num    int
+	indent int
+	text   string
+}
+
+type parser struct {
+	lines []line
+	pos   int
+}
+
+// parse data를 하나의 문서로 읽습니다. 빈 문서는 nullNode 입니다.
+func parse(data []byte) (*node, error) {
+	p := &parser{}
+	if err := p.split(string(data)); err != nil {
+		return nil, err
+	}
+	if len(p.lines) == 0 {
+		return &node{kind: nullNode}, nil
+	}
+	n, err := p.parseBlock(p.lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		return nil, errorf(l.num, "unexpected indentation")
+	}
+	return n, nil
+}
+
+// split 주석과 빈 줄을 빼고 줄마다 들여쓰기를 계산합니다.
+func (p *parser) split(data string) error {
+	data = strings.TrimPrefix(data, "\ufeff")
+	for i, text := range strings.Split(data, "\n") {
+		num := i + 1
+		text = strings.TrimRight(text, "\r")
+		indent := 0
+		for indent < len(text) && text[indent] == ' ' {
+			indent++
+		}
+		if indent < len(text) && text[indent] == '\t' {
+			return errorf(num, "tabs are not allowed for indentation")
+		}
+		text = strings.TrimSpace(stripComment(text))
+		if text == "" {
+			continue
+		}
+		if text == "---" || text == "..." {
+			if len(p.lines) > 0 && text == "---" {
+				return errorf(num, "multiple documents are not supported")
+			}
+			continue
+		}
+		p.lines = append(p.lines, line{num: num, indent: indent, text: text})
+	}
+	return nil
+}
+
+// stripComment 따옴표 밖에 있는 # 주석을 지웁니다. #은 줄 처음이거나 공백 뒤에 있어야 주석입니다.
+func stripComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case quote != 0:
+			if ch == '\\' && quote == '"' {
+				i++
+			} else if ch == '\'' && quote == '\'' && i+1 < len(s) && s[i+1] == '\'' {
+				// 작은따옴표 안의 ''는 따옴표 하나입니다.
+				i++
+			} else if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			if i == 0 || strings.ContainsRune(" \t[{,:-", rune(s[i-1])) {
+				quote = ch
+			}
+		case ch == '#':
+			if i == 0 || s[i-1] == ' ' || s[i-1] == '\t' {
+				return s[:i]
+			}
+		}
+	}
+	return s
+}
+
+func isSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// parseBlock indent 들여쓰기에서 시작하는 매핑 또는 시퀀스를 읽습니다.
+func (p *parser) parseBlock(indent int) (*node, error) {
+	if isSequenceItem(p.lines[p.pos].text) {
+		return p.parseSequence(indent)
+	}
+	return p.parseMapping(indent)
+}
+
+func (p *parser) parseMapping(indent int) (*node, error) {
+	n := &node{kind: mappingNode, line: p.lines[p.pos].num}
+	seen := make(map[string]bool)
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if l.indent < indent {
+			break
+		}
+		if l.indent > indent {
+			return nil, errorf(l.num, "unexpected indentation")
+		}
+		if isSequenceItem(l.text) {
+			return nil, errorf(l.num, "expected a mapping key, found a sequence item")
+		}
+		keyText, rest, ok := splitKeyValue(l.text)
+		if !ok {
+			return nil, errorf(l.num, "expected \"key: value\", found %q", l.text)
+		}
+		key, err := parseScalar(keyText, l.num)
+		if err != nil {
+			return nil, err
+		}
+		if seen[key.value] {
+			return nil, errorf(l.num, "duplicate key %q", key.value)
+		}
+		seen[key.value] = true
+		p.pos++
+
+		var value *node
+		if rest != "" {
+			if value, err = parseInline(rest, l.num); err != nil {
+				return nil, err
+			}
+		} else if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+			if value, err = p.parseBlock(p.lines[p.pos].indent); err != nil {
+				return nil, err
+			}
+		} else if p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isSequenceItem(p.lines[p.pos].text) {
+			// key:
+			// - a
+			// 처럼 시퀀스가 키와 같은 들여쓰기에 올 수 있습니다.
+			if value, err = p.parseSequence(indent); err != nil {
+				return nil, err
+			}
+		} else {
+			value = &node{kind: nullNode, line: l.num}
+		}
+		n.keys = append(n.keys, key)
+		n.values = append(n.values, value)
+	}
+	return n, nil
+}
+
+func (p *parser) parseSequence(indent int) (*node, error) {
+	n := &node{kind: sequenceNode, line: p.lines[p.pos].num}
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if l.indent < indent || (l.indent == indent && !isSequenceItem(l.text)) {
+			break
+		}
+		if l.indent > indent {
+			return nil, errorf(l.num, "unexpected indentation")
+		}
+		rest := strings.TrimLeft(l.text[1:], " ")
+		var item *node
+		var err error
+		switch {
+		case rest == "":
+			p.pos++
+			if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+				item, err = p.parseBlock(p.lines[p.pos].indent)
+			} else {
+				item = &node{kind: nullNode, line: l.num}
+			}
+		case isSequenceItem(rest) || isMappingEntry(rest):
+			// "- key: value"는 항목 위치를 들여쓰기로 하는 블록으로 바꿔 읽습니다.
+			p.lines[p.pos] = line{num: l.num, indent: l.indent + len(l.text) - len(rest), text: rest}
+			item, err = p.parseBlock(p.lines[p.pos].indent)
+		default:
+			p.pos++
+			item, err = parseInline(rest, l.num)
+		}
+		if err != nil {
+			return nil, err
+		}
+		n.values = append(n.values, item)
+	}
+	return n, nil
+}
+
+// isMappingEntry text가 "key: value" 형태인지 확인합니다. (흐름 표기와 따옴표 스칼라는 제외)
+func isMappingEntry(text string) bool {
+	if text[0] == '[' || text[0] == '{' {
+		return false
+	}
+	_, _, ok := splitKeyValue(text)
+	return ok
+}
+
+// splitKeyValue 따옴표 밖의 첫 ": " 또는 줄 끝의 ":"에서 키와 값을 나눕니다.
+func splitKeyValue(text string) (key, value string, ok bool) {
+	i := 0
+	if text[0] == '"' || text[0] == '\'' {
+		end, err := quotedEnd(text, 0)
+		if err != nil {
+			return "", "", false
+		}
+		i = end
+	}
+	for ; i < len(text); i++ {
+		if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ') {
+			return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:]), true
+		}
+	}
+	return "", "", false
+}
+
+// quotedEnd start에서 시작하는 따옴표 문자열이 끝난 바로 다음 위치를 반환합니다.
+func quotedEnd(s string, start int) (int, error) {
+	quote := s[start]
+	for i := start + 1; i < len(s); i++ {
+		switch {
+		case quote == '"' && s[i] == '\\':
+			i++
+		case quote == '\'' && s[i] == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			i++
+		case s[i] == quote:
+			return i + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("unterminated quoted string")
+}
+
+// parseInline 키 뒤나 시퀀스 항목에 한 줄로 적힌 값을 읽습니다.
+func parseInline(text string, num int) (*node, error) {
+	switch text[0] {
+	case '[', '{':
+		f := &flowParser{s: text, line: num}
+		n, err := f.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		if f.skipSpace(); f.i != len(f.s) {
+			return nil, errorf(num, "unexpected %q after flow collection", f.s[f.i:])
+		}
+		return n, nil
+	case '|', '>':
+		return nil, errorf(num, "block scalars (| and >) are not supported")
+	case '&', '*', '!':
+		return nil, errorf(num, "anchors, aliases and tags are not supported")
+	}
+	return parseScalar(text, num)
+}
+
+// parseScalar 일반 또는 따옴표 스칼라를 읽습니다.
+func parseScalar(text string, num int) (*node, error) {
+	if text == "" {
+		return &node{kind: nullNode, line: num}, nil
+	}
+	if text[0] != '"' && text[0] != '\'' {
+		if text == "~" || text == "null" || text == "Null" || text == "NULL" {
+			return &node{kind: nullNode, line: num}, nil
+		}
+		return &node{kind: scalarNode, line: num, value: text}, nil
+	}
+	end, err := quotedEnd(text, 0)
+	if err != nil {
+		return nil, errorf(num, "%s", err.Error())
+	}
+	if end != len(text) {
+		return nil, errorf(num, "unexpected %q after quoted string", text[end:])
+	}
+	value, err := unquote(text)
+	if err != nil {
+		return nil, errorf(num, "%s", err.Error())
+	}
+	return &node{kind: scalarNode, line: num, value: value, quoted: true}, nil
+}
+
+func unquote(s string) (string, error) {
+	body := s[1 : len(s)-1]
+	if s[0] == '\'' {
+		return strings.ReplaceAll(body, "''", "'"), nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(body); i++ {
+		if body[i] != '\\' {
+			b.WriteByte(body[i])
+			continue
+		}
+		i++
+		switch body[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case '0':
+			b.WriteByte(0)
+		case '"', '\\', '/':
+			b.WriteByte(body[i])
+		default:
+			return "", fmt.Errorf("unsupported escape sequence \\%c", body[i])
+		}
+	}
+	return b.String(), nil
+}
+
+// flowParser [a, b], {k: v} 형태의 흐름 표기를 읽습니다.
+type flowParser struct {
+	s    string
+	i    int
+	line int
+}
+
+func (f *flowParser) skipSpace() {
+	for f.i < len(f.s) && f.s[f.i] == ' ' {
+		f.i++
+	}
+}
+
+func (f *flowParser) parseValue() (*node, error) {
+	f.skipSpace()
+	if f.i == len(f.s) {
+		return nil, errorf(f.line, "unterminated flow collection")
+	}
+	switch f.s[f.i] {
+	case '[':
+		return f.parseCollection(']')
+	case '{':
+		return f.parseCollection('}')
+	case '"', '\'':
+		end, err := quotedEnd(f.s, f.i)
+		if err != nil {
+			return nil, errorf(f.line, "%s", err.Error())
+		}
+		text := f.s[f.i:end]
+		f.i = end
+		return parseScalar(text, f.line)
+	}
+	start := f.i
+	for f.i < len(f.s) && !strings.ContainsRune(",]}", rune(f.s[f.i])) {
+		if f.s[f.i] == ':' && (f.i+1 == len(f.s) || f.s[f.i+1] == ' ') {
+			break
+		}
+		f.i++
+	}
+	return parseScalar(strings.TrimSpace(f.s[start:f.i]), f.line)
+}
+
+func (f *flowParser) parseCollection(closing byte) (*node, error) {
+	n := &node{kind: sequenceNode, line: f.line}
+	if closing == '}' {
+		n.kind = mappingNode
+	}
+	f.i++
+	for {
+		f.skipSpace()
+		if f.i == len(f.s) {
+			return nil, errorf(f.line, "unterminated flow collection")
+		}
+		if f.s[f.i] == closing {
+			f.i++
+			return n, nil
+		}
+		if len(n.values) > 0 {
+			if f.s[f.i] != ',' {
+				return nil, errorf(f.line, "expected ',' or '%c' in flow collection", closing)
+			}
+			f.i++
+			f.skipSpace()
+		}
+		value, err := f.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		if n.kind == mappingNode {
+			f.skipSpace()
+			if f.i == len(f.s) || f.s[f.i] != ':' {
+				return nil, errorf(f.line, "expected ':' after key in flow mapping")
+			}
+			f.i++
+			key := value
+			if key.kind != scalarNode {
+				return nil, errorf(f.line, "flow mapping keys must be scalars")
+			}
+			if value, err = f.parseValue(); err != nil {
+				return nil, err
+			}
+			n.keys = append(n.keys, key)
+		}
+		n.values = append(n.values, value)
+	}
+}
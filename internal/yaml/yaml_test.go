@@ -0,0 +1,140 @@
+package yaml
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testInner struct {
+	Host string `yaml:"host"`
+	Port int    `yaml:"port"`
+}
+
+type testConfig struct {
+	Name     string                `yaml:"name"`
+	Count    int                   `yaml:"count"`
+	Size     uint32                `yaml:"size"`
+	Ratio    float64               `yaml:"ratio"`
+	Enabled  bool                  `yaml:"enabled"`
+	Timeout  time.Duration         `yaml:"timeout"`
+	Tags     []string              `yaml:"tags"`
+	Inner    testInner             `yaml:"inner"`
+	Ptr      *testInner            `yaml:"ptr"`
+	Servers  []testInner           `yaml:"servers"`
+	Apps     map[string]*testInner `yaml:"apps"`
+	Extra    interface{}           `yaml:"extra"`
+	Untagged string
+	Skipped  string `yaml:"-"`
+}
+
+func TestUnmarshal(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want testConfig
+	}{
+		{"empty", "", testConfig{}},
+		{"comments only", "# comment\n\n---\n", testConfig{}},
+		{"scalars", "name: live\ncount: -3\nsize: 4_096\nratio: 0.5\nenabled: yes\nuntagged: x", testConfig{
+			Name: "live", Count: -3, Size: 4096, Ratio: 0.5, Enabled: true, Untagged: "x",
+		}},
+		{"hex integer", "count: 0x10", testConfig{Count: 16}},
+		{"durations", "timeout: 1h30m", testConfig{Timeout: 90 * time.Minute}},
+		{"null keeps default", "name: ~\ncount: null\ntimeout:", testConfig{}},
+		{"comments", "name: a#b # trailing\n# full line\ncount: 1 # one", testConfig{Name: "a#b", Count: 1}},
+		{"double quoted", `name: "a: b # c\t\"d\""`, testConfig{Name: "a: b # c\t\"d\""}},
+		{"single quoted", `name: 'it''s # not a comment'`, testConfig{Name: "it's # not a comment"}},
+		{"quoted key", `"name": x`, testConfig{Name: "x"}},
+		{"block map", "inner:\n  host: a\n  port: 1\nptr:\n  host: b", testConfig{
+			Inner: testInner{Host: "a", Port: 1}, Ptr: &testInner{Host: "b"},
+		}},
+		{"flow map", "inner: {host: a, port: 2}", testConfig{Inner: testInner{Host: "a", Port: 2}}},
+		{"block list", "tags:\n  - a\n  - 'b c'", testConfig{Tags: []string{"a", "b c"}}},
+		{"block list at key indent", "tags:\n- a\n- b\ncount: 1", testConfig{Tags: []string{"a", "b"}, Count: 1}},
+		{"flow list", "tags: [a, \"b, c\", d]", testConfig{Tags: []string{"a", "b, c", "d"}}},
+		{"empty flow list", "tags: []", testConfig{Tags: []string{}}},
+		{"list of maps", "servers:\n  - host: a\n    port: 1\n  - {host: b}", testConfig{
+			Servers: []testInner{{Host: "a", Port: 1}, {Host: "b"}},
+		}},
+		{"map of structs", "apps:\n  live:\n    port: 1\n  dvr:", testConfig{
+			Apps: map[string]*testInner{"live": {Port: 1}, "dvr": nil},
+		}},
+		{"generic", "extra:\n  a: [1, {b: c}]\n  d: ~", testConfig{
+			Extra: map[string]interface{}{"a": []interface{}{"1", map[string]interface{}{"b": "c"}}, "d": nil},
+		}},
+		{"crlf and bom", "\ufeffname: a\r\ncount: 2\r\n", testConfig{Name: "a", Count: 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got testConfig
+			if err := Unmarshal([]byte(tt.in), &got); err != nil {
+				t.Fatalf("Unmarshal: %s", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshalKeepsDefaults(t *testing.T) {
+	cfg := testConfig{Name: "default", Count: 5, Apps: map[string]*testInner{"live": {Host: "h", Port: 1}}}
+	if err := Unmarshal([]byte("count: 6\napps:\n  live:\n    port: 2"), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "default" || cfg.Count != 6 || *cfg.Apps["live"] != (testInner{Host: "h", Port: 2}) {
+		t.Errorf("got %+v, live %+v", cfg, cfg.Apps["live"])
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		line int
+		msg  string
+	}{
+		{"unknown field", "name: a\nnmae: b", 2, `document: unknown field "nmae"`},
+		{"unknown nested field", "inner:\n  hots: a", 2, `inner: unknown field "hots"`},
+		{"tab indentation", "inner:\n\thost: a", 2, "tabs are not allowed"},
+		{"bad duration", "timeout: 30", 1, `timeout: invalid duration "30"`},
+		{"bad integer", "count: ten", 1, `count: invalid integer "ten"`},
+		{"negative unsigned", "size: -1", 1, `size: invalid unsigned integer "-1"`},
+		{"bad boolean", "enabled: maybe", 1, `enabled: invalid boolean "maybe"`},
+		{"quoted boolean", `enabled: "true"`, 1, `enabled: invalid boolean "true"`},
+		{"scalar for list", "tags: a", 1, "tags: expected a sequence, found a scalar"},
+		{"list for struct", "inner: [a]", 1, "inner: expected a mapping, found a sequence"},
+		{"map for scalar", "name:\n  a: b", 2, "name: expected a scalar value, found a mapping"},
+		{"duplicate key", "name: a\nname: b", 2, `duplicate key "name"`},
+		{"bad indentation", "name: a\n  count: 1", 2, "unexpected indentation"},
+		{"missing colon", "name", 1, `expected "key: value"`},
+		{"unterminated quote", `name: "a`, 1, "unterminated quoted string"},
+		{"trailing after quote", `name: "a" b`, 1, "after quoted string"},
+		{"bad escape", `name: "\x"`, 1, `unsupported escape sequence \x`},
+		{"unterminated flow", "tags: [a, b", 1, "unterminated flow collection"},
+		{"block scalar", "name: |", 1, "block scalars"},
+		{"anchor", "name: &a x", 1, "anchors, aliases and tags"},
+		{"multiple documents", "name: a\n---\nname: b", 2, "multiple documents"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg testConfig
+			err := Unmarshal([]byte(tt.in), &cfg)
+			var yerr *Error
+			if !errors.As(err, &yerr) {
+				t.Fatalf("got %v, want a *yaml.Error", err)
+			}
+			if yerr.Line != tt.line || !strings.Contains(yerr.Msg, tt.msg) {
+				t.Errorf("got line %d %q, want line %d containing %q", yerr.Line, yerr.Msg, tt.line, tt.msg)
+			}
+		})
+	}
+
+	var cfg testConfig
+	if err := Unmarshal([]byte("name: a"), cfg); err == nil {
+		t.Error("Unmarshal into a non-pointer succeeded")
+	}
+}
@@ -0,0 +1,132 @@
+package rtmp
+
+import (
+	"context"
+	"errors"
+	"example/hello/internal"
+	"fmt"
+)
+
+// onStatus, _error 응답의 code에 대응하는 에러입니다. errors.Is로 비교할 수 있습니다.
+var (
+	ErrConnectRejected = errors.New("rtmp: connection rejected")
+	ErrStreamNotFound  = errors.New("rtmp: stream not found")
+	ErrBadName         = errors.New("rtmp: stream name is already in use")
+	ErrPlayFailed      = errors.New("rtmp: play failed")
+	ErrPublishFailed   = errors.New("rtmp: publish failed")
+	ErrClosed          = internal.ErrClientClosed
+)
+
+// StatusError 서버가 보낸 onStatus 또는 _error의 code와 description 입니다. errors.As로 꺼낼 수 있습니다.
+type StatusError = internal.RTMPStatusError
+
+// statusErrors onStatus code와 에러의 대응입니다.
+var statusErrors = map[string]error{
+	"NetConnection.Connect.Rejected": ErrConnectRejected,
+	"NetConnection.Connect.Failed":   ErrConnectRejected,
+	"NetStream.Play.StreamNotFound":  ErrStreamNotFound,
+	"NetStream.Play.Failed":          ErrPlayFailed,
+	"NetStream.Publish.BadName":      ErrBadName,
+	"NetStream.Publish.Denied":       ErrPublishFailed,
+	"NetStream.Publish.Failed":       ErrPublishFailed,
+}
+
+// mapError StatusError를 code에 대응하는 에러로 감쌉니다. errors.Is, errors.As 모두 사용할 수 있습니다.
+func mapError(err error) error {
+	var status *StatusError
+	if errors.As(err, &status) {
+		if target, ok := statusErrors[status.Code]; ok {
+			return fmt.Errorf("%w: %w", target, err)
+		}
+	}
+	return err
+}
+
+// Client RTMP 서버에 접속해 스트림을 보내거나 받는 클라이언트입니다.
+//
+//	c, err := rtmp.Dial(ctx, "rtmp://localhost/live/stream")
+//	err = c.Connect(ctx, "", "")
+//	err = c.CreateStream(ctx)
+//	err = c.Publish(ctx, "")        // 또는 c.Play(ctx, "")
+//	err = c.WritePacket(packet)     // 또는 packet, err := c.ReadPacket(ctx)
+//	c.Close()
+type Client struct {
+	c *internal.RTMPClient
+}
+
+// Dial rtmp://host[:port]/app/stream 주소의 서버에 접속해 핸드셰이크를 마칩니다.
+// 경로의 첫 부분이 app, 나머지(쿼리 포함)가 스트림 이름입니다.
+func Dial(ctx context.Context, rawURL string) (*Client, error) {
+	c, err := internal.DialRTMP(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{c: c}, nil
+}
+
+// App 접속할 app 이름입니다.
+func (c *Client) App() string {
+	return c.c.App
+}
+
+// Stream 주소에서 얻은 스트림 이름입니다. Publish, Play에 이름을 주지 않으면 이 이름을 사용합니다.
+func (c *Client) Stream() string {
+	return c.c.Stream
+}
+
+// TcURL connect 명령에 보내는 tcUrl 입니다.
+func (c *Client) TcURL() string {
+	return c.c.TcURL
+}
+
+// Connect connect 명령으로 app에 접속합니다. app, tcURL이 비어 있으면 주소에서 얻은 값을 사용합니다.
+func (c *Client) Connect(ctx context.Context, app, tcURL string) error {
+	if app != "" {
+		c.c.App = app
+	}
+	if tcURL != "" {
+		c.c.TcURL = tcURL
+	}
+	return mapError(c.c.Connect(ctx))
+}
+
+// CreateStream 메시지 스트림을 만듭니다. Publish, Play 전에 호출해야 합니다.
+func (c *Client) CreateStream(ctx context.Context) error {
+	return mapError(c.c.CreateStream(ctx))
+}
+
+// Publish 스트림 퍼블리시를 시작하고 NetStream.Publish.Start를 기다립니다. stream이 비어 있으면 주소의 스트림 이름을 사용합니다.
+func (c *Client) Publish(ctx context.Context, stream string) error {
+	if stream == "" {
+		stream = c.c.Stream
+	}
+	return mapError(c.c.Publish(ctx, stream))
+}
+
+// Play 스트림 재생을 시작하고 NetStream.Play.Start를 기다립니다. stream이 비어 있으면 주소의 스트림 이름을 사용합니다.
+func (c *Client) Play(ctx context.Context, stream string) error {
+	if stream == "" {
+		stream = c.c.Stream
+	}
+	return mapError(c.c.Play(ctx, stream))
+}
+
+// WritePacket 퍼블리시 중인 스트림에 패킷을 보냅니다. 메타데이터 패킷은 @setDataFrame을 붙여 보냅니다.
+func (c *Client) WritePacket(p *Packet) error {
+	return c.c.WritePacket(p)
+}
+
+// ReadPacket 재생 중인 스트림의 다음 패킷을 읽습니다. 메타데이터 패킷의 Data는 onMetaData부터 시작합니다.
+func (c *Client) ReadPacket(ctx context.Context) (*Packet, error) {
+	return c.c.ReadPacket(ctx)
+}
+
+// Done 연결이 끊어지면 닫히는 채널입니다.
+func (c *Client) Done() <-chan struct{} {
+	return c.c.Done()
+}
+
+// Close 연결을 닫습니다.
+func (c *Client) Close() error {
+	return c.c.Close()
+}
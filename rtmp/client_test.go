@@ -0,0 +1,69 @@
+package rtmp
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestClientPublishPlay(t *testing.T) {
+	addr := startTestServer(t, &Server{Apps: map[string]*AppConfig{"live": nil}})
+
+	publisher := publishTestStream(t, "rtmp://"+addr+"/live/cam?key=1")
+	if publisher.App() != "live" || publisher.Stream() != "cam?key=1" || publisher.TcURL() != "rtmp://"+addr+"/live" {
+		t.Errorf("app %q, stream %q, tcUrl %q", publisher.App(), publisher.Stream(), publisher.TcURL())
+	}
+
+	player, err := dialTestClient(t, "rtmp://"+addr+"/live/cam")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	if err := player.Play(ctx, ""); err != nil {
+		t.Fatalf("play: %s", err)
+	}
+	expectTestKeyFrame(t, player)
+
+	player.Close()
+	select {
+	case <-player.Done():
+	case <-ctx.Done():
+		t.Fatal("Done was not closed")
+	}
+	if _, err := player.ReadPacket(ctx); !errors.Is(err, ErrClosed) {
+		t.Errorf("ReadPacket after Close: %v", err)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	addr := startTestServer(t, &Server{Apps: map[string]*AppConfig{"live": nil}})
+	publishTestStream(t, "rtmp://"+addr+"/live/cam")
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	// 같은 이름으로 퍼블리시 중이면 NetStream.Publish.BadName 입니다.
+	c, err := dialTestClient(t, "rtmp://"+addr+"/live/cam")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.Publish(ctx, "")
+	var status *StatusError
+	if !errors.Is(err, ErrBadName) || !errors.As(err, &status) || status.Code != "NetStream.Publish.BadName" {
+		t.Errorf("duplicate publish: %v", err)
+	}
+
+	if err := c.Play(ctx, "missing"); !errors.Is(err, ErrStreamNotFound) {
+		t.Errorf("play of a missing stream: %v", err)
+	}
+
+	// Connect에 준 app이 주소의 app 대신 사용됩니다.
+	c, err = Dial(ctx, "rtmp://"+addr+"/live/cam")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.Connect(ctx, "unknown", ""); !errors.Is(err, ErrConnectRejected) {
+		t.Errorf("connect to an unknown app: %v", err)
+	}
+}
@@ -0,0 +1,16 @@
+// Package rtmp RTMP 스트림을 보내고(Publish) 받는(Play) 클라이언트와 서버를 제공합니다.
+//
+// 청크 코덱, AMF 인코더, 핸드셰이크는 서버(internal 패키지)와 같은 구현을 사용합니다.
+package rtmp
+
+import "example/hello/internal"
+
+// Packet 하나의 미디어 메시지(오디오, 비디오, 메타데이터) 입니다. Data는 FLV 태그 바디와 같은 형식입니다.
+type Packet = internal.Packet
+
+// RTMP 메시지 타입 ID 입니다.
+const (
+	MessageTypeAudio = internal.MessageTypeAudio
+	MessageTypeVideo = internal.MessageTypeVideo
+	MessageTypeData  = internal.MessageTypeData
+)
@@ -0,0 +1,199 @@
+package rtmp
+
+import (
+	"context"
+	"example/hello/internal"
+	"net"
+	"sync"
+)
+
+// DefaultAddr Addr가 비어 있을 때 ListenAndServe가 사용하는 주소입니다.
+const DefaultAddr = ":1935"
+
+// ErrServerClosed Shutdown 이후 Serve, ListenAndServe가 반환하는 에러입니다.
+var ErrServerClosed = internal.ErrServerClosed
+
+// AppConfig RTMP app(rtmp://host/{app}/{stream})별 설정입니다. (CMAF, 녹화, VOD, DVR, 푸시, 엣지 등)
+type AppConfig = internal.AppConfig
+
+// Handler 연결 이벤트를 처리합니다. net/http의 Handler처럼 Server에 설정해 사용합니다.
+// OnConnect, OnPublish, OnPlay가 에러를 반환하면 요청을 거절하고, 클라이언트에게 에러 상태를 보냅니다.
+// 에러를 반환하지 않으면 서버의 기본 동작(허브 등록, 시청자에게 전달, 녹화 등)이 그대로 이어집니다.
+// 일부 메서드만 필요하면 DefaultHandler를 임베드합니다.
+type Handler interface {
+	OnConnect(c *Conn) error
+	OnPublish(c *Conn, stream string) error
+	OnPlay(c *Conn, stream string) error
+	// OnPacket 퍼블리셔가 보낸 패킷마다 호출됩니다. 연결의 읽기 고루틴에서 호출되므로 오래 막으면 안 되고, p를 수정하면 안 됩니다.
+	OnPacket(c *Conn, p *Packet)
+	OnClose(c *Conn)
+}
+
+// DefaultHandler 모든 요청을 허용하는 기본 Handler 입니다.
+type DefaultHandler struct{}
+
+func (DefaultHandler) OnConnect(c *Conn) error                { return nil }
+func (DefaultHandler) OnPublish(c *Conn, stream string) error { return nil }
+func (DefaultHandler) OnPlay(c *Conn, stream string) error    { return nil }
+func (DefaultHandler) OnPacket(c *Conn, p *Packet)            {}
+func (DefaultHandler) OnClose(c *Conn)                        {}
+
+// Conn 서버에 접속한 하나의 RTMP 연결입니다. 같은 연결에 대해서는 항상 같은 *Conn이 전달됩니다.
+type Conn struct {
+	c *internal.Connection
+}
+
+// App connect 명령으로 접속한 app 이름입니다.
+func (c *Conn) App() string {
+	return c.c.AppName
+}
+
+// Stream 퍼블리시 중인 스트림 이름입니다. (퍼블리셔가 아니면 빈 문자열)
+func (c *Conn) Stream() string {
+	return c.c.StreamKey
+}
+
+// RemoteAddr 클라이언트 주소입니다.
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.c.Conn.RemoteAddr()
+}
+
+// Close 연결을 끊습니다.
+func (c *Conn) Close() error {
+	return c.c.Conn.Close()
+}
+
+// 서버 설정 타입입니다. 실행 파일의 설정 파일에서 같은 이름의 항목과 같습니다.
+type (
+	TimeoutConfig       = internal.TimeoutConfig
+	LimitConfig         = internal.LimitConfig
+	AccessConfig        = internal.AccessConfig
+	ProxyProtocolConfig = internal.ProxyProtocolConfig
+)
+
+// Server 다른 Go 서비스에 넣어 사용할 수 있는 RTMP 서버입니다.
+//
+//	srv := &rtmp.Server{Addr: ":1935", Handler: myHandler}
+//	go srv.ListenAndServe()
+//	...
+//	srv.Shutdown(ctx)
+//
+// 설정 필드는 Serve, ListenAndServe를 처음 호출하기 전에 정해야 합니다.
+type Server struct {
+	Addr    string                // 비어 있으면 DefaultAddr
+	Handler Handler               // nil이면 DefaultHandler
+	Apps    map[string]*AppConfig // app별 설정 (nil이면 모든 app을 기본 설정으로 받음. 없는 app은 "*" app이 있을 때만 받음. nil 값은 기본 설정)
+
+	// Timeouts 핸드셰이크, 유휴, 퍼블리시 유휴, 쓰기 시간 제한입니다.
+	// nil이면 실행 파일과 같은 기본값을 사용합니다. 값이 0인 항목은 제한이 없습니다.
+	Timeouts *TimeoutConfig
+	// Limits 연결 수, IP별 연결 수, 퍼블리셔, 시청자 수, 전송량 제한입니다. (0은 제한 없음)
+	Limits LimitConfig
+	// Access 클라이언트 주소로 connect, publish, play를 허용하거나 거절하는 규칙입니다.
+	Access AccessConfig
+	// ProxyProtocol 로드 밸런서 뒤에서 실행할 때 PROXY protocol 헤더로 원래 클라이언트 주소를 받습니다.
+	ProxyProtocol ProxyProtocolConfig
+
+	once  sync.Once
+	err   error // 설정이 잘못되었을 때 Serve, ListenAndServe가 반환하는 에러
+	srv   *internal.Server
+	conns sync.Map // *internal.Connection → *Conn
+}
+
+func (s *Server) init() error {
+	s.once.Do(func() {
+		cfg := internal.DefaultConfig()
+		apps := s.Apps
+		if apps == nil {
+			apps = map[string]*AppConfig{internal.WildcardAppName: nil}
+		}
+		// 호출한 쪽의 맵과 설정은 바꾸지 않도록 복사해서 이름을 채웁니다. nil인 설정은 기본 설정입니다.
+		cfg.Apps = make(map[string]*AppConfig, len(apps))
+		for name, app := range apps {
+			var conf AppConfig
+			if app != nil {
+				conf = *app
+			}
+			conf.Name = name
+			cfg.Apps[name] = &conf
+		}
+		if s.Timeouts != nil {
+			cfg.Timeouts = *s.Timeouts
+		}
+		cfg.Limits, cfg.Access, cfg.ProxyProtocol = s.Limits, s.Access, s.ProxyProtocol
+		if s.err = cfg.Validate(); s.err != nil {
+			return
+		}
+		ctx := internal.NewStreamContext(cfg)
+		if s.Handler != nil {
+			ctx.Hooks = hooks{s}
+		}
+		s.srv = internal.NewServer(ctx)
+	})
+	return s.err
+}
+
+// ListenAndServe Addr에서 TCP 연결을 받아 처리합니다.
+func (s *Server) ListenAndServe() error {
+	if err := s.init(); err != nil {
+		return err
+	}
+	addr := s.Addr
+	if addr == "" {
+		addr = DefaultAddr
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve 리스너에서 연결을 받아 처리합니다. 여러 리스너에서 동시에 호출할 수 있습니다.
+func (s *Server) Serve(l net.Listener) error {
+	if err := s.init(); err != nil {
+		l.Close()
+		return err
+	}
+	return s.srv.Serve(l)
+}
+
+// Shutdown 새 연결을 받지 않고, 퍼블리셔와 시청자에게 종료를 알린 뒤 모든 연결을 닫습니다.
+// 녹화 파일과 플레이리스트가 마무리될 때까지 기다리며, ctx가 먼저 끝나면 남은 연결을 강제로 끊고 ctx의 에러를 반환합니다.
+func (s *Server) Shutdown(ctx context.Context) error {
+	if err := s.init(); err != nil {
+		return err
+	}
+	return s.srv.Shutdown(ctx)
+}
+
+func (s *Server) conn(c *internal.Connection) *Conn {
+	v, _ := s.conns.LoadOrStore(c, &Conn{c: c})
+	return v.(*Conn)
+}
+
+// hooks Handler를 internal.Hooks로 연결합니다.
+type hooks struct {
+	s *Server
+}
+
+func (h hooks) OnConnect(c *internal.Connection) error {
+	return h.s.Handler.OnConnect(h.s.conn(c))
+}
+
+func (h hooks) OnPublish(c *internal.Connection, stream string) error {
+	return h.s.Handler.OnPublish(h.s.conn(c), stream)
+}
+
+func (h hooks) OnPlay(c *internal.Connection, stream string) error {
+	return h.s.Handler.OnPlay(h.s.conn(c), stream)
+}
+
+func (h hooks) OnPacket(c *internal.Connection, p *Packet) {
+	h.s.Handler.OnPacket(h.s.conn(c), p)
+}
+
+func (h hooks) OnClose(c *internal.Connection) {
+	h.s.Handler.OnClose(h.s.conn(c))
+	h.s.conns.Delete(c)
+}
@@ -0,0 +1,196 @@
+package rtmp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testTimeout = 5 * time.Second
+
+var testKeyFrame = &Packet{Type: MessageTypeVideo, Data: []byte{0x12, 0xAB, 0xCD}}
+
+// startTestServer 127.0.0.1의 임의 포트에서 s를 시작하고, 테스트가 끝나면 종료합니다.
+func startTestServer(t *testing.T, s *Server) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(l)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+		defer cancel()
+		s.Shutdown(ctx)
+	})
+	return l.Addr().String()
+}
+
+// dialTestClient rawURL의 서버에 접속해 connect, createStream까지 마칩니다.
+func dialTestClient(t *testing.T, rawURL string) (*Client, error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	c, err := Dial(ctx, rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	if err := c.Connect(ctx, "", ""); err != nil {
+		return c, err
+	}
+	return c, c.CreateStream(ctx)
+}
+
+// publishTestStream rawURL로 퍼블리시를 시작하고, 테스트가 끝날 때까지 주기적으로 키프레임을 보냅니다.
+func publishTestStream(t *testing.T, rawURL string) *Client {
+	t.Helper()
+	c, err := dialTestClient(t, rawURL)
+	if err != nil {
+		t.Fatalf("connect %s: %s", rawURL, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	if err := c.Publish(ctx, ""); err != nil {
+		t.Fatalf("publish %s: %s", rawURL, err)
+	}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(20 * time.Millisecond)
+		defer ticker.Stop()
+		for timestamp := uint32(0); ; timestamp += 20 {
+			if c.WritePacket(&Packet{Type: testKeyFrame.Type, Timestamp: timestamp, Data: testKeyFrame.Data}) != nil {
+				return
+			}
+			select {
+			case <-ticker.C:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	t.Cleanup(func() {
+		close(stop)
+		<-done
+	})
+	return c
+}
+
+// expectTestKeyFrame 재생 중인 클라이언트가 publishTestStream이 보낸 키프레임을 받는지 확인합니다.
+func expectTestKeyFrame(t *testing.T, c *Client) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	for {
+		p, err := c.ReadPacket(ctx)
+		if err != nil {
+			t.Fatalf("no media received: %s", err)
+		}
+		if p.Type == MessageTypeVideo && bytes.Equal(p.Data, testKeyFrame.Data) {
+			return
+		}
+	}
+}
+
+// testHandler app "denied"의 connect, 스트림 "nopub"의 퍼블리시, "noplay"의 재생을 거절합니다.
+type testHandler struct {
+	DefaultHandler
+	packets atomic.Int32
+}
+
+func (h *testHandler) OnConnect(c *Conn) error {
+	if c.App() == "denied" {
+		return errors.New("app denied by handler")
+	}
+	return nil
+}
+
+func (h *testHandler) OnPublish(c *Conn, stream string) error {
+	if stream == "nopub" {
+		return errors.New("publish denied by handler")
+	}
+	return nil
+}
+
+func (h *testHandler) OnPlay(c *Conn, stream string) error {
+	if stream == "noplay" {
+		return errors.New("play denied by handler")
+	}
+	return nil
+}
+
+func (h *testHandler) OnPacket(c *Conn, p *Packet) {
+	h.packets.Add(1)
+}
+
+func TestServer(t *testing.T) {
+	h := &testHandler{}
+	apps := map[string]*AppConfig{"live": nil, "*": {}}
+	addr := startTestServer(t, &Server{Handler: h, Apps: apps})
+
+	publishTestStream(t, "rtmp://"+addr+"/live/cam")
+	player, err := dialTestClient(t, "rtmp://"+addr+"/live/cam")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	if err := player.Play(ctx, ""); err != nil {
+		t.Fatalf("play: %s", err)
+	}
+	expectTestKeyFrame(t, player)
+	if h.packets.Load() == 0 {
+		t.Error("OnPacket was not called")
+	}
+
+	// 호출한 쪽의 Apps는 바뀌지 않습니다.
+	if apps["live"] != nil || apps["*"].Name != "" {
+		t.Errorf("Apps modified: live %v, * name %q", apps["live"], apps["*"].Name)
+	}
+
+	// Handler가 거절하면 클라이언트는 code에 맞는 에러와 Handler의 에러 메시지를 받습니다.
+	expectRejected := func(err, target error, description string) {
+		t.Helper()
+		var status *StatusError
+		if !errors.Is(err, target) || !errors.As(err, &status) || !strings.Contains(status.Description, description) {
+			t.Errorf("got %v, want %v with %q", err, target, description)
+		}
+	}
+	_, err = dialTestClient(t, "rtmp://"+addr+"/denied/cam")
+	expectRejected(err, ErrConnectRejected, "app denied by handler")
+
+	c, err := dialTestClient(t, "rtmp://"+addr+"/live/nopub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectRejected(c.Publish(ctx, ""), ErrPublishFailed, "publish denied by handler")
+
+	c, err = dialTestClient(t, "rtmp://"+addr+"/live/cam")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectRejected(c.Play(ctx, "noplay"), ErrPlayFailed, "play denied by handler")
+}
+
+func TestServerNilApps(t *testing.T) {
+	// Apps가 nil이면 모든 app을 받습니다.
+	addr := startTestServer(t, &Server{})
+	publishTestStream(t, "rtmp://"+addr+"/anything/cam")
+	player, err := dialTestClient(t, "rtmp://"+addr+"/anything/cam")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	if err := player.Play(ctx, ""); err != nil {
+		t.Fatalf("play: %s", err)
+	}
+	expectTestKeyFrame(t, player)
+}